@@ -1,6 +1,7 @@
 package netmap
 
 import (
+	"math"
 	"sort"
 )
 
@@ -13,11 +14,22 @@ type (
 		Clear()
 	}
 
+	// MergeableAggregator is an Aggregator which can merge partial
+	// aggregate computed by another aggregator of the same type.
+	MergeableAggregator interface {
+		Aggregator
+		Merge(Aggregator)
+	}
+
 	// Normalizer normalizes weight.
 	Normalizer interface {
 		Normalize(w float64) float64
 	}
 
+	sumAgg struct {
+		sum float64
+	}
+
 	meanSumAgg struct {
 		sum   float64
 		count int
@@ -36,11 +48,32 @@ type (
 		max float64
 	}
 
+	minPriceAgg struct {
+		min float64
+		set bool
+	}
+
 	meanIQRAgg struct {
 		k   float64
 		arr []float64
 	}
 
+	varianceAgg struct {
+		mean  float64
+		m2    float64
+		count int
+	}
+
+	stdDevAgg struct {
+		varianceAgg
+	}
+
+	reputationAgg struct {
+		sum   float64
+		count int
+		zero  bool
+	}
+
 	reverseMinNorm struct {
 		min float64
 	}
@@ -53,6 +86,11 @@ type (
 		scale float64
 	}
 
+	logisticNorm struct {
+		mid float64
+		k   float64
+	}
+
 	constNorm struct {
 		value float64
 	}
@@ -62,18 +100,41 @@ type (
 )
 
 var (
+	_ Aggregator = (*sumAgg)(nil)
 	_ Aggregator = (*meanSumAgg)(nil)
 	_ Aggregator = (*meanAgg)(nil)
 	_ Aggregator = (*minAgg)(nil)
 	_ Aggregator = (*maxAgg)(nil)
+	_ Aggregator = (*minPriceAgg)(nil)
 	_ Aggregator = (*meanIQRAgg)(nil)
+	_ Aggregator = (*varianceAgg)(nil)
+	_ Aggregator = (*stdDevAgg)(nil)
+	_ Aggregator = (*reputationAgg)(nil)
+
+	_ MergeableAggregator = (*sumAgg)(nil)
+	_ MergeableAggregator = (*meanSumAgg)(nil)
+	_ MergeableAggregator = (*meanAgg)(nil)
+	_ MergeableAggregator = (*minAgg)(nil)
+	_ MergeableAggregator = (*maxAgg)(nil)
+	_ MergeableAggregator = (*minPriceAgg)(nil)
+	_ MergeableAggregator = (*meanIQRAgg)(nil)
+	_ MergeableAggregator = (*varianceAgg)(nil)
+	_ MergeableAggregator = (*stdDevAgg)(nil)
+	_ MergeableAggregator = (*reputationAgg)(nil)
 
 	_ Normalizer = (*reverseMinNorm)(nil)
 	_ Normalizer = (*maxNorm)(nil)
 	_ Normalizer = (*sigmoidNorm)(nil)
+	_ Normalizer = (*logisticNorm)(nil)
 	_ Normalizer = (*constNorm)(nil)
 )
 
+// NewSumAgg returns an aggregator which
+// computes sum of values.
+func NewSumAgg() Aggregator {
+	return new(sumAgg)
+}
+
 // NewMeanSumAgg returns an aggregator which
 // computes mean value by keeping total sum.
 func NewMeanSumAgg() Aggregator {
@@ -99,12 +160,38 @@ func NewMaxAgg() Aggregator {
 	return new(maxAgg)
 }
 
+// NewMinPriceAgg returns an aggregator which
+// computes min positive value. Zero prices are
+// ignored as they mean that price is not set.
+func NewMinPriceAgg() Aggregator {
+	return new(minPriceAgg)
+}
+
 // NewMeanIQRAgg returns an aggregator which
 // computes mean value of values from IQR interval.
 func NewMeanIQRAgg() Aggregator {
 	return new(meanIQRAgg)
 }
 
+// NewVarianceAgg returns an aggregator which
+// computes population variance using Welford's algorithm.
+func NewVarianceAgg() Aggregator {
+	return new(varianceAgg)
+}
+
+// NewStdDevAgg returns an aggregator which
+// computes population standard deviation using Welford's algorithm.
+func NewStdDevAgg() Aggregator {
+	return new(stdDevAgg)
+}
+
+// NewReputationAgg returns an aggregator which
+// computes harmonic mean of reputations, so that
+// nodes with poor reputation have bigger impact.
+func NewReputationAgg() Aggregator {
+	return new(reputationAgg)
+}
+
 // NewReverseMinNorm returns a normalizer which
 // normalize values in range of 0.0 to 1.0 to a minimum value.
 func NewReverseMinNorm(min float64) Normalizer {
@@ -123,12 +210,35 @@ func NewSigmoidNorm(scale float64) Normalizer {
 	return &sigmoidNorm{scale: scale}
 }
 
+// NewLogisticNorm returns a normalizer which
+// normalize values in range of 0.0 to 1.0 to a logistic curve
+// with midpoint mid and steepness k.
+func NewLogisticNorm(mid, k float64) Normalizer {
+	return &logisticNorm{mid: mid, k: k}
+}
+
 // NewConstNorm returns a normalizer which
 // returns a constant values
 func NewConstNorm(value float64) Normalizer {
 	return &constNorm{value: value}
 }
 
+func (a *sumAgg) Add(n float64) {
+	a.sum += n
+}
+
+func (a *sumAgg) Compute() float64 {
+	return a.sum
+}
+
+func (a *sumAgg) Clear() {
+	a.sum = 0
+}
+
+func (a *sumAgg) Merge(x Aggregator) {
+	a.sum += x.(*sumAgg).sum
+}
+
 func (a *meanSumAgg) Add(n float64) {
 	a.sum += n
 	a.count++
@@ -146,6 +256,12 @@ func (a *meanSumAgg) Clear() {
 	a.count = 0
 }
 
+func (a *meanSumAgg) Merge(x Aggregator) {
+	o := x.(*meanSumAgg)
+	a.sum += o.sum
+	a.count += o.count
+}
+
 func (a *meanAgg) Add(n float64) {
 	c := a.count + 1
 	a.mean = a.mean*(float64(a.count)/float64(c)) + n/float64(c)
@@ -161,6 +277,16 @@ func (a *meanAgg) Clear() {
 	a.mean = 0
 }
 
+func (a *meanAgg) Merge(x Aggregator) {
+	o := x.(*meanAgg)
+	c := a.count + o.count
+	if c == 0 {
+		return
+	}
+	a.mean = a.mean*(float64(a.count)/float64(c)) + o.mean*(float64(o.count)/float64(c))
+	a.count = c
+}
+
 func (a *minAgg) Add(n float64) {
 	if a.min == 0 || n < a.min {
 		a.min = n
@@ -175,6 +301,12 @@ func (a *minAgg) Clear() {
 	a.min = 0
 }
 
+func (a *minAgg) Merge(x Aggregator) {
+	if o := x.(*minAgg); o.min != 0 && (a.min == 0 || o.min < a.min) {
+		a.min = o.min
+	}
+}
+
 func (a *maxAgg) Add(n float64) {
 	if n > a.max {
 		a.max = n
@@ -189,6 +321,32 @@ func (a *maxAgg) Clear() {
 	a.max = 0
 }
 
+func (a *maxAgg) Merge(x Aggregator) {
+	a.Add(x.(*maxAgg).max)
+}
+
+func (a *minPriceAgg) Add(n float64) {
+	if n > 0 && (!a.set || n < a.min) {
+		a.min = n
+		a.set = true
+	}
+}
+
+func (a *minPriceAgg) Compute() float64 {
+	return a.min
+}
+
+func (a *minPriceAgg) Clear() {
+	a.min = 0
+	a.set = false
+}
+
+func (a *minPriceAgg) Merge(x Aggregator) {
+	if o := x.(*minPriceAgg); o.set {
+		a.Add(o.min)
+	}
+}
+
 func (a *meanIQRAgg) Add(n float64) {
 	a.arr = append(a.arr, n)
 }
@@ -225,6 +383,83 @@ func (a *meanIQRAgg) Clear() {
 	a.arr = a.arr[:0]
 }
 
+func (a *meanIQRAgg) Merge(x Aggregator) {
+	a.arr = append(a.arr, x.(*meanIQRAgg).arr...)
+}
+
+func (a *varianceAgg) Add(n float64) {
+	a.count++
+	d := n - a.mean
+	a.mean += d / float64(a.count)
+	a.m2 += d * (n - a.mean)
+}
+
+func (a *varianceAgg) Compute() float64 {
+	if a.count == 0 {
+		return 0
+	}
+	return a.m2 / float64(a.count)
+}
+
+func (a *varianceAgg) Clear() {
+	a.mean = 0
+	a.m2 = 0
+	a.count = 0
+}
+
+// Merge combines partial results using Chan's parallel algorithm.
+func (a *varianceAgg) Merge(x Aggregator) {
+	a.merge(x.(*varianceAgg))
+}
+
+func (a *varianceAgg) merge(o *varianceAgg) {
+	c := a.count + o.count
+	if c == 0 {
+		return
+	}
+	d := o.mean - a.mean
+	a.mean += d * float64(o.count) / float64(c)
+	a.m2 += o.m2 + d*d*float64(a.count)*float64(o.count)/float64(c)
+	a.count = c
+}
+
+func (a *stdDevAgg) Compute() float64 {
+	return math.Sqrt(a.varianceAgg.Compute())
+}
+
+func (a *stdDevAgg) Merge(x Aggregator) {
+	a.merge(&x.(*stdDevAgg).varianceAgg)
+}
+
+func (a *reputationAgg) Add(n float64) {
+	if n <= 0 {
+		a.zero = true
+	} else {
+		a.sum += 1 / n
+	}
+	a.count++
+}
+
+func (a *reputationAgg) Compute() float64 {
+	if a.count == 0 || a.zero {
+		return 0
+	}
+	return float64(a.count) / a.sum
+}
+
+func (a *reputationAgg) Clear() {
+	a.sum = 0
+	a.count = 0
+	a.zero = false
+}
+
+func (a *reputationAgg) Merge(x Aggregator) {
+	o := x.(*reputationAgg)
+	a.sum += o.sum
+	a.count += o.count
+	a.zero = a.zero || o.zero
+}
+
 func (r *reverseMinNorm) Normalize(w float64) float64 {
 	if w == 0 {
 		return 0
@@ -247,6 +482,10 @@ func (r *sigmoidNorm) Normalize(w float64) float64 {
 	return x / (1 + x)
 }
 
+func (r *logisticNorm) Normalize(w float64) float64 {
+	return 1 / (1 + math.Exp(-r.k*(w-r.mid)))
+}
+
 func (r *constNorm) Normalize(_ float64) float64 {
 	return r.value
 }
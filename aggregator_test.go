@@ -14,8 +14,8 @@ const (
 )
 
 func initTestBucket(t *testing.T, b *Bucket) {
-	require.Nil(t, b.AddBucket("/opt:first", Nodes{{0, 1, 2}, {2, 3, 2}}))
-	require.Nil(t, b.AddBucket("/opt:second/sub:1", Nodes{{1, 2, 3}, {10, 6, 1}}))
+	require.Nil(t, b.AddBucket("/opt:first", Nodes{{N: 0, C: 1, P: 2}, {N: 2, C: 3, P: 2}}))
+	require.Nil(t, b.AddBucket("/opt:second/sub:1", Nodes{{N: 1, C: 2, P: 3}, {N: 10, C: 6, P: 1}}))
 
 	b.fillNodes()
 }
@@ -37,16 +37,32 @@ func TestNewWeightFunc(t *testing.T) {
 	copy(nodes, b.nodes)
 
 	expected := Nodes{
-		{10, 6, 1},
-		{2, 3, 2},
-		{1, 2, 3},
-		{0, 1, 2},
+		{N: 10, C: 6, P: 1},
+		{N: 2, C: 3, P: 2},
+		{N: 1, C: 2, P: 3},
+		{N: 0, C: 1, P: 2},
 	}
 
 	sort.Slice(nodes, func(i, j int) bool { return wf(nodes[i]) > wf(nodes[j]) })
 	require.Equal(t, expected, nodes)
 }
 
+func TestDefaultWeightFunc(t *testing.T) {
+	var b Bucket
+
+	initTestBucket(t, &b)
+
+	wf := DefaultWeightFunc(b.nodes)
+	expected := NewWeightFunc(NewSigmoidNorm(3), NewReverseMinNorm(1))
+	for _, n := range b.nodes {
+		require.InEpsilon(t, expected(n), wf(n), eps)
+	}
+
+	wf = DefaultWeightFunc(Nodes{{C: 2}, {C: 2, P: 4}})
+	require.Equal(t, 0.0, wf(Node{C: 2}))
+	require.InEpsilon(t, 0.5, wf(Node{C: 2, P: 4}), eps)
+}
+
 func TestAggregator_Compute(t *testing.T) {
 	var (
 		b Bucket
@@ -59,6 +75,12 @@ func TestAggregator_Compute(t *testing.T) {
 	b.Traverse(a, CapWeightFunc)
 	require.InEpsilon(t, 3.0, a.Compute(), eps)
 
+	a = NewSumAgg()
+	for _, c := range []float64{1, 2, 3} {
+		a.Add(c)
+	}
+	require.InEpsilon(t, 6.0, a.Compute(), eps)
+
 	a = NewMeanSumAgg()
 	b.Traverse(a, CapWeightFunc)
 	require.InEpsilon(t, 3.0, a.Compute(), eps)
@@ -71,6 +93,16 @@ func TestAggregator_Compute(t *testing.T) {
 	b.Traverse(a, PriceWeightFunc)
 	require.InEpsilon(t, 3.0, a.Compute(), eps)
 
+	a = NewMinPriceAgg()
+	b.Traverse(a, PriceWeightFunc)
+	require.InEpsilon(t, 1.0, a.Compute(), eps)
+
+	a.Clear()
+	for _, p := range []float64{0, 7, 0, 4, 9} {
+		a.Add(p)
+	}
+	require.InEpsilon(t, 4.0, a.Compute(), eps)
+
 	a = NewMeanIQRAgg()
 	b.Traverse(a, PriceWeightFunc)
 	require.InEpsilon(t, 2.0, a.Compute(), eps)
@@ -99,6 +131,63 @@ func TestAggregator_Compute(t *testing.T) {
 	require.InEpsilon(t, 51.0, mp.Compute(), eps)
 }
 
+func TestVarianceAgg(t *testing.T) {
+	var b Bucket
+
+	initTestBucket(t, &b)
+
+	a := NewVarianceAgg()
+	require.Equal(t, 0.0, a.Compute())
+
+	b.Traverse(a, CapWeightFunc)
+	require.InEpsilon(t, 3.5, a.Compute(), eps)
+
+	s := NewStdDevAgg()
+	b.Traverse(s, CapWeightFunc)
+	require.InEpsilon(t, math.Sqrt(3.5), s.Compute(), eps)
+
+	a.Clear()
+	for _, v := range []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16} {
+		a.Add(v)
+	}
+	require.InEpsilon(t, 22.5, a.Compute(), eps)
+
+	a.Clear()
+	a.Add(5)
+	require.Equal(t, 0.0, a.Compute())
+}
+
+func TestReputationAgg(t *testing.T) {
+	a := NewReputationAgg()
+	require.Equal(t, 0.0, a.Compute())
+
+	nodes := Nodes{{R: 1}, {R: 0.5}, {R: 0.25}}
+	for i := range nodes {
+		a.Add(ReputationWeightFunc(nodes[i]))
+	}
+	require.InEpsilon(t, 3.0/7.0, a.Compute(), eps)
+
+	a.Add(0)
+	require.Equal(t, 0.0, a.Compute())
+
+	a.Clear()
+	a.Add(0.8)
+	require.InEpsilon(t, 0.8, a.Compute(), eps)
+}
+
+func TestNewReputationWeightFunc(t *testing.T) {
+	wf := NewReputationWeightFunc(NewConstNorm(1), NewConstNorm(0.5))
+
+	require.InEpsilon(t, 0.5, wf(Node{R: 1}), eps)
+	require.InEpsilon(t, 0.5, wf(Node{R: 2}), eps)
+	require.InEpsilon(t, 0.1, wf(Node{R: 0.2}), eps)
+	require.Equal(t, 0.0, wf(Node{R: -1}))
+
+	var b Bucket
+	require.NoError(t, b.AddBucket("/opt:first", Nodes{{N: 1, C: 1, P: 1, R: 0.1}, {N: 2, C: 1, P: 1, R: 0.9}}))
+	require.Equal(t, []uint32{2, 1}, b.TopNodes(2, wf))
+}
+
 func TestSigmoidNorm_Normalize(t *testing.T) {
 	t.Run("sigmoid norm must equal to 1/2 at `scale`", func(t *testing.T) {
 		norm := NewSigmoidNorm(1)
@@ -126,6 +215,21 @@ func TestSigmoidNorm_Normalize(t *testing.T) {
 	})
 }
 
+func TestLogisticNorm_Normalize(t *testing.T) {
+	norm := NewLogisticNorm(10, 1)
+	require.InEpsilon(t, 0.5, norm.Normalize(10), eps)
+	require.InEpsilon(t, 0.731, norm.Normalize(11), eps)
+	require.InEpsilon(t, 0.269, norm.Normalize(9), eps)
+	require.InEpsilon(t, 1.0, norm.Normalize(100), eps)
+
+	steep := NewLogisticNorm(10, 5)
+	require.InEpsilon(t, 0.5, steep.Normalize(10), eps)
+	require.True(t, steep.Normalize(11) > norm.Normalize(11))
+	require.True(t, steep.Normalize(9) < norm.Normalize(9))
+
+	require.InEpsilon(t, 0.5, NewLogisticNorm(10, 0).Normalize(1000), eps)
+}
+
 func TestReverseMinNorm_Normalize(t *testing.T) {
 	t.Run("reverseMin norm should not panic", func(t *testing.T) {
 		norm := NewReverseMinNorm(0)
@@ -164,11 +268,11 @@ func TestBucket_TraverseTree(t *testing.T) {
 
 	b := &Bucket{
 		children: []Bucket{
-			{nodes: Nodes{{0, 1, 2}, {2, 3, 2}}},
+			{nodes: Nodes{{N: 0, C: 1, P: 2}, {N: 2, C: 3, P: 2}}},
 			{
 				children: []Bucket{
-					{nodes: Nodes{{1, 2, 3}, {10, 6, 1}}},
-					{nodes: Nodes{{12, 3, 4}, {2, 3, 4}}},
+					{nodes: Nodes{{N: 1, C: 2, P: 3}, {N: 10, C: 6, P: 1}}},
+					{nodes: Nodes{{N: 12, C: 3, P: 4}, {N: 2, C: 3, P: 4}}},
 				},
 			},
 		},
@@ -187,3 +291,89 @@ func TestBucket_TraverseTree(t *testing.T) {
 	require.InEpsilon(t, 1, b.children[1].children[0].weight, eps)
 	require.InEpsilon(t, 4, b.children[1].children[1].weight, eps)
 }
+
+func TestBucket_AggregatePerChild(t *testing.T) {
+	root, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 10}, {N: 2, C: 20}}},
+		strawBucket{"/Location:Europe/Country:Spain", Nodes{{N: 3, C: 5}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 4, C: 1}, {N: 5, C: 3}}},
+	)
+	require.NoError(t, err)
+
+	r := root.AggregatePerChild("Country", NewMeanAgg, CapWeightFunc)
+	require.Len(t, r, 3)
+	require.InEpsilon(t, 15.0, r["Germany"], eps)
+	require.InEpsilon(t, 5.0, r["Spain"], eps)
+	require.InEpsilon(t, 2.0, r["China"], eps)
+
+	r = root.AggregatePerChild("Location", NewMaxAgg, CapWeightFunc)
+	require.Equal(t, map[string]float64{"Europe": 20, "Asia": 3}, r)
+
+	require.Empty(t, root.AggregatePerChild("City", NewMeanAgg, CapWeightFunc))
+}
+
+type countAgg struct{ n int }
+
+func (a *countAgg) Add(float64)      { a.n++ }
+func (a *countAgg) Compute() float64 { return float64(a.n) }
+func (a *countAgg) Clear()           { a.n = 0 }
+
+func TestBucket_TraverseParallel(t *testing.T) {
+	var (
+		b  Bucket
+		ns = make(Nodes, 10000)
+	)
+
+	r := rand.New(rand.NewSource(1))
+	for i := range ns {
+		ns[i] = Node{N: uint32(i), C: uint64(r.Intn(1000) + 1), P: uint64(r.Intn(10)), R: r.Float64()}
+	}
+	require.NoError(t, b.AddBucket("/opt:first", ns))
+
+	factories := map[string]func() Aggregator{
+		"sum":        NewSumAgg,
+		"meanSum":    NewMeanSumAgg,
+		"mean":       NewMeanAgg,
+		"min":        NewMinAgg,
+		"max":        NewMaxAgg,
+		"minPrice":   NewMinPriceAgg,
+		"meanIQR":    NewMeanIQRAgg,
+		"variance":   NewVarianceAgg,
+		"stdDev":     NewStdDevAgg,
+		"reputation": NewReputationAgg,
+	}
+	for name, f := range factories {
+		t.Run(name, func(t *testing.T) {
+			af := AggregatorFactory{New: f}
+			for _, wf := range []WeightFunc{CapWeightFunc, PriceWeightFunc, ReputationWeightFunc} {
+				expected := b.Traverse(f(), wf).Compute()
+				for _, workers := range []int{1, 3, 8} {
+					actual := b.TraverseParallel(af, wf, workers).Compute()
+					if expected == 0 {
+						require.Equal(t, expected, actual)
+					} else {
+						require.InEpsilon(t, expected, actual, eps)
+					}
+				}
+			}
+		})
+	}
+
+	t.Run("not mergeable", func(t *testing.T) {
+		af := AggregatorFactory{New: func() Aggregator { return new(countAgg) }}
+		require.Equal(t, 10000.0, b.TraverseParallel(af, CapWeightFunc, 4).Compute())
+	})
+}
+
+func TestBucket_TopNodes(t *testing.T) {
+	var b Bucket
+
+	initTestBucket(t, &b)
+
+	require.Nil(t, b.TopNodes(0, CapWeightFunc))
+	require.Equal(t, []uint32{10, 2}, b.TopNodes(2, CapWeightFunc))
+	require.Equal(t, []uint32{10, 2, 1, 0}, b.TopNodes(10, CapWeightFunc))
+
+	// nodes with equal weight are ordered by index
+	require.Equal(t, []uint32{1, 0, 2}, b.TopNodes(3, PriceWeightFunc))
+}
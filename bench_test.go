@@ -0,0 +1,104 @@
+package netmap_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/nspcc-dev/netmap/netmaptest"
+)
+
+var benchSizes = []netmaptest.Config{
+	{Countries: 5, Cities: 4, Racks: 5, Nodes: 10},
+	{Countries: 10, Cities: 10, Racks: 10, Nodes: 10},
+	{Countries: 20, Cities: 10, Racks: 10, Nodes: 50},
+}
+
+var benchGroup = netmap.SFGroup{
+	Selectors: []netmap.Select{
+		{Key: netmaptest.CountryKey, Count: 3},
+		{Key: netmaptest.RackKey, Count: 2},
+		{Key: netmap.NodesBucket, Count: 1},
+	},
+	Filters: []netmap.Filter{
+		{Key: netmaptest.CountryKey, F: netmap.FilterNE("Country1")},
+		{Key: netmaptest.CityKey, F: netmap.FilterNE("City5")},
+	},
+}
+
+func benchmarkNetmaps(b *testing.B, f func(b *testing.B, m *netmap.Bucket)) {
+	for _, c := range benchSizes {
+		m := netmaptest.Random(1, c)
+		b.Run(strconv.Itoa(c.Size()), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			f(b, &m)
+		})
+	}
+}
+
+func BenchmarkBucket_FindNodes(b *testing.B) {
+	benchmarkNetmaps(b, func(b *testing.B, m *netmap.Bucket) {
+		for i := 0; i < b.N; i++ {
+			if len(m.FindNodes([]byte(strconv.Itoa(i)), benchGroup)) == 0 {
+				b.Fatal("selection failed")
+			}
+		}
+	})
+}
+
+func BenchmarkBucket_PlaceMany(b *testing.B) {
+	pivots := make([][]byte, 100)
+	for i := range pivots {
+		pivots[i] = []byte(strconv.Itoa(i))
+	}
+	benchmarkNetmaps(b, func(b *testing.B, m *netmap.Bucket) {
+		for i := 0; i < b.N; i++ {
+			if len(m.PlaceMany(pivots, []netmap.SFGroup{benchGroup})[0]) == 0 {
+				b.Fatal("selection failed")
+			}
+		}
+	})
+}
+
+func BenchmarkBucket_GetMaxSelection(b *testing.B) {
+	benchmarkNetmaps(b, func(b *testing.B, m *netmap.Bucket) {
+		for i := 0; i < b.N; i++ {
+			if m.GetMaxSelection(benchGroup) == nil {
+				b.Fatal("selection failed")
+			}
+		}
+	})
+}
+
+func BenchmarkBucket_UnmarshalBinary(b *testing.B) {
+	benchmarkNetmaps(b, func(b *testing.B, m *netmap.Bucket) {
+		data, err := m.MarshalBinary()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var r netmap.Bucket
+			if err := r.UnmarshalBinary(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBucket_Merge(b *testing.B) {
+	benchmarkNetmaps(b, func(b *testing.B, m *netmap.Bucket) {
+		var gs []*netmap.Bucket
+		for i := 0; i < 10; i++ {
+			gs = append(gs, m.FindGraph([]byte(strconv.Itoa(i)), benchGroup))
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var r netmap.Bucket
+			for _, g := range gs {
+				r.Merge(*g)
+			}
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package netmap
+
+import (
+	"sort"
+)
+
+// Option configures bucket created by NewBucket.
+type Option func(*Bucket)
+
+// NewBucket returns bucket with the specified key and value configured by
+// opts. Nodes of the bucket are sorted by index without duplicates and
+// include nodes of all sub-buckets, so it is ready for selection.
+func NewBucket(key, value string, opts ...Option) Bucket {
+	b := Bucket{Key: key, Value: value}
+	for _, o := range opts {
+		o(&b)
+	}
+	return b
+}
+
+// WithNodes adds nodes ns to the bucket. Nodes can be in any order,
+// only the first of the nodes with the same index is kept.
+func WithNodes(ns ...Node) Option {
+	return func(b *Bucket) {
+		b.nodes = merge(b.nodes, sortedNodes(ns))
+	}
+}
+
+// WithChildren adds cs as sub-buckets of the bucket, see AddChild.
+func WithChildren(cs ...Bucket) Option {
+	return func(b *Bucket) {
+		for i := range cs {
+			b.AddChild(cs[i])
+		}
+	}
+}
+
+// sortedNodes returns ns sorted by index without duplicates.
+// It returns ns itself if it is already sorted, a copy otherwise.
+func sortedNodes(ns Nodes) Nodes {
+	if isStrictlySorted(ns) {
+		return ns
+	}
+
+	r := append(Nodes(nil), ns...)
+	sort.Stable(r)
+	return dedupNodes(r)
+}
+
+func isStrictlySorted(ns Nodes) bool {
+	for i := 1; i < len(ns); i++ {
+		if ns[i].N <= ns[i-1].N {
+			return false
+		}
+	}
+	return true
+}
+
+// isNormalized checks whether nodes of b and its sub-buckets are sorted
+// without duplicates and parent buckets contain nodes of their children.
+func (b Bucket) isNormalized() bool {
+	if !isStrictlySorted(b.nodes) {
+		return false
+	}
+	for _, c := range b.children {
+		if !c.isNormalized() {
+			return false
+		}
+		for _, n := range c.nodes {
+			if !containsSorted(b.nodes, n.N) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// normalize brings b to the form checked by isNormalized.
+func (b *Bucket) normalize() {
+	if len(b.children) != 0 {
+		b.ownChildren()
+		for i := range b.children {
+			b.children[i].normalize()
+		}
+	}
+	b.nodes = sortedNodes(b.nodes)
+	if len(b.children) != 0 {
+		b.nodes = merge(b.nodes, childNodes(b.children))
+	}
+}
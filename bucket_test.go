@@ -0,0 +1,49 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBucket(t *testing.T) {
+	germany := NewBucket("Country", "Germany", WithNodes(Node{N: 3}, Node{N: 1}, Node{N: 3, C: 10}))
+	require.Equal(t, Nodes{{N: 1}, {N: 3}}, germany.Nodelist())
+
+	china := Bucket{Key: "Country", Value: "China", nodes: Nodes{{N: 5}, {N: 4}, {N: 5}}}
+	asia := Bucket{Key: "Location", Value: "Asia", children: []Bucket{china}}
+
+	root := NewBucket("", "",
+		WithChildren(
+			NewBucket("Location", "Europe", WithChildren(germany)),
+			asia,
+		),
+		WithNodes(Node{N: 6}),
+	)
+	require.Empty(t, root.Validate())
+	require.Equal(t, []uint32{1, 3, 4, 5, 6}, root.Nodelist().Nodes())
+	require.Equal(t, []uint32{4, 5}, root.children[1].Nodelist().Nodes())
+
+	// hand-built buckets are left intact
+	require.Nil(t, asia.nodes)
+	require.Equal(t, Nodes{{N: 5}, {N: 4}, {N: 5}}, china.nodes)
+
+	nodes := root.FindNodes(defaultPivot, SFGroup{Selectors: []Select{
+		{Key: "Location", Count: 2},
+		{Key: NodesBucket, Count: 1},
+	}})
+	require.Len(t, nodes, 2)
+}
+
+func TestBucket_AddBucketUnsorted(t *testing.T) {
+	var b Bucket
+
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:Germany", Nodes{{N: 3}, {N: 1}, {N: 3}}))
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:Spain", Nodes{{N: 4}, {N: 2}}))
+	require.Empty(t, b.Validate())
+	require.Equal(t, []uint32{1, 2, 3, 4}, b.Nodelist().Nodes())
+
+	var m Bucket
+	m.Merge(Bucket{nodes: Nodes{{N: 2}, {N: 1}, {N: 2}}})
+	require.Equal(t, []uint32{1, 2}, m.Nodelist().Nodes())
+}
@@ -0,0 +1,157 @@
+package netmap
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// CandidatePool collects nodes registering for the next epoch.
+	// Candidates become active only after CommitEpoch, which checks them
+	// and builds the netmap of the new epoch.
+	CandidatePool struct {
+		epoch   uint64
+		filters []Filter
+		entries []NodeEntry
+		schema  *Schema
+	}
+
+	// Rejection describes candidate which was not admitted to the netmap.
+	Rejection struct {
+		Node uint32
+		Err  error
+	}
+)
+
+// NewCandidatePool returns empty pool for the epoch following epoch.
+// Candidates must satisfy all filters fs to be admitted, filter is satisfied
+// if the node has an option or attribute with the filter key and the value
+// accepted by the filter.
+func NewCandidatePool(epoch uint64, fs ...Filter) *CandidatePool {
+	return &CandidatePool{epoch: epoch, filters: fs}
+}
+
+// Epoch returns number of the last committed epoch.
+func (p *CandidatePool) Epoch() uint64 {
+	return p.epoch
+}
+
+// Len returns number of registered candidates.
+func (p *CandidatePool) Len() int {
+	return len(p.entries)
+}
+
+// SetSchema attaches schema s to the pool. Options of candidates must match
+// it to be registered and are checked again by CommitEpoch, which returns
+// netmap with the schema attached, see WithSchema.
+func (p *CandidatePool) SetSchema(s *Schema) {
+	p.schema = s
+}
+
+// Register adds node e to the candidates of the next epoch. Only the format
+// of options and the schema are checked here, other checks are deferred
+// until CommitEpoch.
+func (p *CandidatePool) Register(e NodeEntry) error {
+	if err := checkOptions(p.schema, e.Options); err != nil {
+		return errors.Wrapf(err, "node %d", e.Node.N)
+	}
+	p.entries = append(p.entries, e)
+	return nil
+}
+
+// CommitEpoch advances epoch and returns netmap consisting of the admitted
+// candidates. Candidate is rejected if:
+// - it is registered several times with different attributes or options;
+// - its public key is already used by the node with lower index;
+// - it has different values of the same option, e.g. two Countries;
+// - it doesn't satisfy pool filters.
+// Rejected candidates are returned sorted by node index. The pool is
+// emptied, so nodes must register again for the next epoch.
+func (p *CandidatePool) CommitEpoch() (Bucket, []Rejection) {
+	var (
+		root = NewBucket("", "", WithSchema(p.schema))
+		rs   []Rejection
+		es   = p.entries
+		keys = make(map[string]uint32)
+	)
+
+	sort.SliceStable(es, func(i, j int) bool { return es[i].Node.N < es[j].Node.N })
+	for i := 0; i < len(es); {
+		j := i + 1
+		for j < len(es) && es[j].Node.N == es[i].Node.N {
+			j++
+		}
+
+		e := es[i]
+		if err := checkDuplicates(es[i:j]); err != nil {
+			rs = append(rs, Rejection{Node: e.Node.N, Err: err})
+		} else if err := p.checkCandidate(e, keys); err != nil {
+			rs = append(rs, Rejection{Node: e.Node.N, Err: err})
+		} else {
+			if len(e.Node.PubKey) != 0 {
+				keys[string(e.Node.PubKey)] = e.Node.N
+			}
+			if err := root.AddStrawNode(e.Node, e.Options...); err != nil {
+				rs = append(rs, Rejection{Node: e.Node.N, Err: err})
+			}
+		}
+		i = j
+	}
+
+	for _, r := range rs {
+		getLogger().Log("candidate rejected", "epoch", p.epoch+1, "node", r.Node, "error", r.Err)
+	}
+
+	p.epoch++
+	p.entries = nil
+	return root, rs
+}
+
+// checkDuplicates checks that all registrations of the same node are equal.
+func checkDuplicates(es []NodeEntry) error {
+	for _, e := range es[1:] {
+		if !e.Node.Equals(es[0].Node) || !equalStrings(e.Options, es[0].Options) {
+			return errors.Errorf("node %d is registered %d times with different attributes", e.Node.N, len(es))
+		}
+	}
+	return nil
+}
+
+func (p *CandidatePool) checkCandidate(e NodeEntry, keys map[string]uint32) error {
+	if n, ok := keys[string(e.Node.PubKey)]; ok {
+		return errors.Errorf("public key %x is already used by node %d", e.Node.PubKey, n)
+	}
+
+	var ps []bucketPath
+	for _, o := range e.Options {
+		if o == Separator {
+			continue
+		}
+		for _, b := range splitProps(o[1:]) {
+			for _, p := range ps {
+				if p.key == b.Key && p.value != b.Value {
+					return Conflict{Node: e.Node.N, Path: p.path, OtherPath: o}
+				}
+			}
+			ps = append(ps, bucketPath{key: b.Key, value: b.Value, path: o})
+		}
+	}
+
+loop:
+	for _, f := range p.filters {
+		sf := f.GetF()
+		if sf == nil {
+			continue
+		} else if v, ok := e.Node.Attrs[f.Key]; ok && sf.Check(v) {
+			continue
+		}
+		for _, p := range ps {
+			if p.key == f.Key && sf.Check(p.value) {
+				continue loop
+			}
+		}
+		return errors.Errorf("filter on %s is not satisfied", f.Key)
+	}
+	return nil
+}
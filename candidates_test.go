@@ -0,0 +1,68 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCandidatePool_CommitEpoch(t *testing.T) {
+	p := NewCandidatePool(10, Filter{Key: "Country", F: FilterNE("Russia")})
+
+	for _, e := range []NodeEntry{
+		{Node: Node{N: 3, C: 1}, Options: []string{"/Location:Europe/Country:Germany"}},
+		{Node: Node{N: 1, C: 1, PubKey: []byte{1}}, Options: []string{"/Location:Europe/Country:France"}},
+		{Node: Node{N: 3, C: 1}, Options: []string{"/Location:Europe/Country:Germany"}},
+		// registered twice with different capacity
+		{Node: Node{N: 2, C: 1}, Options: []string{"/Location:Europe/Country:Spain"}},
+		{Node: Node{N: 2, C: 2}, Options: []string{"/Location:Europe/Country:Spain"}},
+		// public key of node 1
+		{Node: Node{N: 4, PubKey: []byte{1}}, Options: []string{"/Location:Europe/Country:Italy"}},
+		// conflicting options
+		{Node: Node{N: 5}, Options: []string{"/Location:Europe/Country:Italy", "/Country:Spain"}},
+		// filtered out
+		{Node: Node{N: 6}, Options: []string{"/Location:Asia/Country:Russia"}},
+		{Node: Node{N: 7}, Options: []string{"/Location:Asia"}},
+	} {
+		require.NoError(t, p.Register(e))
+	}
+	require.Error(t, p.Register(NodeEntry{Node: Node{N: 8}, Options: []string{"Country:Spain"}}))
+	require.Equal(t, 9, p.Len())
+
+	root, rs := p.CommitEpoch()
+	require.Equal(t, uint64(11), p.Epoch())
+	require.Equal(t, 0, p.Len())
+	require.Equal(t, []uint32{1, 3}, root.Nodelist().Nodes())
+	require.Equal(t, []uint32{3}, root.GetNodesByOption("/Location:Europe/Country:Germany").Nodes())
+
+	var rejected []uint32
+	for _, r := range rs {
+		rejected = append(rejected, r.Node)
+	}
+	require.Equal(t, []uint32{2, 4, 5, 6, 7}, rejected)
+	require.IsType(t, Conflict{}, rs[2].Err)
+
+	root, rs = p.CommitEpoch()
+	require.Equal(t, uint64(12), p.Epoch())
+	require.Empty(t, root.Nodelist())
+	require.Empty(t, rs)
+}
+
+func TestCandidatePool_CommitEpochSchema(t *testing.T) {
+	p := NewCandidatePool(10)
+	require.NoError(t, p.Register(NodeEntry{Node: Node{N: 1}, Options: []string{"/Country:Germany", "/Tier:Fast"}}))
+	require.NoError(t, p.Register(NodeEntry{Node: Node{N: 2}, Options: []string{"/Country:France"}}))
+
+	// options accepted at registration are checked again on commit
+	p.SetSchema(NewSchema(Attribute{Key: "Country"}))
+	err := p.Register(NodeEntry{Node: Node{N: 3}, Options: []string{"/Tier:Fast"}})
+	require.True(t, errors.Is(err, ErrSchemaViolation))
+
+	root, rs := p.CommitEpoch()
+	require.Len(t, rs, 1)
+	require.Equal(t, uint32(1), rs[0].Node)
+	require.True(t, errors.Is(rs[0].Err, ErrSchemaViolation))
+	require.Equal(t, []uint32{2}, root.Nodelist().Nodes())
+	require.Empty(t, root.GetNodesByOption("/Country:Germany"))
+}
@@ -0,0 +1,122 @@
+package netmap
+
+import (
+	"crypto/sha256"
+	"sort"
+)
+
+// Canonicalize brings b to the canonical form: children of every bucket
+// are sorted by key and value as by SortChildren, nodes are sorted by index
+// without duplicates.
+// Equal netmaps have equal binary encoding in the canonical form.
+func (b *Bucket) Canonicalize() {
+	b.nodes = append(Nodes(nil), b.nodes...)
+	b.ownChildren()
+	sort.Slice(b.nodes, func(i, j int) bool {
+		x, y := b.nodes[i], b.nodes[j]
+		if x.N != y.N {
+			return x.N < y.N
+		}
+		if x.C != y.C {
+			return x.C < y.C
+		}
+		return x.P < y.P
+	})
+	b.nodes = dedupNodes(b.nodes)
+
+	for i := range b.children {
+		b.children[i].Canonicalize()
+	}
+	sort.Sort(byName(b.children))
+}
+
+// SortChildren sorts children of every bucket by key and value, like
+// Canonicalize does, but leaves nodes intact. Buckets are shared with
+// copies of b only if they are already sorted.
+func (b *Bucket) SortChildren() {
+	if b.childrenSorted() {
+		return
+	}
+
+	b.ownChildren()
+	sort.Stable(byName(b.children))
+	for i := range b.children {
+		b.children[i].SortChildren()
+	}
+}
+
+// childrenSorted checks whether children of b and all its sub-buckets are sorted.
+func (b Bucket) childrenSorted() bool {
+	if !sort.IsSorted(byName(b.children)) {
+		return false
+	}
+	for i := range b.children {
+		if !b.children[i].childrenSorted() {
+			return false
+		}
+	}
+	return true
+}
+
+// byName sorts buckets by key and value.
+type byName []Bucket
+
+func (bs byName) Len() int      { return len(bs) }
+func (bs byName) Swap(i, j int) { bs[i], bs[j] = bs[j], bs[i] }
+func (bs byName) Less(i, j int) bool {
+	if bs[i].Key != bs[j].Key {
+		return bs[i].Key < bs[j].Key
+	}
+	return bs[i].Value < bs[j].Value
+}
+
+// Digest returns SHA-256 hash of canonical binary encoding of b.
+// It differs from Hash, which is used for HRW sorting of buckets.
+func (b Bucket) Digest() [32]byte {
+	c := b.Copy()
+	c.Canonicalize()
+
+	data, _ := c.MarshalBinary()
+	return sha256.Sum256(data)
+}
+
+// DeepEqual checks if b and b1 have the same key and value, nodes and
+// children. Unlike Equals, contained nodes and buckets are compared too.
+// Order of nodes and children doesn't matter.
+func (b Bucket) DeepEqual(b1 Bucket) bool {
+	if !b.Equals(b1) || len(b.nodes) != len(b1.nodes) || len(b.children) != len(b1.children) {
+		return false
+	}
+
+	ns := make(map[uint32]Node, len(b1.nodes))
+	for _, n := range b1.nodes {
+		ns[n.N] = n
+	}
+	for _, n := range b.nodes {
+		if n1, ok := ns[n.N]; !ok || !n.Equals(n1) {
+			return false
+		}
+	}
+
+	for _, c := range b.children {
+		if i := findChild(b1.children, c); i < 0 || !c.DeepEqual(b1.children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// dedupNodes removes adjacent nodes with the same index from sorted ns.
+func dedupNodes(ns Nodes) Nodes {
+	if len(ns) < 2 {
+		return ns
+	}
+
+	r := ns[:1]
+	for i := 1; i < len(ns); i++ {
+		if ns[i].N != r[len(r)-1].N {
+			r = append(r, ns[i])
+		}
+	}
+	return r
+}
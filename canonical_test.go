@@ -0,0 +1,115 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Canonicalize(t *testing.T) {
+	b := Bucket{
+		nodes: Nodes{{N: 3}, {N: 1}, {N: 3}, {N: 2}},
+		children: []Bucket{
+			{Key: "Country", Value: "Spain", nodes: Nodes{{N: 3}, {N: 3}}},
+			{Key: "City", Value: "Berlin", nodes: Nodes{{N: 2}}},
+			{Key: "Country", Value: "Germany", nodes: Nodes{{N: 1}}},
+		},
+	}
+
+	b.Canonicalize()
+	require.Equal(t, []uint32{1, 2, 3}, b.nodes.Nodes())
+	require.Equal(t, []uint32{3}, b.children[2].nodes.Nodes())
+
+	var names []string
+	for _, c := range b.children {
+		names = append(names, c.Name())
+	}
+	require.Equal(t, []string{"City:Berlin", "Country:Germany", "Country:Spain"}, names)
+}
+
+func TestBucket_Digest(t *testing.T) {
+	b1, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	b2, err := newRoot(
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+		bucket{"/Location:Europe/Country:Germany", []uint32{2, 1}},
+	)
+	require.NoError(t, err)
+
+	before := b1.Copy()
+	require.Equal(t, b1.Digest(), b2.Digest())
+	require.Equal(t, before, b1)
+
+	require.NoError(t, b2.AddBucket("/Location:Asia/Country:China", Nodes{{N: 4}}))
+	require.NotEqual(t, b1.Digest(), b2.Digest())
+}
+
+func TestBucket_DeepEqual(t *testing.T) {
+	b1, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	b2, err := newRoot(
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+		bucket{"/Location:Europe/Country:Germany", []uint32{2, 1}},
+	)
+	require.NoError(t, err)
+	require.True(t, b1.DeepEqual(b2))
+	require.True(t, b2.DeepEqual(b1))
+
+	b3 := b2.Copy()
+	require.NoError(t, b3.AddBucket("/Location:Asia/Country:China", Nodes{{N: 4}}))
+	require.False(t, b1.DeepEqual(b3))
+
+	b3, err = newRoot(
+		bucket{"/Location:Asia/Country:Japan", []uint32{3}},
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+	)
+	require.NoError(t, err)
+	require.False(t, b1.DeepEqual(b3))
+
+	b3 = b1.Copy()
+	b3.children[0].children[0].nodes[0].C++
+	require.False(t, b1.DeepEqual(b3))
+	require.True(t, b1.Equals(b3))
+}
+
+func TestBucket_SortChildren(t *testing.T) {
+	germany := bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}}
+	france := bucket{"/Location:Europe/Country:France", []uint32{4}}
+	china := bucket{"/Location:Asia/Country:China", []uint32{3}}
+
+	b1, err := newRoot(germany, france, china)
+	require.NoError(t, err)
+	before := b1.Copy()
+
+	b2 := b1.Copy()
+	b2.SortChildren()
+	require.Equal(t, before, b1)
+	require.Equal(t, "Location:Asia", b2.children[0].Name())
+	require.Equal(t, "Country:France", b2.children[1].children[0].Name())
+
+	t.Run("merge", func(t *testing.T) {
+		x, err := newRoot(germany)
+		require.NoError(t, err)
+		y, err := newRoot(china, france)
+		require.NoError(t, err)
+
+		m1, m2 := x.Copy(), y.Copy()
+		m1.Merge(y)
+		m2.Merge(x)
+
+		d1, err := m1.MarshalBinary()
+		require.NoError(t, err)
+		d2, err := m2.MarshalBinary()
+		require.NoError(t, err)
+		require.Equal(t, d1, d2)
+		require.Equal(t, b2, m1)
+	})
+}
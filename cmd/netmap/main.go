@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+type (
+	// jsonNode is a node description in JSON netmap.
+	jsonNode struct {
+		ID         uint32            `json:"id"`
+		Capacity   uint64            `json:"capacity,omitempty"`
+		Price      uint64            `json:"price,omitempty"`
+		Reputation float64           `json:"reputation,omitempty"`
+		Options    []string          `json:"options"`
+		Attrs      map[string]string `json:"attrs,omitempty"`
+	}
+
+	command struct {
+		usage string
+		run   func(args []string) error
+	}
+
+	// stderrLogger writes log messages to stderr as key=value pairs.
+	stderrLogger struct{}
+)
+
+var (
+	errWrongFormat = errors.New("wrong command format")
+	defaultSource  = "default-source-of-bytes"
+)
+
+var commands = map[string]command{
+	"select": {
+		usage: "select [-pivot <string>] [-v] <netmap> <policy>",
+		run:   selectNodes,
+	},
+	"dump": {
+		usage: "dump [-dot] <netmap>",
+		run:   dumpNetmap,
+	},
+	"diff": {
+		usage: "diff <old netmap> <new netmap>",
+		run:   diffNetmaps,
+	},
+	"fairness": {
+		usage: "fairness [-n <pivots>] <netmap> <policy>",
+		run:   fairness,
+	},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	c, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	if err := c.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		if err == errWrongFormat {
+			fmt.Fprintln(os.Stderr, "Usage: netmap", c.usage)
+		}
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	for _, name := range []string{"select", "dump", "diff", "fairness"} {
+		fmt.Fprintln(os.Stderr, "  netmap", commands[name].usage)
+	}
+	fmt.Fprintln(os.Stderr, `
+Netmap files with .json extension contain an array of nodes
+{"id": 1, "capacity": 10, "price": 1, "options": ["/Location:Europe/Country:Germany"],
+ "attrs": {"SSD": "true"}}, other files are in binary format used by REPL.
+
+Policy consists of clauses separated by ';' or newlines:
+  SELECT <count>|<percent>%|ALL <key> [DISTINCT <key>] [SAME <key>] [FROM <name>]
+  FILTER <key> <operation> <value> [AS <name>]
+  FILTER <key> RANGE <from> <to> [AS <name>]
+  FILTER @<name> [@<name> ...] AS <name>
+  EXCLUDE <node> [<node> ...]
+  SUBNET <subnet>
+  NODEFILTER <Capacity|Price> <operation> <value>
+  GROUP (starts new selection group)`)
+	os.Exit(2)
+}
+
+func selectNodes(args []string) error {
+	fs := flag.NewFlagSet("select", flag.ContinueOnError)
+	pivot := fs.String("pivot", defaultSource, "pivot used for selection")
+	verbose := fs.Bool("v", false, "log placement decisions to stderr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errWrongFormat
+	}
+	if *verbose {
+		netmap.SetLogger(stderrLogger{})
+	}
+
+	b, err := load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ss, err := parsePolicy(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if *verbose {
+		stderrLogger{}.Log("policy parsed", "groups", len(ss))
+	}
+
+	nodes := b.FindNodes([]byte(*pivot), ss...)
+	if len(nodes) == 0 {
+		return errors.New("policy can't be satisfied")
+	}
+	if _, err := b.FindGraphStrict([]byte(*pivot), ss...); err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+
+	fmt.Println(nodes.Nodes())
+	return nil
+}
+
+// Log implements netmap.Logger.
+func (stderrLogger) Log(msg string, keyvals ...interface{}) {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(&sb, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	fmt.Fprintln(os.Stderr, sb.String())
+}
+
+func dumpNetmap(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	dot := fs.Bool("dot", false, "dump netmap in *.dot format")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errWrongFormat
+	}
+
+	b, err := load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if !*dot {
+		fmt.Print(b)
+		return nil
+	}
+
+	s, err := b.Sdump()
+	if err != nil {
+		return err
+	}
+	fmt.Print(s)
+	return nil
+}
+
+func diffNetmaps(args []string) error {
+	if len(args) != 2 {
+		return errWrongFormat
+	}
+
+	old, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	cur, err := load(args[1])
+	if err != nil {
+		return err
+	}
+
+	d := cur.Diff(*old)
+	for _, e := range d.Removed {
+		fmt.Println("-", e.Path, e.Nodes.Nodes())
+	}
+	for _, e := range d.Added {
+		fmt.Println("+", e.Path, e.Nodes.Nodes())
+	}
+	return nil
+}
+
+func fairness(args []string) error {
+	fs := flag.NewFlagSet("fairness", flag.ContinueOnError)
+	pivots := fs.Int("n", 10000, "number of simulated pivots")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errWrongFormat
+	}
+
+	b, err := load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	ss, err := parsePolicy(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	r := b.Fairness(*pivots, ss...)
+	ns := make([]uint32, 0, len(r.Placements))
+	for n := range r.Placements {
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+	for _, n := range ns {
+		fmt.Printf("%d\t%d\t%.4f\n", n, r.Placements[n], r.Frequency(n))
+	}
+	fmt.Printf("pivots=%d failed=%d mean=%.2f stddev=%.2f gini=%.4f\n",
+		r.Pivots, r.Failed, r.Mean, r.StdDev, r.Gini)
+	return nil
+}
+
+// load reads netmap from file name. Files with .json extension
+// contain JSON array of nodes, others are in binary format.
+func load(name string) (*netmap.Bucket, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(netmap.Bucket)
+	if !strings.EqualFold(filepath.Ext(name), ".json") {
+		return b, errors.Wrapf(b.UnmarshalBinary(data), "can't read %s", name)
+	}
+
+	var ns []jsonNode
+	if err := json.Unmarshal(data, &ns); err != nil {
+		return nil, errors.Wrapf(err, "can't read %s", name)
+	}
+	es := make([]netmap.NodeEntry, 0, len(ns))
+	for _, n := range ns {
+		es = append(es, netmap.NodeEntry{
+			Node:    netmap.Node{N: n.ID, C: n.Capacity, P: n.Price, R: n.Reputation, Attrs: n.Attrs},
+			Options: n.Options,
+		})
+	}
+	return b, errors.Wrapf(b.AddNodes(es), "can't read %s", name)
+}
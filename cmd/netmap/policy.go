@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+// parsePolicy parses placement rule in the format of REPL commands.
+// Clauses are separated by ';' or newlines, GROUP starts new SFGroup:
+//
+//	SELECT 2 Country DISTINCT DC; SELECT 1 Node
+//	FILTER Location NE Asia; FILTER Price RANGE 1 10
+//	EXCLUDE 1 2; SUBNET 3; NODEFILTER Capacity GE 100
+//	GROUP; SELECT 1 Node
+//
+// Named filters are defined with AS and used by SELECT clauses with FROM,
+// filters defined with the same name must all be satisfied, other named
+// filters are referenced with '@':
+//
+//	FILTER Location EQ Europe AS EU; FILTER @EU AS F1; FILTER Price LE 10 AS F1
+//	SELECT 2 Country FROM F1
+func parsePolicy(s string) ([]netmap.SFGroup, error) {
+	var (
+		gs    = []netmap.Selection{{}}
+		g     = &gs[0]
+		named []netmap.NamedFilter
+	)
+
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == '\n' }) {
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		var err error
+		switch strings.ToUpper(args[0]) {
+		case "GROUP":
+			if len(args) != 1 {
+				return nil, errors.Errorf("GROUP has no arguments: %s", line)
+			}
+			gs = append(gs, netmap.Selection{})
+			g = &gs[len(gs)-1]
+		case "SELECT":
+			var (
+				sel  netmap.Select
+				from string
+			)
+			if sel, from, err = parseSelect(args[1:]); err != nil {
+				break
+			} else if from != "" && g.From != "" && from != g.From {
+				err = errors.New("group can't select FROM different filters")
+				break
+			} else if from != "" {
+				g.From = from
+			}
+			g.Selectors = append(g.Selectors, sel)
+		case "FILTER":
+			if n := len(args); n > 3 && strings.ToUpper(args[n-2]) == "AS" {
+				named, err = parseNamedFilter(named, args[1:n-2], args[n-1])
+				break
+			}
+			var f netmap.Filter
+			if f, err = parseFilter(args[1:]); err == nil {
+				g.Filters = append(g.Filters, f)
+			}
+		case "EXCLUDE":
+			for _, a := range args[1:] {
+				var n uint64
+				if n, err = strconv.ParseUint(a, 10, 32); err != nil {
+					break
+				}
+				g.Exclude = append(g.Exclude, uint32(n))
+			}
+		case "NODEFILTER":
+			var f netmap.NodeFilter
+			if f, err = parseNodeFilter(args[1:]); err == nil {
+				g.NodeFilters = append(g.NodeFilters, f)
+			}
+		case "SUBNET":
+			var n uint64
+			if len(args) != 2 {
+				err = errWrongFormat
+			} else if n, err = strconv.ParseUint(args[1], 10, 32); err == nil {
+				g.Subnet = uint32(n)
+			}
+		default:
+			err = errors.New("unknown clause")
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid clause %q", line)
+		}
+	}
+
+	for i := range gs {
+		if len(gs[i].Selectors) == 0 {
+			return nil, errors.Errorf("group %d has no SELECT clauses", i)
+		}
+	}
+	return netmap.ResolvePolicy(named, gs...)
+}
+
+// parseNamedFilter adds filter defined by args or references to other
+// filters to the named filter name.
+func parseNamedFilter(named []netmap.NamedFilter, args []string, name string) ([]netmap.NamedFilter, error) {
+	i := 0
+	for i < len(named) && named[i].Name != name {
+		i++
+	}
+	if i == len(named) {
+		named = append(named, netmap.NamedFilter{Name: name})
+	}
+
+	if strings.HasPrefix(args[0], "@") {
+		for _, a := range args {
+			if !strings.HasPrefix(a, "@") || len(a) == 1 {
+				return nil, errWrongFormat
+			}
+			named[i].Refs = append(named[i].Refs, a[1:])
+		}
+		return named, nil
+	}
+
+	f, err := parseFilter(args)
+	if err != nil {
+		return nil, err
+	}
+	named[i].Filters = append(named[i].Filters, f)
+	return named, nil
+}
+
+func parseSelect(args []string) (sel netmap.Select, from string, err error) {
+	if len(args) < 2 || len(args)%2 != 0 {
+		return sel, "", errWrongFormat
+	}
+
+	if strings.ToUpper(args[0]) == "ALL" {
+		sel.Count = netmap.CountAll
+	} else if p := strings.TrimSuffix(args[0], "%"); p != args[0] {
+		percent, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || percent == 0 || percent > 100 {
+			return sel, "", errors.New("percentage must be integer in range 1-100")
+		}
+		sel.Percent = uint32(percent)
+	} else {
+		count, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return sel, "", errors.Wrap(err, "count must be integer")
+		}
+		sel.Count = uint32(count)
+	}
+	sel.Key = args[1]
+
+	for i := 2; i < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "DISTINCT":
+			sel.Distinct = args[i+1]
+		case "SAME":
+			sel.Same = args[i+1]
+		case "FROM":
+			from = args[i+1]
+		default:
+			return sel, "", errWrongFormat
+		}
+	}
+	return sel, from, nil
+}
+
+func parseFilter(args []string) (netmap.Filter, error) {
+	if len(args) < 3 {
+		return netmap.Filter{}, errWrongFormat
+	}
+
+	op, ok := netmap.Operation_value[strings.ToUpper(args[1])]
+	if !ok {
+		return netmap.Filter{}, errors.New("operation must be one of: EQ, NE, LT, LE, GT, GE, RANGE")
+	}
+
+	f := netmap.NewFilter(netmap.Operation(op), args[2])
+	if netmap.Operation(op) == netmap.Operation_RANGE {
+		if len(args) != 4 {
+			return netmap.Filter{}, errWrongFormat
+		}
+		from, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return netmap.Filter{}, errors.Wrap(err, "range bounds must be numbers")
+		}
+		to, err := strconv.ParseFloat(args[3], 64)
+		if err != nil {
+			return netmap.Filter{}, errors.Wrap(err, "range bounds must be numbers")
+		}
+		f = netmap.FilterRange(from, to)
+	} else if len(args) != 3 {
+		return netmap.Filter{}, errWrongFormat
+	}
+	return netmap.Filter{Key: args[0], F: f}, nil
+}
+
+func parseNodeFilter(args []string) (netmap.NodeFilter, error) {
+	if len(args) == 0 {
+		return netmap.NodeFilter{}, errWrongFormat
+	}
+
+	field, ok := netmap.NodeField_value[strings.ToUpper(args[0])]
+	if !ok {
+		return netmap.NodeFilter{}, errors.New("field must be one of: CAPACITY, PRICE")
+	}
+
+	f, err := parseFilter(args)
+	if err != nil {
+		return netmap.NodeFilter{}, err
+	}
+	return netmap.NewNodeFilter(netmap.NodeField(field), f.F)
+}
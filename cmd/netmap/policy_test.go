@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	gs, err := parsePolicy(`SELECT 2 Country DISTINCT DC; select 1 Node same City
+SELECT 50% City; SELECT all Rack
+FILTER Location NE Asia
+FILTER Price RANGE 1 10
+EXCLUDE 1 2
+GROUP; SELECT 3 Node; SUBNET 5; NODEFILTER capacity GE 100`)
+	require.NoError(t, err)
+	require.Equal(t, []netmap.SFGroup{
+		{
+			Selectors: []netmap.Select{
+				{Count: 2, Key: "Country", Distinct: "DC"},
+				{Count: 1, Key: "Node", Same: "City"},
+				{Percent: 50, Key: "City"},
+				{Count: netmap.CountAll, Key: "Rack"},
+			},
+			Filters: []netmap.Filter{
+				{Key: "Location", F: netmap.FilterNE("Asia")},
+				{Key: "Price", F: netmap.FilterRange(1, 10)},
+			},
+			Exclude: []uint32{1, 2},
+		},
+		{
+			Selectors: []netmap.Select{{Count: 3, Key: "Node"}},
+			Subnet:    5,
+			NodeFilters: []netmap.NodeFilter{
+				{Field: netmap.NodeField_CAPACITY, F: netmap.NewFilter(netmap.Operation_GE, "100")},
+			},
+		},
+	}, gs)
+
+	t.Run("named filters", func(t *testing.T) {
+		gs, err := parsePolicy(`FILTER Location EQ Europe AS EU
+			FILTER @EU AS F1; FILTER Price LE 10 AS F1
+			SELECT 2 Country FROM F1; FILTER Country NE Germany
+			GROUP; SELECT 1 Node FROM EU`)
+		require.NoError(t, err)
+		require.Equal(t, []netmap.SFGroup{
+			{
+				Selectors: []netmap.Select{{Count: 2, Key: "Country"}},
+				Filters: []netmap.Filter{
+					{Key: "Price", F: netmap.FilterLE(10)},
+					{Key: "Location", F: netmap.FilterEQ("Europe")},
+					{Key: "Country", F: netmap.FilterNE("Germany")},
+				},
+			},
+			{
+				Selectors: []netmap.Select{{Count: 1, Key: "Node"}},
+				Filters:   []netmap.Filter{{Key: "Location", F: netmap.FilterEQ("Europe")}},
+			},
+		}, gs)
+	})
+
+	for _, s := range []string{
+		"",
+		"SELECT 1 Node; GROUP",
+		"SELECT x Node",
+		"SELECT 101% Node",
+		"SELECT x% Node",
+		"SELECT 1 Node UNIQUE DC",
+		"SELECT 1 Node; FILTER Country XX Germany",
+		"SELECT 1 Node; FILTER Price RANGE 1",
+		"SELECT 1 Node; EXCLUDE a",
+		"SELECT 1 Node; SUBNET",
+		"SELECT 1 Node; NODEFILTER Weight GE 1",
+		"SELECT 1 Node; NODEFILTER Capacity GE lots",
+		"SELECT 1 Node; NODEFILTER",
+		"SELECT 1 Node FROM F1",
+		"SELECT 1 Node FROM F1; SELECT 1 Node FROM F2; FILTER A EQ 1 AS F1; FILTER A EQ 2 AS F2",
+		"SELECT 1 Node FROM F1; FILTER @F2 AS F1; FILTER @F1 AS F2",
+		"SELECT 1 Node; FILTER @F1 A AS F2",
+		"CHOOSE 1 Node",
+	} {
+		_, err := parsePolicy(s)
+		require.Error(t, err, s)
+	}
+}
@@ -39,6 +39,12 @@ Example:
 [13 14]`,
 		Func: getSelection,
 	},
+	{
+		Name:     "explain-selection",
+		Help:     "show how current selection rules are applied",
+		LongHelp: "Usage: explain-selection",
+		Func:     explainSelection,
+	},
 	{
 		Name:     "clear-selection",
 		Help:     "clear selection rules",
@@ -82,7 +88,7 @@ Example:
 	{
 		Name: "select",
 		Help: "add SELECT placement rule",
-		LongHelp: `Usage: select <number> <key>
+		LongHelp: `Usage: select <number> <key> [distinct <key>] [same <key>]
 
 Example:
 >>> add 1 /Location:Europe/Country:Germany
@@ -90,19 +96,22 @@ Example:
 >>> add 2 /Location:Asia/Country:Korea
 >>> add 2 /Location:Asia/Country:Japan
 >>> select 1 Location
->>> select 2 Country`,
+>>> select 2 Country distinct Location
+>>> select 2 Node same Location`,
 		Func: addSelect,
 	},
 	{
 		Name: "filter",
 		Help: "add FILTER placement rule",
 		LongHelp: `Usage: filter <key> <operation> <value>
+       filter <key> RANGE <from> <to>
 Operation can be one of EQ, NE, LT, LE, GT, GE
 
 Example:
 >>> add 1 /Location:Europe/Country:Germany
 >>> add 2 /Location:Europe/Country:Austria
 >>> filter Country NE Austria
+>>> filter Price RANGE 1 10
 `,
 		Func: addFilter,
 	},
@@ -164,6 +173,17 @@ func getSelection(c *ishell.Context) {
 	c.Println(nil)
 }
 
+func explainSelection(c *ishell.Context) {
+	s := getState(c)
+	t, err := s.b.ExplainSelection(defaultSource, netmap.SFGroup{Selectors: s.ss, Filters: s.fs})
+	c.Print(t)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	c.Println(t.Nodes)
+}
+
 func clearSelection(c *ishell.Context) {
 	s := getState(c)
 	s.ss = nil
@@ -247,7 +267,7 @@ func addNode(c *ishell.Context) {
 }
 
 func addSelect(c *ishell.Context) {
-	if len(c.Args) != 2 {
+	if len(c.Args) < 2 || len(c.Args)%2 != 0 {
 		c.Err(errWrongFormat)
 		return
 	}
@@ -256,11 +276,23 @@ func addSelect(c *ishell.Context) {
 		c.Err(errors.Wrapf(err, "count must be integer"))
 		return
 	}
-	s := getState(c)
-	s.ss = append(s.ss, netmap.Select{
+	sel := netmap.Select{
 		Key:   c.Args[1],
 		Count: uint32(count),
-	})
+	}
+	for i := 2; i < len(c.Args); i += 2 {
+		switch c.Args[i] {
+		case "distinct":
+			sel.Distinct = c.Args[i+1]
+		case "same":
+			sel.Same = c.Args[i+1]
+		default:
+			c.Err(errWrongFormat)
+			return
+		}
+	}
+	s := getState(c)
+	s.ss = append(s.ss, sel)
 }
 
 func addFilter(c *ishell.Context) {
@@ -270,13 +302,31 @@ func addFilter(c *ishell.Context) {
 	}
 	op, ok := netmap.Operation_value[c.Args[1]]
 	if !ok {
-		c.Err(errors.New("operation must be one of: EQ, NE, LT, LE, GT, GE"))
+		c.Err(errors.New("operation must be one of: EQ, NE, LT, LE, GT, GE, RANGE"))
 		return
 	}
+	f := netmap.NewFilter(netmap.Operation(op), c.Args[2])
+	if netmap.Operation(op) == netmap.Operation_RANGE {
+		if len(c.Args) != 4 {
+			c.Err(errWrongFormat)
+			return
+		}
+		from, err := strconv.ParseFloat(c.Args[2], 64)
+		if err != nil {
+			c.Err(errors.Wrapf(err, "range bounds must be numbers"))
+			return
+		}
+		to, err := strconv.ParseFloat(c.Args[3], 64)
+		if err != nil {
+			c.Err(errors.Wrapf(err, "range bounds must be numbers"))
+			return
+		}
+		f = netmap.FilterRange(from, to)
+	}
 	s := getState(c)
 	s.fs = append(s.fs, netmap.Filter{
 		Key: c.Args[0],
-		F:   netmap.NewFilter(netmap.Operation(op), c.Args[2]),
+		F:   f,
 	})
 }
 
@@ -0,0 +1,46 @@
+package netmap
+
+// CompactSeparator joins keys and values of buckets collapsed by Compact.
+const CompactSeparator = "+"
+
+// Prune removes buckets without nodes and children from b recursively.
+func (b *Bucket) Prune() {
+	b.ownChildren()
+	cs := b.children[:0]
+	for i := range b.children {
+		b.children[i].Prune()
+		if len(b.children[i].nodes) != 0 || len(b.children[i].children) != 0 {
+			cs = append(cs, b.children[i])
+		}
+	}
+	for i := len(cs); i < len(b.children); i++ {
+		b.children[i] = Bucket{}
+	}
+	b.children = cs
+}
+
+// Compact collapses chains of buckets having a single child into one bucket
+// with keys and values joined by CompactSeparator, e.g. Location:Europe with
+// the only child Country:Germany becomes Location+Country:Europe+Germany.
+// Root bucket is never collapsed. As keys of collapsed buckets change,
+// placement rules must refer to combined keys after compaction.
+func (b *Bucket) Compact() {
+	b.ownChildren()
+	for i := range b.children {
+		b.children[i].compact()
+	}
+}
+
+func (b *Bucket) compact() {
+	b.ownChildren()
+	for i := range b.children {
+		b.children[i].compact()
+	}
+	if len(b.children) == 1 {
+		c := b.children[0]
+		b.Key += CompactSeparator + c.Key
+		b.Value += CompactSeparator + c.Value
+		b.nodes = merge(b.nodes, c.nodes)
+		b.children = c.children
+	}
+}
@@ -0,0 +1,51 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Prune(t *testing.T) {
+	b, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	expected := b.Copy()
+	b.AddChild(Bucket{Key: "Location", Value: "America"})
+	b.children[0].children = append(b.children[0].children,
+		Bucket{Key: "Country", Value: "Spain", children: []Bucket{{Key: "City", Value: "Madrid"}}})
+
+	b.Prune()
+	require.True(t, expected.DeepEqual(b))
+	require.Len(t, b.Children(), 2)
+
+	empty := Bucket{children: []Bucket{{Key: "Location", Value: "Europe"}}}
+	empty.Prune()
+	require.Empty(t, empty.Children())
+}
+
+func TestBucket_Compact(t *testing.T) {
+	b, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany/City:Berlin", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain/City:Madrid", []uint32{3}},
+		bucket{"/Location:Asia/Country:China/City:Beijing", []uint32{4}},
+	)
+	require.NoError(t, err)
+
+	nodes := b.Nodelist()
+	b.Compact()
+	require.Equal(t, nodes, b.Nodelist())
+	require.True(t, b.IsValid())
+	require.Equal(t, `/ (4)
+  Location:Europe (3)
+    Country+City:Germany+Berlin (2) [1 2]
+    Country+City:Spain+Madrid (1) [3]
+  Location+Country+City:Asia+China+Beijing (1) [4]
+`, b.String())
+
+	ss := []Select{{Key: "Country+City", Count: 2}, {Key: NodesBucket, Count: 1}}
+	require.Len(t, b.GetSelection(ss, defaultPivot).Nodelist(), 2)
+}
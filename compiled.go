@@ -0,0 +1,136 @@
+package netmap
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// CompiledPolicy is a placement rule prepared for evaluation on the
+	// netmap it was compiled for. Filters are resolved and maximal selections
+	// are computed once, so that every placement only performs pseudo-random
+	// selection. It must be compiled again after the netmap changes.
+	CompiledPolicy struct {
+		groups []compiledGroup
+		opts   []SelectOption
+	}
+
+	compiledGroup struct {
+		selectors []Select
+		// max is nil if the group can't be satisfied.
+		max *Bucket
+		// newParams returns selection parameters for the pivot
+		// configured by options of the placement.
+		newParams func(pivot []byte, opts []SelectOption) selectParams
+	}
+)
+
+// CompilePolicy validates ss and prepares it for evaluation on b using
+// provided selection options. Every group must contain at least one Select
+// clause, counts must be positive, percentages must not exceed 100 and
+// Select of nodes can be only the last one.
+func (b *Bucket) CompilePolicy(ss []SFGroup, opts ...SelectOption) (*CompiledPolicy, error) {
+	if len(ss) == 0 {
+		return nil, errors.New("policy has no selection groups")
+	}
+	for i := range ss {
+		if err := checkSelectors(ss[i].Selectors); err != nil {
+			return nil, errors.Wrapf(err, "group %d", i)
+		}
+	}
+	if err := b.schema.CheckGroups(ss...); err != nil {
+		return nil, err
+	}
+	return b.compile(ss, opts...), nil
+}
+
+// checkSelectors checks that selection ss is well-formed.
+func checkSelectors(ss []Select) error {
+	if len(ss) == 0 {
+		return errors.New("no SELECT clauses")
+	}
+	for i := range ss {
+		switch {
+		case ss[i].Key == "":
+			return errors.Errorf("SELECT clause %d has no key", i)
+		case ss[i].Count == 0 && ss[i].Percent == 0:
+			return errors.Errorf("SELECT clause %d has zero count", i)
+		case ss[i].Percent > 100:
+			return errors.Errorf("SELECT clause %d has percentage over 100", i)
+		case ss[i].Key == NodesBucket && i != len(ss)-1:
+			return errors.Errorf("SELECT clause %d of nodes is not the last one", i)
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) compile(ss []SFGroup, opts ...SelectOption) *CompiledPolicy {
+	c := &CompiledPolicy{groups: make([]compiledGroup, len(ss)), opts: opts}
+	for i := range ss {
+		g := &c.groups[i]
+		g.selectors = ss[i].Selectors
+		if g.max = b.GetMaxSelection(ss[i]); g.max != nil {
+			g.newParams = b.paramsFactory(ss[i].Selectors, opts...)
+		}
+	}
+	return c
+}
+
+// paramsFactory returns function creating selection parameters for the
+// pivot, the same as newSelectParams does. Attribute values and local nodes
+// are resolved once using opts, stateful parts like quotas are set up by
+// options passed on every call.
+func (b *Bucket) paramsFactory(ss []Select, opts ...SelectOption) func([]byte, []SelectOption) selectParams {
+	base := newSelectParams(*b, ss, nil, 0, opts...)
+	return func(pivot []byte, opts []SelectOption) selectParams {
+		p := selectParams{values: base.values, local: base.local}
+		for _, o := range opts {
+			o(&p)
+		}
+		if p.shuffler == nil && len(pivot) != 0 {
+			p.shuffler = p.pivotShuffler(pivot)
+		}
+		return p
+	}
+}
+
+// Satisfiable checks whether every group of the policy has enough nodes
+// to be placed on, independent of the pivot.
+func (c *CompiledPolicy) Satisfiable() bool {
+	for i := range c.groups {
+		if c.groups[i].max == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Place returns nodes chosen for pivot, it is the same as calling
+// FindNodes on the netmap policy was compiled for.
+func (c *CompiledPolicy) Place(pivot []byte) (nodes Nodes) {
+	opts := forPlacement(c.opts)
+	for i := range c.groups {
+		nodes = merge(nodes, c.groups[i].place(pivot, opts))
+	}
+	return
+}
+
+// place returns nodes chosen for pivot in the group. Like findNodes,
+// it reports the selection to metrics.
+func (g *compiledGroup) place(pivot []byte, opts []SelectOption) Nodes {
+	var ok bool
+	defer observeSelection(time.Now(), &ok)
+
+	if g.max == nil {
+		return nil
+	}
+	r := g.max.selectWithFallback(g.selectors, func() selectParams {
+		return g.newParams(pivot, opts)
+	})
+	if r != nil {
+		ok = true
+		return r.Nodelist()
+	}
+	return nil
+}
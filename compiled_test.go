@@ -0,0 +1,63 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_CompilePolicy(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany/City:Berlin", []uint32{1, 2, 3}},
+		bucket{"/Location:Europe/Country:Spain/City:Madrid", []uint32{4, 5}},
+		bucket{"/Location:Asia/Country:China/City:Beijing", []uint32{6, 7, 8}},
+	)
+	require.NoError(t, err)
+
+	ss := []SFGroup{
+		{
+			Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}},
+			Filters:   []Filter{{Key: "Location", F: FilterEQ("Europe")}},
+		},
+		{Selectors: []Select{{Key: "City", Count: 1}, {Key: NodesBucket, Count: 2}}},
+	}
+
+	c, err := root.CompilePolicy(ss)
+	require.NoError(t, err)
+	require.True(t, c.Satisfiable())
+	for i := 0; i < 20; i++ {
+		pivot := []byte{byte(i)}
+		require.Equal(t, root.FindNodes(pivot, ss...), c.Place(pivot))
+	}
+
+	t.Run("options", func(t *testing.T) {
+		opts := []SelectOption{
+			WithLocality("/Location:Asia"),
+			WithQuota("Location", 3),
+			WithBacktracking(100),
+			WithJumpHash(),
+		}
+		c, err := root.CompilePolicy(ss, opts...)
+		require.NoError(t, err)
+		for i := 0; i < 20; i++ {
+			pivot := []byte{byte(i)}
+			require.Equal(t, root.FindNodesWith(pivot, ss, opts...), c.Place(pivot))
+		}
+	})
+
+	c, err = root.CompilePolicy([]SFGroup{{Selectors: []Select{{Key: "Country", Count: 4}}}})
+	require.NoError(t, err)
+	require.False(t, c.Satisfiable())
+	require.Empty(t, c.Place([]byte{1}))
+
+	for _, ss := range [][]SFGroup{
+		nil,
+		{{}},
+		{{Selectors: []Select{{Key: "Country"}}}},
+		{{Selectors: []Select{{Count: 1}}}},
+		{{Selectors: []Select{{Key: NodesBucket, Count: 1}, {Key: "Country", Count: 1}}}},
+	} {
+		_, err := root.CompilePolicy(ss)
+		require.Error(t, err)
+	}
+}
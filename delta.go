@@ -0,0 +1,368 @@
+package netmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Delta describes changes between two netmaps in terms of nodes attached
+	// to buckets. Nodes with changed capacity or price are both removed and added.
+	Delta struct {
+		Removed []DeltaEntry
+		Added   []DeltaEntry
+	}
+
+	// DeltaEntry contains nodes attached to the bucket located at Path.
+	DeltaEntry struct {
+		Path  string
+		Nodes Nodes
+	}
+)
+
+// Diff returns changes which must be applied to old to get b.
+func (b Bucket) Diff(old Bucket) *Delta {
+	var (
+		d  = new(Delta)
+		ol = old.pathNodes()
+		nl = b.pathNodes()
+	)
+
+	for _, p := range sortedKeys(ol) {
+		if ns := nodesDiff(ol[p], nl[p]); len(ns) != 0 {
+			d.Removed = append(d.Removed, DeltaEntry{Path: p, Nodes: ns})
+		}
+	}
+	for _, p := range sortedKeys(nl) {
+		if ns := nodesDiff(nl[p], ol[p]); len(ns) != 0 {
+			d.Added = append(d.Added, DeltaEntry{Path: p, Nodes: ns})
+		}
+	}
+	return d
+}
+
+// ApplyDelta applies changes from d to b. Leaf buckets left without nodes
+// are removed.
+func (b *Bucket) ApplyDelta(d *Delta) error {
+	for _, e := range d.Removed {
+		rm := make(map[uint32]struct{}, len(e.Nodes))
+		for _, n := range e.Nodes {
+			rm[n.N] = struct{}{}
+		}
+		if !b.removeNodes(splitPath(e.Path), rm) {
+			return errors.Wrapf(ErrBadBucketPath, "bucket %s not found", e.Path)
+		}
+	}
+
+	for _, e := range d.Added {
+		ns := sortedNodes(e.Nodes)
+		if e.Path == Separator {
+			b.nodes = merge(b.nodes, ns)
+		} else if err := b.AddBucket(e.Path, ns); err != nil {
+			return errors.Wrapf(err, "can't add bucket %s", e.Path)
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) removeNodes(path []Bucket, rm map[uint32]struct{}) bool {
+	if len(path) == 0 {
+		ns := make(Nodes, 0, len(b.nodes))
+		for _, n := range b.nodes {
+			if _, ok := rm[n.N]; !ok {
+				ns = append(ns, n)
+			}
+		}
+		b.nodes = ns
+		return true
+	}
+
+	own := b.ownNodes()
+	b.ownChildren()
+	for i := range b.children {
+		if !b.children[i].Equals(path[0]) {
+			continue
+		} else if !b.children[i].removeNodes(path[1:], rm) {
+			return false
+		}
+
+		if len(b.children[i].nodes) == 0 && len(b.children[i].children) == 0 {
+			b.children = append(b.children[:i], b.children[i+1:]...)
+		}
+
+		b.nodes = merge(own, childNodes(b.children))
+		return true
+	}
+	return false
+}
+
+// pathNodes returns nodes attached directly to every bucket of b indexed
+// by path. For leaf buckets these are all their nodes, inner buckets are
+// present only if they have nodes absent in their children.
+func (b Bucket) pathNodes() map[string]Nodes {
+	m := make(map[string]Nodes)
+	b.collectPathNodes("", m)
+	return m
+}
+
+func (b Bucket) collectPathNodes(prefix string, m map[string]Nodes) {
+	p := prefix
+	if p == "" {
+		p = Separator
+	}
+	if own := b.ownNodes(); len(own) != 0 || len(b.children) == 0 {
+		m[p] = merge(m[p], own)
+	}
+	for _, c := range b.children {
+		c.collectPathNodes(prefix+Separator+c.Name(), m)
+	}
+}
+
+// ownNodes returns nodes attached directly to b, i.e. absent in its children.
+func (b Bucket) ownNodes() Nodes {
+	if len(b.children) == 0 {
+		return b.nodes
+	}
+	return subtract(b.nodes, childNodes(b.children))
+}
+
+// nodesDiff returns nodes from a which are absent or differ in b.
+func nodesDiff(a, b Nodes) (r Nodes) {
+	m := make(map[uint32]Node, len(b))
+	for _, n := range b {
+		m[n.N] = n
+	}
+	for _, n := range a {
+		if bn, ok := m[n.N]; !ok || !bn.Equals(n) {
+			r = append(r, n)
+		}
+	}
+	return
+}
+
+func splitPath(p string) []Bucket {
+	if p = strings.Trim(p, Separator); p == "" {
+		return nil
+	}
+	return splitProps(p)
+}
+
+func sortedKeys(m map[string]Nodes) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// All numbers are encoded as unsigned varints.
+func (d Delta) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writeDeltaEntries(buf, d.Removed)
+	writeDeltaEntries(buf, d.Added)
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (d *Delta) UnmarshalBinary(data []byte) (err error) {
+	r := bytes.NewReader(data)
+	if d.Removed, err = readDeltaEntries(r); err != nil {
+		return errors.Wrap(err, "can't read removed nodes")
+	}
+	if d.Added, err = readDeltaEntries(r); err != nil {
+		return errors.Wrap(err, "can't read added nodes")
+	}
+	if r.Len() != 0 {
+		return errors.Wrap(ErrMalformedEncoding, "trailing data")
+	}
+	return nil
+}
+
+func writeDeltaEntries(buf *bytes.Buffer, es []DeltaEntry) {
+	putUvarint(buf, uint64(len(es)))
+	for _, e := range es {
+		putUvarint(buf, uint64(len(e.Path)))
+		buf.WriteString(e.Path)
+		putUvarint(buf, uint64(len(e.Nodes)))
+		for _, n := range e.Nodes {
+			putUvarint(buf, uint64(n.N))
+			putUvarint(buf, n.C)
+			putUvarint(buf, n.P)
+			putUvarint(buf, math.Float64bits(n.R))
+			putUvarint(buf, math.Float64bits(n.Coord.X))
+			putUvarint(buf, math.Float64bits(n.Coord.Y))
+			putUvarint(buf, uint64(len(n.PubKey)))
+			buf.Write(n.PubKey)
+			putUvarint(buf, uint64(len(n.Addresses)))
+			for _, a := range n.Addresses {
+				putUvarint(buf, uint64(len(a)))
+				buf.WriteString(a)
+			}
+			if len(n.Attrs) == 0 {
+				putUvarint(buf, uint64(len(n.Subnets)))
+			} else {
+				putUvarint(buf, uint64(len(n.Subnets))|attrsFlag)
+			}
+			for _, sn := range n.Subnets {
+				putUvarint(buf, uint64(sn))
+			}
+			if len(n.Attrs) == 0 {
+				continue
+			}
+
+			keys := n.attrKeys()
+			putUvarint(buf, uint64(len(keys)))
+			for _, k := range keys {
+				putUvarint(buf, uint64(len(k)))
+				buf.WriteString(k)
+				putUvarint(buf, uint64(len(n.Attrs[k])))
+				buf.WriteString(n.Attrs[k])
+			}
+		}
+	}
+}
+
+func readDeltaEntries(r *bytes.Reader) ([]DeltaEntry, error) {
+	ln, err := readLength(r)
+	if err != nil || ln == 0 {
+		return nil, err
+	}
+
+	es := make([]DeltaEntry, ln)
+	for i := range es {
+		p, err := readDeltaBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		es[i].Path = string(p)
+
+		if ln, err = readLength(r); err != nil {
+			return nil, err
+		}
+		es[i].Nodes = make(Nodes, ln)
+		for j := range es[i].Nodes {
+			n := &es[i].Nodes[j]
+			num, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			} else if num > math.MaxUint32 {
+				return nil, errors.Wrap(ErrMalformedEncoding, "node index overflow")
+			}
+			n.N = uint32(num)
+			if n.C, err = binary.ReadUvarint(r); err != nil {
+				return nil, err
+			}
+			if n.P, err = binary.ReadUvarint(r); err != nil {
+				return nil, err
+			}
+			rep, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			n.R = math.Float64frombits(rep)
+			for _, c := range []*float64{&n.Coord.X, &n.Coord.Y} {
+				v, err := binary.ReadUvarint(r)
+				if err != nil {
+					return nil, err
+				}
+				*c = math.Float64frombits(v)
+			}
+			if n.PubKey, err = readDeltaBytes(r); err != nil {
+				return nil, err
+			}
+			if ln, err = readLength(r); err != nil {
+				return nil, err
+			}
+			for k := 0; k < ln; k++ {
+				a, err := readDeltaBytes(r)
+				if err != nil {
+					return nil, err
+				}
+				n.Addresses = append(n.Addresses, string(a))
+			}
+			sl, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			attrs := sl&attrsFlag != 0
+			if sl &^= attrsFlag; sl > uint64(r.Len()) {
+				return nil, errors.Wrap(ErrMalformedEncoding, "invalid length")
+			}
+			for k := uint64(0); k < sl; k++ {
+				sn, err := binary.ReadUvarint(r)
+				if err != nil {
+					return nil, err
+				} else if sn > math.MaxUint32 {
+					return nil, errors.Wrap(ErrMalformedEncoding, "subnet overflow")
+				}
+				n.Subnets = append(n.Subnets, uint32(sn))
+			}
+			if attrs {
+				if n.Attrs, err = readDeltaAttrs(r); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return es, nil
+}
+
+func readDeltaAttrs(r *bytes.Reader) (map[string]string, error) {
+	ln, err := readLength(r)
+	if err != nil {
+		return nil, err
+	} else if ln == 0 {
+		return nil, errors.Wrap(ErrMalformedEncoding, "invalid number of attributes")
+	}
+
+	attrs := make(map[string]string)
+	for i := 0; i < ln; i++ {
+		k, err := readDeltaBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readDeltaBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		attrs[string(k)] = string(v)
+	}
+	return attrs, nil
+}
+
+func readDeltaBytes(r *bytes.Reader) ([]byte, error) {
+	ln, err := readLength(r)
+	if err != nil || ln == 0 {
+		return nil, err
+	}
+
+	data := make([]byte, ln)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readLength reads collection length, which can't exceed the remaining data size.
+func readLength(r *bytes.Reader) (int, error) {
+	ln, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	} else if ln > uint64(r.Len()) {
+		return 0, errors.Wrap(ErrMalformedEncoding, "invalid length")
+	}
+	return int(ln), nil
+}
+
+func putUvarint(buf *bytes.Buffer, x uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], x)])
+}
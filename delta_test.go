@@ -0,0 +1,125 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Diff(t *testing.T) {
+	old, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3}},
+		bucket{"/Location:Asia/Country:China", []uint32{4, 5}},
+	)
+	require.NoError(t, err)
+
+	cur := old.Copy()
+	d := cur.Diff(old)
+	require.Empty(t, d.Added)
+	require.Empty(t, d.Removed)
+
+	// node 4 changed its capacity
+	cur, err = newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 2}, {N: 6, C: 7}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 4, C: 100}, {N: 5, C: 6}}},
+		strawBucket{"/Location:Asia/Country:Japan", Nodes{{N: 7, C: 8}}},
+	)
+	require.NoError(t, err)
+
+	d = cur.Diff(old)
+	require.Equal(t, []DeltaEntry{
+		{Path: "/Location:Asia/Country:China", Nodes: Nodes{{N: 4, C: 100}}},
+		{Path: "/Location:Asia/Country:Japan", Nodes: Nodes{{N: 7, C: 8}}},
+		{Path: "/Location:Europe/Country:Germany", Nodes: Nodes{{N: 6, C: 7}}},
+	}, d.Added)
+	require.Len(t, d.Removed, 3)
+
+	t.Run("apply", func(t *testing.T) {
+		b := old.Copy()
+		require.NoError(t, b.ApplyDelta(d))
+		require.Equal(t, cur.Digest(), b.Digest())
+		require.Empty(t, b.Query("/Location:Europe/Country:Spain"))
+		require.Equal(t, cur.Nodelist(), b.Nodelist())
+	})
+
+	t.Run("marshal", func(t *testing.T) {
+		data, err := d.MarshalBinary()
+		require.NoError(t, err)
+
+		full, err := cur.MarshalBinary()
+		require.NoError(t, err)
+		require.True(t, len(data) < len(full))
+
+		d1 := new(Delta)
+		require.NoError(t, d1.UnmarshalBinary(data))
+		require.Equal(t, d, d1)
+
+		require.Error(t, d1.UnmarshalBinary(data[:len(data)-1]))
+		require.Error(t, d1.UnmarshalBinary(append(data, 0)))
+	})
+
+	t.Run("node metadata", func(t *testing.T) {
+		b := cur.Copy()
+		require.NoError(t, b.AddBucket("/Location:Asia/Country:Japan", Nodes{{
+			N:         8,
+			PubKey:    []byte{3, 1, 2},
+			Addresses: []string{"/ip4/10.0.0.8/tcp/8080"},
+		}}))
+
+		d := b.Diff(cur)
+		require.Len(t, d.Added, 1)
+
+		data, err := d.MarshalBinary()
+		require.NoError(t, err)
+
+		d1 := new(Delta)
+		require.NoError(t, d1.UnmarshalBinary(data))
+		require.Equal(t, d, d1)
+	})
+
+	t.Run("missing bucket", func(t *testing.T) {
+		b := old.Copy()
+		err := b.ApplyDelta(&Delta{Removed: []DeltaEntry{{Path: "/Location:Africa", Nodes: Nodes{{N: 1}}}}})
+		require.Error(t, err)
+	})
+}
+
+func TestBucket_DiffInnerNodes(t *testing.T) {
+	var old Bucket
+	require.NoError(t, old.AddBucket("/Location:Europe", Nodes{{N: 5}}))
+	require.NoError(t, old.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 6}}))
+	require.Equal(t, []uint32{5, 6}, old.Nodelist().Nodes())
+
+	var cur Bucket
+	require.NoError(t, cur.AddBucket("/Location:Europe", Nodes{{N: 5}}))
+	require.NoError(t, cur.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 8}}))
+
+	d := cur.Diff(old)
+	require.Equal(t, []DeltaEntry{{Path: "/Location:Europe/Country:DE", Nodes: Nodes{{N: 6}}}}, d.Removed)
+	require.Equal(t, []DeltaEntry{{Path: "/Location:Europe/Country:DE", Nodes: Nodes{{N: 8}}}}, d.Added)
+
+	b := old.Copy()
+	require.NoError(t, b.ApplyDelta(d))
+	require.Equal(t, []uint32{5, 8}, b.Nodelist().Nodes())
+	require.Equal(t, cur.Digest(), b.Digest())
+
+	// node attached to the inner bucket is removed and added as well
+	d = old.Diff(cur)
+	b = cur.Copy()
+	require.NoError(t, b.ApplyDelta(d))
+	require.Equal(t, []uint32{5, 6}, b.Nodelist().Nodes())
+
+	d = Bucket{}.Diff(old)
+	require.Equal(t, []DeltaEntry{
+		{Path: "/Location:Europe", Nodes: Nodes{{N: 5}}},
+		{Path: "/Location:Europe/Country:DE", Nodes: Nodes{{N: 6}}},
+	}, d.Removed)
+	b = old.Copy()
+	require.NoError(t, b.ApplyDelta(d))
+	require.Empty(t, b.Nodelist())
+
+	b = Bucket{}
+	require.NoError(t, b.ApplyDelta(old.Diff(Bucket{})))
+	require.Equal(t, old.Digest(), b.Digest())
+}
@@ -0,0 +1,65 @@
+package netmap
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// DrainReport lists containers which must migrate off the draining node.
+type DrainReport struct {
+	Node uint32
+	// Containers are pivots of containers having the node in their
+	// current placement, in the order they were passed.
+	Containers [][]byte
+}
+
+// Draining returns indices of all draining nodes in ascending order.
+func (m *NetMap) Draining() []uint32 {
+	var ns []uint32
+	for n, info := range m.nodes {
+		if info.state == NodeDraining {
+			ns = append(ns, n)
+		}
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+	return ns
+}
+
+// ExcludeDraining returns copy of ss in which draining nodes are excluded
+// from every group. Draining nodes are not present in m.Root(), so it is
+// needed only for new selections in m.RootWithDraining().
+func (m *NetMap) ExcludeDraining(ss ...SFGroup) []SFGroup {
+	var (
+		ns  = m.Draining()
+		res = make([]SFGroup, len(ss))
+	)
+	for i := range ss {
+		res[i] = ss[i]
+		if len(ns) != 0 {
+			res[i].Exclude = append(append([]uint32(nil), ss[i].Exclude...), ns...)
+		}
+	}
+	return res
+}
+
+// DrainReport places every container pivot according to ss in the current
+// netmap and returns ones placed on draining node n.
+func (m *NetMap) DrainReport(n uint32, pivots [][]byte, ss ...SFGroup) (DrainReport, error) {
+	r := DrainReport{Node: n}
+	if info, ok := m.nodes[n]; !ok {
+		return r, errors.Errorf("node %d not found", n)
+	} else if info.state != NodeDraining {
+		return r, errors.Errorf("node %d is %s, not draining", n, info.state)
+	}
+
+	for _, pivot := range pivots {
+		for _, node := range m.all.FindNodes(pivot, ss...) {
+			if node.N == n {
+				r.Containers = append(r.Containers, pivot)
+				break
+			}
+		}
+	}
+	return r, nil
+}
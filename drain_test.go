@@ -0,0 +1,118 @@
+package netmap
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetMap_Draining(t *testing.T) {
+	m := NewNetMap()
+	for i := uint32(1); i <= 6; i++ {
+		require.NoError(t, m.Apply(Event{
+			Type:    NodeAdded,
+			Node:    Node{N: i, C: 10},
+			Options: []string{"/Location:L" + strconv.Itoa(int(i%3))},
+		}))
+	}
+
+	var (
+		ss     = []SFGroup{{Selectors: []Select{{Key: NodesBucket, Count: 2}}}}
+		pivots = make([][]byte, 20)
+	)
+	for i := range pivots {
+		pivots[i] = []byte(strconv.Itoa(i))
+	}
+
+	_, err := m.DrainReport(2, pivots, ss...)
+	require.Error(t, err)
+	_, err = m.DrainReport(10, pivots, ss...)
+	require.Error(t, err)
+	require.Equal(t, ss, m.ExcludeDraining(ss...))
+
+	require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeDraining}))
+	_, st, _ := m.Node(2)
+	require.Equal(t, NodeDraining, st)
+	require.Equal(t, "draining", st.String())
+	require.Equal(t, []uint32{2}, m.Draining())
+
+	// draining node stays in the netmap, but isn't chosen anymore
+	root, all := m.Root(), m.RootWithDraining()
+	require.Len(t, root.Nodelist(), 5)
+	require.Len(t, all.Nodelist(), 6)
+	gs := m.ExcludeDraining(ss...)
+	require.Equal(t, []uint32{2}, gs[0].Exclude)
+	require.Empty(t, ss[0].Exclude)
+
+	r, err := m.DrainReport(2, pivots, ss...)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), r.Node)
+	require.NotEmpty(t, r.Containers)
+
+	for _, pivot := range pivots {
+		var placed bool
+		for _, n := range all.FindNodes(pivot, ss...) {
+			placed = placed || n.N == 2
+		}
+		require.Equal(t, placed, containsPivot(r.Containers, pivot), string(pivot))
+
+		for _, ns := range []Nodes{root.FindNodes(pivot, ss...), all.FindNodes(pivot, gs...)} {
+			require.Len(t, ns, 2)
+			for _, n := range ns {
+				require.NotEqual(t, uint32(2), n.N)
+			}
+		}
+	}
+
+	// node leaves the tree of existing placements only when set offline
+	require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeOffline}))
+	require.Len(t, m.Root().Nodelist(), 5)
+	require.Len(t, m.RootWithDraining().Nodelist(), 5)
+	require.Empty(t, m.Draining())
+	require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeDraining}))
+	require.Len(t, m.Root().Nodelist(), 5)
+	require.Len(t, m.RootWithDraining().Nodelist(), 6)
+	require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeOnline}))
+	require.Len(t, m.Root().Nodelist(), 6)
+	require.Len(t, m.RootWithDraining().Nodelist(), 6)
+}
+
+func TestNetMap_DrainingExpired(t *testing.T) {
+	var (
+		m   = NewNetMap()
+		old = time.Now().Add(-time.Minute)
+	)
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 1}, Options: []string{"/Location:Europe"}, Time: old}))
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 2}, Options: []string{"/Location:Europe"}, Time: old}))
+	require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeDraining}))
+
+	// silent draining node goes offline too
+	evs := m.ExpireStale(40 * time.Second)
+	require.Len(t, evs, 2)
+	require.Equal(t, uint32(2), evs[1].Node.N)
+	require.Equal(t, NodeOffline, evs[1].State)
+	require.Empty(t, m.RootWithDraining().Nodelist())
+
+	// and is draining again after heartbeat
+	require.NoError(t, m.Apply(Event{Type: Heartbeat, Node: Node{N: 2}}))
+	_, st, _ := m.Node(2)
+	require.Equal(t, NodeDraining, st)
+	require.Empty(t, m.Root().Nodelist())
+	require.Equal(t, []uint32{2}, m.RootWithDraining().Nodelist().Nodes())
+
+	require.NoError(t, m.Apply(Event{Type: Heartbeat, Node: Node{N: 1}}))
+	_, st, _ = m.Node(1)
+	require.Equal(t, NodeOnline, st)
+	require.Equal(t, []uint32{1}, m.Root().Nodelist().Nodes())
+}
+
+func containsPivot(ps [][]byte, p []byte) bool {
+	for i := range ps {
+		if string(ps[i]) == string(p) {
+			return true
+		}
+	}
+	return false
+}
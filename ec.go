@@ -0,0 +1,73 @@
+package netmap
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ECRule describes placement of erasure-coded object split
+// into Data data parts and Parity parity parts.
+type ECRule struct {
+	Data   uint32
+	Parity uint32
+	// Domain is the key of failure domain buckets (e.g. Rack), every
+	// part is stored in a separate domain. If empty, parts are only
+	// stored on different nodes.
+	Domain string
+	// Group contains filters, excluded nodes and subnet applied to
+	// the netmap, its selectors are ignored.
+	Group SFGroup
+}
+
+// Slots returns total number of parts.
+func (r ECRule) Slots() int {
+	return int(r.Data + r.Parity)
+}
+
+// IsParity checks whether slot i contains parity part.
+func (r ECRule) IsParity(i int) bool {
+	return i >= int(r.Data) && i < r.Slots()
+}
+
+// group returns selection group choosing nodes for all parts.
+func (r ECRule) group() SFGroup {
+	s := r.Group
+	if r.Domain == "" || r.Domain == NodesBucket {
+		s.Selectors = []Select{{Key: NodesBucket, Count: uint32(r.Slots())}}
+	} else {
+		s.Selectors = []Select{
+			{Key: r.Domain, Count: uint32(r.Slots())},
+			{Key: NodesBucket, Count: 1},
+		}
+	}
+	return s
+}
+
+// FindECSlots returns nodes storing parts of erasure-coded object according
+// to r. Node at index i stores i-th part: first r.Data slots contain data
+// parts and the rest contain parity parts. Assignment of nodes to slots is
+// determined by pivot, so that every client computes the same one.
+// If the rule can't be satisfied, ErrNotEnoughNodes is returned.
+func (b *Bucket) FindECSlots(pivot []byte, r ECRule, opts ...SelectOption) (Nodes, error) {
+	if r.Slots() == 0 {
+		return nil, errors.New("erasure coding rule has no parts")
+	}
+
+	s := r.group()
+	if err := b.schema.CheckGroups(s); err != nil {
+		return nil, err
+	}
+
+	opts = forPlacement(opts)
+	ns := b.findNodes(pivot, s, opts...)
+	if len(ns) != r.Slots() {
+		return nil, errors.Wrapf(ErrNotEnoughNodes, "%d of %d slots can be filled", len(ns), r.Slots())
+	}
+
+	slots := make(Nodes, len(ns))
+	copy(slots, ns)
+	sort.Sort(slots)
+	newSelectParams(*b, nil, pivot, 0, opts...).shuffleNodes(slots)
+	return slots, nil
+}
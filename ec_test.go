@@ -0,0 +1,64 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_FindECSlots(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Rack:1", []uint32{1, 2}},
+		bucket{"/Rack:2", []uint32{3, 4}},
+		bucket{"/Rack:3", []uint32{5, 6}},
+		bucket{"/Rack:4", []uint32{7, 8}},
+		bucket{"/Rack:5", []uint32{9}},
+	)
+	require.NoError(t, err)
+
+	r := ECRule{Data: 3, Parity: 2, Domain: "Rack"}
+	require.Equal(t, 5, r.Slots())
+	require.False(t, r.IsParity(2))
+	require.True(t, r.IsParity(3))
+	require.False(t, r.IsParity(5))
+
+	slots, err := root.FindECSlots([]byte("pivot"), r)
+	require.NoError(t, err)
+	require.Len(t, slots, 5)
+
+	racks := root.nodeValues("Rack")
+	used := make(map[string]struct{})
+	for _, n := range slots {
+		used[racks[n.N]] = struct{}{}
+	}
+	require.Len(t, used, 5)
+
+	// the same pivot gives the same assignment
+	slots1, err := root.FindECSlots([]byte("pivot"), r)
+	require.NoError(t, err)
+	require.Equal(t, slots, slots1)
+
+	t.Run("nodes", func(t *testing.T) {
+		slots, err := root.FindECSlots([]byte("pivot"), ECRule{Data: 6, Parity: 3})
+		require.NoError(t, err)
+		require.Len(t, slots, 9)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		r := ECRule{Data: 2, Parity: 1, Domain: "Rack", Group: SFGroup{Exclude: []uint32{1, 2, 3, 4}}}
+		slots, err := root.FindECSlots([]byte("pivot"), r)
+		require.NoError(t, err)
+		for _, n := range slots {
+			require.True(t, n.N > 4)
+		}
+	})
+
+	t.Run("not enough domains", func(t *testing.T) {
+		_, err := root.FindECSlots([]byte("pivot"), ECRule{Data: 4, Parity: 2, Domain: "Rack"})
+		require.True(t, errors.Is(err, ErrNotEnoughNodes))
+
+		_, err = root.FindECSlots([]byte("pivot"), ECRule{})
+		require.Error(t, err)
+	})
+}
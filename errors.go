@@ -0,0 +1,24 @@
+package netmap
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Errors returned by the package are wrapped with the context of the failure,
+// use errors.Is or errors.Cause to compare them with these values.
+var (
+	// ErrNotEnoughNodes is returned when the netmap has not enough nodes
+	// or buckets to satisfy placement rule.
+	ErrNotEnoughNodes = errors.New("not enough nodes")
+
+	// ErrBadBucketPath is returned when bucket path or option has invalid
+	// format or refers to missing bucket.
+	ErrBadBucketPath = errors.New("bad bucket path")
+
+	// ErrMalformedEncoding is returned when binary data can't be decoded.
+	ErrMalformedEncoding = errors.New("malformed encoding")
+
+	// ErrSchemaViolation is returned when option or placement rule
+	// doesn't match the schema attached to the bucket, see WithSchema.
+	ErrSchemaViolation = errors.New("schema violation")
+)
@@ -0,0 +1,60 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	t.Run("not enough nodes", func(t *testing.T) {
+		ss := []SFGroup{{Selectors: []Select{{Key: "Country", Count: 3}, {Key: NodesBucket, Count: 1}}}}
+
+		_, err := root.FindGraphStrict(defaultPivot, ss...)
+		require.True(t, errors.Is(err, ErrNotEnoughNodes))
+
+		_, err = Policy{ReplFactor: 4}.Apply(&root, defaultPivot)
+		require.True(t, errors.Is(err, ErrNotEnoughNodes))
+		require.Equal(t, ErrNotEnoughNodes, errors.Cause(err))
+
+		_, err = root.ExplainSelection(defaultPivot, ss...)
+		require.True(t, errors.Is(err, ErrNotEnoughNodes))
+	})
+
+	t.Run("bad bucket path", func(t *testing.T) {
+		b := root.Copy()
+		err := b.AddNode(4, "Location:Europe")
+		require.True(t, errors.Is(err, ErrBadBucketPath))
+
+		err = b.AddNodes([]NodeEntry{{Node: Node{N: 4}, Options: []string{"/Country:Spain/"}}})
+		require.True(t, errors.Is(err, ErrBadBucketPath))
+
+		err = b.ApplyDelta(&Delta{Removed: []DeltaEntry{{Path: "/Country:Spain", Nodes: Nodes{{N: 4}}}}})
+		require.True(t, errors.Is(err, ErrBadBucketPath))
+	})
+
+	t.Run("malformed encoding", func(t *testing.T) {
+		data, err := root.MarshalBinary()
+		require.NoError(t, err)
+		data[0] = 0x80 // negative name length
+		require.True(t, errors.Is(new(Bucket).UnmarshalBinary(data), ErrMalformedEncoding))
+
+		data, err = root.MarshalBinaryVersion(FormatV2)
+		require.NoError(t, err)
+		data[1] = FormatV2 + 1
+		require.True(t, errors.Is(new(Bucket).UnmarshalBinary(data), ErrMalformedEncoding))
+
+		data = root.MarshalStackItem()
+		data[0] = 0xFE
+		require.True(t, errors.Is(new(Bucket).UnmarshalStackItem(data), ErrMalformedEncoding))
+
+		require.True(t, errors.Is(new(Delta).UnmarshalBinary([]byte{0, 0, 1}), ErrMalformedEncoding))
+	})
+}
@@ -0,0 +1,164 @@
+package netmap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Trace is a step-by-step record of selection.
+	Trace struct {
+		Steps []TraceStep
+		Nodes Nodes
+	}
+
+	// TraceStep describes single decision made during selection.
+	TraceStep struct {
+		// Group is an index of SFGroup being processed.
+		Group  int
+		Action TraceAction
+		// Bucket is a name of the bucket decision is made about.
+		Bucket string
+		Nodes  []uint32
+		Reason string
+	}
+
+	// TraceAction is a kind of decision made during selection.
+	TraceAction int
+)
+
+const (
+	// TraceConsidered means that bucket is a candidate for selection.
+	TraceConsidered TraceAction = iota
+	// TraceFilteredOut means that bucket or nodes were removed by filters.
+	TraceFilteredOut
+	// TraceChosen means that bucket or nodes were chosen.
+	TraceChosen
+	// TraceRejected means that bucket can't satisfy selection.
+	TraceRejected
+)
+
+// String implements fmt.Stringer interface.
+func (a TraceAction) String() string {
+	switch a {
+	case TraceConsidered:
+		return "considered"
+	case TraceFilteredOut:
+		return "filtered out"
+	case TraceChosen:
+		return "chosen"
+	case TraceRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// String returns human-readable representation of t, one step per line.
+func (t *Trace) String() string {
+	var sb strings.Builder
+	for _, s := range t.Steps {
+		fmt.Fprintf(&sb, "[%d] %s %s", s.Group, s.Action, s.Bucket)
+		if len(s.Nodes) != 0 {
+			fmt.Fprintf(&sb, " %v", s.Nodes)
+		}
+		if s.Reason != "" {
+			fmt.Fprintf(&sb, ": %s", s.Reason)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ExplainSelection performs the same selection as FindNodes and returns
+// record of all buckets which were considered, filtered out and chosen.
+// If selection fails, trace is returned along with an error.
+func (b *Bucket) ExplainSelection(pivot []byte, ss ...SFGroup) (*Trace, error) {
+	t := new(Trace)
+	if err := b.schema.CheckGroups(ss...); err != nil {
+		return t, err
+	}
+	for i := range ss {
+		if err := b.explainGroup(t, i, pivot, ss[i]); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func (b *Bucket) explainGroup(t *Trace, group int, pivot []byte, s SFGroup) error {
+	for _, f := range s.Filters {
+		cs := b.findKey(f.Key)
+		if len(cs) == 0 {
+			t.add(group, TraceFilteredOut, traceName(*b), nil, "no buckets with key "+f.Key)
+		}
+		for _, c := range cs {
+			if !f.admits(*c) {
+				t.add(group, TraceFilteredOut, traceName(*c), c.Nodelist().Nodes(), "filter on "+f.Key)
+			}
+		}
+	}
+	if len(s.Exclude) != 0 {
+		t.add(group, TraceFilteredOut, traceName(*b), s.Exclude, "excluded")
+	}
+	if s.Subnet != 0 {
+		var out []uint32
+		for _, n := range b.Nodelist() {
+			if !n.InSubnet(s.Subnet) {
+				out = append(out, n.N)
+			}
+		}
+		if len(out) != 0 {
+			t.add(group, TraceFilteredOut, traceName(*b), out, fmt.Sprintf("not in subnet %d", s.Subnet))
+		}
+	}
+	for _, f := range s.NodeFilters {
+		var out []uint32
+		for _, n := range b.Nodelist() {
+			if !f.Check(n) {
+				out = append(out, n.N)
+			}
+		}
+		if len(out) != 0 {
+			t.add(group, TraceFilteredOut, traceName(*b), out, "node filter on "+f.Field.String())
+		}
+	}
+
+	c := b.GetMaxSelection(s)
+	if c == nil {
+		t.add(group, TraceRejected, traceName(*b), nil, "not enough buckets satisfy filters")
+		return errors.Wrapf(ErrNotEnoughNodes, "selection group %d can't be satisfied", group)
+	}
+
+	p := newSelectParams(*b, s.Selectors, pivot, 0)
+	p.trace = t
+	p.group = group
+	if c = c.getSelection(s.Selectors, p); c == nil {
+		return errors.Wrapf(ErrNotEnoughNodes, "selection group %d can't be satisfied", group)
+	}
+
+	nodes := c.Nodelist()
+	t.add(group, TraceChosen, traceName(*b), nodes.Nodes(), "result")
+	t.Nodes = merge(t.Nodes, nodes)
+	return nil
+}
+
+func (t *Trace) add(group int, a TraceAction, bucket string, nodes []uint32, reason string) {
+	t.Steps = append(t.Steps, TraceStep{
+		Group:  group,
+		Action: a,
+		Bucket: bucket,
+		Nodes:  nodes,
+		Reason: reason,
+	})
+}
+
+// traceName returns bucket name used in trace, root bucket is named "/".
+func traceName(b Bucket) string {
+	if b.Key == "" && b.Value == "" {
+		return "/"
+	}
+	return b.Name()
+}
@@ -0,0 +1,70 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_ExplainSelection(t *testing.T) {
+	buckets := []bucket{
+		{"/Location:Europe/Country:Germany", []uint32{1, 2, 3, 4}},
+		{"/Location:Europe/Country:Spain", []uint32{5, 6}},
+		{"/Location:Asia/Country:China", []uint32{7, 8}},
+	}
+	root, err := newRoot(buckets...)
+	require.NoError(t, err)
+
+	hasStep := func(tr *Trace, a TraceAction, name string) bool {
+		for _, s := range tr.Steps {
+			if s.Action == a && s.Bucket == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("same result as FindNodes", func(t *testing.T) {
+		g := SFGroup{
+			Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}},
+			Filters:   []Filter{{Key: "Location", F: FilterEQ("Europe")}},
+			Exclude:   []uint32{1},
+		}
+		tr, err := root.ExplainSelection(defaultPivot, g)
+		require.NoError(t, err)
+		require.Equal(t, root.FindNodes(defaultPivot, g), tr.Nodes)
+
+		require.True(t, hasStep(tr, TraceFilteredOut, "Location:Asia"))
+		require.True(t, hasStep(tr, TraceChosen, "Country:Germany"))
+		require.True(t, hasStep(tr, TraceChosen, "Country:Spain"))
+		require.False(t, hasStep(tr, TraceConsidered, "Country:China"))
+		require.NotEmpty(t, tr.String())
+	})
+
+	t.Run("rejected bucket", func(t *testing.T) {
+		g := SFGroup{Selectors: []Select{{Key: "Country", Count: 3}, {Key: NodesBucket, Count: 3}}}
+		tr, err := root.ExplainSelection(defaultPivot, g)
+		require.Error(t, err)
+		require.NotNil(t, tr)
+		require.True(t, hasStep(tr, TraceRejected, "/"))
+	})
+
+	t.Run("distinct conflict", func(t *testing.T) {
+		dc, err := newRoot(
+			bucket{"/Country:Germany", []uint32{1, 2}},
+			bucket{"/Country:Spain", []uint32{3}},
+			bucket{"/DC:1", []uint32{1, 2, 3}},
+		)
+		require.NoError(t, err)
+
+		g := SFGroup{Selectors: []Select{{Key: "Country", Count: 2, Distinct: "DC"}, {Key: NodesBucket, Count: 1}}}
+		tr, err := dc.ExplainSelection(defaultPivot, g)
+		require.Error(t, err)
+
+		var found bool
+		for _, s := range tr.Steps {
+			found = found || s.Action == TraceRejected && s.Reason == "distinct DC conflict"
+		}
+		require.True(t, found)
+	})
+}
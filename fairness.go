@@ -0,0 +1,98 @@
+package netmap
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// FairnessReport describes how evenly placement rule distributes
+// objects across nodes of the netmap.
+type FairnessReport struct {
+	// Pivots is the number of simulated pivots, Failed is the number
+	// of pivots which couldn't be placed.
+	Pivots int
+	Failed int
+	// Placements contains number of placements for every node of the netmap,
+	// nodes never chosen are present with zero count.
+	Placements map[uint32]int
+	// Mean and StdDev are mean and population standard deviation of
+	// the number of placements per node.
+	Mean   float64
+	StdDev float64
+	// Gini is Gini coefficient of placement counts: 0 means that all nodes
+	// are chosen equally often, values close to 1 mean that few nodes get
+	// almost all placements.
+	Gini float64
+}
+
+// Fairness places pivots number of objects with deterministic pivots
+// according to ss and reports how evenly they are distributed across nodes.
+// It allows to detect skewed placement rules before deployment.
+func (b *Bucket) Fairness(pivots int, ss ...SFGroup) FairnessReport {
+	var (
+		nodes = b.Nodelist()
+		r     = FairnessReport{Pivots: pivots, Placements: make(map[uint32]int, len(nodes))}
+		pivot = make([]byte, 8)
+	)
+	for _, n := range nodes {
+		r.Placements[n.N] = 0
+	}
+
+	for i := 0; i < pivots; i++ {
+		binary.BigEndian.PutUint64(pivot, uint64(i))
+		ns := b.FindNodes(pivot, ss...)
+		if len(ns) == 0 {
+			r.Failed++
+			continue
+		}
+		for _, n := range ns {
+			r.Placements[n.N]++
+		}
+	}
+
+	r.Mean, r.StdDev, r.Gini = placementStats(r.Placements)
+	return r
+}
+
+// Frequency returns share of pivots placed on node n.
+func (r FairnessReport) Frequency(n uint32) float64 {
+	if r.Pivots == 0 {
+		return 0
+	}
+	return float64(r.Placements[n]) / float64(r.Pivots)
+}
+
+// placementStats returns mean, standard deviation and Gini coefficient of counts.
+func placementStats(m map[uint32]int) (mean, stddev, gini float64) {
+	if len(m) == 0 {
+		return 0, 0, 0
+	}
+
+	var (
+		sum float64
+		xs  = make([]float64, 0, len(m))
+	)
+	for _, c := range m {
+		xs = append(xs, float64(c))
+		sum += float64(c)
+	}
+	mean = sum / float64(len(xs))
+
+	for _, x := range xs {
+		stddev += (x - mean) * (x - mean)
+	}
+	stddev = math.Sqrt(stddev / float64(len(xs)))
+
+	if sum == 0 {
+		return mean, stddev, 0
+	}
+	sort.Float64s(xs)
+	var weighted float64
+	for i, x := range xs {
+		weighted += float64(i+1) * x
+	}
+	n := float64(len(xs))
+	gini = 2*weighted/(n*sum) - (n+1)/n
+	return mean, stddev, gini
+}
@@ -0,0 +1,48 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Fairness(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2, 3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+	)
+	require.NoError(t, err)
+
+	ss := SFGroup{Selectors: []Select{{Key: "Location", Count: 1}, {Key: NodesBucket, Count: 1}}}
+	r := root.Fairness(1000, ss)
+	require.Equal(t, 1000, r.Pivots)
+	require.Equal(t, 0, r.Failed)
+	require.Len(t, r.Placements, 6)
+
+	var total int
+	for _, c := range r.Placements {
+		total += c
+	}
+	require.Equal(t, 1000, total)
+	require.InDelta(t, 1000.0/6, r.Mean, 1e-9)
+	// nodes of Asia are chosen twice as often as nodes of Europe
+	require.InDelta(t, 0.25, r.Frequency(5), 0.05)
+	require.InDelta(t, 0.125, r.Frequency(1), 0.05)
+	require.True(t, r.Gini > 0.1 && r.Gini < 0.3)
+
+	r = root.Fairness(10, SFGroup{Selectors: []Select{{Key: "Location", Count: 3}}})
+	require.Equal(t, 10, r.Failed)
+	require.Zero(t, r.Gini)
+}
+
+func TestPlacementStats(t *testing.T) {
+	mean, stddev, gini := placementStats(map[uint32]int{1: 5, 2: 5, 3: 5})
+	require.Equal(t, 5.0, mean)
+	require.Zero(t, stddev)
+	require.Zero(t, gini)
+
+	mean, stddev, gini = placementStats(map[uint32]int{1: 0, 2: 0, 3: 0, 4: 8})
+	require.Equal(t, 2.0, mean)
+	require.InDelta(t, 3.4641, stddev, 1e-4)
+	require.InDelta(t, 0.75, gini, 1e-9)
+}
@@ -0,0 +1,621 @@
+package netmap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatV1 is the original binary format of the bucket with fixed-size
+	// length prefixes, see Bucket.Write. It starts with formatMagic and the
+	// version. Data without them is read in the legacy layout, in which
+	// nodes contain only index, capacity and price.
+	FormatV1 = 1
+
+	// FormatV2 is the compact binary format of the bucket. All numbers are
+	// encoded as varints, keys, values and nodes repeated in the tree are
+	// written only once and are referenced by their index afterwards.
+	FormatV2 = 2
+
+	// formatMagic is the first byte of versioned formats. Legacy data in
+	// FormatV1 starts with non-negative int32 name length, so it can't
+	// start with it.
+	formatMagic = 0xFF
+
+	// gzipMagic is the first byte of gzip header. Data in FormatV1 can
+	// start with it only if bucket name is longer than 500MB.
+	gzipMagic = 0x1F
+
+	// MaxDecompressedSize is the maximum size of decompressed bucket, larger
+	// data is rejected as malformed without being fully decompressed.
+	// Compressed buckets are written in FormatV2, so it is enough for netmaps
+	// with millions of nodes.
+	MaxDecompressedSize = 64 << 20
+
+	// minNodeSizeV2 and minBucketSizeV2 are sizes of the shortest node
+	// reference and bucket header in FormatV2.
+	minNodeSizeV2   = 1
+	minBucketSizeV2 = 4
+)
+
+type (
+	// encoderV2 writes bucket tree in FormatV2.
+	encoderV2 struct {
+		buf     *bytes.Buffer
+		strings map[string]uint64
+		nodes   map[uint32]uint64
+		table   Nodes
+	}
+
+	// decoderV2 reads bucket tree in FormatV2.
+	decoderV2 struct {
+		r       byteReader
+		strings []string
+		nodes   Nodes
+	}
+
+	byteReader interface {
+		io.Reader
+		io.ByteReader
+	}
+
+	// singleByteReader implements io.ByteReader without reading ahead,
+	// so that data following the bucket is left in the underlying reader.
+	singleByteReader struct {
+		io.Reader
+	}
+
+	// strictReader is a reader used by UnmarshalBinaryStrict.
+	// Decoders check the order of nodes only when reading from it.
+	strictReader struct {
+		*bytes.Reader
+	}
+
+	// DecodeError describes failure of strict decoding, Offset is
+	// the position in the data at which decoding failed.
+	DecodeError struct {
+		Offset int64
+		Err    error
+	}
+)
+
+// WriteVersion writes b to w in binary format of the specified version.
+// Read detects the format automatically.
+func (b Bucket) WriteVersion(w io.Writer, version int) error {
+	switch version {
+	case FormatV1:
+		return b.Write(w)
+	case FormatV2:
+		buf := new(bytes.Buffer)
+		if err := b.writeV2(buf); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	default:
+		return errors.Errorf("unsupported format version %d", version)
+	}
+}
+
+// MarshalBinaryVersion returns b in binary format of the specified version.
+func (b Bucket) MarshalBinaryVersion(version int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := b.WriteVersion(buf, version); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteCompressed writes b to w in FormatV2 compressed by gzip with
+// the specified compression level, e.g. gzip.BestCompression.
+// Read decompresses data automatically.
+func (b Bucket) WriteCompressed(w io.Writer, level int) error {
+	zw, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return err
+	}
+	if err := b.WriteVersion(zw, FormatV2); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// MarshalBinaryCompressed returns b in compressed form, see WriteCompressed.
+func (b Bucket) MarshalBinaryCompressed(level int) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := b.WriteCompressed(buf, level); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readCompressed reads gzip-compressed bucket in any uncompressed format.
+// Data is decompressed in advance, so that lengths read from it are checked
+// against the size of decompressed data.
+func (b *Bucket) readCompressed(r io.Reader) error {
+	data, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	if err := b.read(bytes.NewReader(data), false); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (b Bucket) writeV2(buf *bytes.Buffer) error {
+	e := &encoderV2{
+		buf:     buf,
+		strings: make(map[string]uint64),
+		nodes:   make(map[uint32]uint64),
+	}
+
+	buf.WriteByte(formatMagic)
+	putUvarint(buf, FormatV2)
+	e.putHeader(&b)
+
+	stack := []bucketFrame{{b: &b}}
+	for len(stack) != 0 {
+		top := &stack[len(stack)-1]
+		if top.next == len(top.b.children) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		c := &top.b.children[top.next]
+		top.next++
+		if len(stack) >= MaxBucketDepth {
+			return errors.Errorf("bucket tree is deeper than %d", MaxBucketDepth)
+		}
+		e.putHeader(c)
+		stack = append(stack, bucketFrame{b: c})
+	}
+	return nil
+}
+
+// putHeader writes key, value, nodes and the number of children of b.
+func (e *encoderV2) putHeader(b *Bucket) {
+	e.putString(b.Key)
+	e.putString(b.Value)
+	putUvarint(e.buf, uint64(len(b.nodes)))
+	for i := range b.nodes {
+		e.putNode(b.nodes[i])
+	}
+	putUvarint(e.buf, uint64(len(b.children)))
+}
+
+// putString writes reference to s, s itself is written only once.
+func (e *encoderV2) putString(s string) {
+	if i, ok := e.strings[s]; ok {
+		putUvarint(e.buf, i)
+		return
+	}
+
+	i := uint64(len(e.strings))
+	e.strings[s] = i
+	putUvarint(e.buf, i)
+	putUvarint(e.buf, uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+// putNode writes reference to n. Node is written in full only if it is
+// the first occurrence of its index or its attributes differ from the
+// previous occurrence.
+func (e *encoderV2) putNode(n Node) {
+	if i, ok := e.nodes[n.N]; ok && e.table[i].Equals(n) {
+		putUvarint(e.buf, i)
+		return
+	}
+
+	i := uint64(len(e.table))
+	e.table = append(e.table, n)
+	e.nodes[n.N] = i
+	putUvarint(e.buf, i)
+
+	putUvarint(e.buf, uint64(n.N))
+	putUvarint(e.buf, n.C)
+	putUvarint(e.buf, n.P)
+	putFloat(e.buf, n.R)
+	putFloat(e.buf, n.Coord.X)
+	putFloat(e.buf, n.Coord.Y)
+	putUvarint(e.buf, uint64(len(n.PubKey)))
+	e.buf.Write(n.PubKey)
+	putUvarint(e.buf, uint64(len(n.Addresses)))
+	for _, a := range n.Addresses {
+		putUvarint(e.buf, uint64(len(a)))
+		e.buf.WriteString(a)
+	}
+	if len(n.Attrs) == 0 {
+		putUvarint(e.buf, uint64(len(n.Subnets)))
+	} else {
+		putUvarint(e.buf, uint64(len(n.Subnets))|attrsFlag)
+	}
+	for _, sn := range n.Subnets {
+		putUvarint(e.buf, uint64(sn))
+	}
+	if len(n.Attrs) == 0 {
+		return
+	}
+
+	keys := n.attrKeys()
+	putUvarint(e.buf, uint64(len(keys)))
+	for _, k := range keys {
+		e.putString(k)
+		e.putString(n.Attrs[k])
+	}
+}
+
+// putFloat writes f as varint with reversed bytes, so that
+// small integers and simple fractions are encoded compactly.
+func putFloat(buf *bytes.Buffer, f float64) {
+	putUvarint(buf, bits.ReverseBytes64(math.Float64bits(f)))
+}
+
+// UnmarshalBinaryStrict decodes b from data in any supported binary format
+// like UnmarshalBinary does. Unlike UnmarshalBinary, it fails on truncated
+// data, trailing bytes and node lists which are not sorted by index or
+// contain duplicates. Errors are returned as *DecodeError wrapping
+// ErrMalformedEncoding. For compressed data, offsets of the errors in
+// the bucket refer to the decompressed data.
+func (b *Bucket) UnmarshalBinaryStrict(data []byte) error {
+	return b.unmarshalStrict(data, true)
+}
+
+func (b *Bucket) unmarshalStrict(data []byte, compressed bool) error {
+	var (
+		res Bucket
+		err error
+		r   = &strictReader{bytes.NewReader(data)}
+	)
+
+	switch {
+	case len(data) == 0:
+		err = io.ErrUnexpectedEOF
+	case data[0] == gzipMagic && compressed:
+		if data, err = decompress(r); err == gzip.ErrHeader || err == gzip.ErrChecksum {
+			err = errors.Wrap(ErrMalformedEncoding, err.Error())
+		}
+		if err != nil {
+			break
+		} else if r.Len() != 0 {
+			return &DecodeError{Offset: r.offset(), Err: errors.Wrapf(ErrMalformedEncoding, "%d trailing bytes", r.Len())}
+		}
+		if err = res.unmarshalStrict(data, false); err != nil {
+			return err
+		}
+		res.schema = b.schema
+		*b = res
+		return nil
+	case data[0] == formatMagic:
+		_, _ = r.ReadByte()
+		err = res.readVersioned(r)
+	default:
+		err = res.readV1(r, true)
+	}
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = errors.Wrap(ErrMalformedEncoding, "unexpected end of data")
+	} else if err == nil && r.Len() != 0 {
+		err = errors.Wrapf(ErrMalformedEncoding, "%d trailing bytes", r.Len())
+	}
+	if err != nil {
+		if de, ok := err.(*DecodeError); ok {
+			return de
+		}
+		return &DecodeError{Offset: r.offset(), Err: err}
+	}
+	res.schema = b.schema
+	*b = res
+	return nil
+}
+
+// decompress reads single gzip stream from r,
+// which can't exceed MaxDecompressedSize when decompressed.
+func decompress(r io.Reader) ([]byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	zr.Multistream(false)
+	data, err := ioutil.ReadAll(io.LimitReader(zr, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > MaxDecompressedSize {
+		return nil, errors.Wrapf(ErrMalformedEncoding, "decompressed data exceeds %d bytes", MaxDecompressedSize)
+	}
+	return data, zr.Close()
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("offset %d: %v", e.Offset, e.Err)
+}
+
+// Cause returns the underlying error, see errors.Cause.
+func (e *DecodeError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error, see errors.Unwrap.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (r *strictReader) offset() int64 {
+	return r.Size() - int64(r.Len())
+}
+
+// readOffset returns the current offset of r if it is strictReader.
+func readOffset(r io.Reader) int64 {
+	if sr, ok := r.(*strictReader); ok {
+		return sr.offset()
+	}
+	return 0
+}
+
+// checkNodeOrder checks that the last node of ns has greater index than
+// the previous one if r is strictReader. Start is the offset of the last node.
+func checkNodeOrder(r io.Reader, ns Nodes, start int64) error {
+	if _, ok := r.(*strictReader); !ok || len(ns) < 2 {
+		return nil
+	}
+
+	prev, cur := ns[len(ns)-2].N, ns[len(ns)-1].N
+	if cur == prev {
+		return &DecodeError{Offset: start, Err: errors.Wrapf(ErrMalformedEncoding, "duplicate node %d", cur)}
+	} else if cur < prev {
+		return &DecodeError{Offset: start, Err: errors.Wrapf(ErrMalformedEncoding, "node %d follows node %d", cur, prev)}
+	}
+	return nil
+}
+
+// readVersioned reads bucket in the format specified by the version,
+// magic byte must already be consumed.
+func (b *Bucket) readVersioned(r byteReader) error {
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	switch version {
+	case FormatV1:
+		return b.readV1(r, false)
+	case FormatV2:
+		return b.readV2(r)
+	default:
+		return errors.Wrapf(ErrMalformedEncoding, "unsupported format version %d", version)
+	}
+}
+
+// readV2 reads bucket in FormatV2, magic byte and version
+// must already be consumed.
+func (b *Bucket) readV2(r byteReader) error {
+	d := &decoderV2{r: r}
+	n, err := d.readHeader(b)
+	if err != nil {
+		return err
+	}
+
+	stack := []bucketFrame{{b: b, count: n}}
+	for len(stack) != 0 {
+		top := &stack[len(stack)-1]
+		if top.next == top.count {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		top.next++
+		if len(stack) >= MaxBucketDepth {
+			return errors.Wrapf(ErrMalformedEncoding, "bucket tree is deeper than %d", MaxBucketDepth)
+		}
+		top.b.children = append(top.b.children, Bucket{})
+		c := &top.b.children[len(top.b.children)-1]
+		if n, err = d.readHeader(c); err != nil {
+			return err
+		}
+		stack = append(stack, bucketFrame{b: c, count: n})
+	}
+	return nil
+}
+
+// readHeader reads key, value and nodes of b and returns the number of its
+// children. Children are appended by the caller as they are read.
+func (d *decoderV2) readHeader(b *Bucket) (int, error) {
+	var err error
+	if b.Key, err = d.readString(); err != nil {
+		return 0, err
+	}
+	if b.Value, err = d.readString(); err != nil {
+		return 0, err
+	}
+
+	ln, err := d.readLength()
+	if err != nil {
+		return 0, err
+	}
+	b.nodes = nil
+	if ln > 0 {
+		b.nodes = make(Nodes, 0, preallocLen(d.r, ln, minNodeSizeV2))
+		for i := 0; i < ln; i++ {
+			start := readOffset(d.r)
+			n, err := d.readNode()
+			if err != nil {
+				return 0, err
+			}
+			b.nodes = append(b.nodes, n)
+			if err = checkNodeOrder(d.r, b.nodes, start); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if ln, err = d.readLength(); err != nil {
+		return 0, err
+	}
+	b.children = nil
+	if ln > 0 {
+		b.children = make([]Bucket, 0, preallocLen(d.r, ln, minBucketSizeV2))
+	}
+	return ln, nil
+}
+
+func (d *decoderV2) readString() (string, error) {
+	i, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", err
+	} else if i < uint64(len(d.strings)) {
+		return d.strings[i], nil
+	} else if i > uint64(len(d.strings)) {
+		return "", errors.Wrap(ErrMalformedEncoding, "invalid string reference")
+	}
+
+	data, err := d.readBytes()
+	if err != nil {
+		return "", err
+	}
+	d.strings = append(d.strings, string(data))
+	return string(data), nil
+}
+
+func (d *decoderV2) readNode() (Node, error) {
+	i, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return Node{}, err
+	} else if i < uint64(len(d.nodes)) {
+		// node is referenced from every bucket containing it,
+		// copies must not share memory
+		return d.nodes[i].clone(), nil
+	} else if i > uint64(len(d.nodes)) {
+		return Node{}, errors.Wrap(ErrMalformedEncoding, "invalid node reference")
+	}
+
+	var n Node
+	num, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return n, err
+	} else if num > math.MaxUint32 {
+		return n, errors.Wrap(ErrMalformedEncoding, "node index overflow")
+	}
+	n.N = uint32(num)
+	if n.C, err = binary.ReadUvarint(d.r); err != nil {
+		return n, err
+	}
+	if n.P, err = binary.ReadUvarint(d.r); err != nil {
+		return n, err
+	}
+	for _, f := range []*float64{&n.R, &n.Coord.X, &n.Coord.Y} {
+		v, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return n, err
+		}
+		*f = math.Float64frombits(bits.ReverseBytes64(v))
+	}
+	if n.PubKey, err = d.readBytes(); err != nil {
+		return n, err
+	}
+
+	ln, err := d.readLength()
+	if err != nil {
+		return n, err
+	}
+	for k := 0; k < ln; k++ {
+		a, err := d.readBytes()
+		if err != nil {
+			return n, err
+		}
+		n.Addresses = append(n.Addresses, string(a))
+	}
+
+	sl, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return n, err
+	}
+	attrs := sl&attrsFlag != 0
+	if sl &^= attrsFlag; sl > math.MaxInt32 {
+		return n, errors.Wrap(ErrMalformedEncoding, "invalid length")
+	}
+	for k := uint64(0); k < sl; k++ {
+		sn, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return n, err
+		} else if sn > math.MaxUint32 {
+			return n, errors.Wrap(ErrMalformedEncoding, "subnet overflow")
+		}
+		n.Subnets = append(n.Subnets, uint32(sn))
+	}
+
+	if attrs {
+		if n.Attrs, err = d.readAttrs(); err != nil {
+			return n, err
+		}
+	}
+
+	d.nodes = append(d.nodes, n)
+	return n, nil
+}
+
+func (d *decoderV2) readAttrs() (map[string]string, error) {
+	ln, err := d.readLength()
+	if err != nil {
+		return nil, err
+	} else if ln == 0 {
+		return nil, errors.Wrap(ErrMalformedEncoding, "invalid number of attributes")
+	}
+
+	attrs := make(map[string]string)
+	for i := 0; i < ln; i++ {
+		k, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		if attrs[k], err = d.readString(); err != nil {
+			return nil, err
+		}
+	}
+	return attrs, nil
+}
+
+func (d *decoderV2) readBytes() ([]byte, error) {
+	ln, err := d.readLength()
+	if err != nil || ln == 0 {
+		return nil, err
+	}
+	return readN(d.r, ln)
+}
+
+// readLength reads collection length, which must fit in int32 as in FormatV1.
+func (d *decoderV2) readLength() (int, error) {
+	ln, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return 0, err
+	} else if ln > math.MaxInt32 {
+		return 0, errors.Wrap(ErrMalformedEncoding, "invalid length")
+	}
+	return int(ln), nil
+}
+
+// asByteReader returns r as byteReader without buffering it.
+func asByteReader(r io.Reader) byteReader {
+	if br, ok := r.(byteReader); ok {
+		return br
+	}
+	return singleByteReader{r}
+}
+
+// ReadByte implements io.ByteReader.
+func (r singleByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r.Reader, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
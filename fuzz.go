@@ -0,0 +1,72 @@
+package netmap
+
+import (
+	"bytes"
+)
+
+// FuzzRead is an entry point for fuzzing the binary decoder of the bucket.
+// It decodes data in any supported format and checks that the result can
+// be encoded and decoded again to the same bucket. It panics if this is
+// not the case and returns 1 if data was decoded successfully, 0 otherwise,
+// as expected by go-fuzz. It is also used by native fuzz tests.
+func FuzzRead(data []byte) int {
+	var b Bucket
+	if err := b.Read(bytes.NewReader(data)); err != nil {
+		return 0
+	}
+	for _, version := range []int{FormatV1, FormatV2} {
+		checkRoundTrip(b, version)
+	}
+	return 1
+}
+
+// FuzzReadStrict is like FuzzRead, but uses UnmarshalBinaryStrict.
+func FuzzReadStrict(data []byte) int {
+	var b Bucket
+	if err := b.UnmarshalBinaryStrict(data); err != nil {
+		if _, ok := err.(*DecodeError); !ok {
+			panic(err)
+		}
+		return 0
+	}
+	checkRoundTrip(b, FormatV2)
+	return 1
+}
+
+// FuzzUnmarshalStackItem is like FuzzRead, but uses UnmarshalStackItem.
+func FuzzUnmarshalStackItem(data []byte) int {
+	var b Bucket
+	if err := b.UnmarshalStackItem(data); err != nil {
+		return 0
+	}
+
+	var b1 Bucket
+	data = b.MarshalStackItem()
+	if err := b1.UnmarshalStackItem(data); err != nil {
+		panic(err)
+	} else if !bytes.Equal(data, b1.MarshalStackItem()) {
+		panic("stack item round trip mismatch")
+	}
+	return 1
+}
+
+// checkRoundTrip panics if b can't be restored from its binary representation
+// in the specified format. Representations are compared instead of buckets,
+// because NaN values in nodes are not equal to themselves.
+func checkRoundTrip(b Bucket, version int) {
+	data, err := b.MarshalBinaryVersion(version)
+	if err != nil {
+		panic(err)
+	}
+
+	var b1 Bucket
+	if err := b1.Read(bytes.NewReader(data)); err != nil {
+		panic(err)
+	}
+	data1, err := b1.MarshalBinaryVersion(version)
+	if err != nil {
+		panic(err)
+	} else if !bytes.Equal(data, data1) {
+		panic("round trip mismatch")
+	}
+}
@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package netmap
+
+import (
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fuzzSeeds(f *testing.F) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+	)
+	require.NoError(f, err)
+
+	for _, version := range []int{FormatV1, FormatV2} {
+		data, err := root.MarshalBinaryVersion(version)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+	data, err := root.MarshalBinaryCompressed(gzip.BestSpeed)
+	require.NoError(f, err)
+	f.Add(data)
+	f.Add(root.MarshalStackItem())
+}
+
+func FuzzBucket_Read(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FuzzRead(data)
+	})
+}
+
+func FuzzBucket_UnmarshalBinaryStrict(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FuzzReadStrict(data)
+	})
+}
+
+func FuzzBucket_UnmarshalStackItem(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FuzzUnmarshalStackItem(data)
+	})
+}
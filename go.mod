@@ -14,10 +14,10 @@ require (
 	github.com/mattn/go-colorable v0.0.9 // indirect
 	github.com/mattn/go-isatty v0.0.4 // indirect
 	github.com/nspcc-dev/hrw v1.0.8
-	github.com/pkg/errors v0.8.0
+	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.3.0
-	golang.org/x/sync v0.0.0-20181108010431-42b317875d0f // indirect
-	golang.org/x/sys v0.0.0-20181228144115-9a3f9b0469bb // indirect
+	go.etcd.io/bbolt v1.3.5
+	google.golang.org/grpc v1.26.0
 	gopkg.in/abiosoft/ishell.v2 v2.0.0
 )
 
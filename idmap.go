@@ -0,0 +1,157 @@
+package netmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// IDMap maps external 64-bit node identifiers to compact indices used
+// in the netmap. Indices are assigned sequentially in order of appearance.
+type IDMap struct {
+	next uint64
+	ids  map[uint32]uint64
+	idx  map[uint64]uint32
+}
+
+// idMapEntrySize is the size of binary representation of single mapping.
+const idMapEntrySize = 4 + 8
+
+// NewIDMap returns empty IDMap.
+func NewIDMap() *IDMap {
+	return &IDMap{
+		ids: make(map[uint32]uint64),
+		idx: make(map[uint64]uint32),
+	}
+}
+
+// IdentityIDMap returns IDMap in which identifier of every node from ns
+// is equal to its index. It allows to use netmaps encoded with 32-bit
+// identifiers along with the 64-bit ones.
+func IdentityIDMap(ns Nodes) *IDMap {
+	m := NewIDMap()
+	for _, n := range ns {
+		m.set(n.N, uint64(n.N))
+	}
+	return m
+}
+
+// Index returns index of node with identifier id, assigning new one if needed.
+func (m *IDMap) Index(id uint64) (uint32, error) {
+	if n, ok := m.idx[id]; ok {
+		return n, nil
+	}
+	for m.next <= math.MaxUint32 {
+		n := uint32(m.next)
+		if _, ok := m.ids[n]; !ok {
+			m.set(n, id)
+			return n, nil
+		}
+		m.next++
+	}
+	return 0, errors.New("node indices exhausted")
+}
+
+// Lookup returns index of node with identifier id if it is present.
+func (m *IDMap) Lookup(id uint64) (uint32, bool) {
+	n, ok := m.idx[id]
+	return n, ok
+}
+
+// ID returns identifier of node with index n.
+func (m *IDMap) ID(n uint32) (uint64, bool) {
+	id, ok := m.ids[n]
+	return id, ok
+}
+
+// IDs returns identifiers of nodes from ns. Unknown nodes are skipped.
+func (m *IDMap) IDs(ns Nodes) []uint64 {
+	r := make([]uint64, 0, len(ns))
+	for _, n := range ns {
+		if id, ok := m.ids[n.N]; ok {
+			r = append(r, id)
+		}
+	}
+	return r
+}
+
+// Len returns number of mapped identifiers.
+func (m *IDMap) Len() int {
+	return len(m.ids)
+}
+
+func (m *IDMap) set(n uint32, id uint64) {
+	m.ids[n] = id
+	m.idx[id] = n
+	if uint64(n) >= m.next {
+		m.next = uint64(n) + 1
+	}
+}
+
+// Write writes mappings to w sorted by index.
+func (m *IDMap) Write(w io.Writer) error {
+	ns := make([]uint32, 0, len(m.ids))
+	for n := range m.ids {
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+
+	if err := binary.Write(w, binary.BigEndian, uint32(len(ns))); err != nil {
+		return err
+	}
+
+	var buf [idMapEntrySize]byte
+	for _, n := range ns {
+		binary.BigEndian.PutUint32(buf[0:], n)
+		binary.BigEndian.PutUint64(buf[4:], m.ids[n])
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read reads mappings from r.
+func (m *IDMap) Read(r io.Reader) error {
+	var ln uint32
+	if err := binary.Read(r, binary.BigEndian, &ln); err != nil {
+		return err
+	}
+
+	*m = *NewIDMap()
+
+	var buf [idMapEntrySize]byte
+	for i := uint32(0); i < ln; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return err
+		}
+
+		n := binary.BigEndian.Uint32(buf[0:])
+		id := binary.BigEndian.Uint64(buf[4:])
+		if _, ok := m.ids[n]; ok {
+			return errors.Wrapf(ErrMalformedEncoding, "duplicate index %d", n)
+		} else if _, ok := m.idx[id]; ok {
+			return errors.Wrapf(ErrMalformedEncoding, "duplicate identifier %d", id)
+		}
+		m.set(n, id)
+	}
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (m *IDMap) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.Write(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (m *IDMap) UnmarshalBinary(data []byte) error {
+	return m.Read(bytes.NewReader(data))
+}
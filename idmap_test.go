@@ -0,0 +1,61 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDMap(t *testing.T) {
+	const base = uint64(1) << 40
+
+	m := NewIDMap()
+	for i := uint64(0); i < 3; i++ {
+		n, err := m.Index(base + i)
+		require.NoError(t, err)
+		require.Equal(t, uint32(i), n)
+	}
+
+	n, err := m.Index(base + 1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), n)
+
+	_, ok := m.Lookup(base + 10)
+	require.False(t, ok)
+
+	id, ok := m.ID(2)
+	require.True(t, ok)
+	require.Equal(t, base+2, id)
+
+	var b Bucket
+	for i := uint64(0); i < 3; i++ {
+		n, _ := m.Index(base + i)
+		require.NoError(t, b.AddNode(n, "/Location:Europe"))
+	}
+	require.Equal(t, []uint64{base, base + 1, base + 2}, m.IDs(b.Nodelist()))
+
+	t.Run("marshal", func(t *testing.T) {
+		data, err := m.MarshalBinary()
+		require.NoError(t, err)
+
+		m1 := new(IDMap)
+		require.NoError(t, m1.UnmarshalBinary(data))
+		require.Equal(t, m, m1)
+
+		n, err := m1.Index(base + 3)
+		require.NoError(t, err)
+		require.Equal(t, uint32(3), n)
+
+		require.Error(t, m1.UnmarshalBinary(data[:len(data)-1]))
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		m := IdentityIDMap(Nodes{{N: 1}, {N: 5}})
+		require.Equal(t, 2, m.Len())
+		require.Equal(t, []uint64{1, 5}, m.IDs(Nodes{{N: 1}, {N: 5}}))
+
+		n, err := m.Index(base)
+		require.NoError(t, err)
+		require.Equal(t, uint32(6), n)
+	})
+}
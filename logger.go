@@ -0,0 +1,39 @@
+package netmap
+
+import "sync/atomic"
+
+type (
+	// Logger receives messages about notable decisions made by the package,
+	// e.g. selection falling back to non-local buckets or detected conflicts.
+	// Context is passed as alternating keys and values.
+	// Implementations must be safe for concurrent use.
+	Logger interface {
+		Log(msg string, keyvals ...interface{})
+	}
+
+	noopLogger struct{}
+
+	// loggerHolder wraps Logger to store values of different types in atomic.Value.
+	loggerHolder struct {
+		Logger
+	}
+)
+
+var logger atomic.Value
+
+// SetLogger sets logger for the package. Nil disables logging, which is the default.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = noopLogger{}
+	}
+	logger.Store(loggerHolder{l})
+}
+
+func getLogger() Logger {
+	if h, ok := logger.Load().(loggerHolder); ok {
+		return h.Logger
+	}
+	return noopLogger{}
+}
+
+func (noopLogger) Log(string, ...interface{}) {}
@@ -0,0 +1,43 @@
+package netmap
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Log(msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	l.messages = append(l.messages, fmt.Sprint(append([]interface{}{msg}, keyvals...)...))
+	l.mu.Unlock()
+}
+
+func TestSetLogger(t *testing.T) {
+	l := new(testLogger)
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1}},
+		bucket{"/Location:Asia/Country:China", []uint32{2, 3}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}
+	g := root.FindGraphWith(defaultPivot, []SFGroup{{Selectors: ss}}, WithLocality("/Location:Asia"))
+	require.NotNil(t, g)
+	require.Len(t, l.messages, 1)
+	require.Contains(t, l.messages[0], "non-local bucket")
+
+	l.messages = nil
+	require.Error(t, root.AddBucketStrict("/Location:Asia/Country:Japan", Nodes{{N: 2}}))
+	require.Len(t, l.messages, 1)
+	require.Contains(t, l.messages[0], "conflict detected")
+}
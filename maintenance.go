@@ -0,0 +1,87 @@
+package netmap
+
+import (
+	"sort"
+	"time"
+)
+
+type (
+	// MaintenanceWindow is a period of planned downtime of the node.
+	// Node is unavailable since Start till End, not including End.
+	MaintenanceWindow struct {
+		Start time.Time
+		End   time.Time
+	}
+
+	// Maintenance contains maintenance windows of nodes by their indices.
+	Maintenance map[uint32][]MaintenanceWindow
+
+	// Clock returns current time.
+	Clock func() time.Time
+)
+
+// Contains checks whether t is within w.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// Schedule adds maintenance window of node n.
+func (m Maintenance) Schedule(n uint32, start, end time.Time) {
+	m[n] = append(m[n], MaintenanceWindow{Start: start, End: end})
+}
+
+// Active checks whether node n is in maintenance at t.
+func (m Maintenance) Active(n uint32, t time.Time) bool {
+	for _, w := range m[n] {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// At returns indices of nodes in maintenance at t in ascending order.
+func (m Maintenance) At(t time.Time) []uint32 {
+	var ns []uint32
+	for n := range m {
+		if m.Active(n, t) {
+			ns = append(ns, n)
+		}
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+	return ns
+}
+
+// WithMaintenance returns option which excludes from selection nodes being
+// in maintenance according to m. Current time is taken from clock once per
+// selection, if clock is nil, time.Now is used.
+func WithMaintenance(m Maintenance, clock Clock) SelectOption {
+	return func(p *selectParams) {
+		// option can be applied concurrently, so clock must not be changed
+		now := clock
+		if now == nil {
+			now = time.Now
+		}
+
+		ns := m.At(now())
+		if len(ns) == 0 {
+			p.maintenance = nil
+			return
+		}
+		p.maintenance = make(map[uint32]struct{}, len(ns))
+		for _, n := range ns {
+			p.maintenance[n] = struct{}{}
+		}
+	}
+}
+
+// available returns copy of ns without nodes in maintenance.
+func (p selectParams) available(ns Nodes) Nodes {
+	r := make(Nodes, 0, len(ns))
+	for i := range ns {
+		if _, ok := p.maintenance[ns[i].N]; !ok {
+			r = append(r, ns[i])
+		}
+	}
+	return r
+}
@@ -0,0 +1,68 @@
+package netmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3}},
+		bucket{"/Location:Asia/Country:China", []uint32{4}},
+	)
+	require.NoError(t, err)
+
+	var (
+		start = time.Unix(1000, 0)
+		end   = start.Add(time.Hour)
+		m     = make(Maintenance)
+		now   time.Time
+		clock = func() time.Time { return now }
+	)
+	m.Schedule(3, start, end)
+	m.Schedule(4, start.Add(-time.Hour), start.Add(time.Minute))
+	m.Schedule(4, end, end.Add(time.Hour))
+
+	require.Empty(t, m.At(start.Add(-2*time.Hour)))
+	require.Equal(t, []uint32{3, 4}, m.At(start))
+	require.Equal(t, []uint32{3}, m.At(start.Add(time.Minute)))
+	require.Equal(t, []uint32{4}, m.At(end))
+	require.False(t, m.Active(1, start))
+
+	ss := []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}
+
+	now = start.Add(-2 * time.Hour)
+	r := root.GetSelection(ss, defaultPivot, WithMaintenance(m, clock))
+	require.Equal(t, root.GetSelection(ss, defaultPivot), r)
+
+	// only Germany has available nodes
+	now = start
+	require.Nil(t, root.GetSelection(ss, defaultPivot, WithMaintenance(m, clock)))
+	require.Equal(t, []uint32{1, 2}, root.GetSelection([]Select{{Key: "Country", Count: 1}}, defaultPivot, WithMaintenance(m, clock)).Nodelist().Nodes())
+
+	now = start.Add(time.Minute)
+	for i := 0; i < 10; i++ {
+		r := root.GetSelection(ss, []byte{byte(i)}, WithMaintenance(m, clock))
+		require.NotNil(t, r)
+		require.NotContains(t, r.Nodelist().Nodes(), uint32(3))
+		require.Contains(t, r.Nodelist().Nodes(), uint32(4))
+	}
+
+	ns := root.FindNodesWith(defaultPivot, []SFGroup{{Selectors: []Select{{Key: "Location", Count: 1}}}},
+		WithMaintenance(m, clock))
+	require.NotContains(t, ns.Nodes(), uint32(3))
+
+	// percentage is taken of available nodes only
+	now = start
+	r = root.GetSelection([]Select{PercentSelect(100, NodesBucket, 0)}, defaultPivot, WithMaintenance(m, clock))
+	require.NotNil(t, r)
+	require.Equal(t, []uint32{1, 2}, r.Nodelist().Nodes())
+
+	// nil clock means current time
+	r = root.GetSelection([]Select{PercentSelect(100, NodesBucket, 0)}, defaultPivot, WithMaintenance(m, nil))
+	require.NotNil(t, r)
+	require.Len(t, r.Nodelist(), 4)
+}
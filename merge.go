@@ -0,0 +1,124 @@
+package netmap
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// MergeStrategy defines how MergeNetmaps reconciles different views of the netmap.
+type MergeStrategy int
+
+const (
+	// MergeUnion keeps nodes present in any of the netmaps.
+	MergeUnion MergeStrategy = iota
+	// MergeIntersection keeps nodes present in all netmaps.
+	MergeIntersection
+	// MergeMajority keeps nodes present in more than half of the netmaps.
+	// Attributes and options of the node are the ones reported most often.
+	MergeMajority
+)
+
+// nodeView is a node along with the paths of buckets it is attached to.
+type nodeView struct {
+	node  Node
+	paths []string
+}
+
+// String implements fmt.Stringer interface.
+func (s MergeStrategy) String() string {
+	switch s {
+	case MergeUnion:
+		return "union"
+	case MergeIntersection:
+		return "intersection"
+	case MergeMajority:
+		return "majority"
+	default:
+		return "unknown"
+	}
+}
+
+// MergeNetmaps reconciles netmaps reported by several sources, e.g. by
+// inner ring nodes, according to the strategy. Unlike Merge, it works with
+// whole nodes: node either is added with all its options or is not added.
+// For MergeUnion and MergeIntersection node attributes and options are taken
+// from the first netmap containing the node, so maps must be ordered by priority.
+func MergeNetmaps(strategy MergeStrategy, maps ...Bucket) (Bucket, error) {
+	var need int
+	switch strategy {
+	case MergeUnion:
+		need = 1
+	case MergeIntersection:
+		need = len(maps)
+	case MergeMajority:
+		need = len(maps)/2 + 1
+	default:
+		return Bucket{}, errors.Errorf("invalid merge strategy %d", strategy)
+	}
+
+	views := make(map[uint32][]nodeView)
+	for i := range maps {
+		for n, v := range maps[i].nodeViews() {
+			views[n] = append(views[n], v)
+		}
+	}
+
+	es := make([]NodeEntry, 0, len(views))
+	for _, vs := range views {
+		if len(vs) < need {
+			continue
+		}
+
+		v := vs[0]
+		if strategy == MergeMajority {
+			v = vote(vs)
+		}
+		es = append(es, NodeEntry{Node: v.node, Options: v.paths})
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].Node.N < es[j].Node.N })
+
+	var b Bucket
+	err := b.AddNodes(es)
+	return b, err
+}
+
+// nodeViews returns views of all nodes of b including ones attached to inner buckets.
+func (b Bucket) nodeViews() map[uint32]nodeView {
+	var (
+		ls = b.pathNodes()
+		m  = make(map[uint32]nodeView, len(b.nodes))
+	)
+	for _, p := range sortedKeys(ls) {
+		for _, n := range ls[p] {
+			v, ok := m[n.N]
+			if !ok {
+				v.node = n
+			}
+			v.paths = append(v.paths, p)
+			m[n.N] = v
+		}
+	}
+	return m
+}
+
+// vote returns the most frequent of vs, the first one wins in case of a tie.
+func vote(vs []nodeView) nodeView {
+	var best, max int
+	for i := range vs {
+		var count int
+		for j := range vs {
+			if vs[i].equals(vs[j]) {
+				count++
+			}
+		}
+		if count > max {
+			best, max = i, count
+		}
+	}
+	return vs[best]
+}
+
+func (v nodeView) equals(v1 nodeView) bool {
+	return v.node.Equals(v1.node) && equalStrings(v.paths, v1.paths)
+}
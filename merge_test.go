@@ -0,0 +1,71 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeNetmaps(t *testing.T) {
+	m1, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 10}, {N: 2, C: 10}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 3, C: 10}}},
+	)
+	require.NoError(t, err)
+	m2, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 20}, {N: 2, C: 10}}},
+		strawBucket{"/Location:Europe/Country:France", Nodes{{N: 4, C: 10}}},
+	)
+	require.NoError(t, err)
+	m3, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 20}}},
+		strawBucket{"/Location:Europe/Country:Spain", Nodes{{N: 2, C: 10}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 3, C: 10}}},
+	)
+	require.NoError(t, err)
+
+	t.Run("union", func(t *testing.T) {
+		b, err := MergeNetmaps(MergeUnion, m1, m2, m3)
+		require.NoError(t, err)
+		require.Equal(t, []uint32{1, 2, 3, 4}, b.Nodelist().Nodes())
+		require.Equal(t, Nodes{{N: 1, C: 10}, {N: 2, C: 10}}, b.GetNodesByOption("/Location:Europe/Country:Germany"))
+		require.True(t, b.IsValid())
+	})
+
+	t.Run("intersection", func(t *testing.T) {
+		b, err := MergeNetmaps(MergeIntersection, m1, m2, m3)
+		require.NoError(t, err)
+		require.Equal(t, Nodes{{N: 1, C: 10}, {N: 2, C: 10}}, b.Nodelist())
+	})
+
+	t.Run("majority", func(t *testing.T) {
+		b, err := MergeNetmaps(MergeMajority, m1, m2, m3)
+		require.NoError(t, err)
+		require.Equal(t, []uint32{1, 2, 3}, b.Nodelist().Nodes())
+		require.Equal(t, Nodes{{N: 1, C: 20}, {N: 2, C: 10}}, b.GetNodesByOption("/Location:Europe/Country:Germany"))
+		require.Empty(t, b.Query("/Location:Europe/Country:Spain"))
+	})
+
+	b, err := MergeNetmaps(MergeUnion)
+	require.NoError(t, err)
+	require.Empty(t, b.Nodelist())
+
+	_, err = MergeNetmaps(MergeStrategy(42), m1)
+	require.Error(t, err)
+}
+
+func TestMergeNetmaps_InnerBucketNodes(t *testing.T) {
+	var a, b Bucket
+	require.NoError(t, a.AddBucket("/Location:Europe", Nodes{{N: 5}}))
+	require.NoError(t, a.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 6}}))
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 6}}))
+
+	m, err := MergeNetmaps(MergeUnion, a, b)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{5, 6}, m.Nodelist().Nodes())
+	require.Equal(t, []uint32{5, 6}, m.GetNodesByOption("/Location:Europe").Nodes())
+
+	m, err = MergeNetmaps(MergeIntersection, a, b)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{6}, m.Nodelist().Nodes())
+}
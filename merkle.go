@@ -0,0 +1,154 @@
+package netmap
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// MerkleProof proves that node belongs to the netmap with known Merkle root.
+	MerkleProof struct {
+		// Bucket is a name of the bucket node is attached to.
+		Bucket string
+		// Nodes are all nodes attached to the bucket itself, i.e. all nodes
+		// of the leaf bucket or nodes of inner bucket not present in children.
+		Nodes Nodes
+		// Hashes are Merkle hashes of all children of the bucket,
+		// they are empty for leaf bucket.
+		Hashes [][sha256.Size]byte
+		// Path contains ancestors of the bucket from the bottom to the root.
+		Path []MerkleStep
+	}
+
+	// MerkleStep describes single ancestor in the Merkle proof.
+	MerkleStep struct {
+		Bucket string
+		// Index is a position of the previous bucket of the path among children.
+		Index int
+		// Nodes are nodes attached to the ancestor itself.
+		Nodes Nodes
+		// Hashes are Merkle hashes of all children.
+		Hashes [][sha256.Size]byte
+	}
+)
+
+const (
+	merkleLeafPrefix  = 0
+	merkleInnerPrefix = 1
+)
+
+// MerkleRoot returns root of the Merkle tree built over canonical form of b.
+// Leaf buckets are hashed together with their nodes, inner buckets are
+// hashed together with nodes attached to them directly and hashes of
+// their children.
+func (b Bucket) MerkleRoot() [sha256.Size]byte {
+	c := b.Copy()
+	c.Canonicalize()
+	return c.merkleHash()
+}
+
+// ProveNode returns proof that node n belongs to b.
+func (b Bucket) ProveNode(n uint32) (*MerkleProof, error) {
+	c := b.Copy()
+	c.Canonicalize()
+
+	p := new(MerkleProof)
+	if !c.prove(n, p) {
+		return nil, errors.Errorf("node %d not found", n)
+	}
+	return p, nil
+}
+
+// Verify checks that p proves inclusion of node n in the netmap with Merkle root.
+func (p MerkleProof) Verify(root [sha256.Size]byte, n uint32) bool {
+	if !contains(p.Nodes, Node{N: n}) {
+		return false
+	}
+
+	h := merkleLeaf(p.Bucket, p.Nodes)
+	if len(p.Hashes) != 0 {
+		h = merkleInner(p.Bucket, p.Nodes, p.Hashes)
+	}
+	for _, s := range p.Path {
+		if s.Index < 0 || s.Index >= len(s.Hashes) || s.Hashes[s.Index] != h {
+			return false
+		}
+		h = merkleInner(s.Bucket, s.Nodes, s.Hashes)
+	}
+	return h == root
+}
+
+func (b Bucket) merkleHash() [sha256.Size]byte {
+	if len(b.children) == 0 {
+		return merkleLeaf(b.Name(), b.nodes)
+	}
+	return merkleInner(b.Name(), b.ownNodes(), b.childHashes())
+}
+
+func (b Bucket) childHashes() [][sha256.Size]byte {
+	hs := make([][sha256.Size]byte, len(b.children))
+	for i := range b.children {
+		hs[i] = b.children[i].merkleHash()
+	}
+	return hs
+}
+
+func (b Bucket) prove(n uint32, p *MerkleProof) bool {
+	if len(b.children) == 0 {
+		if !contains(b.nodes, Node{N: n}) {
+			return false
+		}
+		p.Bucket = b.Name()
+		p.Nodes = b.nodes
+		return true
+	}
+
+	own := b.ownNodes()
+	if contains(own, Node{N: n}) {
+		p.Bucket = b.Name()
+		p.Nodes = own
+		p.Hashes = b.childHashes()
+		return true
+	}
+
+	for i := range b.children {
+		if b.children[i].prove(n, p) {
+			p.Path = append(p.Path, MerkleStep{
+				Bucket: b.Name(),
+				Index:  i,
+				Nodes:  own,
+				Hashes: b.childHashes(),
+			})
+			return true
+		}
+	}
+	return false
+}
+
+func merkleLeaf(name string, ns Nodes) (r [sha256.Size]byte) {
+	h := newMerkleHash(merkleLeafPrefix, name)
+	_ = ns.Write(h)
+	copy(r[:], h.Sum(nil))
+	return
+}
+
+func merkleInner(name string, own Nodes, hs [][sha256.Size]byte) (r [sha256.Size]byte) {
+	h := newMerkleHash(merkleInnerPrefix, name)
+	_ = own.Write(h)
+	for i := range hs {
+		h.Write(hs[i][:])
+	}
+	copy(r[:], h.Sum(nil))
+	return
+}
+
+func newMerkleHash(prefix byte, name string) hash.Hash {
+	h := sha256.New()
+	h.Write([]byte{prefix})
+	_ = binary.Write(h, binary.BigEndian, uint32(len(name)))
+	h.Write([]byte(name))
+	return h
+}
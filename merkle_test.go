@@ -0,0 +1,73 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_ProveNode(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3}},
+		bucket{"/Location:Asia/Country:China", []uint32{4, 5}},
+	)
+	require.NoError(t, err)
+
+	mr := root.MerkleRoot()
+	for n := uint32(1); n <= 5; n++ {
+		p, err := root.ProveNode(n)
+		require.NoError(t, err)
+		require.Len(t, p.Path, 2)
+		require.True(t, p.Verify(mr, n))
+	}
+
+	_, err = root.ProveNode(6)
+	require.Error(t, err)
+
+	p, err := root.ProveNode(3)
+	require.NoError(t, err)
+	require.False(t, p.Verify(mr, 1))
+
+	t.Run("tampered proof", func(t *testing.T) {
+		p, err := root.ProveNode(1)
+		require.NoError(t, err)
+
+		p.Nodes = append(p.Nodes, Node{N: 6})
+		require.True(t, contains(p.Nodes, Node{N: 6}))
+		require.False(t, p.Verify(mr, 6))
+	})
+
+	t.Run("changed netmap", func(t *testing.T) {
+		p, err := root.ProveNode(4)
+		require.NoError(t, err)
+
+		c := root.Copy()
+		require.NoError(t, c.AddBucket("/Location:Asia/Country:Japan", Nodes{{N: 7}}))
+		require.NotEqual(t, mr, c.MerkleRoot())
+		require.False(t, p.Verify(c.MerkleRoot(), 4))
+	})
+
+	t.Run("inner bucket nodes", func(t *testing.T) {
+		var b, c Bucket
+		require.NoError(t, b.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 2}}))
+		require.NoError(t, c.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 2}}))
+		require.NoError(t, c.AddBucket("/Location:Europe", Nodes{{N: 1}}))
+		require.NotEqual(t, b.MerkleRoot(), c.MerkleRoot())
+
+		mr := c.MerkleRoot()
+		p, err := c.ProveNode(1)
+		require.NoError(t, err)
+		require.Equal(t, "Location:Europe", p.Bucket)
+		require.Equal(t, []uint32{1}, p.Nodes.Nodes())
+		require.Len(t, p.Hashes, 1)
+		require.Len(t, p.Path, 1)
+		require.True(t, p.Verify(mr, 1))
+		require.False(t, p.Verify(b.MerkleRoot(), 1))
+
+		p, err = c.ProveNode(2)
+		require.NoError(t, err)
+		require.True(t, p.Verify(mr, 2))
+		require.False(t, p.Verify(b.MerkleRoot(), 2))
+	})
+}
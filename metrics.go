@@ -0,0 +1,56 @@
+package netmap
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Metrics receives instrumentation events, e.g. to update Prometheus
+	// collectors. Implementations must be safe for concurrent use.
+	Metrics interface {
+		// ObserveSelection is called after selection of nodes for every
+		// SFGroup with its duration and result.
+		ObserveSelection(d time.Duration, ok bool)
+		// ObserveEpochNodes is called with the number of nodes in the netmap
+		// of the new epoch passed to Watcher.
+		ObserveEpochNodes(epoch uint64, count int)
+		// ObserveCacheLookup is called on every lookup of cached values,
+		// such as temporary node buffers used by selection.
+		ObserveCacheLookup(hit bool)
+	}
+
+	noopMetrics struct{}
+
+	// metricsHolder wraps Metrics to store values of different types in atomic.Value.
+	metricsHolder struct {
+		Metrics
+	}
+)
+
+var metrics atomic.Value
+
+// SetMetrics sets receiver of instrumentation events for the package.
+// Nil disables instrumentation, which is the default.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noopMetrics{}
+	}
+	metrics.Store(metricsHolder{m})
+}
+
+func getMetrics() Metrics {
+	if h, ok := metrics.Load().(metricsHolder); ok {
+		return h.Metrics
+	}
+	return noopMetrics{}
+}
+
+// observeSelection reports selection started at start, must be deferred.
+func observeSelection(start time.Time, ok *bool) {
+	getMetrics().ObserveSelection(time.Since(start), *ok)
+}
+
+func (noopMetrics) ObserveSelection(time.Duration, bool) {}
+func (noopMetrics) ObserveEpochNodes(uint64, int)        {}
+func (noopMetrics) ObserveCacheLookup(bool)              {}
@@ -0,0 +1,67 @@
+package netmap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMetrics struct {
+	mu         sync.Mutex
+	selections []bool
+	epochNodes map[uint64]int
+	lookups    int
+}
+
+func (m *testMetrics) ObserveSelection(_ time.Duration, ok bool) {
+	m.mu.Lock()
+	m.selections = append(m.selections, ok)
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) ObserveEpochNodes(epoch uint64, count int) {
+	m.mu.Lock()
+	m.epochNodes[epoch] = count
+	m.mu.Unlock()
+}
+
+func (m *testMetrics) ObserveCacheLookup(bool) {
+	m.mu.Lock()
+	m.lookups++
+	m.mu.Unlock()
+}
+
+func TestSetMetrics(t *testing.T) {
+	m := &testMetrics{epochNodes: make(map[uint64]int)}
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	root, err := newRoot(
+		bucket{"/Location:Europe", []uint32{1, 2}},
+		bucket{"/Location:Asia", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	var (
+		ss = []Select{{Key: "Location", Count: 2}, {Key: NodesBucket, Count: 1}}
+		fs = []Filter{{Key: "Location", F: FilterNE("Africa")}}
+	)
+	require.NotNil(t, root.FindGraph(defaultPivot, SFGroup{Selectors: ss, Filters: fs}))
+
+	ss[0].Count = 3
+	require.Empty(t, root.FindNodes(defaultPivot, SFGroup{Selectors: ss}))
+	require.Equal(t, []bool{true, false}, m.selections)
+	require.NotZero(t, m.lookups)
+
+	// compiled policies are reported the same way
+	m.selections = nil
+	cp, err := root.CompilePolicy([]SFGroup{{Selectors: ss}, {Selectors: ss[1:]}})
+	require.NoError(t, err)
+	require.Len(t, cp.Place(defaultPivot), 1)
+	require.Equal(t, []bool{false, true}, m.selections)
+
+	require.NoError(t, NewWatcher().Update(5, root))
+	require.Equal(t, map[uint64]int{5: 3}, m.epochNodes)
+}
@@ -0,0 +1,99 @@
+package netmap
+
+import (
+	"github.com/pkg/errors"
+)
+
+type (
+	// NamedFilter is a filter definition which can be referenced by
+	// selections and other filters by its name.
+	NamedFilter struct {
+		Name string
+		// Filters must all be satisfied.
+		Filters []Filter
+		// Refs are names of other filters which must be satisfied too.
+		Refs []string
+	}
+
+	// Selection is a selection group choosing nodes which satisfy
+	// named filter From in addition to its own filters.
+	// Empty From means that no named filter is applied.
+	Selection struct {
+		SFGroup
+		From string
+	}
+
+	filterResolver struct {
+		defs     map[string]*NamedFilter
+		resolved map[string][]Filter
+		visiting map[string]bool
+	}
+)
+
+// ResolvePolicy returns selection groups of ss with filters referenced by
+// name substituted from fs. Error is returned if names of fs are not unique,
+// unknown name is referenced or filters reference each other in a cycle.
+func ResolvePolicy(fs []NamedFilter, ss ...Selection) ([]SFGroup, error) {
+	r := filterResolver{
+		defs:     make(map[string]*NamedFilter, len(fs)),
+		resolved: make(map[string][]Filter, len(fs)),
+		visiting: make(map[string]bool),
+	}
+	for i := range fs {
+		if fs[i].Name == "" {
+			return nil, errors.Errorf("filter %d has no name", i)
+		} else if _, ok := r.defs[fs[i].Name]; ok {
+			return nil, errors.Errorf("filter %s is defined twice", fs[i].Name)
+		}
+		r.defs[fs[i].Name] = &fs[i]
+	}
+
+	// resolve all definitions, so that unused ones are checked too
+	for i := range fs {
+		if _, err := r.resolve(fs[i].Name); err != nil {
+			return nil, err
+		}
+	}
+
+	gs := make([]SFGroup, len(ss))
+	for i := range ss {
+		gs[i] = ss[i].SFGroup
+		if ss[i].From == "" {
+			continue
+		}
+		f, err := r.resolve(ss[i].From)
+		if err != nil {
+			return nil, errors.Wrapf(err, "group %d", i)
+		}
+		gs[i].Filters = append(f[:len(f):len(f)], ss[i].Filters...)
+	}
+	return gs, nil
+}
+
+// resolve returns all filters of the named filter name.
+func (r *filterResolver) resolve(name string) ([]Filter, error) {
+	if fs, ok := r.resolved[name]; ok {
+		return fs, nil
+	}
+
+	def, ok := r.defs[name]
+	if !ok {
+		return nil, errors.Errorf("unknown filter %s", name)
+	} else if r.visiting[name] {
+		return nil, errors.Errorf("filter %s references itself", name)
+	}
+
+	r.visiting[name] = true
+	defer delete(r.visiting, name)
+
+	fs := append([]Filter(nil), def.Filters...)
+	for _, ref := range def.Refs {
+		rfs, err := r.resolve(ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "filter %s", name)
+		}
+		fs = append(fs, rfs...)
+	}
+	r.resolved[name] = fs
+	return fs, nil
+}
@@ -0,0 +1,61 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePolicy(t *testing.T) {
+	var (
+		europe = Filter{Key: "Location", F: FilterEQ("Europe")}
+		cheap  = Filter{Key: "Price", F: FilterLE(10)}
+		notDE  = Filter{Key: "Country", F: FilterNE("Germany")}
+	)
+
+	fs := []NamedFilter{
+		{Name: "F1", Filters: []Filter{europe}},
+		{Name: "F2", Filters: []Filter{cheap}, Refs: []string{"F1"}},
+		{Name: "F3", Refs: []string{"F2"}},
+	}
+	sel := []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 1}}
+
+	gs, err := ResolvePolicy(fs,
+		Selection{SFGroup: SFGroup{Selectors: sel}, From: "F3"},
+		Selection{SFGroup: SFGroup{Selectors: sel, Filters: []Filter{notDE}}, From: "F1"},
+		Selection{SFGroup: SFGroup{Selectors: sel, Exclude: []uint32{1}}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []SFGroup{
+		{Selectors: sel, Filters: []Filter{cheap, europe}},
+		{Selectors: sel, Filters: []Filter{europe, notDE}},
+		{Selectors: sel, Exclude: []uint32{1}},
+	}, gs)
+	// definitions are left intact
+	require.Equal(t, []Filter{europe}, fs[0].Filters)
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ResolvePolicy(fs, Selection{SFGroup: SFGroup{Selectors: sel}, From: "F4"})
+		require.Error(t, err)
+
+		_, err = ResolvePolicy(append(fs, NamedFilter{Name: "F1"}))
+		require.Error(t, err)
+
+		_, err = ResolvePolicy([]NamedFilter{{Filters: []Filter{europe}}})
+		require.Error(t, err)
+
+		_, err = ResolvePolicy([]NamedFilter{{Name: "F1", Refs: []string{"F5"}}})
+		require.Error(t, err)
+
+		cycle := []NamedFilter{
+			{Name: "A", Refs: []string{"B"}},
+			{Name: "B", Filters: []Filter{europe}, Refs: []string{"C"}},
+			{Name: "C", Refs: []string{"A"}},
+		}
+		_, err = ResolvePolicy(cycle)
+		require.Error(t, err)
+
+		_, err = ResolvePolicy([]NamedFilter{{Name: "A", Refs: []string{"A"}}})
+		require.Error(t, err)
+	})
+}
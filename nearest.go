@@ -0,0 +1,39 @@
+package netmap
+
+import (
+	"math"
+	"sort"
+)
+
+// Coord is a position of the node in network coordinate space,
+// where distance between nodes approximates latency between them.
+type Coord struct {
+	X float64
+	Y float64
+}
+
+// Distance returns euclidean distance between c and c1.
+func (c Coord) Distance(c1 Coord) float64 {
+	return math.Hypot(c.X-c1.X, c.Y-c1.Y)
+}
+
+// Nearest returns k nodes from b which are the closest to from.
+// Nodes with equal distance are ordered by index.
+func (b Bucket) Nearest(k int, from Coord) Nodes {
+	if k <= 0 {
+		return nil
+	}
+
+	ns := b.Nodelist()
+	r := make(Nodes, len(ns))
+	copy(r, ns)
+	sort.Sort(r)
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].Coord.Distance(from) < r[j].Coord.Distance(from)
+	})
+
+	if k < len(r) {
+		r = r[:k]
+	}
+	return r
+}
@@ -0,0 +1,28 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Nearest(t *testing.T) {
+	root, err := newStrawRoot(
+		strawBucket{"/Location:Europe", Nodes{
+			{N: 1, Coord: Coord{X: 1, Y: 1}},
+			{N: 2, Coord: Coord{X: 5, Y: 0}},
+		}},
+		strawBucket{"/Location:Asia", Nodes{
+			{N: 3, Coord: Coord{X: 10, Y: 10}},
+			{N: 4, Coord: Coord{X: -1, Y: -1}},
+		}},
+	)
+	require.NoError(t, err)
+
+	require.Nil(t, root.Nearest(0, Coord{}))
+	require.Equal(t, []uint32{1, 4}, root.Nearest(2, Coord{}).Nodes())
+	require.Equal(t, []uint32{2, 1, 4, 3}, root.Nearest(10, Coord{X: 5}).Nodes())
+	require.Equal(t, []uint32{3}, root.Nearest(1, Coord{X: 9, Y: 9}).Nodes())
+
+	require.InEpsilon(t, 5.0, Coord{X: 3, Y: 4}.Distance(Coord{}), eps)
+}
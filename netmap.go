@@ -0,0 +1,348 @@
+package netmap
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// NetMap is a netmap maintained as a fold over membership events.
+	// Only online nodes are present in the bucket tree used for selection,
+	// draining nodes are kept in a separate tree along with online ones.
+	NetMap struct {
+		root  Bucket
+		all   Bucket
+		nodes map[uint32]nodeInfo
+	}
+
+	// NodeState represents membership state of the node.
+	NodeState uint8
+
+	// EventType is a kind of membership event.
+	EventType uint8
+
+	// Event describes single change of netmap membership.
+	Event struct {
+		Type EventType
+		// Node contains index of the node and its capacity and price
+		// for NodeAdded and AttributeChanged events.
+		Node Node
+		// Options are node options for NodeAdded and AttributeChanged events.
+		Options []string
+		// State is a new node state for StateChanged event.
+		State NodeState
+		// Time is the moment the node was seen, current time is used if it
+		// is zero. It is ignored for NodeRemoved and StateChanged events.
+		Time time.Time
+	}
+
+	nodeInfo struct {
+		node  Node
+		opts  []string
+		state NodeState
+		seen  time.Time
+		// expired is set when node was set offline by ExpireStale, such
+		// node returns to the state it had before (online or draining)
+		// on the next heartbeat.
+		expired bool
+		resume  NodeState
+	}
+)
+
+const (
+	// NodeOnline is a state of node participating in selection.
+	NodeOnline NodeState = iota
+	// NodeOffline is a state of known node excluded from selection.
+	NodeOffline
+	// NodeDraining is a state of node which is going to leave the netmap.
+	// It is excluded from new selections, but is kept in the tree returned
+	// by RootWithDraining, so that existing placements are resolved.
+	NodeDraining
+)
+
+const (
+	// NodeAdded is an event of new node joining the netmap.
+	NodeAdded EventType = iota
+	// NodeRemoved is an event of node leaving the netmap.
+	NodeRemoved
+	// StateChanged is an event of node changing its state.
+	StateChanged
+	// AttributeChanged is an event of node changing its options, capacity or price.
+	AttributeChanged
+	// Heartbeat is an event of node reporting it is alive.
+	Heartbeat
+)
+
+// ExpireRemoveFactor is a multiplier of the maximum age after which
+// silent nodes are removed by ExpireStale.
+const ExpireRemoveFactor = 2
+
+// String implements fmt.Stringer interface.
+func (s NodeState) String() string {
+	switch s {
+	case NodeOnline:
+		return "online"
+	case NodeOffline:
+		return "offline"
+	case NodeDraining:
+		return "draining"
+	default:
+		return "unknown"
+	}
+}
+
+// attached checks whether node in state s is present in the bucket tree.
+func (s NodeState) attached() bool {
+	return s == NodeOnline || s == NodeDraining
+}
+
+// String implements fmt.Stringer interface.
+func (t EventType) String() string {
+	switch t {
+	case NodeAdded:
+		return "node added"
+	case NodeRemoved:
+		return "node removed"
+	case StateChanged:
+		return "state changed"
+	case AttributeChanged:
+		return "attribute changed"
+	case Heartbeat:
+		return "heartbeat"
+	default:
+		return "unknown"
+	}
+}
+
+// NewNetMap returns empty netmap.
+func NewNetMap() *NetMap {
+	return &NetMap{nodes: make(map[uint32]nodeInfo)}
+}
+
+// Root returns snapshot of bucket tree containing all online nodes, it must be
+// used for new selections. It isn't affected by events applied later.
+func (m *NetMap) Root() Bucket {
+	return m.root
+}
+
+// RootWithDraining returns snapshot of bucket tree containing all online and
+// draining nodes, it must be used to resolve existing placements, e.g. to find
+// nodes storing objects of the container. It isn't affected by events applied later.
+func (m *NetMap) RootWithDraining() Bucket {
+	return m.all
+}
+
+// SetSchema attaches schema s to the netmap, so that options of nodes
+// passed to Apply and placement rules checked by selection methods of Root
+// and RootWithDraining must match it, see WithSchema. Nodes which are
+// already in the netmap are not checked again.
+func (m *NetMap) SetSchema(s *Schema) {
+	m.root.schema = s
+	m.all.schema = s
+}
+
+// Copy returns copy of m, events applied to it don't affect m.
+func (m *NetMap) Copy() *NetMap {
+	c := &NetMap{root: m.root, all: m.all, nodes: make(map[uint32]nodeInfo, len(m.nodes))}
+	for n, info := range m.nodes {
+		c.nodes[n] = info
+	}
+	return c
+}
+
+// Node returns node with index n and its state.
+func (m *NetMap) Node(n uint32) (Node, NodeState, bool) {
+	info, ok := m.nodes[n]
+	return info.node, info.state, ok
+}
+
+// Apply applies membership event ev to m.
+func (m *NetMap) Apply(ev Event) error {
+	n := ev.Node.N
+	info, ok := m.nodes[n]
+
+	switch ev.Type {
+	case NodeAdded:
+		if ok {
+			return errors.Errorf("node %d already exists", n)
+		} else if err := checkOptions(m.all.schema, ev.Options); err != nil {
+			return err
+		}
+		info = nodeInfo{node: ev.Node, opts: ev.Options, seen: ev.seen()}
+		if err := m.attach(info); err != nil {
+			return err
+		}
+	case NodeRemoved:
+		if !ok {
+			return errors.Errorf("node %d not found", n)
+		}
+		m.detach(info)
+		delete(m.nodes, n)
+		return nil
+	case StateChanged:
+		if !ok {
+			return errors.Errorf("node %d not found", n)
+		} else if ev.State == info.state {
+			return nil
+		}
+		switch ev.State {
+		case NodeOnline, NodeDraining, NodeOffline:
+		default:
+			return errors.Errorf("invalid state %d", ev.State)
+		}
+		m.detach(info)
+		info.state, info.expired = ev.State, false
+		if err := m.attach(info); err != nil {
+			return err
+		}
+	case AttributeChanged:
+		if !ok {
+			return errors.Errorf("node %d not found", n)
+		} else if err := checkOptions(m.all.schema, ev.Options); err != nil {
+			return err
+		}
+		m.detach(info)
+		info.node, info.opts, info.seen = ev.Node, ev.Options, ev.seen()
+		if err := m.attach(info); err != nil {
+			return err
+		}
+	case Heartbeat:
+		if !ok {
+			return errors.Errorf("node %d not found", n)
+		}
+		info.seen = ev.seen()
+		if info.expired {
+			info.state, info.expired = info.resume, false
+			if err := m.attach(info); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("invalid event type %d", ev.Type)
+	}
+
+	m.nodes[n] = info
+	return nil
+}
+
+// UpdateNode calls fn for node with index n and stores modified node, e.g.
+// with new capacity or price reported by the node. It is much cheaper than
+// AttributeChanged event, because options are kept and the bucket tree isn't
+// rebuilt. Weights of the buckets containing the node are reset, because they
+// depend on node attributes, so they must be recomputed by TraverseTree.
+// fn must not change index of the node.
+func (m *NetMap) UpdateNode(n uint32, fn func(*Node)) error {
+	info, ok := m.nodes[n]
+	if !ok {
+		return errors.Errorf("node %d not found", n)
+	}
+
+	node := info.node
+	fn(&node)
+	if node.N != n {
+		return errors.Errorf("index of node %d can't be changed to %d", n, node.N)
+	}
+	if info.state.attached() {
+		m.all.replaceNode(node)
+	}
+	if info.state == NodeOnline {
+		m.root.replaceNode(node)
+	}
+	info.node = node
+	m.nodes[n] = info
+	return nil
+}
+
+// replaceNode replaces node with index n.N in b and its sub-buckets and
+// resets their weights. Buckets without the node are left intact.
+func (b *Bucket) replaceNode(n Node) {
+	i := sort.Search(len(b.nodes), func(i int) bool { return b.nodes[i].N >= n.N })
+	if i == len(b.nodes) || b.nodes[i].N != n.N {
+		return
+	}
+
+	b.nodes = append(Nodes(nil), b.nodes...)
+	b.nodes[i] = n
+	b.weight = 0
+
+	b.ownChildren()
+	for i := range b.children {
+		b.children[i].replaceNode(n)
+	}
+}
+
+// LastSeen returns the moment node with index n was seen for the last time.
+func (m *NetMap) LastSeen(n uint32) (time.Time, bool) {
+	info, ok := m.nodes[n]
+	return info.seen, ok
+}
+
+// ExpireStale sets offline online and draining nodes which were not seen for
+// maxAge and removes nodes which were not seen for ExpireRemoveFactor*maxAge.
+// Nodes set offline this way return to their previous state on the next
+// heartbeat. Applied events are returned in the order of node indices.
+func (m *NetMap) ExpireStale(maxAge time.Duration) []Event {
+	var (
+		evs []Event
+		ns  = make([]uint32, 0, len(m.nodes))
+		now = time.Now()
+	)
+	for n := range m.nodes {
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool { return ns[i] < ns[j] })
+
+	for _, n := range ns {
+		info := m.nodes[n]
+		age := now.Sub(info.seen)
+		switch {
+		case age > ExpireRemoveFactor*maxAge:
+			m.detach(info)
+			delete(m.nodes, n)
+			evs = append(evs, Event{Type: NodeRemoved, Node: Node{N: n}})
+		case age > maxAge && info.state.attached():
+			m.detach(info)
+			info.state, info.expired, info.resume = NodeOffline, true, info.state
+			m.nodes[n] = info
+			evs = append(evs, Event{Type: StateChanged, Node: Node{N: n}, State: NodeOffline})
+		}
+	}
+	return evs
+}
+
+func (ev Event) seen() time.Time {
+	if ev.Time.IsZero() {
+		return time.Now()
+	}
+	return ev.Time
+}
+
+// attach adds node to the bucket trees according to its state.
+func (m *NetMap) attach(info nodeInfo) error {
+	if !info.state.attached() {
+		return nil
+	}
+	if err := m.all.addNode(info.node, info.opts...); err != nil {
+		return err
+	}
+	if info.state == NodeOnline {
+		return m.root.addNode(info.node, info.opts...)
+	}
+	return nil
+}
+
+// detach removes node from the bucket trees.
+func (m *NetMap) detach(info nodeInfo) {
+	if !info.state.attached() {
+		return
+	}
+	rm := map[uint32]struct{}{info.node.N: {}}
+	for _, o := range info.opts {
+		m.all.removeNodes(splitPath(o), rm)
+		if info.state == NodeOnline {
+			m.root.removeNodes(splitPath(o), rm)
+		}
+	}
+}
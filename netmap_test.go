@@ -0,0 +1,182 @@
+package netmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetMap_Apply(t *testing.T) {
+	m := NewNetMap()
+	events := []Event{
+		{Type: NodeAdded, Node: Node{N: 1, C: 10}, Options: []string{"/Location:Europe/Country:Germany"}},
+		{Type: NodeAdded, Node: Node{N: 2, C: 20}, Options: []string{"/Location:Europe/Country:Spain"}},
+		{Type: NodeAdded, Node: Node{N: 3, C: 30}, Options: []string{"/Location:Asia/Country:China"}},
+	}
+	for _, ev := range events {
+		require.NoError(t, m.Apply(ev))
+	}
+
+	root := m.Root()
+	require.Equal(t, []uint32{1, 2, 3}, root.Nodelist().Nodes())
+	require.Error(t, m.Apply(events[0]))
+	require.Error(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 4}, Options: []string{"Country:Spain"}}))
+
+	t.Run("state changed", func(t *testing.T) {
+		old := m.Root().Copy()
+		require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeOffline}))
+		require.Equal(t, old, root)
+
+		root := m.Root()
+		require.Equal(t, []uint32{1, 3}, root.Nodelist().Nodes())
+		require.Empty(t, root.Query("/Location:Europe/Country:Spain"))
+
+		n, st, ok := m.Node(2)
+		require.True(t, ok)
+		require.Equal(t, NodeOffline, st)
+		require.Equal(t, uint64(20), n.C)
+
+		require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeOnline}))
+		root = m.Root()
+		require.Equal(t, []uint32{1, 2, 3}, root.Nodelist().Nodes())
+		require.Equal(t, []uint32{2}, root.GetNodesByOption("/Location:Europe/Country:Spain").Nodes())
+	})
+
+	t.Run("attribute changed", func(t *testing.T) {
+		ev := Event{Type: AttributeChanged, Node: Node{N: 1, C: 15}, Options: []string{"/Location:Europe/Country:France"}}
+		require.NoError(t, m.Apply(ev))
+
+		root := m.Root()
+		require.Empty(t, root.Query("/Location:Europe/Country:Germany"))
+		require.Equal(t, Nodes{{N: 1, C: 15}}, root.GetNodesByOption("/Location:Europe/Country:France"))
+	})
+
+	t.Run("node removed", func(t *testing.T) {
+		require.NoError(t, m.Apply(Event{Type: NodeRemoved, Node: Node{N: 3}}))
+		require.Error(t, m.Apply(Event{Type: NodeRemoved, Node: Node{N: 3}}))
+
+		_, _, ok := m.Node(3)
+		require.False(t, ok)
+
+		root := m.Root()
+		require.Equal(t, []uint32{1, 2}, root.Nodelist().Nodes())
+		require.Empty(t, root.Query("/Location:Asia"))
+	})
+
+	t.Run("fold", func(t *testing.T) {
+		m1 := NewNetMap()
+		for _, ev := range []Event{
+			{Type: NodeAdded, Node: Node{N: 1, C: 10}, Options: []string{"/Location:Europe/Country:Germany"}},
+			{Type: NodeAdded, Node: Node{N: 2, C: 20}, Options: []string{"/Location:Europe/Country:Spain"}},
+			{Type: AttributeChanged, Node: Node{N: 1, C: 15}, Options: []string{"/Location:Europe/Country:France"}},
+		} {
+			require.NoError(t, m1.Apply(ev))
+		}
+		require.Equal(t, m.Root().Digest(), m1.Root().Digest())
+	})
+
+	t.Run("update node", func(t *testing.T) {
+		old := m.Root()
+		require.NoError(t, m.UpdateNode(1, func(n *Node) { n.C, n.P = 25, 3 }))
+		require.Equal(t, Nodes{{N: 1, C: 15}}, old.GetNodesByOption("/Location:Europe/Country:France"))
+
+		root := m.Root()
+		require.Equal(t, Nodes{{N: 1, C: 25, P: 3}}, root.GetNodesByOption("/Location:Europe/Country:France"))
+		require.Equal(t, Nodes{{N: 1, C: 25, P: 3}}, root.GetNodesByOption("/Location:Europe")[:1])
+
+		n, _, _ := m.Node(1)
+		require.Equal(t, uint64(25), n.C)
+
+		require.Error(t, m.UpdateNode(42, func(*Node) {}))
+		require.Error(t, m.UpdateNode(1, func(n *Node) { n.N = 2 }))
+
+		require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeOffline}))
+		require.NoError(t, m.UpdateNode(2, func(n *Node) { n.C = 30 }))
+		require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeOnline}))
+		root = m.Root()
+		require.Equal(t, Nodes{{N: 2, C: 30}}, root.GetNodesByOption("/Location:Europe/Country:Spain"))
+	})
+}
+
+func TestNetMap_ExpireStale(t *testing.T) {
+	var (
+		m   = NewNetMap()
+		now = time.Now()
+		opt = []string{"/Location:Europe"}
+	)
+	for _, ev := range []Event{
+		{Type: NodeAdded, Node: Node{N: 1}, Options: opt, Time: now},
+		{Type: NodeAdded, Node: Node{N: 2}, Options: opt, Time: now.Add(-90 * time.Minute)},
+		{Type: NodeAdded, Node: Node{N: 3}, Options: opt, Time: now.Add(-3 * time.Hour)},
+		{Type: NodeAdded, Node: Node{N: 4}, Options: opt, Time: now.Add(-90 * time.Minute)},
+		{Type: StateChanged, Node: Node{N: 4}, State: NodeOffline},
+	} {
+		require.NoError(t, m.Apply(ev))
+	}
+
+	seen, ok := m.LastSeen(2)
+	require.True(t, ok)
+	require.True(t, seen.Equal(now.Add(-90*time.Minute)))
+
+	require.Equal(t, []Event{
+		{Type: StateChanged, Node: Node{N: 2}, State: NodeOffline},
+		{Type: NodeRemoved, Node: Node{N: 3}},
+	}, m.ExpireStale(time.Hour))
+
+	root := m.Root()
+	require.Equal(t, []uint32{1}, root.Nodelist().Nodes())
+	_, _, ok = m.Node(3)
+	require.False(t, ok)
+	require.Empty(t, m.ExpireStale(time.Hour))
+
+	// expired node goes online on heartbeat, node set offline explicitly doesn't
+	require.NoError(t, m.Apply(Event{Type: Heartbeat, Node: Node{N: 2}}))
+	require.NoError(t, m.Apply(Event{Type: Heartbeat, Node: Node{N: 4}}))
+	require.Error(t, m.Apply(Event{Type: Heartbeat, Node: Node{N: 3}}))
+
+	root = m.Root()
+	require.Equal(t, []uint32{1, 2}, root.Nodelist().Nodes())
+	_, st, _ := m.Node(4)
+	require.Equal(t, NodeOffline, st)
+}
+
+func TestNetMap_InnerBucketNodes(t *testing.T) {
+	m := NewNetMap()
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 5}, Options: []string{"/Location:Europe"}}))
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 6}, Options: []string{"/Location:Europe/Country:DE"}}))
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 7}, Options: []string{"/Location:Europe/Country:DE"}}))
+	require.Equal(t, []uint32{5, 6, 7}, m.Root().Nodelist().Nodes())
+
+	require.NoError(t, m.Apply(Event{Type: StateChanged, Node: Node{N: 6}, State: NodeOffline}))
+	require.Equal(t, []uint32{5, 7}, m.Root().Nodelist().Nodes())
+
+	require.NoError(t, m.Apply(Event{Type: NodeRemoved, Node: Node{N: 7}}))
+	root := m.Root()
+	require.Equal(t, []uint32{5}, root.Nodelist().Nodes())
+	require.Equal(t, []uint32{5}, root.GetNodesByOption("/Location:Europe").Nodes())
+
+	require.NoError(t, m.Apply(Event{Type: NodeRemoved, Node: Node{N: 5}}))
+	require.Empty(t, m.Root().Nodelist())
+}
+
+func TestNetMap_Copy(t *testing.T) {
+	m := NewNetMap()
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 1}, Options: []string{"/Location:Europe/Country:DE"}}))
+	require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 2}, Options: []string{"/Location:Europe/Country:FR"}}))
+
+	c := m.Copy()
+	require.NoError(t, c.Apply(Event{Type: NodeRemoved, Node: Node{N: 1}}))
+	require.NoError(t, c.Apply(Event{Type: NodeAdded, Node: Node{N: 3}, Options: []string{"/Location:Europe/Country:DE"}}))
+	require.NoError(t, c.Apply(Event{Type: StateChanged, Node: Node{N: 2}, State: NodeDraining}))
+	require.Equal(t, []uint32{3}, c.Root().Nodelist().Nodes())
+	require.Equal(t, []uint32{2, 3}, c.RootWithDraining().Nodelist().Nodes())
+
+	require.Equal(t, []uint32{1, 2}, m.Root().Nodelist().Nodes())
+	require.Equal(t, []uint32{1}, m.Root().GetNodesByOption("/Location:Europe/Country:DE").Nodes())
+	_, state, ok := m.Node(2)
+	require.True(t, ok)
+	require.Equal(t, NodeOnline, state)
+	_, _, ok = m.Node(3)
+	require.False(t, ok)
+}
@@ -0,0 +1,462 @@
+// Package netmapneofs converts placement policies between this package and
+// the NeoFS API. Types of the package mirror messages of NeoFS API v2 netmap
+// package (PlacementPolicy, Replica, Selector, Filter) field by field, so
+// that policies of NeoFS tooling are converted by plain field copying
+// without making NeoFS API a dependency of the netmap.
+package netmapneofs
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+type (
+	// PlacementPolicy is a NeoFS container placement policy.
+	PlacementPolicy struct {
+		Replicas []Replica
+		// ContainerBackupFactor is the number of nodes chosen in every
+		// bucket of a selector, zero means DefaultCBF.
+		ContainerBackupFactor uint32
+		Selectors             []Selector
+		Filters               []Filter
+	}
+
+	// Replica is a number of object copies stored on nodes of selector.
+	// Empty Selector refers to the only selector of the policy.
+	Replica struct {
+		Count    uint32
+		Selector string
+	}
+
+	// Selector chooses Count buckets of nodes with different (Clause
+	// DISTINCT) or the same (Clause SAME) value of Attribute among nodes
+	// satisfying Filter. Empty attribute means that nodes are chosen
+	// directly, filter AllFilter or empty filter means that all nodes
+	// are considered.
+	Selector struct {
+		Name      string
+		Count     uint32
+		Clause    Clause
+		Attribute string
+		Filter    string
+	}
+
+	// Filter is a named filter of node attributes. Filter with AND or OR
+	// operation combines Filters, filter having only Name refers to the
+	// filter of the policy with the same name.
+	Filter struct {
+		Name    string
+		Key     string
+		Op      Operation
+		Value   string
+		Filters []Filter
+	}
+
+	// Clause is a selector clause.
+	Clause uint32
+
+	// Operation is a filter operation.
+	Operation uint32
+)
+
+// Selector clauses, values are the same as in NeoFS API.
+const (
+	ClauseUnspecified Clause = iota
+	ClauseSame
+	ClauseDistinct
+)
+
+// Filter operations, values are the same as in NeoFS API.
+const (
+	OpUnspecified Operation = iota
+	OpEQ
+	OpNE
+	OpGT
+	OpGE
+	OpLT
+	OpLE
+	OpOR
+	OpAND
+)
+
+const (
+	// DefaultCBF is a container backup factor used by NeoFS if it isn't set.
+	DefaultCBF = 3
+
+	// AllFilter is a name of the filter matching all nodes.
+	AllFilter = "*"
+)
+
+var opToNetmap = map[Operation]netmap.Operation{
+	OpEQ: netmap.Operation_EQ,
+	OpNE: netmap.Operation_NE,
+	OpGT: netmap.Operation_GT,
+	OpGE: netmap.Operation_GE,
+	OpLT: netmap.Operation_LT,
+	OpLE: netmap.Operation_LE,
+}
+
+// FromNeoFS returns selection groups equivalent to policy p, one group for
+// every replica. Buckets of a DISTINCT selector are selected by attribute
+// with ContainerBackupFactor nodes in each, SAME selector chooses nodes
+// having the same attribute value. Replica count doesn't affect selection.
+func FromNeoFS(p PlacementPolicy) ([]netmap.SFGroup, error) {
+	cbf := p.ContainerBackupFactor
+	if cbf == 0 {
+		cbf = DefaultCBF
+	}
+
+	fs := make([]netmap.NamedFilter, 0, len(p.Filters))
+	for i := range p.Filters {
+		f, err := namedFilter(p.Filters[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "filter %s", p.Filters[i].Name)
+		}
+		fs = append(fs, f)
+	}
+
+	ss := make([]netmap.Selection, 0, len(p.Replicas))
+	for i, r := range p.Replicas {
+		if r.Count == 0 {
+			return nil, errors.Errorf("replica %d has zero count", i)
+		}
+
+		s, err := findSelector(p.Selectors, r.Selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "replica %d", i)
+		} else if s == nil {
+			// replica without selectors chooses nodes directly
+			s = &Selector{Count: r.Count}
+		}
+
+		sel, err := selection(*s, cbf)
+		if err != nil {
+			return nil, errors.Wrapf(err, "selector %s", s.Name)
+		}
+		ss = append(ss, sel)
+	}
+	return netmap.ResolvePolicy(fs, ss...)
+}
+
+// findSelector returns selector with the specified name. Empty name refers
+// to the only selector, nil is returned if there are no selectors at all.
+func findSelector(ss []Selector, name string) (*Selector, error) {
+	if name == "" {
+		switch len(ss) {
+		case 0:
+			return nil, nil
+		case 1:
+			return &ss[0], nil
+		default:
+			return nil, errors.New("selector must be specified")
+		}
+	}
+	for i := range ss {
+		if ss[i].Name == name {
+			return &ss[i], nil
+		}
+	}
+	return nil, errors.Errorf("unknown selector %s", name)
+}
+
+func selection(s Selector, cbf uint32) (netmap.Selection, error) {
+	var sel netmap.Selection
+
+	if s.Count == 0 {
+		return sel, errors.New("zero count")
+	}
+	if s.Filter != AllFilter {
+		sel.From = s.Filter
+	}
+
+	switch {
+	case s.Attribute == "":
+		sel.Selectors = []netmap.Select{{Key: netmap.NodesBucket, Count: s.Count * cbf}}
+	case s.Clause == ClauseSame:
+		sel.Selectors = []netmap.Select{{Key: netmap.NodesBucket, Count: s.Count * cbf, Same: s.Attribute}}
+	case s.Clause == ClauseDistinct || s.Clause == ClauseUnspecified:
+		sel.Selectors = []netmap.Select{
+			{Key: s.Attribute, Count: s.Count},
+			{Key: netmap.NodesBucket, Count: cbf},
+		}
+	default:
+		return sel, errors.Errorf("unknown clause %d", s.Clause)
+	}
+	return sel, nil
+}
+
+// namedFilter converts top-level filter f of NeoFS policy.
+func namedFilter(f Filter) (netmap.NamedFilter, error) {
+	nf := netmap.NamedFilter{Name: f.Name}
+	if f.Op != OpAND {
+		flt, err := convertFilter(f)
+		if err != nil {
+			return nf, err
+		}
+		nf.Filters = []netmap.Filter{flt}
+		return nf, nil
+	}
+
+	// all filters of a group must be satisfied, so AND is flattened
+	for i := range f.Filters {
+		sub := f.Filters[i]
+		switch {
+		case isReference(sub):
+			nf.Refs = append(nf.Refs, sub.Name)
+		case sub.Op == OpAND:
+			inner, err := namedFilter(Filter{Name: f.Name, Op: OpAND, Filters: sub.Filters})
+			if err != nil {
+				return nf, err
+			}
+			nf.Filters = append(nf.Filters, inner.Filters...)
+			nf.Refs = append(nf.Refs, inner.Refs...)
+		default:
+			flt, err := convertFilter(sub)
+			if err != nil {
+				return nf, err
+			}
+			nf.Filters = append(nf.Filters, flt)
+		}
+	}
+	return nf, nil
+}
+
+// convertFilter converts filter f of a single attribute.
+func convertFilter(f Filter) (netmap.Filter, error) {
+	key, sf, err := simpleFilter(f)
+	if err != nil {
+		return netmap.Filter{}, err
+	}
+	return netmap.Filter{Key: key, F: sf}, nil
+}
+
+// simpleFilter returns attribute checked by f and filter of its values.
+// OR and AND filters can only combine filters of the same attribute.
+func simpleFilter(f Filter) (string, *netmap.SimpleFilter, error) {
+	if isReference(f) {
+		return "", nil, errors.Errorf("reference to %s can't be combined", f.Name)
+	}
+
+	if op, ok := opToNetmap[f.Op]; ok {
+		if f.Key == "" {
+			return "", nil, errors.New("filter has no key")
+		}
+		return f.Key, netmap.NewFilter(op, f.Value), nil
+	}
+
+	if f.Op != OpOR && f.Op != OpAND {
+		return "", nil, errors.Errorf("unknown operation %d", f.Op)
+	} else if len(f.Filters) == 0 {
+		return "", nil, errors.New("no filters to combine")
+	}
+
+	var (
+		key string
+		sfs = make([]*netmap.SimpleFilter, 0, len(f.Filters))
+	)
+	for i := range f.Filters {
+		k, sf, err := simpleFilter(f.Filters[i])
+		if err != nil {
+			return "", nil, err
+		} else if i != 0 && k != key {
+			return "", nil, errors.Errorf("can't combine filters of %s and %s", key, k)
+		}
+		key = k
+		sfs = append(sfs, sf)
+	}
+	if f.Op == OpOR {
+		return key, netmap.FilterOR(sfs...), nil
+	}
+	return key, netmap.FilterAND(sfs...), nil
+}
+
+func isReference(f Filter) bool {
+	return f.Name != "" && f.Key == "" && f.Op == OpUnspecified && len(f.Filters) == 0
+}
+
+// ToNeoFS returns NeoFS policy equivalent to selection groups gs. Every
+// group becomes a replica with a selector and a filter of its own. Group
+// must either choose nodes directly, possibly with the same value of some
+// attribute, or choose buckets of some attribute and then nodes from every
+// bucket. Number of nodes chosen from a bucket becomes ContainerBackupFactor,
+// so it must be the same in all groups and divide other node counts.
+// Exclusions, subnets, node filters, sub-policies, percentages and
+// aggregates have no counterpart in NeoFS and are rejected.
+func ToNeoFS(gs ...netmap.SFGroup) (PlacementPolicy, error) {
+	var p PlacementPolicy
+
+	if len(gs) == 0 {
+		return p, errors.New("policy has no selection groups")
+	}
+
+	cbf := uint32(1)
+	for i := range gs {
+		if ss := gs[i].Selectors; len(ss) == 2 && ss[1].Count != 0 {
+			cbf = ss[1].Count
+			break
+		}
+	}
+
+	for i := range gs {
+		name := "S" + strconv.Itoa(i)
+		s, err := toSelector(gs[i], cbf)
+		if err != nil {
+			return p, errors.Wrapf(err, "group %d", i)
+		}
+
+		s.Name = name
+		if len(gs[i].Filters) != 0 {
+			f, err := toFilter(gs[i].Filters)
+			if err != nil {
+				return p, errors.Wrapf(err, "group %d", i)
+			}
+			f.Name = "F" + strconv.Itoa(i)
+			s.Filter = f.Name
+			p.Filters = append(p.Filters, f)
+		} else {
+			s.Filter = AllFilter
+		}
+
+		p.Selectors = append(p.Selectors, s)
+		p.Replicas = append(p.Replicas, Replica{Count: s.Count, Selector: name})
+	}
+	p.ContainerBackupFactor = cbf
+	return p, nil
+}
+
+func toSelector(g netmap.SFGroup, cbf uint32) (Selector, error) {
+	var s Selector
+
+	switch {
+	case len(g.Exclude) != 0:
+		return s, errors.New("exclusions are not supported")
+	case g.Subnet != 0:
+		return s, errors.New("subnets are not supported")
+	case len(g.NodeFilters) != 0:
+		return s, errors.New("node filters are not supported")
+	}
+
+	for i := range g.Selectors {
+		sel := g.Selectors[i]
+		switch {
+		case sel.Percent != 0 || sel.Count == netmap.CountAll:
+			return s, errors.New("relative counts are not supported")
+		case sel.Aggregate != nil:
+			return s, errors.New("aggregate filters are not supported")
+		case sel.Distinct != "":
+			return s, errors.New("DISTINCT clauses are not supported")
+		}
+	}
+
+	ss := g.Selectors
+	switch {
+	case len(ss) == 1 && ss[0].Key == netmap.NodesBucket:
+		if ss[0].Count%cbf != 0 {
+			return s, errors.Errorf("node count %d is not a multiple of %d", ss[0].Count, cbf)
+		}
+		s.Count = ss[0].Count / cbf
+		if s.Attribute = ss[0].Same; s.Attribute != "" {
+			s.Clause = ClauseSame
+		}
+	case len(ss) == 2 && ss[0].Key != netmap.NodesBucket && ss[1].Key == netmap.NodesBucket:
+		if ss[0].Same != "" || ss[1].Same != "" {
+			return s, errors.New("SAME clauses of buckets are not supported")
+		} else if ss[1].Count != cbf {
+			return s, errors.Errorf("bucket node count %d differs from %d", ss[1].Count, cbf)
+		}
+		s.Count = ss[0].Count
+		s.Clause = ClauseDistinct
+		s.Attribute = ss[0].Key
+	default:
+		return s, errors.New("only one attribute can be selected")
+	}
+
+	if s.Count == 0 {
+		return s, errors.New("zero count")
+	}
+	return s, nil
+}
+
+// toFilter returns NeoFS filter satisfied when all fs are satisfied.
+func toFilter(fs []netmap.Filter) (Filter, error) {
+	result := make([]Filter, 0, len(fs))
+	for i := range fs {
+		if fs[i].GetSub() != nil {
+			return Filter{}, errors.New("sub-policies are not supported")
+		}
+		sf := fs[i].GetF()
+		if sf == nil {
+			return Filter{}, errors.Errorf("filter of %s is empty", fs[i].Key)
+		}
+		f, err := fromSimpleFilter(fs[i].Key, *sf)
+		if err != nil {
+			return Filter{}, errors.Wrapf(err, "filter of %s", fs[i].Key)
+		}
+		result = append(result, f)
+	}
+	if len(result) == 1 {
+		return result[0], nil
+	}
+	return Filter{Op: OpAND, Filters: result}, nil
+}
+
+// fromSimpleFilter converts filter sf of attribute key.
+func fromSimpleFilter(key string, sf netmap.SimpleFilter) (Filter, error) {
+	switch sf.Op {
+	case netmap.Operation_OR, netmap.Operation_AND:
+		args := sf.GetFArgs()
+		if args == nil || len(args.Filters) == 0 {
+			return Filter{}, errors.New("no filters to combine")
+		}
+		f := Filter{Op: OpOR}
+		if sf.Op == netmap.Operation_AND {
+			f.Op = OpAND
+		}
+		for i := range args.Filters {
+			sub, err := fromSimpleFilter(key, args.Filters[i])
+			if err != nil {
+				return Filter{}, err
+			}
+			f.Filters = append(f.Filters, sub)
+		}
+		return f, nil
+	case netmap.Operation_RANGE:
+		r := sf.GetRange()
+		if r == nil {
+			return Filter{}, errors.New("range is empty")
+		}
+		from, to, err := integerRange(*r)
+		if err != nil {
+			return Filter{}, err
+		}
+		return Filter{Op: OpAND, Filters: []Filter{
+			{Key: key, Op: OpGE, Value: strconv.FormatUint(from, 10)},
+			{Key: key, Op: OpLE, Value: strconv.FormatUint(to, 10)},
+		}}, nil
+	}
+
+	for op, nop := range opToNetmap {
+		if nop == sf.Op {
+			return Filter{Key: key, Op: op, Value: sf.GetValue()}, nil
+		}
+	}
+	return Filter{}, errors.Errorf("operation %s is not supported", sf.Op)
+}
+
+// integerRange returns bounds of non-negative integers within r, because
+// NeoFS compares values of numeric filters as unsigned integers.
+func integerRange(r netmap.Range) (uint64, uint64, error) {
+	// 2^64 is the least float64 not representable as uint64
+	const maxUint64 = float64(1 << 64)
+
+	from, to := math.Max(math.Ceil(r.From), 0), math.Floor(r.To)
+	if math.IsNaN(r.From) || math.IsNaN(r.To) || from > to || from >= maxUint64 {
+		return 0, 0, errors.Errorf("range %v..%v contains no unsigned integers", r.From, r.To)
+	} else if to >= maxUint64 {
+		return uint64(from), math.MaxUint64, nil
+	}
+	return uint64(from), uint64(to), nil
+}
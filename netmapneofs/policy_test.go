@@ -0,0 +1,208 @@
+package netmapneofs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromNeoFS(t *testing.T) {
+	// REP 1 IN X REP 2 IN Y CBF 2
+	// SELECT 2 IN DISTINCT Country FROM EU AS X
+	// SELECT 1 IN SAME City FROM * AS Y
+	// FILTER Location EQ Europe AS EU1
+	// FILTER Price LE 10 OR Price EQ 20 AND @EU1 AS EU
+	p := PlacementPolicy{
+		ContainerBackupFactor: 2,
+		Replicas:              []Replica{{Count: 1, Selector: "X"}, {Count: 2, Selector: "Y"}},
+		Selectors: []Selector{
+			{Name: "X", Count: 2, Clause: ClauseDistinct, Attribute: "Country", Filter: "EU"},
+			{Name: "Y", Count: 1, Clause: ClauseSame, Attribute: "City", Filter: AllFilter},
+		},
+		Filters: []Filter{
+			{Name: "EU1", Key: "Location", Op: OpEQ, Value: "Europe"},
+			{Name: "EU", Op: OpAND, Filters: []Filter{
+				{Op: OpOR, Filters: []Filter{
+					{Key: "Price", Op: OpLE, Value: "10"},
+					{Key: "Price", Op: OpEQ, Value: "20"},
+				}},
+				{Name: "EU1"},
+			}},
+		},
+	}
+
+	gs, err := FromNeoFS(p)
+	require.NoError(t, err)
+	require.Equal(t, []netmap.SFGroup{
+		{
+			Selectors: []netmap.Select{{Key: "Country", Count: 2}, {Key: netmap.NodesBucket, Count: 2}},
+			Filters: []netmap.Filter{
+				{Key: "Price", F: netmap.FilterOR(netmap.FilterLE(10), netmap.FilterEQ("20"))},
+				{Key: "Location", F: netmap.FilterEQ("Europe")},
+			},
+		},
+		{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 2, Same: "City"}}},
+	}, gs)
+
+	t.Run("defaults", func(t *testing.T) {
+		gs, err := FromNeoFS(PlacementPolicy{Replicas: []Replica{{Count: 2}}})
+		require.NoError(t, err)
+		require.Equal(t, []netmap.SFGroup{
+			{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 2 * DefaultCBF}}},
+		}, gs)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for name, p := range map[string]PlacementPolicy{
+			"zero replicas": {Replicas: []Replica{{Count: 0}}},
+			"unknown selector": {
+				Replicas:  []Replica{{Count: 1, Selector: "Z"}},
+				Selectors: []Selector{{Name: "X", Count: 1}},
+			},
+			"ambiguous selector": {
+				Replicas:  []Replica{{Count: 1}},
+				Selectors: []Selector{{Name: "X", Count: 1}, {Name: "Y", Count: 1}},
+			},
+			"unknown filter": {
+				Replicas:  []Replica{{Count: 1}},
+				Selectors: []Selector{{Name: "X", Count: 1, Filter: "F"}},
+			},
+			"different keys": {
+				Replicas: []Replica{{Count: 1}},
+				Filters: []Filter{{Name: "F", Op: OpOR, Filters: []Filter{
+					{Key: "A", Op: OpEQ, Value: "1"},
+					{Key: "B", Op: OpEQ, Value: "1"},
+				}}},
+			},
+			"cycle": {
+				Replicas: []Replica{{Count: 1}},
+				Filters: []Filter{
+					{Name: "F", Op: OpAND, Filters: []Filter{{Name: "G"}}},
+					{Name: "G", Op: OpAND, Filters: []Filter{{Name: "F"}}},
+				},
+			},
+		} {
+			_, err := FromNeoFS(p)
+			require.Error(t, err, name)
+		}
+	})
+}
+
+func TestToNeoFS(t *testing.T) {
+	gs := []netmap.SFGroup{
+		{
+			Selectors: []netmap.Select{{Key: "Country", Count: 2}, {Key: netmap.NodesBucket, Count: 2}},
+			Filters: []netmap.Filter{
+				{Key: "Location", F: netmap.FilterEQ("Europe")},
+				{Key: "Price", F: netmap.FilterRange(0.5, 10.5)},
+			},
+		},
+		{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 4, Same: "City"}}},
+		{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 2}}},
+	}
+
+	p, err := ToNeoFS(gs...)
+	require.NoError(t, err)
+	require.Equal(t, PlacementPolicy{
+		ContainerBackupFactor: 2,
+		Replicas: []Replica{
+			{Count: 2, Selector: "S0"},
+			{Count: 2, Selector: "S1"},
+			{Count: 1, Selector: "S2"},
+		},
+		Selectors: []Selector{
+			{Name: "S0", Count: 2, Clause: ClauseDistinct, Attribute: "Country", Filter: "F0"},
+			{Name: "S1", Count: 2, Clause: ClauseSame, Attribute: "City", Filter: AllFilter},
+			{Name: "S2", Count: 1, Filter: AllFilter},
+		},
+		Filters: []Filter{{Name: "F0", Op: OpAND, Filters: []Filter{
+			{Key: "Location", Op: OpEQ, Value: "Europe"},
+			{Op: OpAND, Filters: []Filter{
+				{Key: "Price", Op: OpGE, Value: "1"},
+				{Key: "Price", Op: OpLE, Value: "10"},
+			}},
+		}}},
+	}, p)
+
+	t.Run("round trip", func(t *testing.T) {
+		gs := gs[1:]
+		p, err := ToNeoFS(gs...)
+		require.NoError(t, err)
+
+		actual, err := FromNeoFS(p)
+		require.NoError(t, err)
+		require.Equal(t, gs, actual)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		nodes := []netmap.Select{{Key: netmap.NodesBucket, Count: 1}}
+		for name, gs := range map[string][]netmap.SFGroup{
+			"no groups":    nil,
+			"exclude":      {{Selectors: nodes, Exclude: []uint32{1}}},
+			"subnet":       {{Selectors: nodes, Subnet: 1}},
+			"node filters": {{Selectors: nodes, NodeFilters: []netmap.NodeFilter{netmap.MinCapacity(1)}}},
+			"percent":      {{Selectors: []netmap.Select{netmap.PercentSelect(10, netmap.NodesBucket, 1)}}},
+			"distinct": {{Selectors: []netmap.Select{
+				{Key: "Country", Count: 2, Distinct: "DC"},
+				{Key: netmap.NodesBucket, Count: 1},
+			}}},
+			"two attributes": {{Selectors: []netmap.Select{
+				{Key: "Country", Count: 2},
+				{Key: "City", Count: 2},
+				{Key: netmap.NodesBucket, Count: 1},
+			}}},
+			"different cbf": {
+				{Selectors: []netmap.Select{{Key: "Country", Count: 2}, {Key: netmap.NodesBucket, Count: 2}}},
+				{Selectors: []netmap.Select{{Key: "City", Count: 2}, {Key: netmap.NodesBucket, Count: 3}}},
+			},
+			"not a multiple of cbf": {
+				{Selectors: []netmap.Select{{Key: "Country", Count: 2}, {Key: netmap.NodesBucket, Count: 2}}},
+				{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 3}}},
+			},
+			"sub-policy": {{
+				Selectors: nodes,
+				Filters:   []netmap.Filter{netmap.SubFilter("City", netmap.SFGroup{Selectors: nodes})},
+			}},
+			"range without integers": {{
+				Selectors: nodes,
+				Filters:   []netmap.Filter{{Key: "Price", F: netmap.FilterRange(0.2, 0.8)}},
+			}},
+			"negative range": {{
+				Selectors: nodes,
+				Filters:   []netmap.Filter{{Key: "Price", F: netmap.FilterRange(-5, -1)}},
+			}},
+		} {
+			_, err := ToNeoFS(gs...)
+			require.Error(t, err, name)
+		}
+	})
+}
+
+func TestIntegerRange(t *testing.T) {
+	for _, tc := range []struct {
+		r        netmap.Range
+		from, to uint64
+	}{
+		{netmap.Range{From: 1, To: 10}, 1, 10},
+		{netmap.Range{From: 0.5, To: 10.5}, 1, 10},
+		{netmap.Range{From: -3, To: 2}, 0, 2},
+		{netmap.Range{From: 5, To: 1e30}, 5, math.MaxUint64},
+	} {
+		from, to, err := integerRange(tc.r)
+		require.NoError(t, err)
+		require.Equal(t, tc.from, from, tc.r)
+		require.Equal(t, tc.to, to, tc.r)
+	}
+
+	for _, r := range []netmap.Range{
+		{From: 0.2, To: 0.8},
+		{From: -5, To: -1},
+		{From: 1e30, To: 1e31},
+		{From: math.NaN(), To: 1},
+	} {
+		_, _, err := integerRange(r)
+		require.Error(t, err, r)
+	}
+}
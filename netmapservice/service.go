@@ -0,0 +1,87 @@
+// Package netmapservice provides remote access to the netmap and container
+// placement over gRPC.
+package netmapservice
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server serves netmap requests from the in-memory netmap.
+// It implements NetmapServer and can be registered by RegisterNetmapServer.
+type Server struct {
+	mu    sync.RWMutex
+	m     *netmap.NetMap
+	epoch uint64
+}
+
+// New returns server backed by netmap m of the specified epoch.
+func New(m *netmap.NetMap, epoch uint64) *Server {
+	return &Server{m: m, epoch: epoch}
+}
+
+// Update applies events evs to the netmap and advances it to the new epoch.
+// Events are applied atomically: if some event can't be applied, the error
+// is returned and neither the netmap nor the epoch is changed.
+func (s *Server) Update(epoch uint64, evs ...netmap.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if epoch < s.epoch {
+		return errors.Errorf("epoch %d is older than current %d", epoch, s.epoch)
+	}
+	m := s.m.Copy()
+	for i := range evs {
+		if err := m.Apply(evs[i]); err != nil {
+			return errors.Wrapf(err, "can't apply event #%d", i)
+		}
+	}
+	*s.m = *m
+	s.epoch = epoch
+	return nil
+}
+
+// state returns snapshot of the netmap along with its epoch.
+func (s *Server) state() (netmap.Bucket, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Root(), s.epoch
+}
+
+var _ NetmapServer = (*Server)(nil)
+
+// Snapshot returns current netmap in binary format.
+func (s *Server) Snapshot(_ context.Context, _ *SnapshotRequest) (*SnapshotResponse, error) {
+	root, epoch := s.state()
+	data, err := root.MarshalBinary()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &SnapshotResponse{Epoch: epoch, Netmap: data}, nil
+}
+
+// Epoch returns current netmap epoch.
+func (s *Server) Epoch(_ context.Context, _ *EpochRequest) (*EpochResponse, error) {
+	_, epoch := s.state()
+	return &EpochResponse{Epoch: epoch}, nil
+}
+
+// PlaceContainer returns nodes of the current netmap selected for
+// the container identified by the request pivot.
+func (s *Server) PlaceContainer(_ context.Context, req *PlaceContainerRequest) (*PlaceContainerResponse, error) {
+	if len(req.Rule.SFGroups) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "placement rule is empty")
+	}
+
+	root, epoch := s.state()
+	g := root.FindGraph(req.Pivot, req.Rule.SFGroups...)
+	if g == nil {
+		return nil, status.Error(codes.FailedPrecondition, "placement rule can't be satisfied")
+	}
+	return &PlaceContainerResponse{Epoch: epoch, Nodes: g.Nodelist().Nodes()}, nil
+}
@@ -0,0 +1,115 @@
+package netmapservice
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServer(t *testing.T) {
+	var (
+		ctx = context.Background()
+		s   = New(netmap.NewNetMap(), 1)
+	)
+
+	require.NoError(t, s.Update(2,
+		netmap.Event{Type: netmap.NodeAdded, Node: netmap.Node{N: 1, C: 10}, Options: []string{"/Location:Europe/Country:Germany"}},
+		netmap.Event{Type: netmap.NodeAdded, Node: netmap.Node{N: 2, C: 20}, Options: []string{"/Location:Europe/Country:Spain"}},
+		netmap.Event{Type: netmap.NodeAdded, Node: netmap.Node{N: 3, C: 30}, Options: []string{"/Location:Asia/Country:China"}},
+	))
+
+	er, err := s.Epoch(ctx, new(EpochRequest))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), er.Epoch)
+
+	sr, err := s.Snapshot(ctx, new(SnapshotRequest))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), sr.Epoch)
+
+	var b netmap.Bucket
+	require.NoError(t, b.UnmarshalBinary(sr.Netmap))
+	require.Equal(t, []uint32{1, 2, 3}, b.Nodelist().Nodes())
+
+	req := &PlaceContainerRequest{
+		Pivot: []byte("container"),
+		Rule: netmap.PlacementRule{SFGroups: []netmap.SFGroup{{
+			Selectors: []netmap.Select{
+				{Key: "Location", Count: 2},
+				{Key: netmap.NodesBucket, Count: 1},
+			},
+		}}},
+	}
+	pr, err := s.PlaceContainer(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), pr.Epoch)
+	require.Len(t, pr.Nodes, 2)
+
+	// request passes through protobuf encoding
+	data, err := req.Marshal()
+	require.NoError(t, err)
+	decoded := new(PlaceContainerRequest)
+	require.NoError(t, decoded.Unmarshal(data))
+	pr1, err := s.PlaceContainer(ctx, decoded)
+	require.NoError(t, err)
+	require.Equal(t, pr.Nodes, pr1.Nodes)
+
+	req.Rule.SFGroups[0].Selectors[0].Count = 3
+	_, err = s.PlaceContainer(ctx, req)
+	require.Error(t, err)
+
+	_, err = s.PlaceContainer(ctx, new(PlaceContainerRequest))
+	require.Error(t, err)
+
+	t.Run("update", func(t *testing.T) {
+		require.Error(t, s.Update(1))
+		require.Error(t, s.Update(3, netmap.Event{Type: netmap.NodeRemoved, Node: netmap.Node{N: 4}}))
+		require.Error(t, s.Update(3,
+			netmap.Event{Type: netmap.NodeRemoved, Node: netmap.Node{N: 1}},
+			netmap.Event{Type: netmap.NodeRemoved, Node: netmap.Node{N: 4}}))
+
+		er, err := s.Epoch(ctx, new(EpochRequest))
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), er.Epoch)
+
+		// events preceding the failed one are not applied
+		sr, err := s.Snapshot(ctx, new(SnapshotRequest))
+		require.NoError(t, err)
+		var b netmap.Bucket
+		require.NoError(t, b.UnmarshalBinary(sr.Netmap))
+		require.Equal(t, []uint32{1, 2, 3}, b.Nodelist().Nodes())
+	})
+}
+
+func TestServer_GRPC(t *testing.T) {
+	var (
+		ctx = context.Background()
+		lis = bufconn.Listen(1 << 20)
+		srv = grpc.NewServer()
+	)
+
+	m := netmap.NewNetMap()
+	require.NoError(t, m.Apply(netmap.Event{Type: netmap.NodeAdded, Node: netmap.Node{N: 1}, Options: []string{"/Location:Europe"}}))
+	RegisterNetmapServer(srv, New(m, 7))
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	cli := NewNetmapClient(conn)
+	er, err := cli.Epoch(ctx, new(EpochRequest))
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), er.Epoch)
+
+	_, err = cli.PlaceContainer(ctx, new(PlaceContainerRequest))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
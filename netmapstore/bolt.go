@@ -0,0 +1,395 @@
+package netmapstore
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+)
+
+type (
+	// BoltStore keeps netmaps of different epochs in BoltDB.
+	BoltStore struct {
+		db        *bbolt.DB
+		retention Retention
+		// every and keep are parameters of compaction, zero every
+		// means that all netmaps are stored in full.
+		every int
+		keep  int
+	}
+
+	// Retention returns epochs which must be removed, epochs are
+	// passed in ascending order.
+	Retention func(epochs []uint64) []uint64
+
+	// Option is an optional parameter of BoltStore.
+	Option func(*BoltStore)
+)
+
+var (
+	netmapsBucket = []byte("netmaps")
+	// deltasBucket contains changes of netmaps relative to the netmap
+	// of the previous stored epoch.
+	deltasBucket = []byte("deltas")
+)
+
+// WithRetention returns option which sets retention policy applied
+// every time new netmap is saved. By default all netmaps are kept.
+func WithRetention(r Retention) Option {
+	return func(s *BoltStore) {
+		s.retention = r
+	}
+}
+
+// WithCompaction returns option which makes store keep only every-th netmap
+// in full and changes relative to the previous epoch for netmaps in between.
+// Only keep latest full netmaps are retained along with the changes following
+// them, so that disk usage is bounded. Netmaps of all retained epochs can be
+// loaded as usual. Zero keep means that all full netmaps are retained.
+func WithCompaction(every, keep int) Option {
+	return func(s *BoltStore) {
+		s.every = every
+		s.keep = keep
+	}
+}
+
+// KeepLast returns retention policy keeping only n latest netmaps.
+// The latest netmap is always kept, so n less than 1 is treated as 1.
+func KeepLast(n int) Retention {
+	if n < 1 {
+		n = 1
+	}
+	return func(epochs []uint64) []uint64 {
+		if len(epochs) <= n {
+			return nil
+		}
+		return epochs[:len(epochs)-n]
+	}
+}
+
+// KeepEpochs returns retention policy keeping netmaps of the latest
+// n epochs, i.e. netmaps older than latest epoch by n or more are removed.
+// The latest netmap is always kept, so zero n is treated as 1.
+func KeepEpochs(n uint64) Retention {
+	if n == 0 {
+		n = 1
+	}
+	return func(epochs []uint64) []uint64 {
+		if len(epochs) == 0 {
+			return nil
+		}
+		latest := epochs[len(epochs)-1]
+		i := sort.Search(len(epochs), func(i int) bool { return latest-epochs[i] < n })
+		return epochs[:i]
+	}
+}
+
+// OpenBolt opens BoltDB at path creating it if needed.
+func OpenBolt(path string, opts ...Option) (*BoltStore, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open %s", path)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(netmapsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(deltasBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	s := &BoltStore{db: db}
+	for _, o := range opts {
+		o(s)
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Save stores netmap b of the specified epoch replacing the existing one
+// and applies retention policy and compaction.
+func (s *BoltStore) Save(epoch uint64, b netmap.Bucket) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := s.save(tx, epoch, b); err != nil {
+			return err
+		}
+
+		if s.retention != nil {
+			for _, e := range s.retention(allEpochs(tx)) {
+				if err := remove(tx, e); err != nil {
+					return errors.Wrapf(err, "can't remove netmap of epoch %d", e)
+				}
+			}
+		}
+		return s.prune(tx)
+	})
+}
+
+func (s *BoltStore) save(tx *bbolt.Tx, epoch uint64, b netmap.Bucket) error {
+	var (
+		key    = epochKey(epoch)
+		full   = tx.Bucket(netmapsBucket)
+		deltas = tx.Bucket(deltasBucket)
+		epochs = allEpochs(tx)
+		i      = sort.Search(len(epochs), func(i int) bool { return epochs[i] >= epoch })
+		next   *netmap.Bucket
+	)
+
+	// changes of the following epoch must be rebased on the new netmap
+	j := i
+	if j < len(epochs) && epochs[j] == epoch {
+		j++
+	}
+	if j < len(epochs) && deltas.Get(epochKey(epochs[j])) != nil {
+		nb, err := load(tx, epochs[j])
+		if err != nil {
+			return err
+		}
+		next = &nb
+	}
+
+	// epoch is stored as changes if it replaces changes or follows the
+	// latest netmap which is not the every-th one
+	asDelta := deltas.Get(key) != nil ||
+		s.every > 0 && i == len(epochs) && i != 0 && deltasSince(tx, epochs[i-1]) < s.every-1
+	var d *netmap.Delta
+	if asDelta && i != 0 {
+		prev, err := load(tx, epochs[i-1])
+		if err != nil {
+			return err
+		}
+		d = diff(prev, b)
+	}
+	if d != nil {
+		if err := putDelta(deltas, key, d); err != nil {
+			return err
+		}
+	} else if err := putFull(full, deltas, key, b); err != nil {
+		return err
+	}
+
+	if next == nil {
+		return nil
+	}
+	if d = diff(b, *next); d != nil {
+		return putDelta(deltas, epochKey(epochs[j]), d)
+	}
+	return putFull(full, deltas, epochKey(epochs[j]), *next)
+}
+
+// diff returns changes turning prev into b or nil if applying
+// them doesn't reproduce b exactly, so that b must be stored in full.
+func diff(prev, b netmap.Bucket) *netmap.Delta {
+	d := b.Diff(prev)
+
+	c := prev.Copy()
+	if err := c.ApplyDelta(d); err != nil || c.Digest() != b.Digest() {
+		return nil
+	}
+	return d
+}
+
+func putDelta(bkt *bbolt.Bucket, key []byte, d *netmap.Delta) error {
+	data, err := d.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return bkt.Put(key, data)
+}
+
+func putFull(full, deltas *bbolt.Bucket, key []byte, b netmap.Bucket) error {
+	data, err := b.MarshalBinaryVersion(netmap.FormatV2)
+	if err != nil {
+		return err
+	}
+	if err := deltas.Delete(key); err != nil {
+		return err
+	}
+	return full.Put(key, data)
+}
+
+// prune removes all netmaps preceding s.keep latest full netmaps.
+func (s *BoltStore) prune(tx *bbolt.Tx) error {
+	if s.every == 0 || s.keep <= 0 {
+		return nil
+	}
+
+	fulls := listEpochs(tx.Bucket(netmapsBucket))
+	if len(fulls) <= s.keep {
+		return nil
+	}
+
+	first := fulls[len(fulls)-s.keep]
+	for _, name := range [][]byte{netmapsBucket, deltasBucket} {
+		bkt := tx.Bucket(name)
+		for _, e := range listEpochs(bkt) {
+			if e >= first {
+				break
+			} else if err := bkt.Delete(epochKey(e)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Load returns netmap of the specified epoch. If only changes are stored
+// for the epoch, netmap is reconstructed from the preceding full netmap.
+func (s *BoltStore) Load(epoch uint64) (netmap.Bucket, error) {
+	var b netmap.Bucket
+	err := s.db.View(func(tx *bbolt.Tx) (err error) {
+		b, err = load(tx, epoch)
+		return err
+	})
+	return b, err
+}
+
+// load reconstructs netmap of the specified epoch.
+func load(tx *bbolt.Tx, epoch uint64) (netmap.Bucket, error) {
+	var (
+		b      netmap.Bucket
+		key    = epochKey(epoch)
+		deltas = tx.Bucket(deltasBucket)
+	)
+
+	if data := tx.Bucket(netmapsBucket).Get(key); data != nil {
+		return b, b.UnmarshalBinary(data)
+	} else if deltas.Get(key) == nil {
+		return b, ErrNotFound
+	}
+
+	base, ok := lastFull(tx, epoch)
+	if !ok {
+		return b, errors.Errorf("no full netmap precedes epoch %d", epoch)
+	}
+	if err := b.UnmarshalBinary(tx.Bucket(netmapsBucket).Get(epochKey(base))); err != nil {
+		return b, err
+	}
+
+	c := deltas.Cursor()
+	for k, v := c.Seek(epochKey(base + 1)); k != nil && binary.BigEndian.Uint64(k) <= epoch; k, v = c.Next() {
+		var d netmap.Delta
+		if err := d.UnmarshalBinary(v); err != nil {
+			return b, errors.Wrapf(err, "can't decode changes of epoch %d", binary.BigEndian.Uint64(k))
+		} else if err := b.ApplyDelta(&d); err != nil {
+			return b, errors.Wrapf(err, "can't apply changes of epoch %d", binary.BigEndian.Uint64(k))
+		}
+	}
+	return b, nil
+}
+
+// lastFull returns the latest epoch not after epoch having full netmap.
+func lastFull(tx *bbolt.Tx, epoch uint64) (uint64, bool) {
+	c := tx.Bucket(netmapsBucket).Cursor()
+	k, _ := c.Seek(epochKey(epoch))
+	if k == nil || binary.BigEndian.Uint64(k) > epoch {
+		k, _ = c.Prev()
+	}
+	if k == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(k), true
+}
+
+// deltasSince returns number of epochs stored as changes since the latest
+// full netmap not after epoch.
+func deltasSince(tx *bbolt.Tx, epoch uint64) int {
+	base, ok := lastFull(tx, epoch)
+	if !ok {
+		return 0
+	}
+
+	var n int
+	c := tx.Bucket(deltasBucket).Cursor()
+	for k, _ := c.Seek(epochKey(base)); k != nil && binary.BigEndian.Uint64(k) <= epoch; k, _ = c.Next() {
+		n++
+	}
+	return n
+}
+
+// materializeNext stores netmap of the epoch following epoch in full,
+// if only its changes are stored.
+func materializeNext(tx *bbolt.Tx, epoch uint64) error {
+	deltas := tx.Bucket(deltasBucket)
+	k, _ := deltas.Cursor().Seek(epochKey(epoch + 1))
+	if k == nil {
+		return nil
+	}
+
+	// full netmap between epoch and k means that changes don't depend on epoch
+	next := binary.BigEndian.Uint64(k)
+	if base, ok := lastFull(tx, next); ok && base > epoch {
+		return nil
+	}
+
+	b, err := load(tx, next)
+	if err != nil {
+		return err
+	}
+	return putFull(tx.Bucket(netmapsBucket), deltas, k, b)
+}
+
+// remove removes netmap of the specified epoch keeping following ones intact.
+func remove(tx *bbolt.Tx, epoch uint64) error {
+	if err := materializeNext(tx, epoch); err != nil {
+		return err
+	}
+	if err := tx.Bucket(deltasBucket).Delete(epochKey(epoch)); err != nil {
+		return err
+	}
+	return tx.Bucket(netmapsBucket).Delete(epochKey(epoch))
+}
+
+// Put implements Store, it is the same as Save.
+func (s *BoltStore) Put(epoch uint64, b netmap.Bucket) error {
+	return s.Save(epoch, b)
+}
+
+// Get implements Store, it is the same as Load.
+func (s *BoltStore) Get(epoch uint64) (netmap.Bucket, error) {
+	return s.Load(epoch)
+}
+
+// ListEpochs returns epochs of all stored netmaps in ascending order.
+func (s *BoltStore) ListEpochs() ([]uint64, error) {
+	var epochs []uint64
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		epochs = allEpochs(tx)
+		return nil
+	})
+	return epochs, err
+}
+
+// allEpochs returns epochs of netmaps stored both in full and as changes.
+func allEpochs(tx *bbolt.Tx) []uint64 {
+	epochs := append(listEpochs(tx.Bucket(netmapsBucket)), listEpochs(tx.Bucket(deltasBucket))...)
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs
+}
+
+// listEpochs returns epochs of netmaps in bkt, big-endian
+// keys are iterated in ascending order.
+func listEpochs(bkt *bbolt.Bucket) []uint64 {
+	var epochs []uint64
+	_ = bkt.ForEach(func(k, _ []byte) error {
+		epochs = append(epochs, binary.BigEndian.Uint64(k))
+		return nil
+	})
+	return epochs
+}
+
+func epochKey(epoch uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], epoch)
+	return key[:]
+}
@@ -0,0 +1,191 @@
+package netmapstore
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBolt(t *testing.T, opts ...Option) (*BoltStore, string) {
+	dir, err := ioutil.TempDir("", "netmapstore")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "netmap.db")
+	s, err := OpenBolt(path, opts...)
+	require.NoError(t, err)
+	return s, path
+}
+
+func testNetmap(t *testing.T, n uint32) netmap.Bucket {
+	var b netmap.Bucket
+	for i := uint32(1); i <= n; i++ {
+		require.NoError(t, b.AddStrawNode(netmap.Node{N: i, C: 10}, "/Location:Europe"))
+	}
+	return b
+}
+
+func TestBoltStore(t *testing.T) {
+	s, path := newTestBolt(t)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	b1, b2 := testNetmap(t, 1), testNetmap(t, 2)
+	require.NoError(t, s.Save(1, b1))
+	require.NoError(t, s.Save(10, b2))
+
+	b, err := s.Load(1)
+	require.NoError(t, err)
+	require.Equal(t, b1, b)
+
+	_, err = s.Load(2)
+	require.Equal(t, ErrNotFound, err)
+
+	// netmaps survive restart
+	require.NoError(t, s.Close())
+	s, err = OpenBolt(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	b, err = s.Load(10)
+	require.NoError(t, err)
+	require.Equal(t, b2, b)
+
+	epochs, err := s.ListEpochs()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 10}, epochs)
+}
+
+func TestBoltStore_Retention(t *testing.T) {
+	t.Run("keep last", func(t *testing.T) {
+		s, path := newTestBolt(t, WithRetention(KeepLast(2)))
+		defer os.RemoveAll(filepath.Dir(path))
+		defer s.Close()
+
+		for _, e := range []uint64{1, 2, 5, 7} {
+			require.NoError(t, s.Save(e, testNetmap(t, 1)))
+		}
+		epochs, err := s.ListEpochs()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{5, 7}, epochs)
+	})
+	t.Run("keep epochs", func(t *testing.T) {
+		s, path := newTestBolt(t, WithRetention(KeepEpochs(3)))
+		defer os.RemoveAll(filepath.Dir(path))
+		defer s.Close()
+
+		for _, e := range []uint64{1, 2, 5, 7} {
+			require.NoError(t, s.Save(e, testNetmap(t, 1)))
+		}
+		epochs, err := s.ListEpochs()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{5, 7}, epochs)
+	})
+	t.Run("huge epochs", func(t *testing.T) {
+		epochs := []uint64{1, math.MaxUint64 - 5, math.MaxUint64 - 1}
+		require.Equal(t, epochs[:2], KeepEpochs(3)(epochs))
+		require.Empty(t, KeepEpochs(math.MaxUint64)(epochs))
+	})
+	t.Run("zero", func(t *testing.T) {
+		epochs := []uint64{1, 2, 5}
+		require.Equal(t, epochs[:2], KeepLast(0)(epochs))
+		require.Equal(t, epochs[:2], KeepEpochs(0)(epochs))
+	})
+}
+
+func TestBoltStore_Compaction(t *testing.T) {
+	s, path := newTestBolt(t, WithCompaction(3, 2))
+	defer os.RemoveAll(filepath.Dir(path))
+	defer func() { _ = s.Close() }()
+
+	nms := make(map[uint64]netmap.Bucket)
+	for e := uint64(1); e <= 10; e++ {
+		nms[e] = testNetmap(t, uint32(e%4+1))
+		require.NoError(t, s.Save(e, nms[e]))
+	}
+
+	// netmaps of epochs 1, 4, 7 and 10 are stored in full,
+	// only 2 latest of them remain along with changes in between
+	epochs, err := s.ListEpochs()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{7, 8, 9, 10}, epochs)
+
+	check := func(t *testing.T, epochs ...uint64) {
+		for _, e := range epochs {
+			b, err := s.Load(e)
+			require.NoError(t, err, e)
+			require.Equal(t, nms[e].Nodelist(), b.Nodelist(), e)
+		}
+	}
+	check(t, epochs...)
+
+	_, err = s.Load(5)
+	require.Equal(t, ErrNotFound, err)
+
+	t.Run("overwrite", func(t *testing.T) {
+		nms[8] = testNetmap(t, 10)
+		require.NoError(t, s.Save(8, nms[8]))
+
+		// changes replaced by changes don't affect pruning
+		epochs, err := s.ListEpochs()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{7, 8, 9, 10}, epochs)
+		check(t, epochs...)
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		s.retention = KeepLast(3)
+		defer func() { s.retention = nil }()
+
+		nms[11] = testNetmap(t, 5)
+		require.NoError(t, s.Save(11, nms[11]))
+
+		epochs, err := s.ListEpochs()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{9, 10, 11}, epochs)
+		check(t, epochs...)
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		nms[12], nms[14] = testNetmap(t, 2), testNetmap(t, 3)
+		require.NoError(t, s.Save(14, nms[14]))
+		require.NoError(t, s.Save(12, nms[12]))
+
+		// inserted netmap is stored in full, so 9 is pruned
+		epochs, err := s.ListEpochs()
+		require.NoError(t, err)
+		require.Equal(t, []uint64{10, 11, 12, 14}, epochs)
+		check(t, epochs...)
+	})
+
+	// history survives restart
+	require.NoError(t, s.Close())
+	s, err = OpenBolt(path, WithCompaction(3, 2))
+	require.NoError(t, err)
+	check(t, 10, 11, 12, 14)
+}
+
+func TestBoltStore_CompactionInnerNodes(t *testing.T) {
+	s, path := newTestBolt(t, WithCompaction(4, 0))
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+
+	var b1, b2 netmap.Bucket
+	require.NoError(t, b1.AddBucket("/Location:Europe", netmap.Nodes{{N: 5}}))
+	require.NoError(t, b1.AddBucket("/Location:Europe/Country:DE", netmap.Nodes{{N: 6}}))
+	require.NoError(t, b2.AddBucket("/Location:Europe", netmap.Nodes{{N: 5}}))
+	require.NoError(t, b2.AddBucket("/Location:Europe/Country:DE", netmap.Nodes{{N: 8}}))
+
+	require.NoError(t, s.Save(1, b1))
+	require.NoError(t, s.Save(2, b2))
+
+	for e, b := range map[uint64]netmap.Bucket{1: b1, 2: b2} {
+		l, err := s.Load(e)
+		require.NoError(t, err)
+		require.Equal(t, b.Nodelist(), l.Nodelist(), e)
+		require.Equal(t, b.Digest(), l.Digest(), e)
+	}
+}
@@ -0,0 +1,95 @@
+package netmapstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+// FileStore keeps every netmap in a separate file of the directory.
+type FileStore struct {
+	dir string
+}
+
+const netmapFileExt = ".netmap"
+
+// NewFileStore returns store keeping netmaps in dir creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "can't create %s", dir)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(epoch uint64) string {
+	return filepath.Join(s.dir, strconv.FormatUint(epoch, 10)+netmapFileExt)
+}
+
+// Put implements Store. The file is replaced atomically,
+// so that the netmap is never read partially written.
+func (s *FileStore) Put(epoch uint64, b netmap.Bucket) error {
+	data, err := b.MarshalBinaryVersion(netmap.FormatV2)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.dir, "tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(epoch))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+	return err
+}
+
+// Get implements Store.
+func (s *FileStore) Get(epoch uint64) (netmap.Bucket, error) {
+	var b netmap.Bucket
+	data, err := ioutil.ReadFile(s.path(epoch))
+	if os.IsNotExist(err) {
+		return b, ErrNotFound
+	} else if err != nil {
+		return b, err
+	}
+	return b, b.UnmarshalBinary(data)
+}
+
+// ListEpochs implements Store. Files not created by the store are ignored.
+func (s *FileStore) ListEpochs() ([]uint64, error) {
+	fs, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var epochs []uint64
+	for _, f := range fs {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, netmapFileExt) {
+			continue
+		}
+		e, err := strconv.ParseUint(strings.TrimSuffix(name, netmapFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		epochs = append(epochs, e)
+	}
+
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs, nil
+}
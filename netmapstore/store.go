@@ -0,0 +1,92 @@
+// Package netmapstore persists netmaps of different epochs.
+package netmapstore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Store keeps history of netmaps, so that it can be backed by any database.
+	Store interface {
+		// Put stores netmap b of the specified epoch replacing the existing one.
+		Put(epoch uint64, b netmap.Bucket) error
+		// Get returns netmap of the specified epoch or ErrNotFound.
+		Get(epoch uint64) (netmap.Bucket, error)
+		// ListEpochs returns epochs of all stored netmaps in ascending order.
+		ListEpochs() ([]uint64, error)
+	}
+
+	// MemoryStore keeps netmaps in memory.
+	MemoryStore struct {
+		mu      sync.RWMutex
+		netmaps map[uint64]netmap.Bucket
+	}
+)
+
+// ErrNotFound is returned when netmap of the requested epoch is missing.
+var ErrNotFound = errors.New("netmap not found")
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*FileStore)(nil)
+	_ Store = (*BoltStore)(nil)
+)
+
+// NewMemoryStore returns empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{netmaps: make(map[uint64]netmap.Bucket)}
+}
+
+// Put implements Store. Snapshot of b is stored, so that
+// later modifications of b don't affect it.
+func (s *MemoryStore) Put(epoch uint64, b netmap.Bucket) error {
+	s.mu.Lock()
+	s.netmaps[epoch] = b.Snapshot()
+	s.mu.Unlock()
+	return nil
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(epoch uint64) (netmap.Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.netmaps[epoch]
+	if !ok {
+		return b, ErrNotFound
+	}
+	return b.Snapshot(), nil
+}
+
+// ListEpochs implements Store.
+func (s *MemoryStore) ListEpochs() ([]uint64, error) {
+	s.mu.RLock()
+	epochs := make([]uint64, 0, len(s.netmaps))
+	for e := range s.netmaps {
+		epochs = append(epochs, e)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(epochs, func(i, j int) bool { return epochs[i] < epochs[j] })
+	return epochs, nil
+}
+
+// FindNodesAt returns nodes chosen by ss for pivot in the netmap of the
+// specified epoch stored in s, e.g. to find out which nodes were
+// responsible for an object at that epoch. Nodes are sorted by index.
+// Error is returned if the netmap is missing or some group can't be satisfied.
+func FindNodesAt(s Store, epoch uint64, pivot []byte, ss ...netmap.SFGroup) (netmap.Nodes, error) {
+	b, err := s.Get(epoch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't get netmap of epoch %d", epoch)
+	}
+	g, err := b.FindGraphStrict(pivot, ss...)
+	if err != nil {
+		return nil, err
+	}
+	return g.Nodelist(), nil
+}
@@ -0,0 +1,103 @@
+package netmapstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, s Store) {
+	epochs, err := s.ListEpochs()
+	require.NoError(t, err)
+	require.Empty(t, epochs)
+
+	b1, b2 := testNetmap(t, 1), testNetmap(t, 2)
+	require.NoError(t, s.Put(10, b2))
+	require.NoError(t, s.Put(2, b1))
+
+	b, err := s.Get(2)
+	require.NoError(t, err)
+	require.Equal(t, b1, b)
+
+	_, err = s.Get(3)
+	require.Equal(t, ErrNotFound, err)
+
+	require.NoError(t, s.Put(2, b2))
+	b, err = s.Get(2)
+	require.NoError(t, err)
+	require.Equal(t, b2, b)
+
+	epochs, err = s.ListEpochs()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 10}, epochs)
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	testStore(t, s)
+
+	b := testNetmap(t, 1)
+	require.NoError(t, s.Put(20, b))
+	require.NoError(t, b.AddStrawNode(testNetmap(t, 2).Nodelist()[1], "/Location:Asia"))
+
+	stored, err := s.Get(20)
+	require.NoError(t, err)
+	require.Len(t, stored.Nodelist(), 1)
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netmapstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewFileStore(filepath.Join(dir, "netmaps"))
+	require.NoError(t, err)
+	testStore(t, s)
+
+	// unrelated files are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "netmaps", "README"), nil, 0600))
+	epochs, err := s.ListEpochs()
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 10}, epochs)
+}
+
+func TestBoltStore_Store(t *testing.T) {
+	s, path := newTestBolt(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	defer s.Close()
+
+	testStore(t, s)
+}
+
+func TestFindNodesAt(t *testing.T) {
+	s := NewMemoryStore()
+	require.NoError(t, s.Put(1, testNetmap(t, 2)))
+	require.NoError(t, s.Put(2, testNetmap(t, 5)))
+
+	var (
+		pivot = []byte("object")
+		ss    = []netmap.SFGroup{{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 2}}}}
+	)
+
+	ns, err := FindNodesAt(s, 1, pivot, ss...)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2}, ns.Nodes())
+
+	b, err := s.Get(2)
+	require.NoError(t, err)
+	ns, err = FindNodesAt(s, 2, pivot, ss...)
+	require.NoError(t, err)
+	require.ElementsMatch(t, b.FindNodes(pivot, ss...), ns)
+
+	_, err = FindNodesAt(s, 3, pivot, ss...)
+	require.Equal(t, ErrNotFound, errors.Cause(err))
+
+	ss[0].Selectors[0].Count = 3
+	_, err = FindNodesAt(s, 1, pivot, ss...)
+	require.Error(t, err)
+}
@@ -0,0 +1,47 @@
+package netmaptest
+
+import (
+	"bytes"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+// CheckDeterminism selects subgraph of b for placement rule ss twice for
+// the same pivot and checks that both results have byte-for-byte identical
+// binary encoding. Selection is done both with HRW ordering and with
+// pseudo-random one seeded by the pivot, the second run of every kind uses
+// a copy of b, so that selection depending on or modifying shared state
+// of the netmap is caught too.
+func CheckDeterminism(b *netmap.Bucket, pivot []byte, ss []netmap.SFGroup) error {
+	c := b.Copy()
+	for _, seeded := range []bool{false, true} {
+		first, err := selectGraph(b, pivot, ss, seeded)
+		if err != nil {
+			return err
+		}
+		second, err := selectGraph(&c, pivot, ss, seeded)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(first, second) {
+			return errors.Errorf("selection is not deterministic for pivot %x (seeded: %t)", pivot, seeded)
+		}
+	}
+	return nil
+}
+
+// selectGraph returns binary encoding of the subgraph selected from b,
+// nil if ss can't be satisfied.
+func selectGraph(b *netmap.Bucket, pivot []byte, ss []netmap.SFGroup, seeded bool) ([]byte, error) {
+	var opts []netmap.SelectOption
+	if seeded {
+		opts = append(opts, netmap.WithShuffler(netmap.NewRandShuffler(netmap.SeedFromBytes(pivot))))
+	}
+
+	g := b.FindGraphWith(pivot, ss, opts...)
+	if g == nil {
+		return nil, nil
+	}
+	return g.MarshalBinary()
+}
@@ -0,0 +1,118 @@
+package netmaptest
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update regression corpus of selection results")
+
+const corpusFile = "selection.json"
+
+type (
+	// corpusCase is a selection from netmap generated by Random(Seed, DefaultConfig)
+	// along with the expected result. Nil Nodes means that policy can't be satisfied.
+	corpusCase struct {
+		Name   string        `json:"name"`
+		Seed   int64         `json:"seed"`
+		Pivot  string        `json:"pivot"`
+		Seeded bool          `json:"seeded,omitempty"`
+		Policy []corpusGroup `json:"policy"`
+		Nodes  []uint32      `json:"nodes"`
+	}
+
+	corpusGroup struct {
+		Selectors []netmap.Select `json:"selectors"`
+		Filters   []corpusFilter  `json:"filters,omitempty"`
+		Exclude   []uint32        `json:"exclude,omitempty"`
+	}
+
+	corpusFilter struct {
+		Key   string `json:"key"`
+		Op    string `json:"op"`
+		Value string `json:"value"`
+	}
+)
+
+func (c corpusCase) groups(t *testing.T) []netmap.SFGroup {
+	ss := make([]netmap.SFGroup, 0, len(c.Policy))
+	for _, g := range c.Policy {
+		s := netmap.SFGroup{Selectors: g.Selectors, Exclude: g.Exclude}
+		for _, f := range g.Filters {
+			op, ok := netmap.Operation_value[f.Op]
+			require.True(t, ok, "unknown operation %s", f.Op)
+			s.Filters = append(s.Filters, netmap.Filter{Key: f.Key, F: &netmap.SimpleFilter{
+				Op:   netmap.Operation(op),
+				Args: &netmap.SimpleFilter_Value{Value: f.Value},
+			}})
+		}
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+func (c corpusCase) run(t *testing.T) []uint32 {
+	var (
+		b    = Random(c.Seed, DefaultConfig)
+		ss   = c.groups(t)
+		opts []netmap.SelectOption
+	)
+	require.NoError(t, CheckDeterminism(&b, []byte(c.Pivot), ss))
+
+	if c.Seeded {
+		opts = append(opts, netmap.WithShuffler(netmap.NewRandShuffler(netmap.SeedFromBytes([]byte(c.Pivot)))))
+	}
+	g := b.FindGraphWith([]byte(c.Pivot), ss, opts...)
+	if g == nil {
+		return nil
+	}
+	return g.Nodelist().Nodes()
+}
+
+func TestCheckDeterminism(t *testing.T) {
+	b := Random(1, DefaultConfig)
+	ss := []netmap.SFGroup{{Selectors: []netmap.Select{
+		{Key: CountryKey, Count: 2},
+		{Key: netmap.NodesBucket, Count: 2},
+	}}}
+	require.NoError(t, CheckDeterminism(&b, []byte("pivot"), ss))
+
+	ss[0].Selectors[0].Count = 100
+	require.NoError(t, CheckDeterminism(&b, []byte("pivot"), ss))
+}
+
+// TestSelectionCorpus guards selection results against unintended changes.
+// Run with -update flag to regenerate expected results after intended ones.
+func TestSelectionCorpus(t *testing.T) {
+	path := filepath.Join("testdata", corpusFile)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var cs []corpusCase
+	require.NoError(t, json.Unmarshal(data, &cs))
+	require.NotEmpty(t, cs)
+
+	for i := range cs {
+		c := &cs[i]
+		t.Run(c.Name, func(t *testing.T) {
+			nodes := c.run(t)
+			if *update {
+				c.Nodes = nodes
+				return
+			}
+			require.Equal(t, c.Nodes, nodes)
+		})
+	}
+
+	if *update {
+		data, err = json.MarshalIndent(cs, "", "\t")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, append(data, '\n'), 0644))
+	}
+}
@@ -0,0 +1,137 @@
+// Package netmaptest provides generators of random netmaps for benchmarks
+// and property tests and simulation of netmap changes across epochs.
+package netmaptest
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CountryKey is the key of the first level of generated netmap.
+	CountryKey = "Country"
+	// CityKey is the key of the second level of generated netmap.
+	CityKey = "City"
+	// RackKey is the key of the third level of generated netmap.
+	RackKey = "Rack"
+)
+
+type (
+	// Distribution returns random value using r as a source of randomness.
+	Distribution func(r *rand.Rand) uint64
+
+	// Config describes the shape of generated netmap.
+	// Cities, Racks and Nodes are numbers of children per parent bucket.
+	Config struct {
+		Countries int
+		Cities    int
+		Racks     int
+		Nodes     int
+
+		// Capacity is a distribution of node capacities, zero if nil.
+		Capacity Distribution
+		// Price is a distribution of node prices, zero if nil.
+		Price Distribution
+	}
+)
+
+// DefaultConfig is a small netmap with 200 nodes in 40 racks.
+var DefaultConfig = Config{
+	Countries: 5,
+	Cities:    2,
+	Racks:     4,
+	Nodes:     5,
+	Capacity:  Uniform(1, 100),
+	Price:     Uniform(1, 10),
+}
+
+// Const returns distribution which always returns v.
+func Const(v uint64) Distribution {
+	return func(*rand.Rand) uint64 { return v }
+}
+
+// Uniform returns uniform distribution of integers in [min, max].
+func Uniform(min, max uint64) Distribution {
+	return func(r *rand.Rand) uint64 {
+		if max <= min {
+			return min
+		}
+		return min + uint64(r.Int63n(int64(max-min+1)))
+	}
+}
+
+// Normal returns normal distribution with specified mean and standard deviation.
+// Negative values are replaced with 0.
+func Normal(mean, stddev float64) Distribution {
+	return func(r *rand.Rand) uint64 {
+		if v := r.NormFloat64()*stddev + mean; v > 0 {
+			return uint64(v + 0.5)
+		}
+		return 0
+	}
+}
+
+// Size returns total number of nodes in netmap generated by c.
+func (c Config) Size() int {
+	return c.Countries * c.Cities * c.Racks * c.Nodes
+}
+
+// Generate returns random netmap of Country/City/Rack hierarchy.
+// Nodes are numbered sequentially starting from 0, bucket values
+// are unique across the whole netmap, e.g. /Country:Country0/City:City3/Rack:Rack7.
+func Generate(r *rand.Rand, c Config) (netmap.Bucket, error) {
+	var (
+		b     netmap.Bucket
+		n     uint32
+		city  int
+		rack  int
+		cp, p = c.Capacity, c.Price
+	)
+
+	if c.Countries < 0 || c.Cities < 0 || c.Racks < 0 || c.Nodes < 0 {
+		return b, errors.New("negative number of buckets")
+	}
+	if cp == nil {
+		cp = Const(0)
+	}
+	if p == nil {
+		p = Const(0)
+	}
+
+	es := make([]netmap.NodeEntry, 0, c.Size())
+	for i := 0; i < c.Countries; i++ {
+		country := "/" + CountryKey + ":" + CountryKey + strconv.Itoa(i)
+		for j := 0; j < c.Cities; j++ {
+			cityPath := country + "/" + CityKey + ":" + CityKey + strconv.Itoa(city)
+			city++
+			for k := 0; k < c.Racks; k++ {
+				path := []string{cityPath + "/" + RackKey + ":" + RackKey + strconv.Itoa(rack)}
+				rack++
+				for l := 0; l < c.Nodes; l++ {
+					es = append(es, netmap.NodeEntry{
+						Node:    netmap.Node{N: n, C: cp(r), P: p(r)},
+						Options: path,
+					})
+					n++
+				}
+			}
+		}
+	}
+	if err := b.AddNodes(es); err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// Random returns netmap generated from c using seed as a source of randomness.
+// It panics if c is invalid.
+func Random(seed int64, c Config) netmap.Bucket {
+	b, err := Generate(rand.New(rand.NewSource(seed)), c)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
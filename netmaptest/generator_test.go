@@ -0,0 +1,55 @@
+package netmaptest
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	c := DefaultConfig
+	b := Random(42, c)
+	require.Len(t, b.Nodelist(), c.Size())
+	require.Len(t, b.Children(), c.Countries)
+	require.Len(t, b.Query("/Country:*/City:*/Rack:*"), c.Countries*c.Cities*c.Racks)
+	require.Len(t, b.GetNodesByOption("/Country:Country1"), c.Cities*c.Racks*c.Nodes)
+	require.True(t, b.IsValid())
+
+	for _, n := range b.Nodelist() {
+		require.True(t, n.C >= 1 && n.C <= 100)
+		require.True(t, n.P >= 1 && n.P <= 10)
+	}
+
+	require.Equal(t, b.Digest(), Random(42, c).Digest())
+	require.NotEqual(t, b.Digest(), Random(43, c).Digest())
+
+	ss := []netmap.SFGroup{{Selectors: []netmap.Select{
+		{Key: CountryKey, Count: 3},
+		{Key: RackKey, Count: 1},
+		{Key: netmap.NodesBucket, Count: 1},
+	}}}
+	require.Len(t, b.FindNodes([]byte("pivot"), ss...), 3)
+
+	t.Run("zero distributions", func(t *testing.T) {
+		b, err := Generate(rand.New(rand.NewSource(1)), Config{Countries: 1, Cities: 1, Racks: 1, Nodes: 3})
+		require.NoError(t, err)
+		require.Equal(t, netmap.Nodes{{N: 0}, {N: 1}, {N: 2}}, b.Nodelist())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Generate(rand.New(rand.NewSource(1)), Config{Countries: -1})
+		require.Error(t, err)
+	})
+}
+
+func TestNormal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	d := Normal(-100, 1)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, uint64(0), d(r))
+	}
+	require.Equal(t, uint64(7), Const(7)(r))
+	require.Equal(t, uint64(5), Uniform(5, 5)(r))
+}
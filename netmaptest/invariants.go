@@ -0,0 +1,171 @@
+package netmaptest
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+// CheckSelectionInvariants selects nodes from b for every group of ss
+// using iterations random pivots and checks that group is satisfied if and
+// only if b.GetMaxSelection succeeds for it, selected nodes are distinct,
+// every Select clause chooses exactly Count buckets in every parent bucket,
+// DISTINCT and SAME clauses hold and nodes satisfy group filters,
+// exclusions and subnet. Pivots are generated deterministically,
+// so failures are reproducible. The first violation is returned
+// as an error containing pivot.
+func CheckSelectionInvariants(b *netmap.Bucket, ss []netmap.SFGroup, iterations int) error {
+	var (
+		r      = rand.New(rand.NewSource(int64(iterations)))
+		attrs  = nodeAttributes(b)
+		nodes  = make(map[uint32]netmap.Node)
+		pivot  = make([]byte, 32)
+		groups = make([]bool, len(ss))
+	)
+
+	for _, n := range b.Nodelist() {
+		nodes[n.N] = n
+	}
+	for i := range ss {
+		groups[i] = b.GetMaxSelection(ss[i]) != nil
+	}
+
+	for it := 0; it < iterations; it++ {
+		r.Read(pivot)
+		for i := range ss {
+			ns := b.FindNodes(pivot, ss[i])
+			if err := checkGroup(ss[i], ns, groups[i], nodes, attrs); err != nil {
+				return errors.Wrapf(err, "group %d, pivot %x", i, pivot)
+			}
+		}
+	}
+	return nil
+}
+
+func checkGroup(s netmap.SFGroup, ns netmap.Nodes, ok bool, nodes map[uint32]netmap.Node, attrs map[uint32]map[string]string) error {
+	if !ok {
+		if len(ns) != 0 {
+			return errors.New("unsatisfiable group has selected nodes")
+		}
+		return nil
+	} else if len(ns) == 0 {
+		return errors.New("satisfiable group has no selected nodes")
+	}
+
+	seen := make(map[uint32]struct{}, len(ns))
+	for _, n := range ns {
+		if _, ok := seen[n.N]; ok {
+			return errors.Errorf("node %d is selected twice", n.N)
+		}
+		seen[n.N] = struct{}{}
+
+		if _, ok := nodes[n.N]; !ok {
+			return errors.Errorf("node %d is absent in netmap", n.N)
+		}
+		for _, e := range s.Exclude {
+			if e == n.N {
+				return errors.Errorf("excluded node %d is selected", n.N)
+			}
+		}
+		if !nodes[n.N].InSubnet(s.Subnet) {
+			return errors.Errorf("node %d is not in subnet %d", n.N, s.Subnet)
+		}
+		for _, f := range s.NodeFilters {
+			if !f.Check(nodes[n.N]) {
+				return errors.Errorf("node %d doesn't satisfy node filter on %s", n.N, f.Field)
+			}
+		}
+		for _, f := range s.Filters {
+			if f.F == nil {
+				continue
+			}
+			if v, ok := attrs[n.N][f.Key]; !ok || !f.F.Check(v) {
+				return errors.Errorf("node %d doesn't satisfy filter on %s", n.N, f.Key)
+			}
+		}
+	}
+
+	// tuples contains path of node in terms of processed selectors.
+	tuples := make(map[uint32]string, len(ns))
+	for i, sel := range s.Selectors {
+		levels := make(map[string]*level)
+		for _, n := range ns {
+			parent := tuples[n.N]
+			if sel.Key == netmap.NodesBucket {
+				tuples[n.N] = parent + "/" + strconv.FormatUint(uint64(n.N), 10)
+			} else {
+				tuples[n.N] = parent + "/" + attrs[n.N][sel.Key]
+			}
+
+			l := levels[parent]
+			if l == nil {
+				l = &level{
+					buckets:  make(map[string]struct{}),
+					distinct: make(map[string]string),
+				}
+				levels[parent] = l
+			}
+			if err := l.add(sel, tuples[n.N], attrs[n.N]); err != nil {
+				return errors.Wrapf(err, "selector %d", i)
+			}
+		}
+
+		for _, l := range levels {
+			if sel.Count != 0 && sel.Count != netmap.CountAll && sel.Percent == 0 && len(l.buckets) != int(sel.Count) {
+				return errors.Errorf("selector %d: expected %d buckets, got %d", i, sel.Count, len(l.buckets))
+			}
+		}
+	}
+	return nil
+}
+
+// level contains buckets chosen by single Select clause in a parent bucket.
+type level struct {
+	buckets  map[string]struct{}
+	distinct map[string]string
+	same     *string
+}
+
+func (l *level) add(sel netmap.Select, bucket string, attrs map[string]string) error {
+	l.buckets[bucket] = struct{}{}
+	if v, ok := attrs[sel.Distinct]; ok && sel.Distinct != "" {
+		if b, ok := l.distinct[v]; ok && b != bucket {
+			return errors.Errorf("buckets %s and %s have the same %s", b, bucket, sel.Distinct)
+		}
+		l.distinct[v] = bucket
+	}
+	if v, ok := attrs[sel.Same]; ok && sel.Same != "" {
+		if l.same != nil && *l.same != v {
+			return errors.Errorf("buckets have different %s: %s and %s", sel.Same, *l.same, v)
+		}
+		l.same = &v
+	}
+	return nil
+}
+
+// nodeAttributes returns values of all attributes of nodes in b.
+// If node has multiple values of the same attribute, the first one is used.
+func nodeAttributes(b *netmap.Bucket) map[uint32]map[string]string {
+	attrs := make(map[uint32]map[string]string)
+	b.Iterate(func(path []string, n uint32) bool {
+		m := attrs[n]
+		if m == nil {
+			m = make(map[string]string, len(path))
+			attrs[n] = m
+		}
+		for _, p := range path {
+			kv := strings.SplitN(p, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if _, ok := m[kv[0]]; !ok {
+				m[kv[0]] = kv[1]
+			}
+		}
+		return true
+	})
+	return attrs
+}
@@ -0,0 +1,60 @@
+package netmaptest
+
+import (
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSelectionInvariants(t *testing.T) {
+	b := Random(1, DefaultConfig)
+
+	ss := []netmap.SFGroup{
+		{Selectors: []netmap.Select{
+			{Key: CountryKey, Count: 2},
+			{Key: CityKey, Count: 2},
+			{Key: netmap.NodesBucket, Count: 2},
+		}},
+		{
+			Selectors: []netmap.Select{{Key: RackKey, Count: 3}, {Key: netmap.NodesBucket, Count: 1}},
+			Filters:   []netmap.Filter{{Key: CountryKey, F: netmap.FilterNE("Country0")}},
+			Exclude:   []uint32{25, 26, 27},
+		},
+		{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 4, Distinct: CountryKey}}},
+		{Selectors: []netmap.Select{{Key: netmap.NodesBucket, Count: 3, Same: CityKey}}},
+		{Selectors: []netmap.Select{{Key: CountryKey, Count: 10}, {Key: netmap.NodesBucket, Count: 1}}},
+	}
+	require.NoError(t, CheckSelectionInvariants(&b, ss, 50))
+
+	t.Run("violations", func(t *testing.T) {
+		var (
+			attrs = nodeAttributes(&b)
+			nodes = make(map[uint32]netmap.Node)
+			ns    = b.FindNodes([]byte("pivot"), ss[0])
+		)
+		for _, n := range b.Nodelist() {
+			nodes[n.N] = n
+		}
+		require.NoError(t, checkGroup(ss[0], ns, true, nodes, attrs))
+		require.Error(t, checkGroup(ss[0], ns, false, nodes, attrs))
+		require.Error(t, checkGroup(ss[0], nil, true, nodes, attrs))
+		require.Error(t, checkGroup(ss[0], ns[1:], true, nodes, attrs))
+		require.Error(t, checkGroup(ss[0], append(ns, ns[0]), true, nodes, attrs))
+
+		s := ss[0]
+		s.Exclude = []uint32{ns[0].N}
+		require.Error(t, checkGroup(s, ns, true, nodes, attrs))
+
+		s = ss[0]
+		s.Filters = []netmap.Filter{{Key: CountryKey, F: netmap.FilterEQ("Country0")}}
+		require.Error(t, checkGroup(s, ns, true, nodes, attrs))
+
+		s = ss[0]
+		s.Selectors = []netmap.Select{{Key: netmap.NodesBucket, Count: uint32(len(ns)), Same: CountryKey}}
+		require.Error(t, checkGroup(s, ns, true, nodes, attrs))
+
+		s.Selectors = []netmap.Select{{Key: netmap.NodesBucket, Count: uint32(len(ns)), Distinct: CountryKey}}
+		require.Error(t, checkGroup(s, ns, true, nodes, attrs))
+	})
+}
@@ -0,0 +1,77 @@
+package netmaptest
+
+import (
+	"github.com/nspcc-dev/netmap"
+	"github.com/pkg/errors"
+)
+
+// EpochReport describes placement in the netmap of a simulated epoch
+// compared to the previous one.
+type EpochReport struct {
+	// Epoch is the index of the epoch in the script, 0 is the initial netmap.
+	Epoch int
+	// Nodes is the number of online nodes.
+	Nodes int
+	// Failed is the number of pivots which couldn't be placed.
+	Failed int
+	// Replicas is the total number of nodes chosen for all pivots,
+	// Moved is the number of them absent in the previous placement
+	// of the same pivot. Pivots which couldn't be placed in the previous
+	// epoch aren't accounted in Moved.
+	Replicas int
+	Moved    int
+}
+
+// MovedShare returns share of replicas which must be moved in the epoch.
+func (r EpochReport) MovedShare() float64 {
+	if r.Replicas == 0 {
+		return 0
+	}
+	return float64(r.Moved) / float64(r.Replicas)
+}
+
+// Simulate applies events of every epoch of script to m in order and
+// places pivots according to ss after each of them, as well as in the
+// initial netmap. Returned reports allow to evaluate how stable placement
+// rule is when nodes join, leave or change their state.
+func Simulate(m *netmap.NetMap, ss []netmap.SFGroup, pivots [][]byte, script [][]netmap.Event) ([]EpochReport, error) {
+	var (
+		reports = make([]EpochReport, 0, len(script)+1)
+		prev    = make([]map[uint32]struct{}, len(pivots))
+	)
+
+	for e := 0; e <= len(script); e++ {
+		if e != 0 {
+			for i, ev := range script[e-1] {
+				if err := m.Apply(ev); err != nil {
+					return nil, errors.Wrapf(err, "epoch %d: can't apply event #%d", e, i)
+				}
+			}
+		}
+
+		var (
+			root = m.Root()
+			r    = EpochReport{Epoch: e, Nodes: len(root.Nodelist())}
+		)
+		for i := range pivots {
+			ns := root.FindNodes(pivots[i], ss...)
+			if len(ns) == 0 {
+				r.Failed++
+				prev[i] = nil
+				continue
+			}
+
+			cur := make(map[uint32]struct{}, len(ns))
+			for _, n := range ns {
+				cur[n.N] = struct{}{}
+				if _, ok := prev[i][n.N]; !ok && prev[i] != nil {
+					r.Moved++
+				}
+			}
+			r.Replicas += len(ns)
+			prev[i] = cur
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
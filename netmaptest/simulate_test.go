@@ -0,0 +1,60 @@
+package netmaptest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/nspcc-dev/netmap"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	m := netmap.NewNetMap()
+	for i := uint32(1); i <= 20; i++ {
+		require.NoError(t, m.Apply(netmap.Event{
+			Type:    netmap.NodeAdded,
+			Node:    netmap.Node{N: i, C: 10},
+			Options: []string{"/" + CountryKey + ":C" + strconv.Itoa(int(i%4))},
+		}))
+	}
+
+	var (
+		ss = []netmap.SFGroup{{Selectors: []netmap.Select{
+			{Key: CountryKey, Count: 2},
+			{Key: netmap.NodesBucket, Count: 2},
+		}}}
+		pivots = make([][]byte, 50)
+	)
+	for i := range pivots {
+		pivots[i] = []byte("pivot" + strconv.Itoa(i))
+	}
+
+	script := [][]netmap.Event{
+		// nothing changes
+		nil,
+		{{Type: netmap.StateChanged, Node: netmap.Node{N: 1}, State: netmap.NodeOffline}},
+		{
+			{Type: netmap.NodeAdded, Node: netmap.Node{N: 21, C: 10}, Options: []string{"/" + CountryKey + ":C1"}},
+			{Type: netmap.StateChanged, Node: netmap.Node{N: 1}, State: netmap.NodeOnline},
+		},
+	}
+
+	rs, err := Simulate(m, ss, pivots, script)
+	require.NoError(t, err)
+	require.Len(t, rs, 4)
+
+	for i, r := range rs {
+		require.Equal(t, i, r.Epoch)
+		require.Zero(t, r.Failed)
+		require.Equal(t, 4*len(pivots), r.Replicas)
+	}
+	require.Equal(t, []int{20, 20, 19, 21}, []int{rs[0].Nodes, rs[1].Nodes, rs[2].Nodes, rs[3].Nodes})
+	require.Zero(t, rs[0].Moved)
+	require.Zero(t, rs[1].Moved)
+	require.True(t, rs[2].Moved > 0)
+	require.True(t, rs[2].MovedShare() < 0.5)
+	require.True(t, rs[3].Moved > 0)
+
+	_, err = Simulate(m, ss, pivots, [][]netmap.Event{{{Type: netmap.NodeRemoved, Node: netmap.Node{N: 100}}}})
+	require.Error(t, err)
+}
@@ -2,10 +2,15 @@ package netmap
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"math"
+	"math/bits"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/nspcc-dev/hrw"
 	"github.com/pkg/errors"
@@ -15,8 +20,41 @@ const (
 	// Separator separates key:value pairs in string representation of options.
 	Separator = "/"
 
+	// AltSeparator separates alternative paths of the option passed
+	// to GetNodesByOption, e.g. "/Country:RU|/Country:DE".
+	AltSeparator = "|"
+
 	// NodesBucket is the name for optionless bucket containing only nodes.
 	NodesBucket = "Node"
+
+	// CountAll is the count of Select clause choosing all matching
+	// buckets or nodes, e.g. for broadcast placement.
+	CountAll = math.MaxUint32
+
+	// MaxBucketDepth is the maximum depth of bucket tree which can be serialized.
+	MaxBucketDepth = 4096
+
+	// nodeHeaderSize is the size of fixed part of binary node representation:
+	// index, capacity, price, reputation, coordinates, public key length,
+	// number of addresses and subnets.
+	nodeHeaderSize = 4 + 8 + 8 + 8 + 16 + 4 + 4 + 4
+
+	// legacyNodeSize is the size of binary node representation in FormatV1
+	// data written without version prefix: index, capacity and price.
+	legacyNodeSize = 4 + 8 + 8
+
+	// bucketHeaderSize is the size of binary bucket representation
+	// with empty name and without nodes and children.
+	bucketHeaderSize = 4 + 4 + 4
+
+	// attrsFlag is set in the number of node subnets in binary formats
+	// if node attributes follow subnets. Decoders not aware of attributes
+	// reject such nodes because of invalid length.
+	attrsFlag = 1 << 31
+
+	// maxPreallocLen is the maximum number of elements allocated in advance
+	// while decoding, so that corrupted lengths can't exhaust memory.
+	maxPreallocLen = 1024
 )
 
 type (
@@ -34,13 +72,30 @@ type (
 		weight   float64
 		nodes    Nodes
 		children []Bucket
-	}
-
-	// Node type represents single graph leaf with index N, capacity C and price P.
+		// schema, if not nil, is used to check options of added
+		// buckets and placement rules.
+		schema *Schema
+	}
+
+	// Node type represents single graph leaf with index N, capacity C, price P
+	// and reputation R in range of 0.0 to 1.0.
+	// Coord is a position of the node in network coordinate space.
+	// PubKey and Addresses allow to dial the node directly.
+	// Subnets contains identifiers of subnets node belongs to.
+	// Attrs are attributes of the node itself which are matched by filters
+	// along with the buckets containing the node, e.g. SSD:true.
+	// Node contains slices and a map, so it can't be compared with ==,
+	// Equals must be used instead.
 	Node struct {
-		N uint32
-		C uint64
-		P uint64
+		N         uint32
+		C         uint64
+		P         uint64
+		R         float64
+		Coord     Coord
+		PubKey    []byte
+		Addresses []string
+		Subnets   []uint32
+		Attrs     map[string]string
 	}
 
 	// Nodes represents slice of graph leafs.
@@ -48,8 +103,70 @@ type (
 
 	// FilterFunc is generic type for filtering function on nodes.
 	FilterFunc func(Nodes) Nodes
+
+	// NodeEntry is a node along with its options used for bulk insertion.
+	NodeEntry struct {
+		Node    Node
+		Options []string
+	}
+
+	// Conflict describes node which is located in different buckets
+	// with the same key in two netmaps.
+	Conflict struct {
+		Node      uint32
+		Path      string
+		OtherPath string
+	}
+
+	// OverlapError is returned when different selection groups choose the same nodes.
+	OverlapError struct {
+		First  int
+		Second int
+		Nodes  []uint32
+	}
 )
 
+// Capacity returns total capacity required to store
+// p.ReplFactor replicas of p.Size bytes. It is math.MaxUint64
+// if the capacity doesn't fit into uint64.
+func (p Policy) Capacity() uint64 {
+	if p.Size <= 0 || p.ReplFactor <= 0 {
+		return 0
+	}
+	hi, lo := bits.Mul64(uint64(p.Size), uint64(p.ReplFactor))
+	if hi != 0 {
+		return math.MaxUint64
+	}
+	return lo
+}
+
+// Apply selects nodes from b satisfying p. At least p.NodeCount nodes are
+// selected (or p.ReplFactor, if node count is not specified) and their total
+// capacity must be enough to store p.ReplFactor replicas of p.Size bytes.
+func (p Policy) Apply(b *Bucket, pivot []byte) ([]uint32, error) {
+	if p.Size < 0 || p.ReplFactor < 0 || p.NodeCount < 0 {
+		return nil, errors.New("policy parameters must be non-negative")
+	}
+
+	count := p.NodeCount
+	if count == 0 {
+		count = p.ReplFactor
+	}
+	if count == 0 {
+		return nil, errors.New("node count or replication factor must be specified")
+	}
+
+	s := SFGroup{Selectors: []Select{{Key: NodesBucket, Count: uint32(count)}}}
+	c := b.GetMaxSelection(s)
+	if c == nil {
+		return nil, errors.Wrapf(ErrNotEnoughNodes, "%d required", count)
+	}
+	if c = c.GetCapacitySelection(s.Selectors, pivot, p.Capacity()); c == nil {
+		return nil, errors.Wrapf(ErrNotEnoughNodes, "not enough capacity: %d required", p.Capacity())
+	}
+	return c.Nodelist().Nodes(), nil
+}
+
 // Hash is a function from hrw.Hasher interface. It is implemented
 // to support weighted hrw therefore sort function sorts nodes
 // based on their `N` value.
@@ -57,32 +174,288 @@ func (n Node) Hash() uint64 {
 	return uint64(n.N)
 }
 func (n Node) Write(w io.Writer) error {
-	var err error
-	if err = binary.Write(w, binary.BigEndian, n.N); err != nil {
+	var buf [nodeHeaderSize]byte
+	binary.BigEndian.PutUint32(buf[0:], n.N)
+	binary.BigEndian.PutUint64(buf[4:], n.C)
+	binary.BigEndian.PutUint64(buf[12:], n.P)
+	binary.BigEndian.PutUint64(buf[20:], math.Float64bits(n.R))
+	binary.BigEndian.PutUint64(buf[28:], math.Float64bits(n.Coord.X))
+	binary.BigEndian.PutUint64(buf[36:], math.Float64bits(n.Coord.Y))
+	binary.BigEndian.PutUint32(buf[44:], uint32(len(n.PubKey)))
+	binary.BigEndian.PutUint32(buf[48:], uint32(len(n.Addresses)))
+	sl := uint32(len(n.Subnets))
+	if len(n.Attrs) != 0 {
+		sl |= attrsFlag
+	}
+	binary.BigEndian.PutUint32(buf[52:], sl)
+	if _, err := w.Write(buf[:]); err != nil {
 		return err
 	}
-	if err = binary.Write(w, binary.BigEndian, n.C); err != nil {
+	if _, err := w.Write(n.PubKey); err != nil {
 		return err
 	}
-	if err = binary.Write(w, binary.BigEndian, n.P); err != nil {
-		return err
+	for i := range n.Addresses {
+		if err := writeBytes(w, []byte(n.Addresses[i])); err != nil {
+			return err
+		}
+	}
+	for i := range n.Subnets {
+		binary.BigEndian.PutUint32(buf[:], n.Subnets[i])
+		if _, err := w.Write(buf[:4]); err != nil {
+			return err
+		}
+	}
+	if len(n.Attrs) != 0 {
+		return n.writeAttrs(w)
 	}
 	return nil
 }
+
 func (n *Node) Read(r io.Reader) error {
-	var err error
-	if err = binary.Read(r, binary.BigEndian, &n.N); err != nil {
+	var buf [nodeHeaderSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
 		return err
 	}
-	if err = binary.Read(r, binary.BigEndian, &n.C); err != nil {
+	n.N = binary.BigEndian.Uint32(buf[0:])
+	n.C = binary.BigEndian.Uint64(buf[4:])
+	n.P = binary.BigEndian.Uint64(buf[12:])
+	n.R = math.Float64frombits(binary.BigEndian.Uint64(buf[20:]))
+	n.Coord.X = math.Float64frombits(binary.BigEndian.Uint64(buf[28:]))
+	n.Coord.Y = math.Float64frombits(binary.BigEndian.Uint64(buf[36:]))
+
+	var (
+		kl    = int32(binary.BigEndian.Uint32(buf[44:]))
+		al    = int32(binary.BigEndian.Uint32(buf[48:]))
+		sl    = int32(binary.BigEndian.Uint32(buf[52:]) &^ attrsFlag)
+		attrs = binary.BigEndian.Uint32(buf[52:])&attrsFlag != 0
+	)
+	if kl < 0 || al < 0 {
+		return errors.Wrap(ErrMalformedEncoding, "negative length")
+	}
+
+	n.PubKey = nil
+	if kl > 0 {
+		var err error
+		if n.PubKey, err = readN(r, int(kl)); err != nil {
+			return err
+		}
+	}
+
+	n.Addresses = nil
+	for i := int32(0); i < al; i++ {
+		a, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		n.Addresses = append(n.Addresses, string(a))
+	}
+
+	n.Subnets = nil
+	for i := int32(0); i < sl; i++ {
+		if _, err := io.ReadFull(r, buf[:4]); err != nil {
+			return err
+		}
+		n.Subnets = append(n.Subnets, binary.BigEndian.Uint32(buf[:4]))
+	}
+
+	n.Attrs = nil
+	if attrs {
+		return n.readAttrs(r)
+	}
+	return nil
+}
+
+// readLegacy reads node in the layout used by FormatV1 data written without
+// version prefix, in which only index, capacity and price are present.
+func (n *Node) readLegacy(r io.Reader) error {
+	var buf [legacyNodeSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
 		return err
 	}
-	if err = binary.Read(r, binary.BigEndian, &n.P); err != nil {
+	*n = Node{
+		N: binary.BigEndian.Uint32(buf[0:]),
+		C: binary.BigEndian.Uint64(buf[4:]),
+		P: binary.BigEndian.Uint64(buf[12:]),
+	}
+	return nil
+}
+
+// writeAttrs writes number of node attributes and key-value pairs sorted by key.
+func (n Node) writeAttrs(w io.Writer) error {
+	keys := n.attrKeys()
+	if err := binary.Write(w, binary.BigEndian, int32(len(keys))); err != nil {
 		return err
 	}
+	for _, k := range keys {
+		if err := writeBytes(w, []byte(k)); err != nil {
+			return err
+		} else if err := writeBytes(w, []byte(n.Attrs[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *Node) readAttrs(r io.Reader) error {
+	var ln int32
+	if err := binary.Read(r, binary.BigEndian, &ln); err != nil {
+		return err
+	} else if ln <= 0 {
+		return errors.Wrap(ErrMalformedEncoding, "invalid number of attributes")
+	}
+
+	n.Attrs = make(map[string]string)
+	for i := int32(0); i < ln; i++ {
+		k, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		v, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		n.Attrs[string(k)] = string(v)
+	}
 	return nil
 }
 
+// attrKeys returns sorted keys of node attributes.
+func (n Node) attrKeys() []string {
+	keys := make([]string, 0, len(n.Attrs))
+	for k := range n.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// InSubnet checks whether n belongs to subnet s.
+// Zero subnet is the default one and contains all nodes.
+func (n Node) InSubnet(s uint32) bool {
+	if s == 0 {
+		return true
+	}
+	for i := range n.Subnets {
+		if n.Subnets[i] == s {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns copy of n which doesn't share slices and attributes with n.
+func (n Node) clone() Node {
+	if n.PubKey != nil {
+		n.PubKey = append(make([]byte, 0, len(n.PubKey)), n.PubKey...)
+	}
+	if n.Addresses != nil {
+		n.Addresses = append(make([]string, 0, len(n.Addresses)), n.Addresses...)
+	}
+	if n.Subnets != nil {
+		n.Subnets = append(make([]uint32, 0, len(n.Subnets)), n.Subnets...)
+	}
+	if n.Attrs != nil {
+		attrs := make(map[string]string, len(n.Attrs))
+		for k, v := range n.Attrs {
+			attrs[k] = v
+		}
+		n.Attrs = attrs
+	}
+	return n
+}
+
+// Equals checks whether n and n1 have the same index and attributes.
+func (n Node) Equals(n1 Node) bool {
+	if n.N != n1.N || n.C != n1.C || n.P != n1.P || n.R != n1.R || n.Coord != n1.Coord ||
+		!bytes.Equal(n.PubKey, n1.PubKey) || len(n.Addresses) != len(n1.Addresses) ||
+		len(n.Subnets) != len(n1.Subnets) {
+		return false
+	}
+	for i := range n.Addresses {
+		if n.Addresses[i] != n1.Addresses[i] {
+			return false
+		}
+	}
+	for i := range n.Subnets {
+		if n.Subnets[i] != n1.Subnets[i] {
+			return false
+		}
+	}
+	if len(n.Attrs) != len(n1.Attrs) {
+		return false
+	}
+	for k, v := range n.Attrs {
+		if v1, ok := n1.Attrs[k]; !ok || v != v1 {
+			return false
+		}
+	}
+	return true
+}
+
+func writeBytes(w io.Writer, data []byte) error {
+	var ln [4]byte
+	binary.BigEndian.PutUint32(ln[:], uint32(len(data)))
+	if _, err := w.Write(ln[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func readBytes(r io.Reader) ([]byte, error) {
+	var ln int32
+	if err := binary.Read(r, binary.BigEndian, &ln); err != nil {
+		return nil, err
+	} else if ln < 0 {
+		return nil, errors.Wrap(ErrMalformedEncoding, "negative length")
+	} else if ln == 0 {
+		return nil, nil
+	}
+	return readN(r, int(ln))
+}
+
+// readN reads n bytes from r. Memory for large data is allocated as it
+// arrives, so that corrupted length can't cause huge allocation.
+func readN(r io.Reader, n int) ([]byte, error) {
+	if n <= maxPreallocLen || n <= remaining(r) {
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, maxPreallocLen))
+	if m, err := io.CopyN(buf, r, int64(n)); err == io.EOF && m != 0 {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// preallocLen returns capacity to allocate for n decoded elements
+// encoded by at least size bytes each.
+func preallocLen(r io.Reader, n, size int) int {
+	if l := remaining(r); l >= 0 {
+		if m := l / size; n > m {
+			return m
+		}
+		return n
+	} else if n > maxPreallocLen {
+		return maxPreallocLen
+	}
+	return n
+}
+
+// remaining returns the number of unread bytes in r or -1 if it is unknown.
+func remaining(r io.Reader) int {
+	if l, ok := r.(interface{ Len() int }); ok {
+		return l.Len()
+	}
+	return -1
+}
+
 func (n Nodes) Len() int           { return len(n) }
 func (n Nodes) Less(i, j int) bool { return n[i].N < n[j].N }
 func (n Nodes) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
@@ -99,17 +472,38 @@ func (n Nodes) Write(w io.Writer) error {
 	return nil
 }
 func (n *Nodes) Read(r io.Reader) error {
+	return n.read(r, false)
+}
+
+// read reads node list, nodes are read in legacy layout if legacy is set.
+func (n *Nodes) read(r io.Reader, legacy bool) error {
 	var (
-		err error
-		ln  int32
+		err  error
+		ln   int32
+		size = nodeHeaderSize
 	)
+	if legacy {
+		size = legacyNodeSize
+	}
 	if err = binary.Read(r, binary.BigEndian, &ln); err != nil {
 		return err
 	}
 	if ln > 0 {
-		nodes := make(Nodes, ln)
-		for i := range nodes {
-			if err = nodes[i].Read(r); err != nil {
+		nodes := make(Nodes, 0, preallocLen(r, int(ln), size))
+		for i := int32(0); i < ln; i++ {
+			var nd Node
+
+			start := readOffset(r)
+			if legacy {
+				err = nd.readLegacy(r)
+			} else {
+				err = nd.Read(r)
+			}
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, nd)
+			if err = checkNodeOrder(r, nodes, start); err != nil {
 				return err
 			}
 		}
@@ -129,7 +523,7 @@ func (n Nodes) Nodes() []uint32 {
 
 // Weights returns slice ow nodes weights W.
 func (n Nodes) Weights() []float64 {
-	f := getDefaultWeightFunc(n)
+	f := DefaultWeightFunc(n)
 	w := make([]float64, 0, len(n))
 	for i := range n {
 		w = append(w, f(n[i]))
@@ -144,11 +538,52 @@ func (b Bucket) Hash() uint64 {
 
 // FindGraph returns random subgraph, corresponding to specified placement rule.
 func (b *Bucket) FindGraph(pivot []byte, ss ...SFGroup) (c *Bucket) {
+	return b.FindGraphWith(pivot, ss)
+}
+
+// FindGraphStrict returns random subgraph, corresponding to specified placement rule.
+// Unlike FindGraph, it fails with OverlapError if nodes selected by different
+// groups overlap, so that replicas stored by these groups are not distinct.
+func (b *Bucket) FindGraphStrict(pivot []byte, ss ...SFGroup) (*Bucket, error) {
+	if err := b.schema.CheckGroups(ss...); err != nil {
+		return nil, err
+	}
+
+	var (
+		c  = &Bucket{Key: b.Key, Value: b.Value}
+		ns = make([]Nodes, len(ss))
+	)
+
+	for i, s := range ss {
+		g := b.findGraph(pivot, s)
+		if g == nil {
+			return nil, errors.Wrapf(ErrNotEnoughNodes, "selection group %d can't be satisfied", i)
+		}
+
+		ns[i] = g.Nodelist()
+		for j := 0; j < i; j++ {
+			if common := intersect(ns[j], ns[i]); len(common) != 0 {
+				return nil, &OverlapError{First: j, Second: i, Nodes: common.Nodes()}
+			}
+		}
+		c.Merge(*g)
+	}
+	return c, nil
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("selection groups %d and %d share nodes %v", e.First, e.Second, e.Nodes)
+}
+
+// FindGraphWith returns random subgraph, corresponding to specified placement rule,
+// using provided selection options.
+func (b *Bucket) FindGraphWith(pivot []byte, ss []SFGroup, opts ...SelectOption) (c *Bucket) {
 	var g *Bucket
 
+	opts = forPlacement(opts)
 	c = &Bucket{Key: b.Key, Value: b.Value}
 	for _, s := range ss {
-		if g = b.findGraph(pivot, s); g == nil {
+		if g = b.findGraph(pivot, s, opts...); g == nil {
 			return nil
 		}
 		c.Merge(*g)
@@ -156,37 +591,126 @@ func (b *Bucket) FindGraph(pivot []byte, ss ...SFGroup) (c *Bucket) {
 	return
 }
 
-func (b *Bucket) findGraph(pivot []byte, s SFGroup) (c *Bucket) {
+// FindGraphCtx returns random subgraph, corresponding to specified placement rule,
+// using provided selection options. Selection is stopped when ctx is done,
+// in this case ctx error is returned. If the rule can't be satisfied,
+// ErrNotEnoughNodes is returned.
+func (b *Bucket) FindGraphCtx(ctx context.Context, pivot []byte, ss []SFGroup, opts ...SelectOption) (*Bucket, error) {
+	var (
+		g = &Bucket{Key: b.Key, Value: b.Value}
+		c = &cancelState{ctx: ctx}
+	)
+
+	if err := b.schema.CheckGroups(ss...); err != nil {
+		return nil, err
+	}
+	opts = append(forPlacement(opts), withCancel(c))
+	for i, s := range ss {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := b.findGraph(pivot, s, opts...)
+		if c.err != nil {
+			return nil, c.err
+		} else if r == nil {
+			return nil, errors.Wrapf(ErrNotEnoughNodes, "selection group %d can't be satisfied", i)
+		}
+		g.Merge(*r)
+	}
+	return g, nil
+}
+
+func (b *Bucket) findGraph(pivot []byte, s SFGroup, opts ...SelectOption) (c *Bucket) {
+	var ok bool
+	defer observeSelection(time.Now(), &ok)
+
 	if c = b.GetMaxSelection(s); c != nil {
-		return c.GetSelection(s.Selectors, pivot)
+		c = c.selectWithFallback(s.Selectors, func() selectParams {
+			return newSelectParams(*b, s.Selectors, pivot, 0, opts...)
+		})
 	}
+	ok = c != nil
 	return
 }
 
 // FindNodes returns list of nodes, corresponding to specified placement rule.
 func (b *Bucket) FindNodes(pivot []byte, ss ...SFGroup) (nodes Nodes) {
+	return b.FindNodesWith(pivot, ss)
+}
+
+// FindNodesWith returns list of nodes, corresponding to specified placement rule,
+// using provided selection options.
+func (b *Bucket) FindNodesWith(pivot []byte, ss []SFGroup, opts ...SelectOption) (nodes Nodes) {
+	opts = forPlacement(opts)
 	for _, s := range ss {
-		nodes = merge(nodes, b.findNodes(pivot, s))
+		nodes = merge(nodes, b.findNodes(pivot, s, opts...))
 	}
 	return
 }
 
-func (b *Bucket) findNodes(pivot []byte, s SFGroup) Nodes {
-	var c *Bucket
+// FindNodesCtx returns list of nodes, corresponding to specified placement rule,
+// using provided selection options. Selection is stopped when ctx is done,
+// in this case ctx error is returned.
+func (b *Bucket) FindNodesCtx(ctx context.Context, pivot []byte, ss []SFGroup, opts ...SelectOption) (nodes Nodes, err error) {
+	if err = b.schema.CheckGroups(ss...); err != nil {
+		return nil, err
+	}
+
+	c := &cancelState{ctx: ctx}
+	opts = append(forPlacement(opts), withCancel(c))
+	for _, s := range ss {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		nodes = merge(nodes, b.findNodes(pivot, s, opts...))
+		if c.err != nil {
+			return nil, c.err
+		}
+	}
+	return
+}
+
+func (b *Bucket) findNodes(pivot []byte, s SFGroup, opts ...SelectOption) Nodes {
+	var (
+		c  *Bucket
+		ok bool
+	)
+	defer observeSelection(time.Now(), &ok)
 
 	if c = b.GetMaxSelection(s); c != nil {
-		if c = c.GetSelection(s.Selectors, pivot); c != nil {
+		c = c.selectWithFallback(s.Selectors, func() selectParams {
+			return newSelectParams(*b, s.Selectors, pivot, 0, opts...)
+		})
+		if c != nil {
+			ok = true
 			return c.Nodelist()
 		}
 	}
 	return nil
 }
 
+// PlaceMany returns nodes chosen by ss for every pivot using provided
+// selection options, result for pivot i is the same as
+// b.FindNodesWith(pivots[i], ss, opts...). Policy is compiled once for all
+// pivots, so it is much faster than calling FindNodesWith for every
+// container sharing the same policy.
+func (b *Bucket) PlaceMany(pivots [][]byte, ss []SFGroup, opts ...SelectOption) []Nodes {
+	var (
+		c      = b.compile(ss, opts...)
+		result = make([]Nodes, len(pivots))
+	)
+	for j := range pivots {
+		result[j] = c.Place(pivots[j])
+	}
+	return result
+}
+
 // Copy returns deep copy of Bucket.
 func (b Bucket) Copy() (bc Bucket) {
 	bc.weight = b.weight
 	bc.Key = b.Key
 	bc.Value = b.Value
+	bc.schema = b.schema
 
 	if b.nodes != nil {
 		bc.nodes = make(Nodes, len(b.nodes))
@@ -202,9 +726,29 @@ func (b Bucket) Copy() (bc Bucket) {
 	return bc
 }
 
+// Snapshot returns a copy of b sharing all subtrees with it. Unlike Copy,
+// it takes O(1) time: methods modifying the tree copy buckets on the path
+// to the change instead of modifying them in place, so b and its snapshots
+// don't affect each other. Buckets returned by Query or passed to Walk
+// must not be modified directly while snapshots exist.
+func (b Bucket) Snapshot() Bucket {
+	return b
+}
+
+// ownChildren copies the slice of children of b, so that they can be
+// modified without affecting snapshots sharing it.
+func (b *Bucket) ownChildren() {
+	if len(b.children) == 0 {
+		b.children = b.children[:0:0]
+		return
+	}
+	b.children = append(make([]Bucket, 0, len(b.children)+1), b.children...)
+}
+
 // IsValid checks if bucket is well-formed:
 // - all nodes contained in sub-bucket must belong to this;
 // - there must be no nodes belonging to 2 buckets.
+// Use Validate to find out what is wrong and Repair to fix it.
 func (b Bucket) IsValid() bool {
 	var (
 		ns    Nodes
@@ -227,35 +771,65 @@ func (b Bucket) IsValid() bool {
 	return len(nodes) == len(ns)
 }
 
+// findAllowed returns nodes of b satisfying all filters fs. Node satisfies
+// filter if it belongs to a bucket or has an attribute with the filter key
+// and the value accepted by the filter. Filters with sub-policy are satisfied
+// only by buckets which can satisfy it.
 func (b Bucket) findAllowed(fs []Filter) (nodes Nodes) {
-	nodes = b.nodes
+	if len(fs) == 0 {
+		return b.nodes
+	}
+
+	var (
+		allowed = getNodesBuffer()
+		bs      []*Bucket
+	)
+	defer putNodesBuffer(allowed)
 
+	nodes = b.nodes
 	for i := range fs {
-		var allowed Nodes
-		for _, c := range b.findKey(fs[i].Key) {
-			if fs[i].F.Check(c.Value) {
-				allowed = append(allowed, c.nodes...)
+		*allowed = (*allowed)[:0]
+		bs = b.appendKey(bs[:0], fs[i].Key)
+		for _, c := range bs {
+			if fs[i].admits(*c) {
+				*allowed = append(*allowed, c.nodes...)
+			}
+		}
+		if sf := fs[i].GetF(); sf != nil && fs[i].Sub == nil {
+			for _, n := range b.nodes {
+				if v, ok := n.Attrs[fs[i].Key]; ok && sf.Check(v) {
+					*allowed = append(*allowed, n)
+				}
 			}
 		}
 
-		sort.Sort(allowed)
-		nodes = intersect(nodes, allowed)
+		sort.Sort(*allowed)
+		*allowed = dedupNodes(*allowed)
+		if nodes == nil {
+			nodes = append(Nodes(nil), *allowed...)
+		} else {
+			nodes = intersect(nodes, *allowed)
+		}
 	}
 
 	return
 }
 
-func (b *Bucket) findKey(key string) (bs []*Bucket) {
+func (b *Bucket) findKey(key string) []*Bucket {
+	return b.appendKey(nil, key)
+}
+
+// appendKey appends buckets with the specified key to bs.
+func (b *Bucket) appendKey(bs []*Bucket, key string) []*Bucket {
 	if b.Key == key {
-		bs = append(bs, b)
-		return
+		return append(bs, b)
 	}
 
 	for i := range b.children {
-		bs = append(bs, b.children[i].findKey(key)...)
+		bs = b.children[i].appendKey(bs, key)
 	}
 
-	return
+	return bs
 }
 
 // filterSubtree returns Bucket which contains only nodes,
@@ -283,12 +857,10 @@ func (b Bucket) filterSubtree(filter FilterFunc) *Bucket {
 
 	for _, c := range b.children {
 		if r = c.filterSubtree(filter); r != nil {
-			root.nodes = merge(root.nodes, r.nodes)
 			root.children = append(root.children, *r)
 		}
 	}
-	if len(root.nodes) > 0 {
-		sort.Sort(root.nodes)
+	if root.nodes = childNodes(root.children); len(root.nodes) > 0 {
 		return &root
 	}
 	return nil
@@ -309,7 +881,7 @@ func (b Bucket) getMaxSelectionC(ss []Select, filter FilterFunc, cut bool) (*Buc
 
 	if len(ss) == 0 || ss[0].Key == NodesBucket {
 		if r = b.filterSubtree(filter); r != nil {
-			if count = uint32(len(r.nodes)); len(ss) == 0 || ss[0].Count <= count {
+			if count = uint32(len(r.nodes)); len(ss) == 0 || ss[0].CountOf(int(count)) <= int(count) {
 				return r, count
 			}
 		}
@@ -324,8 +896,10 @@ func (b Bucket) getMaxSelectionC(ss []Select, filter FilterFunc, cut bool) (*Buc
 			sel = ss[1:]
 		}
 		if r, n = c.getMaxSelectionC(sel, filter, cutc); r != nil {
+			if cutc && ss[0].Aggregate != nil && !ss[0].Aggregate.Check(r.Nodelist()) {
+				continue
+			}
 			root.children = append(root.children, *r)
-			root.nodes = append(root.nodes, r.Nodelist()...)
 			if cutc {
 				count++
 			} else {
@@ -334,93 +908,245 @@ func (b Bucket) getMaxSelectionC(ss []Select, filter FilterFunc, cut bool) (*Buc
 		}
 	}
 
-	if (!cut && count != 0) || count >= ss[0].Count {
-		sort.Sort(root.nodes)
+	if (!cut && count != 0) || int(count) >= ss[0].CountOf(int(count)) {
+		root.nodes = childNodes(root.children)
 		return &root, count
-
 	}
 	return nil, 0
 }
 
 // GetMaxSelection returns 'maximal container' -- subgraph which contains
 // any other subgraph satisfying specified selects and filters.
+// Nodes not satisfying node filters are removed from every bucket.
+// Buckets chosen by selects with aggregate filter are kept only if
+// aggregate computed over their allowed nodes satisfies it.
 func (b Bucket) GetMaxSelection(s SFGroup) (r *Bucket) {
 	var (
-		allowed  = b.findAllowed(s.Filters)
-		excludes = make(map[uint32]bool, len(s.Exclude))
+		allowed  Nodes
+		excludes = make(map[uint32]struct{}, len(s.Exclude))
 	)
 
-	for _, c := range allowed {
-		excludes[c.N] = false
+	if len(s.Filters) != 0 {
+		allowed = b.findAllowed(s.Filters)
 	}
 	for _, c := range s.Exclude {
-		excludes[c] = true
+		excludes[c] = struct{}{}
 	}
 
 	r, _ = b.getMaxSelection(s.Selectors, func(nodes Nodes) Nodes {
-		return diff(nodes, excludes)
+		c := make(Nodes, 0, len(nodes))
+		for _, n := range nodes {
+			if _, ok := excludes[n.N]; ok || !n.InSubnet(s.Subnet) {
+				continue
+			} else if allowed != nil && !containsSorted(allowed, n.N) {
+				continue
+			} else if !checkNodeFilters(s.NodeFilters, n) {
+				continue
+			}
+			c = append(c, n)
+		}
+		return c
 	})
 	return
 }
 
 // GetSelection returns subgraph, satisfying specified selections.
 // It is assumed that all filters were already applied.
-func (b Bucket) GetSelection(ss []Select, pivot []byte) *Bucket {
+func (b Bucket) GetSelection(ss []Select, pivot []byte, opts ...SelectOption) *Bucket {
+	opts = forPlacement(opts)
+	return b.selectWithFallback(ss, func() selectParams {
+		return newSelectParams(b, ss, pivot, 0, opts...)
+	})
+}
+
+// GetCapacitySelection returns subgraph, satisfying specified selections,
+// in which nodes are picked until their total capacity reaches c.
+// Count of nodes in selections is treated as a minimum. Capacity is divided
+// equally between buckets chosen on every level.
+// It is assumed that all filters were already applied.
+func (b Bucket) GetCapacitySelection(ss []Select, pivot []byte, c uint64, opts ...SelectOption) *Bucket {
+	opts = forPlacement(opts)
+	return b.selectWithFallback(ss, func() selectParams {
+		return newSelectParams(b, ss, pivot, c, opts...)
+	})
+}
+
+func (b Bucket) getSelection(ss []Select, p selectParams) *Bucket {
 	var (
-		pivotHash uint64
-		root      = Bucket{Key: b.Key, Value: b.Value}
-		r         *Bucket
-		count, c  int
-		cs        []Bucket
+		root     = Bucket{Key: b.Key, Value: b.Value}
+		r        *Bucket
+		count, c int
+		cs       []Bucket
+		used     map[string]struct{}
 	)
-	if len(pivot) != 0 {
-		pivotHash = hrw.Hash(pivot)
-	}
 
+	if p.canceled() {
+		return nil
+	}
 	if len(ss) == 0 {
+		if p.capacity != 0 {
+			nodes, ok := p.take(p.withinQuota(b.orderedNodes(p)), 0)
+			if !ok {
+				return nil
+			}
+			p.consume(nodes)
+			root.nodes = nodes
+			return &root
+		}
+		if p.quota != nil {
+			return b.getQuotaSelection(p)
+		}
+		if p.maintenance != nil {
+			return b.filterSubtree(p.available)
+		}
 		root.nodes = b.nodes
 		root.children = b.children
 		return &root
 	}
 
-	count = int(ss[0].Count)
+	if ss[0].Same != "" {
+		return b.getSameSelection(ss, p)
+	}
+
 	if ss[0].Key == NodesBucket {
-		if len(b.nodes) < count {
-			return nil
+		// percentage is taken of the nodes available for selection
+		nodes := b.orderedNodes(p)
+		count = ss[0].CountOf(len(nodes))
+		if ss[0].Distinct != "" {
+			nodes = p.distinct(ss[0].Distinct, nodes)
 		}
 
-		nodes := make(Nodes, len(b.nodes))
-		copy(nodes, b.nodes)
-		if len(pivot) != 0 {
-			hrw.SortSliceByWeightValue(nodes, nodes.Weights(), pivotHash)
+		nodes, ok := p.take(p.withinQuota(nodes), count)
+		if !ok {
+			p.record(TraceRejected, b, nil, "not enough nodes")
+			return nil
 		}
-		root.nodes = nodes[:count]
+		p.consume(nodes)
+		p.record(TraceChosen, b, nodes, "")
+		if p.local != nil && p.hasLocal(b.nodes) && !p.allLocal(nodes) {
+			getLogger().Log("selection falls back to non-local nodes", "bucket", traceName(b), "nodes", nodes.Nodes())
+		}
+		root.nodes = nodes
 		return &root
 	}
 
-	cs = getChildrenByKey(b, ss[0])
-	if len(pivot) != 0 {
-		if b.weight == 0 {
-			hrw.SortSliceByValue(cs, pivotHash)
-		} else {
-			weights := make([]float64, len(cs))
-			for i := range weights {
-				weights[i] = cs[i].weight
-			}
-			hrw.SortSliceByWeightValue(cs, weights, pivotHash)
-		}
+	cs = b.orderedChildren(ss[0], p)
+	count = ss[0].CountOf(len(cs))
+	if p.capacity != 0 && count != 0 {
+		p.capacity = (p.capacity + uint64(count) - 1) / uint64(count)
 	}
+	if ss[0].Distinct != "" {
+		used = make(map[string]struct{})
+	}
+
 	for i := 0; i < len(cs); i++ {
-		if r = cs[i].GetSelection(ss[1:], pivot); r != nil {
-			root.Merge(*b.combine(r))
-			if c++; c == count {
-				return &root
+		p.record(TraceConsidered, cs[i], nil, "")
+		if r = cs[i].getSelection(ss[1:], p); r == nil {
+			p.record(TraceRejected, cs[i], nil, "nested selection failed")
+			continue
+		}
+		if used != nil && !p.useValues(ss[0].Distinct, r.Nodelist(), used) {
+			p.release(r.Nodelist())
+			p.record(TraceRejected, cs[i], nil, "distinct "+ss[0].Distinct+" conflict")
+			continue
+		}
+		p.record(TraceChosen, cs[i], nil, "")
+		if p.local != nil && !p.hasLocal(cs[i].Nodelist()) {
+			getLogger().Log("selection falls back to non-local bucket", "bucket", cs[i].Name())
+		}
+		root.merge(*b.combine(r))
+		if c++; c == count {
+			return &root
+		}
+	}
+	p.release(root.Nodelist())
+	p.record(TraceRejected, b, nil, "not enough "+ss[0].Key+" buckets")
+	return nil
+}
+
+// getQuotaSelection returns subtree of b containing all its available
+// nodes which can be chosen without exceeding quotas.
+func (b Bucket) getQuotaSelection(p selectParams) *Bucket {
+	nodes := p.withinQuota(b.orderedNodes(p))
+	if len(nodes) == 0 {
+		return nil
+	}
+	p.consume(nodes)
+
+	chosen := make(Nodes, len(nodes))
+	copy(chosen, nodes)
+	sort.Slice(chosen, func(i, j int) bool { return chosen[i].N < chosen[j].N })
+	return b.filterSubtree(func(ns Nodes) Nodes {
+		r := make(Nodes, 0, len(ns))
+		for i := range ns {
+			if containsSorted(chosen, ns[i].N) {
+				r = append(r, ns[i])
 			}
 		}
+		return r
+	})
+}
+
+// getSameSelection returns subgraph, satisfying specified selections,
+// in which all nodes chosen by ss[0] have the same value of ss[0].Same attribute.
+// Values are tried in pseudo-random order until selection succeeds.
+func (b Bucket) getSameSelection(ss []Select, p selectParams) *Bucket {
+	var (
+		key    = ss[0].Same
+		values = p.values[key]
+		sel    = make([]Select, len(ss))
+	)
+
+	copy(sel, ss)
+	sel[0].Same = ""
+
+	for _, v := range p.sameValues(key, b.Nodelist()) {
+		sub := b.withValue(values, v)
+		if sub == nil {
+			continue
+		}
+		p.record(TraceConsidered, *sub, nil, "same "+key+" "+v)
+		if r := sub.getSelection(sel, p); r != nil {
+			return r
+		}
 	}
+	p.record(TraceRejected, b, nil, "no common value of "+key)
 	return nil
 }
 
+// withValue returns subtree of b containing only nodes with attribute value v.
+func (b Bucket) withValue(values map[uint32]string, v string) *Bucket {
+	return b.filterSubtree(func(nodes Nodes) Nodes {
+		result := make(Nodes, 0, len(nodes))
+		for i := range nodes {
+			if val, ok := values[nodes[i].N]; ok && val == v {
+				result = append(result, nodes[i])
+			}
+		}
+		return result
+	})
+}
+
+// orderedNodes returns copy of b's nodes in the order of selection.
+func (b Bucket) orderedNodes(p selectParams) Nodes {
+	nodes := p.available(b.nodes)
+	p.shuffleNodes(nodes)
+	p.preferLocalNodes(nodes)
+	return nodes
+}
+
+// orderedChildren returns buckets selected by s in the order they must be tried.
+func (b Bucket) orderedChildren(s Select, p selectParams) []Bucket {
+	cs := getChildrenByKey(b, s)
+	if p.bucketWeight != nil {
+		p.shuffleProportional(cs)
+	} else {
+		p.shuffleBuckets(cs, b.weight != 0)
+	}
+	p.preferLocalBuckets(cs)
+	return cs
+}
+
 func (b Bucket) combine(b1 *Bucket) *Bucket {
 	if b.Equals(*b1) {
 		return b1
@@ -444,47 +1170,85 @@ func (b Bucket) combine(b1 *Bucket) *Bucket {
 // Conflict is a situation, when node has different values for the same option
 // in b and b1.
 func (b Bucket) CheckConflicts(b1 Bucket) bool {
-	for _, n := range b1.nodes {
-		if !contains(b.nodes, n) {
-			continue
-		}
-		for _, c := range b.children {
-			check := false
-			if contains(c.nodes, n) {
-				for _, c1 := range b1.children {
-					if contains(c1.nodes, n) && (c.Key != c1.Key || c.Value != c1.Value) {
-						return true
-					}
-					if c.Key == c1.Key && c.Value == c1.Value && !check && c.CheckConflicts(c1) {
-						return true
-					}
-					check = true
+	return len(b.FindConflicts(b1)) != 0
+}
+
+// FindConflicts returns all nodes which are present both in b and b1 under
+// buckets with the same key but different values, e.g. in different Countries.
+// Conflicts are sorted by node index, every conflicting key is reported.
+func (b Bucket) FindConflicts(b1 Bucket) (cs []Conflict) {
+	var (
+		ps  = b.nodePaths()
+		ps1 = b1.nodePaths()
+	)
+
+	for _, n := range b.nodes {
+		for _, p := range ps[n.N] {
+			for _, p1 := range ps1[n.N] {
+				if p.key == p1.key && p.value != p1.value {
+					cs = append(cs, Conflict{Node: n.N, Path: p.path, OtherPath: p1.path})
+					getLogger().Log("conflict detected", "node", n.N, "path", p.path, "other_path", p1.path)
 				}
 			}
 		}
 	}
-	return false
+	return
+}
+
+// Error implements error interface.
+func (c Conflict) Error() string {
+	return fmt.Sprintf("node %d is both in %s and %s", c.Node, c.Path, c.OtherPath)
+}
+
+type bucketPath struct {
+	key, value, path string
 }
 
-// Merge merges b1 into b assuming there are no conflicts.
+// nodePaths returns all buckets containing every node along with their paths.
+func (b Bucket) nodePaths() map[uint32][]bucketPath {
+	m := make(map[uint32][]bucketPath, len(b.nodes))
+	b.collectPaths("", m)
+	return m
+}
+
+func (b Bucket) collectPaths(prefix string, m map[uint32][]bucketPath) {
+	for _, c := range b.children {
+		p := prefix + Separator + c.Name()
+		for _, n := range c.nodes {
+			m[n.N] = append(m[n.N], bucketPath{key: c.Key, value: c.Value, path: p})
+		}
+		c.collectPaths(p, m)
+	}
+}
+
+// Merge adds nodes and sub-buckets of b1 to b. Children of the resulting
+// buckets are sorted by SortChildren, so that merging the same buckets
+// in different order produces equal trees.
 func (b *Bucket) Merge(b1 Bucket) {
-	b.nodes = merge(b.nodes, b1.nodes)
+	b.merge(b1)
+	b.SortChildren()
+}
+
+func (b *Bucket) merge(b1 Bucket) {
+	b.nodes = merge(b.nodes, sortedNodes(b1.nodes))
+	b.ownChildren()
 
 loop:
 	for _, c1 := range b1.children {
 		for i := range b.children {
 			if b.children[i].Equals(c1) {
-				b.children[i].Merge(c1)
+				b.children[i].merge(c1)
 				continue loop
 			}
 		}
 		b.children = append(b.children, c1)
 	}
-	sort.Sort(b.nodes)
+	b.nodes = sortedNodes(b.nodes)
 }
 
 // UpdateIndices is auxiliary function used to update
-// indices of all nodes according to tr.
+// indices of all nodes according to tr. Translation isn't
+// checked, use Remap to renumber nodes safely.
 func (b *Bucket) UpdateIndices(tr map[uint32]Node) Bucket {
 	var (
 		children = make([]Bucket, 0, len(b.children))
@@ -498,6 +1262,7 @@ func (b *Bucket) UpdateIndices(tr map[uint32]Node) Bucket {
 		nodes = append(nodes, tr[b.nodes[i].N])
 	}
 	sort.Sort(nodes)
+	nodes = dedupNodes(nodes)
 
 	return Bucket{
 		Key:      b.Key,
@@ -507,6 +1272,42 @@ func (b *Bucket) UpdateIndices(tr map[uint32]Node) Bucket {
 	}
 }
 
+// Remap returns copy of b with nodes renumbered according to tr, e.g. when
+// node indices are changed between epochs. Other attributes of the nodes are
+// kept. Every node of b must be present in tr and different nodes must not
+// be mapped to the same index.
+func (b Bucket) Remap(tr map[uint32]uint32) (Bucket, error) {
+	used := make(map[uint32]uint32, len(tr))
+	for from, to := range tr {
+		if prev, ok := used[to]; ok {
+			if prev > from {
+				prev, from = from, prev
+			}
+			return Bucket{}, errors.Errorf("nodes %d and %d are both mapped to %d", prev, from, to)
+		}
+		used[to] = from
+	}
+
+	nodes := make(map[uint32]Node, len(b.nodes))
+	err := b.Walk(func(c *Bucket) error {
+		for _, n := range c.nodes {
+			if _, ok := nodes[n.N]; ok {
+				continue
+			}
+			from, ok := n.N, false
+			if n.N, ok = tr[from]; !ok {
+				return errors.Errorf("no new index for node %d", from)
+			}
+			nodes[from] = n
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return Bucket{}, err
+	}
+	return b.UpdateIndices(nodes), nil
+}
+
 func getChildrenByKey(b Bucket, s Select) []Bucket {
 	buckets := make([]Bucket, 0, 10)
 	for _, c := range b.children {
@@ -519,9 +1320,49 @@ func getChildrenByKey(b Bucket, s Select) []Bucket {
 	return buckets
 }
 
-// Writes Bucket with this byte structure
-// [lnName][Name][lnNodes][Node1]...[NodeN][lnSubprops][sub1]...[subN]
+// Write writes Bucket in FormatV1 with this byte structure
+// [magic][version][lnName][Name][lnNodes][Node1]...[NodeN][lnSubprops][sub1]...[subN]
+// where sub-buckets are written without magic and version.
 func (b Bucket) Write(w io.Writer) error {
+	if _, err := w.Write([]byte{formatMagic, FormatV1}); err != nil {
+		return err
+	}
+	if err := b.writeHeader(w); err != nil {
+		return err
+	}
+
+	stack := []bucketFrame{{b: &b}}
+	for len(stack) != 0 {
+		top := &stack[len(stack)-1]
+		if top.next == len(top.b.children) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		c := &top.b.children[top.next]
+		top.next++
+		if len(stack) >= MaxBucketDepth {
+			return errors.Errorf("bucket tree is deeper than %d", MaxBucketDepth)
+		}
+		if err := c.writeHeader(w); err != nil {
+			return err
+		}
+		stack = append(stack, bucketFrame{b: c})
+	}
+	return nil
+}
+
+// bucketFrame is a bucket being processed by iterative serialization
+// along with the index of the next child to process. While decoding,
+// count is the number of children to read.
+type bucketFrame struct {
+	b     *Bucket
+	next  int
+	count int
+}
+
+// writeHeader writes name, nodes and the number of children of b.
+func (b Bucket) writeHeader(w io.Writer) error {
 	var err error
 
 	// writing name
@@ -537,55 +1378,118 @@ func (b Bucket) Write(w io.Writer) error {
 		return err
 	}
 
-	if err = binary.Write(w, binary.BigEndian, int32(len(b.children))); err != nil {
+	return binary.Write(w, binary.BigEndian, int32(len(b.children)))
+}
+
+// Read reads Bucket in any supported binary format, see FormatV1 and FormatV2.
+// Data compressed by WriteCompressed is decompressed transparently, in this
+// case r can be read past the end of the bucket.
+func (b *Bucket) Read(r io.Reader) error {
+	return b.read(r, true)
+}
+
+func (b *Bucket) read(r io.Reader, compressed bool) error {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
 		return err
 	}
-	for i := range b.children {
-		if err = b.children[i].Write(w); err != nil {
-			return err
+
+	switch {
+	case first[0] == formatMagic:
+		err := b.readVersioned(asByteReader(r))
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
+		return wrapTruncated(err)
+	case first[0] == gzipMagic && compressed:
+		return wrapTruncated(b.readCompressed(io.MultiReader(bytes.NewReader(first[:]), r)))
+	default:
+		return wrapTruncated(b.readV1(unreadFirst(r, first[0]), true))
+	}
+}
+
+// wrapTruncated wraps error caused by truncated data into ErrMalformedEncoding.
+func wrapTruncated(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return errors.Wrap(ErrMalformedEncoding, "unexpected end of data")
 	}
+	return err
+}
 
-	return nil
+// unreadFirst returns reader yielding already read first byte
+// followed by the rest of r.
+func unreadFirst(r io.Reader, first byte) io.Reader {
+	if s, ok := r.(io.ByteScanner); ok && s.UnreadByte() == nil {
+		return r
+	}
+	return io.MultiReader(bytes.NewReader([]byte{first}), r)
 }
 
-// Read reads Bucket in serialized form:
+// readV1 reads Bucket in serialized form:
 // [lnName][Name][lnNodes][Node1]...[NodeN][lnSubprops][sub1]...[subN]
-func (b *Bucket) Read(r io.Reader) error {
+// Nodes are read in legacy layout if legacy is set.
+func (b *Bucket) readV1(r io.Reader, legacy bool) error {
+	n, err := b.readHeader(r, legacy)
+	if err != nil {
+		return err
+	}
+
+	stack := []bucketFrame{{b: b, count: n}}
+	for len(stack) != 0 {
+		top := &stack[len(stack)-1]
+		if top.next == top.count {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		top.next++
+		if len(stack) >= MaxBucketDepth {
+			return errors.Wrapf(ErrMalformedEncoding, "bucket tree is deeper than %d", MaxBucketDepth)
+		}
+		top.b.children = append(top.b.children, Bucket{})
+		c := &top.b.children[len(top.b.children)-1]
+		if n, err = c.readHeader(r, legacy); err != nil {
+			return err
+		}
+		stack = append(stack, bucketFrame{b: c, count: n})
+	}
+	return nil
+}
+
+// readHeader reads name and nodes of b and returns the number of its children.
+// Children are appended by the caller as they are read.
+func (b *Bucket) readHeader(r io.Reader, legacy bool) (int, error) {
 	var ln int32
 	var err error
 	if err = binary.Read(r, binary.BigEndian, &ln); err != nil {
-		return err
+		return 0, err
 	}
-	name := make([]byte, ln)
-	lnE, err := r.Read(name)
-	if err != nil {
-		return err
+	if ln < 0 {
+		return 0, errors.Wrap(ErrMalformedEncoding, "negative name length")
 	}
-	if int32(lnE) != ln {
-		return errors.New("unmarshaller error: cannot read name")
+	name, err := readN(r, int(ln))
+	if err == io.ErrUnexpectedEOF {
+		return 0, errors.Wrap(ErrMalformedEncoding, "cannot read name")
+	} else if err != nil {
+		return 0, err
 	}
 
 	b.Key, b.Value, _ = splitKV(string(name))
 
 	// reading node list
-	if err = b.nodes.Read(r); err != nil {
-		return err
+	if err = b.nodes.read(r, legacy); err != nil {
+		return 0, err
 	}
 
 	if err = binary.Read(r, binary.BigEndian, &ln); err != nil {
-		return err
+		return 0, err
 	}
-	if ln > 0 {
-		b.children = make([]Bucket, ln)
-		for i := range b.children {
-			if err = b.children[i].Read(r); err != nil {
-				return err
-			}
-		}
+	b.children = nil
+	if ln <= 0 {
+		return 0, nil
 	}
-
-	return nil
+	b.children = make([]Bucket, 0, preallocLen(r, int(ln), bucketHeaderSize))
+	return int(ln), nil
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -612,12 +1516,12 @@ func (b Bucket) Name() string {
 }
 
 func (b *Bucket) fillNodes() {
-	r := b.nodes
 	for i := range b.children {
 		b.children[i].fillNodes()
-		r = merge(r, b.children[i].Nodelist())
 	}
-	b.nodes = r
+	if len(b.children) != 0 {
+		b.nodes = merge(b.nodes, childNodes(b.children))
+	}
 }
 
 // Nodelist returns slice of nodes belonging to b.
@@ -626,10 +1530,72 @@ func (b Bucket) Nodelist() (r Nodes) {
 		return b.nodes
 	}
 
+	return childNodes(b.children)
+}
+
+// Iterate calls f for every node in leaf buckets of b and every node attached
+// directly to inner buckets along with the path of bucket names leading to it.
+// Path slice is reused between calls and must be copied if retained.
+// Iteration stops when f returns false.
+func (b Bucket) Iterate(f func(path []string, node uint32) bool) {
+	b.iterate(make([]string, 0, 8), f)
+}
+
+func (b Bucket) iterate(path []string, f func([]string, uint32) bool) bool {
+	for _, n := range b.ownNodes() {
+		if !f(path, n.N) {
+			return false
+		}
+	}
+
 	for _, c := range b.children {
-		r = merge(r, c.Nodelist())
+		if !c.iterate(append(path, c.Name()), f) {
+			return false
+		}
 	}
-	return
+	return true
+}
+
+// PathsOf returns names of buckets on every path from b to the deepest
+// sub-buckets containing node n, e.g. [[Location:Europe Country:Germany]].
+// Node not belonging to any sub-bucket has no paths.
+func (b Bucket) PathsOf(n uint32) [][]string {
+	var r [][]string
+	b.pathsOf(n, make([]string, 0, 8), &r)
+	return r
+}
+
+func (b Bucket) pathsOf(n uint32, path []string, r *[][]string) {
+	found := false
+	for _, c := range b.children {
+		if containsSorted(c.nodes, n) {
+			found = true
+			c.pathsOf(n, append(path, c.Name()), r)
+		}
+	}
+	if !found && len(path) != 0 {
+		*r = append(*r, append([]string(nil), path...))
+	}
+}
+
+// Walk traverses b in depth-first order calling pre before visiting
+// subbuckets and post after that. Both functions can be nil.
+// Walk stops at the first error and returns it.
+func (b *Bucket) Walk(pre, post func(*Bucket) error) error {
+	if pre != nil {
+		if err := pre(b); err != nil {
+			return err
+		}
+	}
+	for i := range b.children {
+		if err := b.children[i].Walk(pre, post); err != nil {
+			return err
+		}
+	}
+	if post != nil {
+		return post(b)
+	}
+	return nil
 }
 
 // Children returns array of subbuckets of b.
@@ -639,7 +1605,7 @@ func (b Bucket) Children() []Bucket {
 
 // AddNode adds node n with options opts to b.
 func (b *Bucket) AddNode(n uint32, opts ...string) error {
-	return b.addNode(Node{n, 0, 0}, opts...)
+	return b.addNode(Node{N: n}, opts...)
 }
 
 // AddStrawNode adds straw node n with options opts to b.
@@ -649,6 +1615,10 @@ func (b *Bucket) AddStrawNode(n Node, opts ...string) error {
 }
 
 func (b *Bucket) addNode(n Node, opts ...string) error {
+	// options are checked in advance, so that b isn't changed on failure
+	if err := checkOptions(b.schema, opts); err != nil {
+		return errors.Wrapf(err, "node %d", n.N)
+	}
 	for _, o := range opts {
 		if err := b.AddBucket(o, Nodes{n}); err != nil {
 			return err
@@ -657,29 +1627,151 @@ func (b *Bucket) addNode(n Node, opts ...string) error {
 	return nil
 }
 
+// AddNodes adds all nodes from es to b. It is equivalent to calling AddStrawNode
+// for every entry, but nodes of every bucket are sorted only once, which makes
+// it much faster for building large netmaps.
+func (b *Bucket) AddNodes(es []NodeEntry) error {
+	root := newPendingBucket(Bucket{})
+	for _, e := range es {
+		for _, o := range e.Options {
+			if err := checkOption(b.schema, o); err != nil {
+				return errors.Wrapf(err, "invalid option %s of node %d", o, e.Node.N)
+			}
+
+			p := root
+			p.nodes = append(p.nodes, e.Node)
+			if o == Separator {
+				continue
+			}
+			for _, c := range splitProps(o[1:]) {
+				p = p.child(c)
+				p.nodes = append(p.nodes, e.Node)
+			}
+		}
+	}
+	b.addPending(root)
+	return nil
+}
+
+type pendingBucket struct {
+	Bucket
+	index    map[string]int
+	children []*pendingBucket
+}
+
+func newPendingBucket(b Bucket) *pendingBucket {
+	return &pendingBucket{Bucket: b, index: make(map[string]int)}
+}
+
+func (p *pendingBucket) child(b Bucket) *pendingBucket {
+	name := b.Name()
+	if i, ok := p.index[name]; ok {
+		return p.children[i]
+	}
+	p.index[name] = len(p.children)
+	p.children = append(p.children, newPendingBucket(b))
+	return p.children[len(p.children)-1]
+}
+
+func (b *Bucket) addPending(p *pendingBucket) {
+	sort.Stable(p.nodes)
+	b.nodes = merge(b.nodes, dedupNodes(p.nodes))
+	b.ownChildren()
+
+loop:
+	for _, pc := range p.children {
+		for i := range b.children {
+			if b.children[i].Equals(pc.Bucket) {
+				b.children[i].addPending(pc)
+				continue loop
+			}
+		}
+		b.children = append(b.children, Bucket{Key: pc.Key, Value: pc.Value})
+		b.children[len(b.children)-1].addPending(pc)
+	}
+}
+
 func splitKV(s string) (string, string, error) {
 	kv := strings.SplitN(s, ":", 2)
 	if len(kv) != 2 {
-		return "", "", errors.New("wrong format")
+		return "", "", errors.Wrapf(ErrBadBucketPath, "%s is not a key:value pair", s)
 	}
 	return kv[0], kv[1], nil
 }
 
-// GetNodesByOption returns list of nodes possessing specified options.
+// GetNodesByOption returns list of nodes possessing all specified options.
+// Option can consist of several paths separated by AltSeparator, in which case
+// nodes located at any of the paths possess it, e.g. "/Country:RU|/Country:DE".
+// Paths can contain wildcards as described in Query.
 func (b Bucket) GetNodesByOption(opts ...string) Nodes {
 	var nodes Nodes
-	for _, opt := range opts {
-		nodes = intersect(nodes, getNodes(b, splitProps(opt[1:])))
+	for i, opt := range opts {
+		var ls []Nodes
+		for _, p := range strings.Split(opt, AltSeparator) {
+			for _, c := range b.Query(p) {
+				ls = append(ls, c.Nodelist())
+			}
+		}
+
+		// paths can overlap, e.g. "/Country:RU|/Country:*",
+		// mergeAll keeps single node of every index
+		ns := mergeAll(ls)
+		if i == 0 {
+			nodes = ns
+		} else {
+			nodes = intersect(nodes, ns)
+		}
+		if len(nodes) == 0 {
+			return nil
+		}
 	}
 	return nodes
 }
 
+// Query returns all subbuckets of b located at path. Path has the same format
+// as options, e.g. "/Country:Germany/City:Berlin". Wildcard "*" can be used
+// in place of key or value, or as a whole segment to match any bucket.
+func (b *Bucket) Query(path string) []*Bucket {
+	path = strings.Trim(path, Separator)
+	if path == "" {
+		return []*Bucket{b}
+	}
+	return b.query(strings.Split(path, Separator))
+}
+
+func (b *Bucket) query(path []string) (bs []*Bucket) {
+	if len(path) == 0 {
+		return []*Bucket{b}
+	}
+
+	k, v, err := splitKV(path[0])
+	if err != nil {
+		if path[0] != "*" {
+			return nil
+		}
+		k, v = "*", "*"
+	}
+
+	for i := range b.children {
+		c := &b.children[i]
+		if matchWildcard(k, c.Key) && matchWildcard(v, c.Value) {
+			bs = append(bs, c.query(path[1:])...)
+		}
+	}
+	return
+}
+
+func matchWildcard(pattern, s string) bool {
+	return pattern == "*" || pattern == s
+}
+
 func (b *Bucket) addNodes(bs []Bucket, n Nodes) error {
 	b.nodes = merge(b.nodes, n)
 	if len(bs) == 0 {
 		return nil
 	}
 
+	b.ownChildren()
 	for i := range b.children {
 		if bs[0].Equals(b.children[i]) {
 			return b.children[i].addNodes(bs[1:], n)
@@ -690,19 +1782,75 @@ func (b *Bucket) addNodes(bs []Bucket, n Nodes) error {
 }
 
 // AddBucket add bucket corresponding to option o with nodes n as subbucket to b.
+// Nodes can be in any order, only the first of the nodes with the same index is added.
 func (b *Bucket) AddBucket(o string, n Nodes) error {
-	if o != Separator && (!strings.HasPrefix(o, Separator) || strings.HasSuffix(o, Separator)) {
-		return errors.Errorf("must start and not end with '%s'", Separator)
+	if err := checkOption(b.schema, o); err != nil {
+		return err
 	}
 	if len(n) == 0 {
 		n = nil
 	}
-	return b.addNodes(splitProps(o[1:]), n)
+	return b.addNodes(splitProps(o[1:]), sortedNodes(n))
+}
+
+// AddBucketStrict adds bucket corresponding to option o with nodes n as subbucket to b
+// like AddBucket does. Unlike AddBucket, it fails if any of the nodes already belongs to
+// a bucket with the same key but different value (e.g. to another Country).
+// Adding the same nodes to the same bucket again is not an error.
+func (b *Bucket) AddBucketStrict(o string, n Nodes) error {
+	if err := checkOption(b.schema, o); err != nil {
+		return err
+	}
+
+	props := splitProps(o[1:])
+	for i, p := range props {
+		for _, p1 := range props[:i] {
+			if p.Key == p1.Key && p.Value != p1.Value {
+				return errors.Errorf("conflicting values of %s: %s and %s", p.Key, p1.Value, p.Value)
+			}
+		}
+		for _, c := range b.findKey(p.Key) {
+			if c.Value == p.Value {
+				continue
+			}
+			for _, nd := range n {
+				if contains(c.nodes, nd) {
+					getLogger().Log("conflict detected", "node", nd.N, "bucket", c.Name(), "new_bucket", p.Name())
+					return errors.Errorf("node %d already has %s, can't add it to %s", nd.N, c.Name(), p.Name())
+				}
+			}
+		}
+	}
+	return b.AddBucket(o, n)
+}
+
+// checkOption checks format of option o and that it matches schema s,
+// nil schema accepts any well-formed option.
+func checkOption(s *Schema, o string) error {
+	if o != Separator && (!strings.HasPrefix(o, Separator) || strings.HasSuffix(o, Separator)) {
+		return errors.Wrapf(ErrBadBucketPath, "must start and not end with '%s'", Separator)
+	}
+	return s.CheckOption(o)
 }
 
-// AddChild adds c as direct child to b.
+func checkOptions(s *Schema, opts []string) error {
+	for _, o := range opts {
+		if err := checkOption(s, o); err != nil {
+			return errors.Wrapf(err, "invalid option %s", o)
+		}
+	}
+	return nil
+}
+
+// AddChild adds c as direct child to b. Nodes of c and its sub-buckets
+// are sorted and deduplicated if needed, parent buckets are filled with
+// nodes of their children.
 func (b *Bucket) AddChild(c Bucket) {
+	if !c.isNormalized() {
+		c.normalize()
+	}
 	b.nodes = merge(b.nodes, c.nodes)
+	b.ownChildren()
 	b.children = append(b.children, c)
 }
 
@@ -716,6 +1864,81 @@ func splitProps(o string) []Bucket {
 	return props
 }
 
+// childNodes returns sorted list of nodes of all buckets from cs.
+func childNodes(cs []Bucket) Nodes {
+	if len(cs) == 0 {
+		return nil
+	}
+	ls := make([]Nodes, 0, len(cs))
+	for i := range cs {
+		ls = append(ls, cs[i].Nodelist())
+	}
+	return mergeAll(ls)
+}
+
+// mergeAll merges sorted node lists from ls into a single list. It uses a heap
+// of list heads, so merging k lists takes O(n log k) time and a single allocation
+// instead of O(n k) for merging them one by one. As for merge, node from
+// the earlier list is kept if there are nodes with the same index.
+func mergeAll(ls []Nodes) Nodes {
+	var (
+		total int
+		h     = make([]int, 0, len(ls))
+		pos   = make([]int, len(ls))
+	)
+
+	for i := range ls {
+		if len(ls[i]) != 0 {
+			total += len(ls[i])
+			h = append(h, i)
+		}
+	}
+	switch len(h) {
+	case 0:
+		return nil
+	case 1:
+		return ls[h[0]]
+	}
+
+	less := func(i, j int) bool {
+		a, b := ls[h[i]][pos[h[i]]].N, ls[h[j]][pos[h[j]]].N
+		return a < b || a == b && h[i] < h[j]
+	}
+	down := func(i int) {
+		for {
+			m := i
+			if l := 2*i + 1; l < len(h) && less(l, m) {
+				m = l
+			}
+			if r := 2*i + 2; r < len(h) && less(r, m) {
+				m = r
+			}
+			if m == i {
+				return
+			}
+			h[i], h[m] = h[m], h[i]
+			i = m
+		}
+	}
+	for i := len(h)/2 - 1; i >= 0; i-- {
+		down(i)
+	}
+
+	r := make(Nodes, 0, total)
+	for len(h) != 0 {
+		top := h[0]
+		if n := ls[top][pos[top]]; len(r) == 0 || r[len(r)-1].N != n.N {
+			r = append(r, n)
+		}
+		if pos[top]++; pos[top] == len(ls[top]) {
+			h[0] = h[len(h)-1]
+			h = h[:len(h)-1]
+		}
+		down(0)
+	}
+	return r
+}
+
 func merge(a, b Nodes) Nodes {
 	if len(a) == 0 {
 		return b
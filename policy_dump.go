@@ -1,9 +1,12 @@
 package netmap
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/awalterschulze/gographviz"
 	"github.com/pkg/errors"
@@ -60,6 +63,91 @@ func (b Bucket) Dump(name string) error {
 	return ioutil.WriteFile(name, []byte(s), os.ModePerm)
 }
 
+// String returns indented tree representation of b. Every bucket is followed
+// by the number of its nodes and indices of nodes attached to it directly,
+// which are all nodes for leaf buckets.
+func (b Bucket) String() string {
+	var sb strings.Builder
+	b.writeTree(&sb, 0)
+	return sb.String()
+}
+
+func (b Bucket) writeTree(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	fmt.Fprintf(sb, "%s (%d)", traceName(b), len(b.nodes))
+	if own := b.ownNodes(); len(own) != 0 {
+		fmt.Fprintf(sb, " %v", own.Nodes())
+	}
+	sb.WriteByte('\n')
+
+	for _, c := range b.children {
+		c.writeTree(sb, depth+1)
+	}
+}
+
+// DiffString returns changes required to turn b into other as an indented tree.
+// Lines starting with '-' describe removed buckets and nodes, lines starting
+// with '+' describe added ones and '~' marks nodes with changed parameters.
+// Unchanged buckets are printed only as parents of changed ones.
+// Empty string is returned if trees are the same.
+func (b Bucket) DiffString(other Bucket) string {
+	var sb strings.Builder
+	for _, l := range diffTree(b, other, 0) {
+		sb.WriteString(l)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func diffTree(a, b Bucket, depth int) []string {
+	var (
+		lines  []string
+		indent = strings.Repeat("  ", depth+1)
+	)
+
+	// nodes of children are compared on the next levels,
+	// so only nodes attached to buckets directly are compared here
+	an, bn := a.ownNodes(), b.ownNodes()
+	for _, n := range an {
+		if i := sort.Search(len(bn), func(i int) bool { return bn[i].N >= n.N }); i == len(bn) || bn[i].N != n.N {
+			lines = append(lines, "- "+indent+"node "+strconv.FormatUint(uint64(n.N), 10))
+		} else if !bn[i].Equals(n) {
+			lines = append(lines, "~ "+indent+"node "+strconv.FormatUint(uint64(n.N), 10))
+		}
+	}
+	for _, n := range subtract(bn, an) {
+		lines = append(lines, "+ "+indent+"node "+strconv.FormatUint(uint64(n.N), 10))
+	}
+
+	for _, c := range a.children {
+		if i := findChild(b.children, c); i < 0 {
+			lines = append(lines, fmt.Sprintf("- %s%s (%d) %v", indent, c.Name(), len(c.nodes), c.nodes.Nodes()))
+		} else if sub := diffTree(c, b.children[i], depth+1); len(sub) != 0 {
+			lines = append(lines, "  "+indent+c.Name())
+			lines = append(lines, sub...)
+		}
+	}
+	for _, c := range b.children {
+		if findChild(a.children, c) < 0 {
+			lines = append(lines, fmt.Sprintf("+ %s%s (%d) %v", indent, c.Name(), len(c.nodes), c.nodes.Nodes()))
+		}
+	}
+
+	if depth == 0 && len(lines) != 0 {
+		lines = append([]string{"  " + traceName(a)}, lines...)
+	}
+	return lines
+}
+
+func findChild(cs []Bucket, c Bucket) int {
+	for i := range cs {
+		if cs[i].Key == c.Key && cs[i].Value == c.Value {
+			return i
+		}
+	}
+	return -1
+}
+
 // Sdump returns string representation of Bucket in *.dot format.
 func (b Bucket) Sdump() (string, error) {
 	g, err := b.toGraph()
@@ -1,13 +1,22 @@
 package netmap
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -211,6 +220,32 @@ func TestBucket_checkConflicts(t *testing.T) {
 	require.False(t, b2.CheckConflicts(b1))
 }
 
+func TestBucket_FindConflicts(t *testing.T) {
+	b1, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 3}},
+		bucket{"/Trust:10", []uint32{1}},
+	)
+	require.NoError(t, err)
+
+	b2, err := newRoot(
+		bucket{"/Location:Asia/Country:China", []uint32{1}},
+		bucket{"/Location:Europe/Country:Germany", []uint32{2, 3}},
+		bucket{"/Trust:10", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, []Conflict{
+		{Node: 1, Path: "/Location:Europe", OtherPath: "/Location:Asia"},
+		{Node: 1, Path: "/Location:Europe/Country:Germany", OtherPath: "/Location:Asia/Country:China"},
+	}, b1.FindConflicts(b2))
+	require.Equal(t, "node 1 is both in /Location:Europe and /Location:Asia", b1.FindConflicts(b2)[0].Error())
+	require.Len(t, b2.FindConflicts(b1), 2)
+
+	require.Empty(t, b1.FindConflicts(b1))
+	require.NoError(t, b2.AddBucket("/Trust:5", Nodes{{N: 1}}))
+	require.Equal(t, []Conflict{{Node: 1, Path: "/Trust:10", OtherPath: "/Trust:5"}}, b1.FindConflicts(b2)[2:])
+}
+
 func TestBucket_Merge(t *testing.T) {
 	var (
 		b1, b2, exp Bucket
@@ -230,6 +265,7 @@ func TestBucket_Merge(t *testing.T) {
 
 	exp, err = newRoot(buckets...)
 	require.NoError(t, err)
+	exp.SortChildren()
 
 	b1.Merge(b2)
 	require.Equal(t, exp, b1)
@@ -249,11 +285,31 @@ func TestBucket_Merge(t *testing.T) {
 
 	exp, err = newRoot(buckets...)
 	require.NoError(t, err)
+	exp.SortChildren()
 
 	b1.Merge(b2)
 	require.Equal(t, exp, b1)
 }
 
+func TestBucket_Remap(t *testing.T) {
+	b, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 10}, {N: 3, C: 30}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 2, C: 20}}},
+	)
+	require.NoError(t, err)
+
+	r, err := b.Remap(map[uint32]uint32{1: 5, 2: 1, 3: 2, 4: 3})
+	require.NoError(t, err)
+	require.Equal(t, Nodes{{N: 1, C: 20}, {N: 2, C: 30}, {N: 5, C: 10}}, r.Nodelist())
+	require.Equal(t, Nodes{{N: 2, C: 30}, {N: 5, C: 10}}, r.GetNodesByOption("/Location:Europe/Country:Germany"))
+	require.Equal(t, []uint32{1, 2, 3}, b.Nodelist().Nodes())
+
+	_, err = b.Remap(map[uint32]uint32{1: 5, 2: 1})
+	require.Error(t, err)
+	_, err = b.Remap(map[uint32]uint32{1: 5, 2: 1, 3: 5})
+	require.Error(t, err)
+}
+
 func TestBucket_GetSelection(t *testing.T) {
 	var (
 		err       error
@@ -503,6 +559,63 @@ func TestBucket_GetMaxSelection(t *testing.T) {
 	require.Equal(t, &exp, r)
 }
 
+func TestBucket_GetMaxSelectionAggregate(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Country:Germany/City:Berlin", []uint32{1, 2}},
+		bucket{"/Country:Germany/City:Hamburg", []uint32{3}},
+		bucket{"/Country:Spain/City:Madrid", []uint32{10, 11}},
+		bucket{"/Country:Spain/City:Barcelona", []uint32{20}},
+	)
+	require.NoError(t, err)
+
+	// capacity of node is its index plus 1
+	ss := []Select{
+		AggregateSelect(2, "City", Aggregation_SUM, NodeField_CAPACITY, FilterGE(10)),
+		{Key: NodesBucket, Count: 1},
+	}
+	r := root.GetMaxSelection(SFGroup{Selectors: ss})
+	require.NotNil(t, r)
+	require.Equal(t, []uint32{10, 11, 20}, r.Nodelist().Nodes())
+
+	ss[0].Aggregate.F = FilterGE(22)
+	require.Nil(t, root.GetMaxSelection(SFGroup{Selectors: ss}))
+
+	// aggregate is computed over allowed nodes only
+	ss[0].Aggregate.F = FilterGE(12)
+	require.NotNil(t, root.GetMaxSelection(SFGroup{Selectors: ss}))
+	require.Nil(t, root.GetMaxSelection(SFGroup{Selectors: ss, Exclude: []uint32{11}}))
+
+	ss[0] = AggregateSelect(2, "City", Aggregation_MAX, NodeField_CAPACITY, FilterLT(11))
+	r = root.GetMaxSelection(SFGroup{Selectors: ss})
+	require.NotNil(t, r)
+	require.Equal(t, []uint32{1, 2, 3}, r.Nodelist().Nodes())
+
+	require.NotNil(t, root.FindNodes([]byte("pivot"), SFGroup{Selectors: ss}))
+}
+
+func TestBucket_GetMaxSelectionNodeFilters(t *testing.T) {
+	var root Bucket
+	require.NoError(t, root.AddBucket("/City:Berlin", Nodes{{N: 1, C: 10, P: 5}, {N: 2, C: 100, P: 1}}))
+	require.NoError(t, root.AddBucket("/City:Madrid", Nodes{{N: 3, C: 50, P: 2}, {N: 4, C: 200, P: 9}}))
+
+	ss := []Select{{Key: "City", Count: 2}, {Key: NodesBucket, Count: 1}}
+	r := root.GetMaxSelection(SFGroup{
+		Selectors:   ss,
+		NodeFilters: []NodeFilter{MinCapacity(50), MaxPrice(5)},
+	})
+	require.NotNil(t, r)
+	require.Equal(t, []uint32{2, 3}, r.Nodelist().Nodes())
+
+	r = root.GetMaxSelection(SFGroup{
+		Selectors:   ss,
+		NodeFilters: []NodeFilter{MinCapacity(100), MaxPrice(5)},
+	})
+	require.Nil(t, r)
+
+	ns := root.FindNodes([]byte("pivot"), SFGroup{Selectors: ss, NodeFilters: []NodeFilter{MaxPrice(2)}})
+	require.Equal(t, []uint32{2, 3}, ns.Nodes())
+}
+
 func TestNetMap_GetNodesByOption(t *testing.T) {
 	var (
 		fr, ge, eu, root Bucket
@@ -534,6 +647,58 @@ func TestNetMap_GetNodesByOption(t *testing.T) {
 
 	n2 := root.GetNodesByOption("/Location:Europe/Country:Russia")
 	require.Len(t, n2.Nodes(), 0)
+
+	t.Run("or", func(t *testing.T) {
+		ns := root.GetNodesByOption("/Location:Europe/Country:Germany|/Location:Europe/Country:France")
+		require.Equal(t, []uint32{0, 1, 2, 3, 4}, ns.Nodes())
+
+		ns = root.GetNodesByOption("/Location:Europe/Country:Germany|/Location:Europe/Country:*")
+		require.Equal(t, []uint32{0, 1, 2, 3, 4}, ns.Nodes())
+
+		ns = root.GetNodesByOption("/Location:Europe/Country:Russia|/Location:Europe/Country:Germany")
+		require.Equal(t, []uint32{2, 4}, ns.Nodes())
+
+		ns = root.GetNodesByOption("/Location:Europe/Country:Germany|/Location:Europe/Country:France", "/*/Country:Germany")
+		require.Equal(t, []uint32{2, 4}, ns.Nodes())
+	})
+
+	t.Run("empty option", func(t *testing.T) {
+		ns := root.GetNodesByOption("/Location:Europe/Country:Russia", "/Location:Europe/Country:Germany")
+		require.Empty(t, ns)
+	})
+}
+
+func TestBucket_Query(t *testing.T) {
+	buckets := []bucket{
+		{"/Country:RU/City:Moscow/Rack:1", []uint32{1, 2}},
+		{"/Country:RU/City:Moscow/Rack:2", []uint32{3}},
+		{"/Country:RU/City:SPB/Rack:1", []uint32{4}},
+		{"/Country:DE/City:Berlin/Rack:1", []uint32{5}},
+	}
+	root, err := newRoot(buckets...)
+	require.NoError(t, err)
+
+	names := func(bs []*Bucket) (r []string) {
+		for _, b := range bs {
+			r = append(r, b.Name())
+		}
+		return
+	}
+
+	require.Equal(t, []*Bucket{&root}, root.Query("/"))
+	require.Equal(t, []string{"City:Moscow"}, names(root.Query("/Country:RU/City:Moscow")))
+	require.Empty(t, root.Query("/Country:RU/City:Berlin"))
+	require.Empty(t, root.Query("/Country"))
+
+	bs := root.Query("/Country:RU/City:*/Rack:1")
+	require.Len(t, bs, 2)
+	require.Equal(t, []uint32{1, 2}, bs[0].Nodelist().Nodes())
+	require.Equal(t, []uint32{4}, bs[1].Nodelist().Nodes())
+
+	require.Len(t, root.Query("/*/City:*/Rack:1"), 3)
+	require.Equal(t, []string{"City:Moscow", "City:SPB"}, names(root.Query("/Country:RU/*:*")))
+
+	require.Equal(t, []uint32{1, 2, 4, 5}, root.GetNodesByOption("/*/*/Rack:1").Nodes())
 }
 
 func TestBucket_AddBucket(t *testing.T) {
@@ -576,6 +741,26 @@ func TestBucket_AddBucket(t *testing.T) {
 	require.Equal(t, root, nroot)
 }
 
+func TestBucket_AddBucketStrict(t *testing.T) {
+	var b Bucket
+
+	require.NoError(t, b.AddBucketStrict("/Location:Europe/Country:Germany", Nodes{{N: 1}, {N: 2}}))
+	require.NoError(t, b.AddBucketStrict("/Location:Europe/Country:Germany", Nodes{{N: 1}}))
+	require.NoError(t, b.AddBucketStrict("/Location:Europe/Country:France", Nodes{{N: 3}}))
+	require.NoError(t, b.AddBucketStrict("/Trust:10", Nodes{{N: 1}}))
+
+	expected := b.Copy()
+	require.Error(t, b.AddBucketStrict("/Location:Europe/Country:France", Nodes{{N: 4}, {N: 2}}))
+	require.Error(t, b.AddBucketStrict("/Location:Asia/Country:Germany", Nodes{{N: 1}}))
+	require.Error(t, b.AddBucketStrict("/Trust:5", Nodes{{N: 1}}))
+	require.Error(t, b.AddBucketStrict("/Trust:5/Trust:6", Nodes{{N: 5}}))
+	require.Error(t, b.AddBucketStrict("Trust:5", Nodes{{N: 5}}))
+	require.Equal(t, expected, b)
+
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:France", Nodes{{N: 2}}))
+	require.Equal(t, []uint32{2, 3}, b.GetNodesByOption("/Location:Europe/Country:France").Nodes())
+}
+
 func TestBucket_AddNode(t *testing.T) {
 	var (
 		nroot Bucket
@@ -596,6 +781,49 @@ func TestBucket_AddNode(t *testing.T) {
 	require.Equal(t, []uint32{1, 3, 7}, ns.Nodes())
 }
 
+func TestBucket_AddNodes(t *testing.T) {
+	es := make([]NodeEntry, 0, 200)
+	for i := uint32(0); i < 200; i++ {
+		es = append(es, NodeEntry{
+			Node: Node{N: 199 - i, C: uint64(i)},
+			Options: []string{
+				fmt.Sprintf("/Location:L%d/Country:C%d/City:C%d", i%3, i%7, i%11),
+				fmt.Sprintf("/Trust:%d", i%5),
+			},
+		})
+	}
+
+	var expected Bucket
+	for _, e := range es {
+		require.NoError(t, expected.AddStrawNode(e.Node, e.Options...))
+	}
+
+	var b Bucket
+	require.NoError(t, b.AddNodes(es))
+	require.Equal(t, expected, b)
+
+	// nodes are merged with existing ones
+	b = Bucket{}
+	require.NoError(t, b.AddNodes(es[:50]))
+	require.NoError(t, b.AddNodes(es[50:]))
+	require.True(t, expected.DeepEqual(b))
+
+	b = Bucket{}
+	require.NoError(t, b.AddNodes([]NodeEntry{{Node: Node{N: 1}, Options: []string{"/", "/Trust:1"}}}))
+	require.Equal(t, []uint32{1}, b.Nodelist().Nodes())
+
+	require.Error(t, b.AddNodes([]NodeEntry{
+		{Node: Node{N: 2}, Options: []string{"/Trust:2"}},
+		{Node: Node{N: 3}, Options: []string{"Trust:3"}},
+	}))
+	require.Equal(t, []uint32{1}, b.Nodelist().Nodes())
+
+	// nothing is added if any option is invalid
+	require.Error(t, b.AddStrawNode(Node{N: 4}, "/Trust:4", "Trust:4"))
+	require.Equal(t, []uint32{1}, b.Nodelist().Nodes())
+	require.Empty(t, b.GetNodesByOption("/Trust:4"))
+}
+
 func TestNetMap_AddNode(t *testing.T) {
 	var (
 		root Bucket
@@ -642,6 +870,435 @@ func TestBucket_MarshalBinary(t *testing.T) {
 	require.Equal(t, before, after)
 }
 
+func TestBucket_MarshalBinaryDepth(t *testing.T) {
+	var (
+		b    Bucket
+		path string
+	)
+
+	for i := 0; i < MaxBucketDepth; i++ {
+		path += fmt.Sprintf("/k%d:v", i)
+	}
+	require.NoError(t, b.AddBucket(path, Nodes{{N: 1}}))
+
+	_, err := b.MarshalBinary()
+	require.Error(t, err)
+
+	// tree with depth exactly MaxBucketDepth
+	b = Bucket{}
+	require.NoError(t, b.AddBucket(path[:strings.LastIndex(path, "/")], Nodes{{N: 1}}))
+	data, err := b.MarshalBinary()
+	require.NoError(t, err)
+
+	var r Bucket
+	require.NoError(t, r.UnmarshalBinary(data))
+	require.Equal(t, b, r)
+
+	// every level has single child
+	level := []byte{0, 0, 0, 1, ':', 0, 0, 0, 0, 0, 0, 0, 1}
+	data = bytes.Repeat(level, MaxBucketDepth+1)
+	require.Error(t, r.UnmarshalBinary(data))
+}
+
+func TestNode_MarshalBinary(t *testing.T) {
+	var (
+		before, after Bucket
+		data          []byte
+		err           error
+	)
+
+	before, err = newStrawRoot(
+		strawBucket{"/Location:Europe", Nodes{{
+			N:         1,
+			C:         2,
+			P:         3,
+			R:         0.75,
+			Coord:     Coord{X: 1.5, Y: -2},
+			PubKey:    []byte{2, 0xAB, 0xCD},
+			Addresses: []string{"/ip4/10.0.0.1/tcp/8080", "/ip4/10.0.0.2/tcp/8080"},
+		}}},
+		strawBucket{"/Location:Asia", Nodes{{N: 2, Addresses: []string{"/dns4/node2/tcp/8080"}, Subnets: []uint32{1, 7}}}},
+	)
+	require.NoError(t, err)
+
+	data, err = before.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, after.UnmarshalBinary(data))
+	require.Equal(t, before, after)
+
+	n := before.children[0].nodes[0]
+	require.True(t, n.Equals(after.children[0].nodes[0]))
+	require.False(t, n.Equals(after.children[1].nodes[0]))
+
+	n1 := n
+	n1.Addresses = n.Addresses[:1]
+	require.False(t, n.Equals(n1))
+}
+
+func TestNode_Attrs(t *testing.T) {
+	before, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{
+			{N: 1, C: 1, Attrs: map[string]string{"SSD": "true", "Tier": "1"}},
+			{N: 2, C: 1},
+		}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 3, C: 1, Attrs: map[string]string{"SSD": "true"}}}},
+		strawBucket{"/Location:Asia/Country:Japan", Nodes{{N: 4, C: 1, Attrs: map[string]string{"SSD": "false"}}}},
+	)
+	require.NoError(t, err)
+
+	for _, v := range []int{FormatV1, FormatV2} {
+		data, err := before.MarshalBinaryVersion(v)
+		require.NoError(t, err)
+
+		var after Bucket
+		require.NoError(t, after.UnmarshalBinary(data))
+		require.Equal(t, before, after)
+	}
+
+	var after Bucket
+	require.NoError(t, after.FromStackItem(before.ToStackItem()))
+	require.Equal(t, before, after)
+
+	d := before.Diff(Bucket{})
+	data, err := d.MarshalBinary()
+	require.NoError(t, err)
+	d1 := new(Delta)
+	require.NoError(t, d1.UnmarshalBinary(data))
+	require.Equal(t, d, d1)
+
+	n := Node{N: 1, Attrs: map[string]string{"SSD": "true"}}
+	require.False(t, n.Equals(Node{N: 1}))
+	require.False(t, n.Equals(Node{N: 1, Attrs: map[string]string{"SSD": "false"}}))
+	require.True(t, n.Equals(Node{N: 1, Attrs: map[string]string{"SSD": "true"}}))
+
+	t.Run("filter", func(t *testing.T) {
+		nodes := before.FindNodes([]byte("pivot"), SFGroup{
+			Selectors: []Select{{Key: NodesBucket, Count: 2}},
+			Filters:   []Filter{{Key: "SSD", F: FilterEQ("true")}},
+		})
+		require.ElementsMatch(t, []uint32{1, 3}, nodes.Nodes())
+
+		nodes = before.FindNodes([]byte("pivot"), SFGroup{
+			Selectors: []Select{{Key: NodesBucket, Count: 1}},
+			Filters: []Filter{
+				{Key: "SSD", F: FilterEQ("true")},
+				{Key: "Country", F: FilterEQ("China")},
+			},
+		})
+		require.Equal(t, []uint32{3}, nodes.Nodes())
+	})
+}
+
+func TestBucket_MarshalBinaryVersion(t *testing.T) {
+	before, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{
+			N:         1,
+			C:         2,
+			P:         3,
+			R:         0.75,
+			Coord:     Coord{X: 1.5, Y: -2},
+			PubKey:    []byte{2, 0xAB, 0xCD},
+			Addresses: []string{"/ip4/10.0.0.1/tcp/8080"},
+		}, {N: 3, C: 10}}},
+		strawBucket{"/Location:Europe/Country:France", Nodes{{N: 4, C: 10}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 2, Subnets: []uint32{1, 7}}}},
+	)
+	require.NoError(t, err)
+
+	v1, err := before.MarshalBinaryVersion(FormatV1)
+	require.NoError(t, err)
+	data, err := before.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, data, v1)
+
+	v2, err := before.MarshalBinaryVersion(FormatV2)
+	require.NoError(t, err)
+	require.True(t, len(v2) < len(v1)/2)
+
+	for _, data := range [][]byte{v1, v2} {
+		var after Bucket
+		require.NoError(t, after.UnmarshalBinary(data))
+		require.Equal(t, before, after)
+
+		// reader without io.ByteReader, trailing data must be left intact
+		var (
+			r    = io.MultiReader(bytes.NewReader(data), strings.NewReader("tail"))
+			tail = make([]byte, 4)
+		)
+		after = Bucket{}
+		require.NoError(t, after.Read(r))
+		require.Equal(t, before, after)
+		_, err = io.ReadFull(r, tail)
+		require.NoError(t, err)
+		require.Equal(t, "tail", string(tail))
+	}
+
+	var after Bucket
+	for _, data := range [][]byte{v1, v2} {
+		for _, l := range []int{2, len(data) / 2, len(data) - 1} {
+			err = after.UnmarshalBinary(data[:l])
+			require.True(t, errors.Is(err, ErrMalformedEncoding), "length %d: %v", l, err)
+		}
+	}
+	require.True(t, errors.Is(after.UnmarshalBinary([]byte{formatMagic}), ErrMalformedEncoding))
+	require.True(t, errors.Is(after.UnmarshalBinary([]byte{formatMagic, 3}), ErrMalformedEncoding))
+
+	_, err = before.MarshalBinaryVersion(3)
+	require.Error(t, err)
+}
+
+// TestBucket_ReadLegacyV1 checks that netmaps written before node options
+// were added to the encoding are still readable.
+func TestBucket_ReadLegacyV1(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "netmap_v1_legacy.bin"))
+	require.NoError(t, err)
+
+	expected, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, C: 10, P: 1}, {N: 2, C: 20, P: 2}}},
+		strawBucket{"/Location:Europe/Country:France", Nodes{{N: 3, C: 30, P: 3}}},
+		strawBucket{"/Location:Asia/Country:Japan", Nodes{{N: 4, C: 40, P: 4}}},
+		strawBucket{"/Tier:Fast", Nodes{{N: 4, C: 40, P: 4}}},
+	)
+	require.NoError(t, err)
+
+	var b Bucket
+	require.NoError(t, b.UnmarshalBinary(data))
+	require.Equal(t, expected, b)
+
+	b = Bucket{}
+	require.NoError(t, b.UnmarshalBinaryStrict(data))
+	require.Equal(t, expected, b)
+
+	// netmap is written in versioned format from now on
+	v1, err := b.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, []byte{formatMagic, FormatV1}, v1[:2])
+	b = Bucket{}
+	require.NoError(t, b.UnmarshalBinary(v1))
+	require.Equal(t, expected, b)
+}
+
+func TestBucket_MarshalBinaryCompressed(t *testing.T) {
+	var before Bucket
+	for i := uint32(1); i <= 100; i++ {
+		path := fmt.Sprintf("/Location:L%d/Country:C%d", i%3, i%10)
+		require.NoError(t, before.AddStrawNode(Node{N: i, C: 10, Addresses: []string{"/ip4/10.0.0.1/tcp/8080"}}, path))
+	}
+
+	plain, err := before.MarshalBinary()
+	require.NoError(t, err)
+	data, err := before.MarshalBinaryCompressed(gzip.BestCompression)
+	require.NoError(t, err)
+	require.True(t, len(data) < len(plain)/10)
+
+	var after Bucket
+	require.NoError(t, after.UnmarshalBinary(data))
+	require.Equal(t, before, after)
+
+	require.Error(t, after.UnmarshalBinary(data[:len(data)/2]))
+
+	_, err = before.MarshalBinaryCompressed(42)
+	require.Error(t, err)
+
+	t.Run("too large", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		zw := gzip.NewWriter(buf)
+		_, err := zw.Write([]byte{formatMagic, FormatV2})
+		require.NoError(t, err)
+		_, err = zw.Write(make([]byte, MaxDecompressedSize))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		err = new(Bucket).UnmarshalBinary(buf.Bytes())
+		require.True(t, errors.Is(err, ErrMalformedEncoding))
+		err = new(Bucket).UnmarshalBinaryStrict(buf.Bytes())
+		require.True(t, errors.Is(err, ErrMalformedEncoding))
+	})
+}
+
+func TestBucket_FindNodesSubnet(t *testing.T) {
+	root, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1, Subnets: []uint32{1}}, {N: 2}}},
+		strawBucket{"/Location:Europe/Country:Spain", Nodes{{N: 3, Subnets: []uint32{1, 2}}, {N: 4, Subnets: []uint32{2}}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 5, Subnets: []uint32{2}}, {N: 6, Subnets: []uint32{1}}}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}
+
+	ns := root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Subnet: 1})
+	require.Len(t, ns, 2)
+	for _, n := range ns {
+		require.Contains(t, []uint32{1, 3, 6}, n.N)
+	}
+
+	ns = root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Subnet: 2})
+	require.Len(t, ns, 2)
+	for _, n := range ns {
+		require.Contains(t, []uint32{3, 4, 5}, n.N)
+	}
+
+	require.Nil(t, root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Subnet: 3}))
+	require.Len(t, root.FindNodes(defaultPivot, SFGroup{Selectors: ss}), 2)
+
+	require.True(t, Node{N: 2}.InSubnet(0))
+	require.False(t, Node{N: 2}.InSubnet(1))
+}
+
+func TestBucket_GetSelectionLocality(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+		bucket{"/Location:Asia/Country:Japan", []uint32{7, 8}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 2}}
+	for i := 0; i < 10; i++ {
+		pivot := []byte{byte(i)}
+		r := root.GetSelection(ss, pivot, WithLocality("/Location:Asia/Country:Japan"))
+		require.NotNil(t, r)
+		require.Equal(t, []uint32{7, 8}, r.Nodelist().Nodes())
+
+		r = root.GetSelection(ss, pivot, WithLocality("/*/Country:Spain"))
+		require.NotNil(t, r)
+		require.Equal(t, []uint32{3, 4}, r.Nodelist().Nodes())
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		ss := []Select{{Key: "Country", Count: 3}, {Key: NodesBucket, Count: 1}}
+		for i := 0; i < 10; i++ {
+			ns := root.FindNodesWith([]byte{byte(i)}, []SFGroup{{Selectors: ss}}, WithLocality("/Location:Europe"))
+			require.Len(t, ns, 3)
+
+			var europe int
+			for _, n := range ns {
+				if n.N <= 4 {
+					europe++
+				}
+			}
+			require.Equal(t, 2, europe)
+		}
+	})
+
+	t.Run("same names", func(t *testing.T) {
+		root, err := newRoot(
+			bucket{"/City:Moscow/Rack:1", []uint32{1, 2}},
+			bucket{"/City:Moscow/Rack:2", []uint32{3, 4}},
+			bucket{"/City:Berlin/Rack:1", []uint32{5, 6}},
+			bucket{"/City:Berlin/Rack:2", []uint32{7, 8}},
+		)
+		require.NoError(t, err)
+
+		ss := []Select{{Key: "Rack", Count: 1}, {Key: NodesBucket, Count: 1}}
+		for i := 0; i < 20; i++ {
+			r := root.GetSelection(ss, []byte{byte(i)}, WithLocality("/City:Berlin"))
+			require.NotNil(t, r)
+			require.Len(t, r.Nodelist(), 1)
+			require.True(t, r.Nodelist()[0].N >= 5, "pivot %d", i)
+		}
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		ss := []Select{{Key: NodesBucket, Count: 3}}
+		r := root.GetSelection(ss, defaultPivot, WithLocality("/Location:Asia/Country:China"))
+		require.NotNil(t, r)
+		require.Contains(t, r.Nodelist().Nodes(), uint32(5))
+		require.Contains(t, r.Nodelist().Nodes(), uint32(6))
+	})
+
+	t.Run("unknown locality", func(t *testing.T) {
+		require.Equal(t,
+			root.GetSelection(ss, defaultPivot),
+			root.GetSelection(ss, defaultPivot, WithLocality("/Location:Africa")))
+	})
+}
+
+func TestBucket_GetSelectionQuota(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Country:DE/Rack:1", []uint32{1, 2, 3}},
+		bucket{"/Country:DE/Rack:2", []uint32{4}},
+		bucket{"/Country:FR/Rack:3", []uint32{5, 6, 7}},
+		bucket{"/DC:A", []uint32{1, 4, 5, 6, 7}},
+		bucket{"/DC:B", []uint32{2, 3}},
+	)
+	require.NoError(t, err)
+
+	racks := root.nodeValues("Rack")
+	checkQuota := func(t *testing.T, ns Nodes, max int) {
+		count := make(map[string]int)
+		for _, n := range ns {
+			count[racks[n.N]]++
+			require.True(t, count[racks[n.N]] <= max)
+		}
+	}
+
+	t.Run("nodes", func(t *testing.T) {
+		ss := []Select{{Key: NodesBucket, Count: 5}}
+		for i := 0; i < 10; i++ {
+			r := root.GetSelection(ss, []byte{byte(i)}, WithQuota("Rack", 2))
+			require.NotNil(t, r)
+			require.Len(t, r.Nodelist(), 5)
+			checkQuota(t, r.Nodelist(), 2)
+		}
+
+		ss = []Select{{Key: NodesBucket, Count: 6}}
+		require.Nil(t, root.GetSelection(ss, defaultPivot, WithQuota("Rack", 2)))
+		require.NotNil(t, root.GetSelection(ss, defaultPivot))
+	})
+
+	t.Run("other level", func(t *testing.T) {
+		ss := []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 2}}
+		require.Nil(t, root.GetSelection(ss, defaultPivot, WithQuota("Rack", 1)))
+
+		ss = []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 2}}
+		for i := 0; i < 10; i++ {
+			r := root.GetSelection(ss, []byte{byte(i)}, WithQuota("Rack", 1))
+			require.NotNil(t, r)
+			require.Len(t, r.Nodelist(), 2)
+			require.Contains(t, r.Nodelist().Nodes(), uint32(4))
+		}
+	})
+
+	t.Run("released on failure", func(t *testing.T) {
+		// FR has single rack, so its nodes must not be accounted
+		// in DC quota after it is rejected
+		ss := []Select{{Key: "Country", Count: 1}, {Key: "Rack", Count: 2}, {Key: NodesBucket, Count: 1}}
+		for i := 0; i < 10; i++ {
+			r := root.GetSelection(ss, []byte{byte(i)}, WithQuota("DC", 2))
+			require.NotNil(t, r)
+			require.Len(t, r.Nodelist(), 2)
+		}
+	})
+
+	t.Run("whole bucket", func(t *testing.T) {
+		// nodes of the chosen bucket are taken without explicit count
+		ss := []Select{{Key: "Rack", Count: 1}}
+		for i := 0; i < 10; i++ {
+			r := root.GetSelection(ss, []byte{byte(i)}, WithQuota("Rack", 2))
+			require.NotNil(t, r)
+			require.NotEmpty(t, r.Nodelist())
+			checkQuota(t, r.Nodelist(), 2)
+		}
+
+		ss = []Select{{Key: "Rack", Count: 3}}
+		r := root.GetSelection(ss, defaultPivot, WithQuota("Rack", 2))
+		require.NotNil(t, r)
+		require.Len(t, r.Nodelist(), 5)
+		checkQuota(t, r.Nodelist(), 2)
+	})
+
+	t.Run("find nodes", func(t *testing.T) {
+		g := []SFGroup{{Selectors: []Select{{Key: NodesBucket, Count: 4}}}}
+		for i := 0; i < 10; i++ {
+			ns := root.FindNodesWith([]byte{byte(i)}, append(g, g...), WithQuota("Rack", 2))
+			require.Len(t, ns, 4)
+			checkQuota(t, ns, 2)
+		}
+	})
+}
+
 func TestBucket_Nodelist(t *testing.T) {
 	var (
 		nodes   Nodes
@@ -668,14 +1325,209 @@ func TestBucket_Nodelist(t *testing.T) {
 	root, err = newRoot(buckets...)
 	require.NoError(t, err)
 
-	root.nodes = nil
+	root.nodes = nil
+
+	root.fillNodes()
+	nodes = root.Nodelist()
+	require.Len(t, nodes, 24)
+	for i := uint32(1); i <= 24; i++ {
+		require.Contains(t, nodes.Nodes(), i)
+	}
+}
+
+func TestBucket_Snapshot(t *testing.T) {
+	b, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3}},
+		bucket{"/Location:Asia/Country:China", []uint32{4}},
+	)
+	require.NoError(t, err)
+
+	var (
+		expected = b.Copy()
+		s        = b.Snapshot()
+	)
+
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:Germany", Nodes{{N: 5}}))
+	require.NoError(t, b.AddBucket("/Location:America/Country:USA", Nodes{{N: 6}}))
+	require.NoError(t, b.AddNodes([]NodeEntry{{Node: Node{N: 7}, Options: []string{"/Location:Asia/Country:Japan"}}}))
+	b.Merge(Bucket{children: []Bucket{{Key: "Location", Value: "Europe", nodes: Nodes{{N: 8}}}}})
+	require.NoError(t, b.ApplyDelta(&Delta{Removed: []DeltaEntry{{Path: "/Location:Europe/Country:Spain", Nodes: Nodes{{N: 3}}}}}))
+	b.TraverseTree(AggregatorFactory{New: NewMeanAgg}, DefaultWeightFunc(b.Nodelist()))
+	b.Canonicalize()
+	b.Compact()
+	b.Prune()
+
+	require.Equal(t, expected, s)
+	require.NotEqual(t, expected.Nodelist(), b.Nodelist())
+
+	// modifications of snapshot don't affect original too
+	s1 := s.Snapshot()
+	require.NoError(t, s1.AddBucket("/Location:Europe/Country:France", Nodes{{N: 9}}))
+	require.Equal(t, expected, s)
+	require.Len(t, s1.GetNodesByOption("/Location:Europe"), 4)
+}
+
+func TestBucket_Iterate(t *testing.T) {
+	buckets := []bucket{
+		{"/Location:Asia/Country:Korea", []uint32{1, 3}},
+		{"/Location:Europe/Country:Germany/City:Berlin", []uint32{9, 10}},
+		{"/Location:Europe/Country:France", []uint32{6}},
+	}
+	root, err := newRoot(buckets...)
+	require.NoError(t, err)
+
+	paths := make(map[uint32]string)
+	root.Iterate(func(path []string, n uint32) bool {
+		paths[n] = "/" + strings.Join(path, "/")
+		return true
+	})
+	require.Equal(t, map[uint32]string{
+		1:  "/Location:Asia/Country:Korea",
+		3:  "/Location:Asia/Country:Korea",
+		6:  "/Location:Europe/Country:France",
+		9:  "/Location:Europe/Country:Germany/City:Berlin",
+		10: "/Location:Europe/Country:Germany/City:Berlin",
+	}, paths)
+
+	// nodes attached to inner buckets are visited too
+	require.NoError(t, root.AddBucket("/Location:Europe", Nodes{{N: 11}}))
+	var visited []uint32
+	root.Iterate(func(path []string, n uint32) bool {
+		if n == 11 {
+			require.Equal(t, []string{"Location:Europe"}, path)
+		}
+		visited = append(visited, n)
+		return true
+	})
+	require.ElementsMatch(t, []uint32{1, 3, 6, 9, 10, 11}, visited)
+	require.Equal(t, [][]string{{"Location:Europe"}}, root.PathsOf(11))
+
+	var count int
+	root.Iterate(func([]string, uint32) bool {
+		count++
+		return count < 2
+	})
+	require.Equal(t, 2, count)
+}
+
+func TestBucket_PathsOf(t *testing.T) {
+	buckets := []bucket{
+		{"/Location:Asia/Country:Korea", []uint32{1, 3}},
+		{"/Location:Europe/Country:Germany/City:Berlin", []uint32{3, 10}},
+		{"/Location:Europe/Country:France", []uint32{6}},
+		{"/Rack:1", []uint32{3}},
+	}
+	root, err := newRoot(buckets...)
+	require.NoError(t, err)
+
+	require.Equal(t, [][]string{
+		{"Location:Asia", "Country:Korea"},
+		{"Location:Europe", "Country:Germany", "City:Berlin"},
+		{"Rack:1"},
+	}, root.PathsOf(3))
+	require.Equal(t, [][]string{{"Location:Europe", "Country:France"}}, root.PathsOf(6))
+	require.Empty(t, root.PathsOf(42))
+}
+
+func TestBucket_Walk(t *testing.T) {
+	buckets := []bucket{
+		{"/Location:Asia/Country:Korea", []uint32{1, 3}},
+		{"/Location:Europe/Country:Germany/City:Berlin", []uint32{9, 10}},
+	}
+	root, err := newRoot(buckets...)
+	require.NoError(t, err)
+
+	var pre, post []string
+	err = root.Walk(func(b *Bucket) error {
+		pre = append(pre, b.Value)
+		return nil
+	}, func(b *Bucket) error {
+		post = append(post, b.Value)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"", "Asia", "Korea", "Europe", "Germany", "Berlin"}, pre)
+	require.Equal(t, []string{"Korea", "Asia", "Berlin", "Germany", "Europe", ""}, post)
+
+	var depth, maxDepth int
+	err = root.Walk(func(*Bucket) error {
+		if depth++; depth > maxDepth {
+			maxDepth = depth
+		}
+		return nil
+	}, func(*Bucket) error {
+		depth--
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, maxDepth)
+
+	stop := errors.New("stop")
+	pre = pre[:0]
+	err = root.Walk(func(b *Bucket) error {
+		if b.Key == "Country" {
+			return stop
+		}
+		pre = append(pre, b.Value)
+		return nil
+	}, nil)
+	require.Equal(t, stop, err)
+	require.Equal(t, []string{"", "Asia"}, pre)
+}
+
+func TestBucket_String(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3}},
+		bucket{"/Location:Asia/Country:China", []uint32{4}},
+		bucket{"/Location:Europe", []uint32{5}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, `/ (5)
+  Location:Europe (4) [5]
+    Country:Germany (2) [1 2]
+    Country:Spain (1) [3]
+  Location:Asia (1)
+    Country:China (1) [4]
+`, root.String())
+	require.Equal(t, "/ (0)\n", Bucket{}.String())
+}
+
+func TestBucket_DiffString(t *testing.T) {
+	old, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1}, {N: 2}}},
+		strawBucket{"/Location:Europe/Country:Spain", Nodes{{N: 3}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 4}, {N: 5}}},
+	)
+	require.NoError(t, err)
+	require.Empty(t, old.DiffString(old.Copy()))
 
-	root.fillNodes()
-	nodes = root.Nodelist()
-	require.Len(t, nodes, 24)
-	for i := uint32(1); i <= 24; i++ {
-		require.Contains(t, nodes.Nodes(), i)
-	}
+	cur, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 1}, {N: 6}}},
+		strawBucket{"/Location:Asia/Country:China", Nodes{{N: 4, C: 10}, {N: 5}}},
+		strawBucket{"/Location:Asia/Country:Japan", Nodes{{N: 7}}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, `  /
+    Location:Europe
+      Country:Germany
+-       node 2
++       node 6
+-     Country:Spain (1) [3]
+    Location:Asia
+      Country:China
+~       node 4
++     Country:Japan (1) [7]
+`, old.DiffString(cur))
+
+	// nodes attached to inner buckets are compared too
+	inner := cur.Copy()
+	require.NoError(t, inner.AddBucket("/Location:Asia", Nodes{{N: 8}}))
+	require.Equal(t, `  /
+    Location:Asia
++     node 8
+`, cur.DiffString(inner))
 }
 
 func TestNetMap_FindGraph(t *testing.T) {
@@ -748,6 +1600,7 @@ func TestNetMap_FindGraph(t *testing.T) {
 
 	exp, err = newRoot(bucket{"/Location:Europe/Country:Russia/City:Moscow", []uint32{13, 14}})
 	require.NoError(t, err)
+	exp.SortChildren()
 
 	c = root.FindGraph(nil, SFGroup{Selectors: ss, Filters: fs})
 	require.NotNil(t, c)
@@ -759,6 +1612,7 @@ func TestNetMap_FindGraph(t *testing.T) {
 	}
 	exp, err = newRoot(buckets...)
 	require.NoError(t, err)
+	exp.SortChildren()
 
 	// check if Select.Count works
 	ss = []Select{
@@ -782,6 +1636,7 @@ func TestNetMap_FindGraph(t *testing.T) {
 	}
 	exp, err = newRoot(buckets...)
 	require.NoError(t, err)
+	exp.SortChildren()
 
 	// check with NotIn filter
 	ss = []Select{
@@ -835,6 +1690,7 @@ func TestNetMap_FindGraph(t *testing.T) {
 	}
 	exp, err = newRoot(buckets...)
 	require.NoError(t, err)
+	exp.SortChildren()
 
 	// multiple selectors
 	c = root.FindGraph(nil,
@@ -854,6 +1710,47 @@ func TestNetMap_FindGraph(t *testing.T) {
 	require.Equal(t, &exp, c)
 }
 
+func TestBucket_FindGraphStrict(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+	)
+	require.NoError(t, err)
+
+	europe := SFGroup{
+		Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 2}},
+		Filters:   []Filter{{Key: "Location", F: FilterEQ("Europe")}},
+	}
+	asia := SFGroup{
+		Selectors: []Select{{Key: NodesBucket, Count: 2}},
+		Filters:   []Filter{{Key: "Location", F: FilterEQ("Asia")}},
+	}
+
+	g, err := root.FindGraphStrict(defaultPivot, europe, asia)
+	require.NoError(t, err)
+	require.Equal(t, root.FindGraph(defaultPivot, europe, asia), g)
+	require.Len(t, g.Nodelist(), 6)
+
+	spain := SFGroup{
+		Selectors: []Select{{Key: NodesBucket, Count: 1}},
+		Filters:   []Filter{{Key: "Country", F: FilterEQ("Spain")}},
+	}
+	_, err = root.FindGraphStrict(defaultPivot, asia, europe, spain)
+	require.Error(t, err)
+
+	oe, ok := err.(*OverlapError)
+	require.True(t, ok)
+	require.Equal(t, 1, oe.First)
+	require.Equal(t, 2, oe.Second)
+	require.Len(t, oe.Nodes, 1)
+	require.Contains(t, []uint32{3, 4}, oe.Nodes[0])
+	require.NotNil(t, root.FindGraph(defaultPivot, asia, europe, spain))
+
+	_, err = root.FindGraphStrict(defaultPivot, SFGroup{Selectors: []Select{{Key: NodesBucket, Count: 10}}})
+	require.Error(t, err)
+}
+
 func TestBucket_FindNodes(t *testing.T) {
 	var (
 		ns         Nodes
@@ -1291,3 +2188,610 @@ func TestBucket_ShuffledSelection(t *testing.T) {
 
 	require.Equal(t, r.nodes, expr.nodes)
 }
+
+func TestBucket_GetCapacitySelection(t *testing.T) {
+	var (
+		root    Bucket
+		r       *Bucket
+		err     error
+		ss      []Select
+		buckets []strawBucket
+	)
+
+	buckets = []strawBucket{
+		{"/Location:Asia/Country:Korea", Nodes{{N: 1, C: 1}, {N: 3, C: 3}}},
+		{"/Location:Asia/Country:China", Nodes{{N: 2, C: 1}}},
+		{"/Location:Europe/Country:Germany", Nodes{{N: 4, C: 8}, {N: 5, C: 2}}},
+		{"/Location:Europe/Country:Spain", Nodes{{N: 6, C: 2}, {N: 7, C: 1}}},
+	}
+	root, err = newStrawRoot(buckets...)
+	require.NoError(t, err)
+
+	capacity := func(b *Bucket) (c uint64) {
+		for _, n := range b.Nodelist() {
+			c += n.C
+		}
+		return
+	}
+
+	t.Run("nodes only", func(t *testing.T) {
+		ss = []Select{{Key: NodesBucket, Count: 1}}
+		r = root.GetCapacitySelection(ss, defaultPivot, 12)
+		require.NotNil(t, r)
+		require.True(t, capacity(r) >= 12)
+
+		r = root.GetCapacitySelection(ss, defaultPivot, 19)
+		require.Nil(t, r)
+	})
+
+	t.Run("count is a minimum", func(t *testing.T) {
+		ss = []Select{{Key: NodesBucket, Count: 7}}
+		r = root.GetCapacitySelection(ss, defaultPivot, 1)
+		require.NotNil(t, r)
+		require.Len(t, r.Nodelist(), 7)
+	})
+
+	t.Run("capacity is divided between buckets", func(t *testing.T) {
+		ss = []Select{
+			{Key: "Location", Count: 2},
+			{Key: NodesBucket, Count: 1},
+		}
+		r = root.GetCapacitySelection(ss, defaultPivot, 8)
+		require.NotNil(t, r)
+		for _, c := range r.Children() {
+			require.True(t, capacity(&c) >= 4)
+		}
+
+		r = root.GetCapacitySelection(ss, defaultPivot, 12)
+		require.Nil(t, r)
+	})
+
+	t.Run("zero capacity", func(t *testing.T) {
+		ss = []Select{{Key: "Country", Count: 2}}
+		require.Equal(t, root.GetSelection(ss, defaultPivot), root.GetCapacitySelection(ss, defaultPivot, 0))
+	})
+
+	t.Run("policy capacity", func(t *testing.T) {
+		require.Equal(t, uint64(0), Policy{Size: 10}.Capacity())
+		require.Equal(t, uint64(30), Policy{Size: 10, ReplFactor: 3}.Capacity())
+		require.Equal(t, uint64(math.MaxInt64)*2, Policy{Size: math.MaxInt64, ReplFactor: 2}.Capacity())
+		require.Equal(t, uint64(math.MaxUint64), Policy{Size: math.MaxInt64, ReplFactor: 3}.Capacity())
+	})
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	var (
+		root  Bucket
+		nodes []uint32
+		err   error
+	)
+
+	root, err = newStrawRoot(
+		strawBucket{"/Location:Asia/Country:Korea", Nodes{{N: 1, C: 1}, {N: 3, C: 3}}},
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{N: 4, C: 8}, {N: 5, C: 2}}},
+	)
+	require.NoError(t, err)
+
+	t.Run("invalid policy", func(t *testing.T) {
+		_, err = Policy{Size: -1, ReplFactor: 1}.Apply(&root, defaultPivot)
+		require.Error(t, err)
+
+		_, err = Policy{Size: 1}.Apply(&root, defaultPivot)
+		require.Error(t, err)
+	})
+
+	t.Run("replication factor as node count", func(t *testing.T) {
+		nodes, err = Policy{ReplFactor: 3}.Apply(&root, defaultPivot)
+		require.NoError(t, err)
+		require.Len(t, nodes, 3)
+	})
+
+	t.Run("node count", func(t *testing.T) {
+		nodes, err = Policy{ReplFactor: 1, NodeCount: 4}.Apply(&root, defaultPivot)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []uint32{1, 3, 4, 5}, nodes)
+
+		_, err = Policy{ReplFactor: 1, NodeCount: 5}.Apply(&root, defaultPivot)
+		require.Error(t, err)
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		nodes, err = Policy{Size: 7, ReplFactor: 2}.Apply(&root, defaultPivot)
+		require.NoError(t, err)
+
+		var c uint64
+		for _, n := range root.Nodelist() {
+			for i := range nodes {
+				if n.N == nodes[i] {
+					c += n.C
+				}
+			}
+		}
+		require.True(t, c >= 14)
+
+		_, err = Policy{Size: 8, ReplFactor: 2}.Apply(&root, defaultPivot)
+		require.Error(t, err)
+	})
+}
+
+func TestBucket_GetSelectionDistinct(t *testing.T) {
+	var (
+		root    Bucket
+		r       *Bucket
+		ns      Nodes
+		err     error
+		ss      []Select
+		buckets []bucket
+	)
+
+	buckets = []bucket{
+		{"/Location:Europe/Country:Germany", []uint32{1, 2, 3, 4}},
+		{"/Location:Europe/Country:Spain", []uint32{5, 6}},
+		{"/Location:Asia/Country:China", []uint32{7, 8}},
+		{"/DC:1", []uint32{1, 2, 3, 4, 5}},
+		{"/DC:2", []uint32{6}},
+		{"/DC:3", []uint32{7, 8}},
+	}
+	root, err = newRoot(buckets...)
+	require.NoError(t, err)
+
+	dcs := root.nodeValues("DC")
+
+	t.Run("distinct nodes", func(t *testing.T) {
+		ss = []Select{{Key: NodesBucket, Count: 3, Distinct: "DC"}}
+		for i := 0; i < 10; i++ {
+			r = root.GetSelection(ss, []byte{byte(i)})
+			require.NotNil(t, r)
+			require.Len(t, r.nodes, 3)
+
+			seen := make(map[string]bool)
+			for _, n := range r.nodes {
+				require.False(t, seen[dcs[n.N]])
+				seen[dcs[n.N]] = true
+			}
+		}
+
+		ss = []Select{{Key: NodesBucket, Count: 4, Distinct: "DC"}}
+		require.Nil(t, root.GetSelection(ss, defaultPivot))
+	})
+
+	t.Run("distinct buckets", func(t *testing.T) {
+		// Germany and Spain share DC 1, thus can't be chosen together
+		ss = []Select{{Key: "Country", Count: 2, Distinct: "DC"}}
+		for i := 0; i < 10; i++ {
+			r = root.GetSelection(ss, []byte{byte(i)})
+			require.NotNil(t, r)
+
+			ns = r.Nodelist()
+			require.Contains(t, ns.Nodes(), uint32(7))
+			require.False(t, contains(ns, Node{N: 1}) && contains(ns, Node{N: 5}))
+		}
+
+		ss = []Select{{Key: "Country", Count: 3, Distinct: "DC"}}
+		require.Nil(t, root.GetSelection(ss, defaultPivot))
+	})
+
+	t.Run("find nodes", func(t *testing.T) {
+		ss = []Select{
+			{Key: "Location", Count: 1},
+			{Key: NodesBucket, Count: 2, Distinct: "DC"},
+		}
+		fs := []Filter{{Key: "Location", F: FilterEQ("Europe")}}
+		ns = root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Filters: fs})
+		require.Len(t, ns, 2)
+		require.NotEqual(t, dcs[ns[0].N], dcs[ns[1].N])
+
+		ss[1].Count = 3
+		ns = root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Filters: fs})
+		require.Empty(t, ns)
+	})
+}
+
+func TestBucket_GetSelectionSame(t *testing.T) {
+	var (
+		root    Bucket
+		r       *Bucket
+		err     error
+		ss      []Select
+		buckets []bucket
+	)
+
+	buckets = []bucket{
+		{"/Location:Europe/Country:Germany/City:Berlin", []uint32{1, 2}},
+		{"/Location:Europe/Country:Germany/City:Hamburg", []uint32{3}},
+		{"/Location:Europe/Country:Spain/City:Madrid", []uint32{4, 5, 6}},
+		{"/Location:Asia/Country:China/City:Beijing", []uint32{7, 8}},
+		{"/Rack:1", []uint32{1, 4, 7}},
+		{"/Rack:2", []uint32{2, 5, 8}},
+		{"/Rack:3", []uint32{3, 6}},
+	}
+	root, err = newRoot(buckets...)
+	require.NoError(t, err)
+
+	cities := root.nodeValues("City")
+
+	t.Run("same city", func(t *testing.T) {
+		ss = []Select{{Key: NodesBucket, Count: 2, Same: "City"}}
+		chosen := make(map[string]bool)
+		for i := 0; i < 20; i++ {
+			r = root.GetSelection(ss, []byte{byte(i)})
+			require.NotNil(t, r)
+			require.Len(t, r.nodes, 2)
+			require.Equal(t, cities[r.nodes[0].N], cities[r.nodes[1].N])
+			chosen[cities[r.nodes[0].N]] = true
+		}
+		// Hamburg has only one node
+		require.False(t, chosen["Hamburg"])
+		require.True(t, len(chosen) > 1)
+
+		ss = []Select{{Key: NodesBucket, Count: 3, Same: "City"}}
+		r = root.GetSelection(ss, defaultPivot)
+		require.NotNil(t, r)
+		require.ElementsMatch(t, []uint32{4, 5, 6}, r.Nodelist().Nodes())
+
+		ss = []Select{{Key: NodesBucket, Count: 4, Same: "City"}}
+		require.Nil(t, root.GetSelection(ss, defaultPivot))
+	})
+
+	t.Run("same with nested selects", func(t *testing.T) {
+		ss = []Select{
+			{Key: "Location", Count: 1, Same: "Rack"},
+			{Key: "Country", Count: 2},
+			{Key: NodesBucket, Count: 1},
+		}
+		for i := 0; i < 10; i++ {
+			r = root.GetSelection(ss, []byte{byte(i)})
+			require.NotNil(t, r)
+
+			ns := r.Nodelist()
+			require.Len(t, ns, 2)
+			racks := root.nodeValues("Rack")
+			require.Equal(t, racks[ns[0].N], racks[ns[1].N])
+		}
+	})
+
+	t.Run("same and distinct", func(t *testing.T) {
+		ss = []Select{{Key: NodesBucket, Count: 2, Same: "Country", Distinct: "Rack"}}
+		r = root.GetSelection(ss, defaultPivot)
+		require.NotNil(t, r)
+		countries := root.nodeValues("Country")
+		racks := root.nodeValues("Rack")
+		require.Equal(t, countries[r.nodes[0].N], countries[r.nodes[1].N])
+		require.NotEqual(t, racks[r.nodes[0].N], racks[r.nodes[1].N])
+	})
+}
+
+func TestBucket_FindNodesRange(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Price:1", []uint32{1, 2}},
+		bucket{"/Location:Europe/Price:5", []uint32{3}},
+		bucket{"/Location:Asia/Price:7", []uint32{4, 5}},
+		bucket{"/Location:Asia/Price:12", []uint32{6}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: NodesBucket, Count: 3}}
+	fs := []Filter{{Key: "Price", F: FilterRange(2, 10)}}
+	ns := root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Filters: fs})
+	require.ElementsMatch(t, []uint32{3, 4, 5}, ns.Nodes())
+
+	ss[0].Count = 4
+	ns = root.FindNodes(defaultPivot, SFGroup{Selectors: ss, Filters: fs})
+	require.Empty(t, ns)
+}
+
+func BenchmarkBucket_AddNodes(b *testing.B) {
+	es := make([]NodeEntry, 0, 5000)
+	for i := uint32(0); i < 5000; i++ {
+		es = append(es, NodeEntry{
+			Node:    Node{N: i},
+			Options: []string{fmt.Sprintf("/Location:L%d/Country:C%d/City:C%d", i%5, i%50, i%500)},
+		})
+	}
+
+	b.Run("AddNodes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var root Bucket
+			if err := root.AddNodes(es); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("AddStrawNode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var root Bucket
+			for _, e := range es {
+				if err := root.AddStrawNode(e.Node, e.Options...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+// countingContext is canceled after Err is called n times.
+type countingContext struct {
+	context.Context
+	n int
+}
+
+func (c *countingContext) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestBucket_FindNodesCtx(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+	)
+	require.NoError(t, err)
+
+	ss := []SFGroup{{Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}}}
+
+	nodes, err := root.FindNodesCtx(context.Background(), defaultPivot, ss)
+	require.NoError(t, err)
+	require.Equal(t, root.FindNodes(defaultPivot, ss...), nodes)
+
+	g, err := root.FindGraphCtx(context.Background(), defaultPivot, ss)
+	require.NoError(t, err)
+	require.Equal(t, root.FindGraph(defaultPivot, ss...), g)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = root.FindNodesCtx(ctx, defaultPivot, ss)
+	require.Equal(t, context.Canceled, err)
+	_, err = root.FindGraphCtx(ctx, defaultPivot, ss)
+	require.Equal(t, context.Canceled, err)
+
+	// canceled in the middle of selection
+	_, err = root.FindNodesCtx(&countingContext{Context: context.Background(), n: 3}, defaultPivot, ss)
+	require.Equal(t, context.Canceled, err)
+
+	ss[0].Selectors[0].Count = 4
+	g, err = root.FindGraphCtx(context.Background(), defaultPivot, ss)
+	require.True(t, errors.Is(err, ErrNotEnoughNodes))
+	require.Nil(t, g)
+}
+
+func TestBucket_UnmarshalBinaryStrict(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	for _, version := range []int{FormatV1, FormatV2} {
+		data, err := root.MarshalBinaryVersion(version)
+		require.NoError(t, err)
+
+		var b Bucket
+		require.NoError(t, b.UnmarshalBinaryStrict(data))
+		require.Equal(t, root, b)
+
+		err = b.UnmarshalBinaryStrict(data[:len(data)-1])
+		require.True(t, errors.Is(err, ErrMalformedEncoding))
+		require.Equal(t, int64(len(data)-1), err.(*DecodeError).Offset)
+
+		err = b.UnmarshalBinaryStrict(append(data, 0, 0))
+		require.True(t, errors.Is(err, ErrMalformedEncoding))
+		require.Equal(t, int64(len(data)), err.(*DecodeError).Offset)
+	}
+
+	data, err := root.MarshalBinaryCompressed(gzip.BestSpeed)
+	require.NoError(t, err)
+
+	var b Bucket
+	require.NoError(t, b.UnmarshalBinaryStrict(data))
+	require.Equal(t, root, b)
+
+	err = b.UnmarshalBinaryStrict(append(data, 1))
+	require.Equal(t, int64(len(data)), err.(*DecodeError).Offset)
+
+	t.Run("unsorted nodes", func(t *testing.T) {
+		b := Bucket{Key: "Country", Value: "Germany", nodes: Nodes{{N: 2}, {N: 1}}}
+		data, err := b.MarshalBinary()
+		require.NoError(t, err)
+
+		// the second node follows magic, version, name, its length and node list length
+		offset := int64(2 + 4 + len(b.Name()) + 4 + nodeHeaderSize)
+		err = new(Bucket).UnmarshalBinaryStrict(data)
+		require.True(t, errors.Is(err, ErrMalformedEncoding))
+		require.Equal(t, offset, err.(*DecodeError).Offset)
+
+		require.NoError(t, new(Bucket).UnmarshalBinary(data))
+	})
+
+	t.Run("duplicate nodes", func(t *testing.T) {
+		b := Bucket{Key: "Country", Value: "Germany", nodes: Nodes{{N: 1}, {N: 1}}}
+		data, err := b.MarshalBinaryVersion(FormatV2)
+		require.NoError(t, err)
+
+		// magic, version, key and value strings, node list length,
+		// the first node of 10 bytes including its reference
+		offset := int64(2 + 2 + len(b.Key) + 2 + len(b.Value) + 1 + 10)
+		err = new(Bucket).UnmarshalBinaryStrict(data)
+		require.True(t, errors.Is(err, ErrMalformedEncoding))
+		require.Equal(t, offset, err.(*DecodeError).Offset)
+	})
+
+	t.Run("node references", func(t *testing.T) {
+		var b Bucket
+		n := Node{
+			N:         1,
+			PubKey:    []byte{1, 2, 3},
+			Addresses: []string{"/ip4/1.2.3.4/tcp/8080"},
+			Subnets:   []uint32{7},
+			Attrs:     map[string]string{"SSD": "true"},
+		}
+		require.NoError(t, b.AddStrawNode(n, "/Country:Germany/City:Berlin"))
+		data, err := b.MarshalBinaryVersion(FormatV2)
+		require.NoError(t, err)
+
+		var r Bucket
+		require.NoError(t, r.UnmarshalBinary(data))
+		c := r.children[0].children[0].nodes[0]
+		c.PubKey[0], c.Addresses[0], c.Subnets[0], c.Attrs["SSD"] = 0, "", 0, "false"
+		require.True(t, n.Equals(r.nodes[0]))
+		require.True(t, n.Equals(r.children[0].nodes[0]))
+	})
+}
+
+func TestBucket_ReadHugeLengths(t *testing.T) {
+	const maxAlloc = 1 << 20
+
+	cases := map[string][]byte{
+		"name":     {0x7F, 0xFF, 0xFF, 0xFF, 'a'},
+		"nodes":    {0, 0, 0, 0, 0x7F, 0xFF, 0xFF, 0xFF},
+		"children": {0, 0, 0, 0, 0, 0, 0, 0, 0x7F, 0xFF, 0xFF, 0xFF},
+		"v2 key":   {formatMagic, FormatV2, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0x07},
+		"v2 nodes": {formatMagic, FormatV2, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0x07},
+	}
+	for name, data := range cases {
+		t.Run(name, func(t *testing.T) {
+			var before, after runtime.MemStats
+
+			runtime.ReadMemStats(&before)
+			_ = new(Bucket).UnmarshalBinary(data)
+			runtime.ReadMemStats(&after)
+
+			require.True(t, after.TotalAlloc-before.TotalAlloc < maxAlloc)
+		})
+	}
+}
+
+func TestBucket_PlaceMany(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany/City:Berlin", []uint32{1, 2, 3}},
+		bucket{"/Location:Europe/Country:Spain/City:Madrid", []uint32{4, 5}},
+		bucket{"/Location:Asia/Country:China/City:Beijing", []uint32{6, 7, 8}},
+		bucket{"/Location:Asia/Country:Korea/City:Seoul", []uint32{9}},
+	)
+	require.NoError(t, err)
+
+	ss := []SFGroup{
+		{
+			Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}},
+			Filters:   []Filter{{Key: "Location", F: FilterEQ("Europe")}},
+		},
+		{Selectors: []Select{{Key: "City", Count: 1}, {Key: NodesBucket, Count: 2}}},
+		{Selectors: []Select{{Key: "Country", Count: 5}}},
+	}
+
+	pivots := make([][]byte, 20)
+	for i := range pivots {
+		pivots[i] = []byte{byte(i)}
+	}
+	pivots = append(pivots, nil)
+
+	rs := root.PlaceMany(pivots, ss)
+	require.Len(t, rs, len(pivots))
+	for i := range pivots {
+		require.Equal(t, root.FindNodes(pivots[i], ss...), rs[i])
+	}
+
+	t.Run("options", func(t *testing.T) {
+		opts := []SelectOption{
+			WithStrategy(StrategyUniform),
+			WithLocality("/Location:Asia"),
+			WithQuota("Location", 3),
+			WithBacktracking(100),
+		}
+		rs := root.PlaceMany(pivots, ss, opts...)
+		require.Len(t, rs, len(pivots))
+		for i := range pivots {
+			require.Equal(t, root.FindNodesWith(pivots[i], ss, opts...), rs[i])
+		}
+	})
+}
+
+func TestBucket_PercentSelect(t *testing.T) {
+	var bs []bucket
+	for i := uint32(0); i < 10; i++ {
+		bs = append(bs, bucket{"/Country:Germany/City:C" + string(rune('A'+i)), []uint32{2 * i, 2*i + 1}})
+	}
+	for i := uint32(0); i < 3; i++ {
+		bs = append(bs, bucket{"/Country:Spain/City:C" + string(rune('A'+i)), []uint32{100 + i}})
+	}
+	root, err := newRoot(bs...)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: "Country", Count: 2}, PercentSelect(30, "City", 0), {Key: NodesBucket, Count: 1}}
+	r := root.GetMaxSelection(SFGroup{Selectors: ss})
+	require.NotNil(t, r)
+
+	g := r.GetSelection(ss, []byte("pivot"))
+	require.NotNil(t, g)
+	// 30% of 10 cities of Germany and at least 1 city of Spain
+	require.Len(t, g.Nodelist(), 4)
+
+	ss[2] = PercentSelect(50, NodesBucket, 0)
+	g = root.GetSelection(ss, []byte("pivot"))
+	require.NotNil(t, g)
+	require.Len(t, g.Nodelist(), 4)
+
+	ss = []Select{PercentSelect(30, "City", 14)}
+	require.Nil(t, root.GetMaxSelection(SFGroup{Selectors: ss}))
+}
+
+func TestBucket_AllSelect(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Country:Germany/City:Berlin", []uint32{1, 2}},
+		bucket{"/Country:Germany/City:Hamburg", []uint32{3}},
+		bucket{"/Country:Spain/City:Madrid", []uint32{4, 5}},
+	)
+	require.NoError(t, err)
+
+	ss := []SFGroup{{Selectors: []Select{AllSelect("City"), {Key: NodesBucket, Count: 1}}}}
+	ns := root.FindNodes([]byte("pivot"), ss...)
+	require.Len(t, ns, 3)
+
+	ss = []SFGroup{{Selectors: []Select{{Key: "Country", Count: 1}, AllSelect(NodesBucket)}}}
+	for i := 0; i < 10; i++ {
+		ns = root.FindNodes([]byte{byte(i)}, ss...)
+		require.True(t, len(ns) == 2 || len(ns) == 3)
+	}
+
+	ss[0].Filters = []Filter{{Key: "Country", F: FilterEQ("France")}}
+	require.Empty(t, root.FindNodes([]byte("pivot"), ss...))
+}
+
+func TestBucket_SubFilter(t *testing.T) {
+	ssd := map[string]string{"SSD": "true"}
+
+	var root Bucket
+	require.NoError(t, root.AddBucket("/Country:Germany/City:Berlin",
+		Nodes{{N: 1, Attrs: ssd}, {N: 2, Attrs: ssd}, {N: 3, Attrs: ssd}, {N: 4}}))
+	require.NoError(t, root.AddBucket("/Country:Germany/City:Hamburg",
+		Nodes{{N: 5, Attrs: ssd}, {N: 6}, {N: 7}}))
+	require.NoError(t, root.AddBucket("/Country:Spain/City:Madrid",
+		Nodes{{N: 8, Attrs: ssd}, {N: 9, Attrs: ssd}, {N: 10, Attrs: ssd}}))
+
+	// keep only cities having 3 nodes with SSD
+	f := SubFilter("City", SFGroup{
+		Selectors: []Select{{Key: NodesBucket, Count: 3}},
+		Filters:   []Filter{{Key: "SSD", F: FilterEQ("true")}},
+	})
+	require.True(t, f.Check(*root.findKey("City")[0]))
+
+	ss := []Select{{Key: "City", Count: 2}, {Key: NodesBucket, Count: 1}}
+	r := root.GetMaxSelection(SFGroup{Selectors: ss, Filters: []Filter{f}})
+	require.NotNil(t, r)
+	require.Equal(t, []uint32{1, 2, 3, 4, 8, 9, 10}, r.Nodelist().Nodes())
+
+	f.F = FilterNE("Madrid")
+	require.Nil(t, root.GetMaxSelection(SFGroup{Selectors: ss, Filters: []Filter{f}}))
+
+	f.Sub.Selectors[0].Count = 4
+	f.F = nil
+	require.Empty(t, root.FindNodes([]byte("pivot"), SFGroup{
+		Selectors: []Select{{Key: NodesBucket, Count: 1}},
+		Filters:   []Filter{f},
+	}))
+}
@@ -0,0 +1,164 @@
+package netmap
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Migration describes single replica which must be moved after netmap change.
+type Migration struct {
+	Pivot []byte
+	// From is a node which stored replica in the old placement.
+	// If it is still a part of the new placement, replica must be copied,
+	// otherwise it can be removed from From after copying.
+	From uint32
+	// To is a node of the new placement which doesn't store replica yet.
+	To uint32
+}
+
+// Rebalance computes migrations required to move replicas placed in old netmap
+// according to ss to their placement in b. Replicas which stay on the same nodes
+// are not moved, so the number of migrations for every pivot is the number
+// of nodes in the new placement absent in the old one.
+// Pivots which can't be placed in old are skipped, as there are no replicas to move.
+func (b *Bucket) Rebalance(old *Bucket, pivots [][]byte, ss ...SFGroup) ([]Migration, error) {
+	var ms []Migration
+
+	for _, pivot := range pivots {
+		on := old.FindNodes(pivot, ss...)
+		if len(on) == 0 {
+			continue
+		}
+
+		nn := b.FindNodes(pivot, ss...)
+		if len(nn) == 0 {
+			return nil, errors.Wrapf(ErrNotEnoughNodes, "can't place %x in the new netmap", pivot)
+		}
+
+		sort.Sort(on)
+		sort.Sort(nn)
+
+		var (
+			added   = subtract(nn, on)
+			removed = subtract(on, nn)
+			kept    = intersect(on, nn)
+		)
+
+		for i, n := range added {
+			var from uint32
+			switch {
+			case i < len(removed):
+				from = removed[i].N
+			case len(kept) != 0:
+				from = kept[0].N
+			default:
+				from = removed[0].N
+			}
+			ms = append(ms, Migration{Pivot: pivot, From: from, To: n.N})
+		}
+	}
+	return ms, nil
+}
+
+// NextCandidate returns node replacing failed node of the placement vector
+// chosen by ss, current contains the rest of the vector. Failed node must be
+// removed from b or excluded by ss. Candidates are tried in the order of
+// rendezvous hashing with pivot and the first one, together with which
+// current satisfies ss, is returned. So every client chooses the same
+// replacement and other nodes of the vector are preserved.
+func (b *Bucket) NextCandidate(current []uint32, pivot []byte, ss ...SFGroup) (uint32, error) {
+	var (
+		nodes = b.Nodelist()
+		keep  = make(map[uint32]struct{}, len(current)+1)
+		cands Nodes
+	)
+	for _, n := range current {
+		if !containsSorted(nodes, n) {
+			return 0, errors.Errorf("node %d is absent in netmap", n)
+		}
+		keep[n] = struct{}{}
+	}
+
+	for _, s := range ss {
+		if m := b.GetMaxSelection(s); m != nil {
+			cands = merge(cands, m.Nodelist())
+		}
+	}
+	cs := make(Nodes, 0, len(cands))
+	for _, n := range cands {
+		if _, ok := keep[n.N]; !ok {
+			cs = append(cs, n)
+		}
+	}
+	newSelectParams(*b, nil, pivot, 0).shuffleNodes(cs)
+
+	for _, c := range cs {
+		keep[c.N] = struct{}{}
+		sub := b.filterSubtree(func(ns Nodes) Nodes {
+			r := make(Nodes, 0, len(ns))
+			for _, n := range ns {
+				if _, ok := keep[n.N]; ok {
+					r = append(r, n)
+				}
+			}
+			return r
+		})
+		if sub != nil && len(sub.FindNodesWith(pivot, ss, WithBacktracking(0))) == len(keep) {
+			return c.N, nil
+		}
+		delete(keep, c.N)
+	}
+	return 0, errors.Wrapf(ErrNotEnoughNodes, "no replacement for vector of %d nodes", len(current))
+}
+
+// ChurnReport describes how placement of objects changes between netmaps.
+type ChurnReport struct {
+	Pivots int
+	// Failed is the number of pivots which can't be placed in the new netmap.
+	Failed int
+	// Replicas is the total number of nodes chosen for all pivots in the
+	// new netmap, Moved is the number of them absent in the old placement
+	// of the same pivot, i.e. replicas which must be copied. Pivots which
+	// can't be placed in the old netmap aren't accounted in Moved.
+	Replicas int
+	Moved    int
+	// Changed is the number of pivots having at least one moved replica.
+	Changed int
+}
+
+// Fraction returns share of replicas which must be moved.
+func (r ChurnReport) Fraction() float64 {
+	if r.Replicas == 0 {
+		return 0
+	}
+	return float64(r.Moved) / float64(r.Replicas)
+}
+
+// PlacementChurn places pivots according to ss in netmaps old and cur and
+// reports what fraction of replicas moves between them. It allows to compare
+// stability of placement rules and selection options under netmap changes.
+func PlacementChurn(old, cur Bucket, pivots [][]byte, ss ...SFGroup) ChurnReport {
+	r := ChurnReport{Pivots: len(pivots)}
+	for _, pivot := range pivots {
+		nn := cur.FindNodes(pivot, ss...)
+		if len(nn) == 0 {
+			r.Failed++
+			continue
+		}
+		r.Replicas += len(nn)
+
+		on := old.FindNodes(pivot, ss...)
+		if len(on) == 0 {
+			continue
+		}
+
+		sort.Sort(on)
+		sort.Sort(nn)
+		if moved := len(subtract(nn, on)); moved != 0 {
+			r.Moved += moved
+			r.Changed++
+		}
+	}
+	return r
+}
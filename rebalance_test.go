@@ -0,0 +1,150 @@
+package netmap
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Rebalance(t *testing.T) {
+	old, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2, 3}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{4, 5, 6}},
+		bucket{"/Location:Asia/Country:China", []uint32{7, 8, 9}},
+	)
+	require.NoError(t, err)
+
+	ss := []SFGroup{{Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}}}
+
+	pivots := make([][]byte, 20)
+	for i := range pivots {
+		pivots[i] = []byte("container" + strconv.Itoa(i))
+	}
+
+	ms, err := old.Rebalance(&old, pivots, ss...)
+	require.NoError(t, err)
+	require.Empty(t, ms)
+
+	cur, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{2, 3}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{4, 5, 6}},
+		bucket{"/Location:Asia/Country:China", []uint32{7, 8, 9, 10}},
+	)
+	require.NoError(t, err)
+
+	ms, err = cur.Rebalance(&old, pivots, ss...)
+	require.NoError(t, err)
+
+	moved := make(map[string][]Migration)
+	for _, m := range ms {
+		moved[string(m.Pivot)] = append(moved[string(m.Pivot)], m)
+	}
+	for _, p := range pivots {
+		on := old.FindNodes(p, ss...)
+		nn := cur.FindNodes(p, ss...)
+		sort.Sort(on)
+		sort.Sort(nn)
+		require.Len(t, moved[string(p)], len(subtract(nn, on)))
+		for _, m := range moved[string(p)] {
+			require.True(t, contains(on, Node{N: m.From}))
+			require.False(t, contains(on, Node{N: m.To}))
+			require.True(t, contains(nn, Node{N: m.To}))
+		}
+		if contains(on, Node{N: 1}) {
+			require.Equal(t, uint32(1), moved[string(p)][0].From)
+		}
+	}
+
+	_, err = cur.Rebalance(&old, pivots, SFGroup{Selectors: []Select{{Key: NodesBucket, Count: 11}}})
+	require.NoError(t, err)
+
+	_, err = new(Bucket).Rebalance(&old, pivots, ss...)
+	require.Error(t, err)
+}
+
+func TestBucket_NextCandidate(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2, 3}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{4, 5, 6}},
+		bucket{"/Location:Asia/Country:China", []uint32{7, 8, 9}},
+	)
+	require.NoError(t, err)
+
+	var (
+		pivot     = []byte("container")
+		s         = SFGroup{Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}}
+		countries = root.nodeValues("Country")
+	)
+
+	v := root.FindNodes(pivot, s).Nodes()
+	require.Len(t, v, 2)
+
+	// first node fails
+	s.Exclude = []uint32{v[0]}
+	n, err := root.NextCandidate(v[1:], pivot, s)
+	require.NoError(t, err)
+	require.NotEqual(t, v[0], n)
+	require.NotEqual(t, v[1], n)
+	require.NotEqual(t, countries[v[1]], countries[n])
+
+	n1, err := root.NextCandidate(v[1:], pivot, s)
+	require.NoError(t, err)
+	require.Equal(t, n, n1)
+
+	_, err = root.NextCandidate([]uint32{100}, pivot, s)
+	require.Error(t, err)
+
+	// only nodes from the same country are left
+	s.Exclude = nil
+	for n, c := range countries {
+		if c != countries[v[1]] {
+			s.Exclude = append(s.Exclude, n)
+		}
+	}
+	_, err = root.NextCandidate(v[1:], pivot, s)
+	require.True(t, errors.Is(err, ErrNotEnoughNodes))
+}
+
+func TestPlacementChurn(t *testing.T) {
+	var (
+		old, b Bucket
+		pivots = make([][]byte, 10)
+		ss     = []SFGroup{{Selectors: []Select{{Key: NodesBucket, Count: 2}}}}
+	)
+	for i := range pivots {
+		pivots[i] = []byte{byte(i)}
+	}
+	for i := uint32(1); i <= 6; i++ {
+		require.NoError(t, old.AddNode(i, "/Location:Europe"))
+		if i != 3 {
+			require.NoError(t, b.AddNode(i, "/Location:Europe"))
+		}
+	}
+
+	r := PlacementChurn(old, old, pivots, ss...)
+	require.Equal(t, ChurnReport{Pivots: 10, Replicas: 20}, r)
+	require.Equal(t, 0.0, r.Fraction())
+
+	// every pivot placed on the removed node moves exactly one replica
+	var affected int
+	for _, p := range pivots {
+		if containsSorted(sortedNodes(old.FindNodes(p, ss...)), 3) {
+			affected++
+		}
+	}
+	require.True(t, affected > 0)
+	r = PlacementChurn(old, b, pivots, ss...)
+	require.Equal(t, ChurnReport{Pivots: 10, Replicas: 20, Moved: affected, Changed: affected}, r)
+	require.Equal(t, float64(affected)/20, r.Fraction())
+
+	// nothing is moved when old placement is impossible
+	r = PlacementChurn(Bucket{}, b, pivots, ss...)
+	require.Equal(t, ChurnReport{Pivots: 10, Replicas: 20}, r)
+
+	ss[0].Selectors[0].Count = 6
+	r = PlacementChurn(old, b, pivots, ss...)
+	require.Equal(t, ChurnReport{Pivots: 10, Failed: 10}, r)
+}
@@ -0,0 +1,96 @@
+package netmap
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/nspcc-dev/hrw"
+	"github.com/pkg/errors"
+)
+
+type (
+	// NodeIterator returns nodes one by one, false is returned when
+	// there are no more nodes. It allows to process netmaps which are
+	// too large to be loaded into memory.
+	NodeIterator func() (Node, bool)
+
+	// sampledNode is a node along with its sampling key.
+	sampledNode struct {
+		node Node
+		key  float64
+	}
+
+	// reservoir is a min-heap of sampled nodes.
+	reservoir []sampledNode
+)
+
+// Iterator returns iterator over ns.
+func (n Nodes) Iterator() NodeIterator {
+	var i int
+	return func() (Node, bool) {
+		if i == len(n) {
+			return Node{}, false
+		}
+		i++
+		return n[i-1], true
+	}
+}
+
+// SampleNodes chooses k nodes returned by next in a single pass keeping at most
+// k nodes in memory. Probability of the node to be chosen is proportional to
+// its weight computed by wf, nil wf means that all nodes have equal weights,
+// nodes with non-positive weight are never chosen. Weighted reservoir sampling
+// (A-Res by Efraimidis and Spirakis) is used with random numbers derived from
+// the pivot and node indices, so the same nodes are chosen for the same pivot
+// independent of their order. Nodes returned by next must have distinct indices.
+// Chosen nodes are sorted by index, ErrNotEnoughNodes is returned if there are
+// less than k nodes with positive weight.
+func SampleNodes(next NodeIterator, k int, pivot []byte, wf WeightFunc) (Nodes, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+
+	var (
+		hash = hrw.Hash(pivot)
+		r    = make(reservoir, 0, k)
+	)
+	for n, ok := next(); ok; n, ok = next() {
+		w := 1.0
+		if wf != nil {
+			if w = wf(n); w <= 0 {
+				continue
+			}
+		}
+
+		key := math.Log(toUnit(mix64(n.Hash()^hash))) / w
+		if len(r) < k {
+			heap.Push(&r, sampledNode{node: n, key: key})
+		} else if key > r[0].key {
+			r[0] = sampledNode{node: n, key: key}
+			heap.Fix(&r, 0)
+		}
+	}
+
+	if len(r) < k {
+		return nil, errors.Wrapf(ErrNotEnoughNodes, "%d of %d nodes sampled", len(r), k)
+	}
+
+	ns := make(Nodes, 0, k)
+	for i := range r {
+		ns = append(ns, r[i].node)
+	}
+	sort.Sort(ns)
+	return ns, nil
+}
+
+func (r reservoir) Len() int            { return len(r) }
+func (r reservoir) Less(i, j int) bool  { return r[i].key < r[j].key }
+func (r reservoir) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *reservoir) Push(x interface{}) { *r = append(*r, x.(sampledNode)) }
+func (r *reservoir) Pop() interface{} {
+	old := *r
+	x := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,54 @@
+package netmap
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleNodes(t *testing.T) {
+	ns := Nodes{{N: 1, C: 10}, {N: 2, C: 30}, {N: 3, C: 10}, {N: 4, C: 10}, {N: 5}}
+
+	r, err := SampleNodes(ns.Iterator(), 2, []byte("pivot"), nil)
+	require.NoError(t, err)
+	require.Len(t, r, 2)
+
+	// order of the stream doesn't matter
+	rev := make(Nodes, 0, len(ns))
+	for i := len(ns) - 1; i >= 0; i-- {
+		rev = append(rev, ns[i])
+	}
+	r1, err := SampleNodes(rev.Iterator(), 2, []byte("pivot"), nil)
+	require.NoError(t, err)
+	require.Equal(t, r, r1)
+
+	r, err = SampleNodes(ns.Iterator(), 4, []byte("pivot"), CapWeightFunc)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{1, 2, 3, 4}, r.Nodes())
+
+	_, err = SampleNodes(ns.Iterator(), 5, []byte("pivot"), CapWeightFunc)
+	require.True(t, errors.Is(err, ErrNotEnoughNodes))
+
+	r, err = SampleNodes(ns.Iterator(), 0, []byte("pivot"), nil)
+	require.NoError(t, err)
+	require.Empty(t, r)
+
+	t.Run("proportional", func(t *testing.T) {
+		const iterations = 3000
+		var (
+			big   int
+			pivot = make([]byte, 8)
+		)
+		for i := 0; i < iterations; i++ {
+			binary.BigEndian.PutUint64(pivot, uint64(i))
+			r, err := SampleNodes(ns.Iterator(), 1, pivot, CapWeightFunc)
+			require.NoError(t, err)
+			if r[0].N == 2 {
+				big++
+			}
+		}
+		require.InDelta(t, 0.5, float64(big)/iterations, 0.03)
+	})
+}
@@ -0,0 +1,102 @@
+package netmap
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/nspcc-dev/hrw"
+)
+
+// DefaultRingReplicas is the number of points every node has
+// on the Ring if not specified explicitly.
+const DefaultRingReplicas = 64
+
+type (
+	// Ring is a consistent-hash ring built from nodes of a bucket. It maps
+	// arbitrary keys to nodes without policy, so that only a small share of
+	// keys is remapped when nodes join or leave. Ring is safe for concurrent use.
+	Ring struct {
+		points []ringPoint
+		nodes  int
+	}
+
+	ringPoint struct {
+		hash uint64
+		node uint32
+	}
+)
+
+// NewRing returns Ring containing all nodes of b, every node is placed at
+// replicas pseudo-random points. Non-positive replicas means DefaultRingReplicas.
+func NewRing(b Bucket, replicas int) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultRingReplicas
+	}
+
+	var (
+		ns  = b.Nodelist()
+		r   = &Ring{points: make([]ringPoint, 0, len(ns)*replicas), nodes: len(ns)}
+		buf [8]byte
+	)
+	for i := range ns {
+		binary.BigEndian.PutUint32(buf[:4], ns[i].N)
+		for j := 0; j < replicas; j++ {
+			binary.BigEndian.PutUint32(buf[4:], uint32(j))
+			r.points = append(r.points, ringPoint{hash: hrw.Hash(buf[:]), node: ns[i].N})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash == r.points[j].hash {
+			return r.points[i].node < r.points[j].node
+		}
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// Len returns number of nodes in r.
+func (r *Ring) Len() int {
+	return r.nodes
+}
+
+// Get returns index of the node responsible for key or -1 if r is empty.
+func (r *Ring) Get(key []byte) int32 {
+	if len(r.points) == 0 {
+		return -1
+	}
+	return int32(r.points[r.search(key)].node)
+}
+
+// GetN returns indices of n distinct nodes responsible for key in the order
+// of their preference. If r contains less than n nodes, all of them are returned.
+func (r *Ring) GetN(key []byte, n int) []int32 {
+	if n > r.nodes {
+		n = r.nodes
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	var (
+		res  = make([]int32, 0, n)
+		seen = make(map[uint32]struct{}, n)
+	)
+	for i := r.search(key); len(res) < n; i = (i + 1) % len(r.points) {
+		p := r.points[i]
+		if _, ok := seen[p.node]; !ok {
+			seen[p.node] = struct{}{}
+			res = append(res, int32(p.node))
+		}
+	}
+	return res
+}
+
+// search returns position of the first point following hash of key clockwise.
+func (r *Ring) search(key []byte) int {
+	h := hrw.Hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return i
+}
@@ -0,0 +1,47 @@
+package netmap
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	var b Bucket
+	for i := uint32(1); i <= 10; i++ {
+		require.NoError(t, b.AddNode(i, "/Location:L"+strconv.Itoa(int(i%3))))
+	}
+
+	r := NewRing(b, 0)
+	require.Equal(t, 10, r.Len())
+
+	key := []byte("object")
+	ns := r.GetN(key, 3)
+	require.Len(t, ns, 3)
+	require.Equal(t, ns[0], r.Get(key))
+	require.Equal(t, ns, NewRing(b, 0).GetN(key, 3))
+	require.Len(t, r.GetN(key, 20), 10)
+	require.Nil(t, r.GetN(key, 0))
+
+	// removing a node remaps only keys which were mapped to it
+	var b1 Bucket
+	for i := uint32(1); i <= 10; i++ {
+		if i != 5 {
+			require.NoError(t, b1.AddNode(i, "/Location:L"+strconv.Itoa(int(i%3))))
+		}
+	}
+	r1 := NewRing(b1, 0)
+	for i := 0; i < 1000; i++ {
+		key := []byte(strconv.Itoa(i))
+		if n := r.Get(key); n != 5 {
+			require.Equal(t, n, r1.Get(key))
+		} else {
+			require.NotEqual(t, int32(5), r1.Get(key))
+		}
+	}
+
+	empty := NewRing(Bucket{}, 0)
+	require.Equal(t, int32(-1), empty.Get(key))
+	require.Nil(t, empty.GetN(key, 1))
+}
@@ -0,0 +1,150 @@
+package netmap
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Schema declares attributes known to the netmap. When it is attached
+	// to the bucket by WithSchema or to the netmap by NetMap.SetSchema,
+	// options of added buckets and placement rules passed to selection
+	// methods returning errors (e.g. FindGraphStrict) are checked against it,
+	// so that typos like Contry:RU are caught early.
+	Schema struct {
+		attrs map[string]Attribute
+	}
+
+	// Attribute describes known attribute key.
+	Attribute struct {
+		Key string
+		// Values lists allowed values, any value is allowed if it is empty.
+		Values []string
+		// Numeric means that values are numbers, so that they are ordered
+		// and can be used in numeric filters, e.g. FilterGT or FilterRange.
+		Numeric bool
+	}
+)
+
+// WithSchema attaches schema s to the bucket. Options passed to AddBucket,
+// AddNodes, AddStrawNode and paths of changes passed to ApplyDelta are
+// rejected with ErrSchemaViolation once they don't match it. Placement rules
+// are checked by selection methods returning errors, e.g. FindGraphStrict
+// and CompilePolicy. Nil disables checks, which is the default. Schema is
+// kept by Copy and decoding, buckets without it are not affected.
+func WithSchema(s *Schema) Option {
+	return func(b *Bucket) {
+		b.schema = s
+	}
+}
+
+// NewSchema returns schema declaring attributes attrs.
+func NewSchema(attrs ...Attribute) *Schema {
+	s := &Schema{attrs: make(map[string]Attribute, len(attrs))}
+	for _, a := range attrs {
+		a.Values = append([]string(nil), a.Values...)
+		s.attrs[a.Key] = a
+	}
+	return s
+}
+
+// Attribute returns description of the attribute with the specified key.
+func (s *Schema) Attribute(key string) (Attribute, bool) {
+	a, ok := s.attrs[key]
+	return a, ok
+}
+
+// CheckOption checks that all key:value pairs of option o are declared
+// by the schema. Nil schema accepts any option.
+func (s *Schema) CheckOption(o string) error {
+	if s == nil || o == Separator {
+		return nil
+	}
+	for _, p := range strings.Split(strings.TrimPrefix(o, Separator), Separator) {
+		k, v, err := splitKV(p)
+		if err != nil {
+			return err
+		}
+		if err := s.checkValue(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckGroups checks that selectors and filters of ss refer to the attributes
+// declared by the schema, numeric filters are applied to numeric attributes
+// and compared values are allowed. Nil schema accepts any group.
+func (s *Schema) CheckGroups(ss ...SFGroup) error {
+	if s == nil {
+		return nil
+	}
+	for i := range ss {
+		for _, sel := range ss[i].Selectors {
+			if sel.Key == NodesBucket {
+				continue
+			} else if _, ok := s.attrs[sel.Key]; !ok {
+				return errors.Wrapf(ErrSchemaViolation, "group %d: unknown selector key %s", i, sel.Key)
+			}
+		}
+		for _, f := range ss[i].Filters {
+			a, ok := s.attrs[f.Key]
+			if !ok {
+				return errors.Wrapf(ErrSchemaViolation, "group %d: unknown filter key %s", i, f.Key)
+			} else if sf := f.GetF(); sf != nil {
+				if err := s.checkFilter(a, *sf); err != nil {
+					return errors.Wrapf(err, "group %d", i)
+				}
+			}
+			if sub := f.GetSub(); sub != nil {
+				if err := s.CheckGroups(*sub); err != nil {
+					return errors.Wrapf(err, "group %d: sub-policy of filter on %s", i, f.Key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) checkFilter(a Attribute, sf SimpleFilter) error {
+	switch sf.Op {
+	case Operation_OR, Operation_AND:
+		if args := sf.GetFArgs(); args != nil {
+			for i := range args.Filters {
+				if err := s.checkFilter(a, args.Filters[i]); err != nil {
+					return err
+				}
+			}
+		}
+	case Operation_EQ, Operation_NE:
+		return s.checkValue(a.Key, sf.GetValue())
+	case Operation_GT, Operation_GE, Operation_LT, Operation_LE, Operation_RANGE:
+		if !a.Numeric {
+			return errors.Wrapf(ErrSchemaViolation, "numeric filter %s on non-numeric key %s", sf.Op, a.Key)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) checkValue(key, value string) error {
+	a, ok := s.attrs[key]
+	if !ok {
+		return errors.Wrapf(ErrSchemaViolation, "unknown key %s", key)
+	}
+	if a.Numeric {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return errors.Wrapf(ErrSchemaViolation, "value %s of %s is not a number", value, key)
+		}
+	}
+	if len(a.Values) == 0 {
+		return nil
+	}
+	for _, v := range a.Values {
+		if v == value {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrSchemaViolation, "value %s of %s is not allowed", value, key)
+}
@@ -0,0 +1,84 @@
+package netmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	s := NewSchema(
+		Attribute{Key: "Location", Values: []string{"Europe", "Asia"}},
+		Attribute{Key: "Country"},
+		Attribute{Key: "Capacity", Numeric: true},
+	)
+
+	b := NewBucket("", "", WithSchema(s))
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:Germany/Capacity:100", Nodes{{N: 1}}))
+	require.NoError(t, b.AddBucket("/Location:Asia/Country:China/Capacity:50.5", Nodes{{N: 2}}))
+
+	err := b.AddBucket("/Location:Europe/Contry:RU", Nodes{{N: 3}})
+	require.True(t, errors.Is(err, ErrSchemaViolation))
+	err = b.AddBucket("/Location:America", Nodes{{N: 3}})
+	require.True(t, errors.Is(err, ErrSchemaViolation))
+	err = b.AddNodes([]NodeEntry{{Node: Node{N: 3}, Options: []string{"/Capacity:big"}}})
+	require.True(t, errors.Is(err, ErrSchemaViolation))
+	require.Equal(t, []uint32{1, 2}, b.Nodelist().Nodes())
+
+	sel := []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 1}}
+	good := []SFGroup{{Selectors: sel, Filters: []Filter{
+		{Key: "Capacity", F: FilterGE(100)},
+		{Key: "Location", F: FilterIn("Europe", "Asia")},
+	}}}
+	_, err = b.FindGraphStrict(defaultPivot, good...)
+	require.NoError(t, err)
+
+	bad := map[string][]SFGroup{
+		"selector key":   {{Selectors: []Select{{Key: "Contry", Count: 1}}}},
+		"filter key":     {{Selectors: sel, Filters: []Filter{{Key: "Contry", F: FilterEQ("RU")}}}},
+		"filter value":   {{Selectors: sel, Filters: []Filter{{Key: "Location", F: FilterOR(FilterEQ("Europe"), FilterEQ("Erope"))}}}},
+		"numeric filter": {{Selectors: sel, Filters: []Filter{{Key: "Country", F: FilterGT(1)}}}},
+	}
+	for name, ss := range bad {
+		t.Run(name, func(t *testing.T) {
+			_, err := b.FindGraphStrict(defaultPivot, ss...)
+			require.True(t, errors.Is(err, ErrSchemaViolation))
+			_, err = b.FindNodesCtx(context.Background(), defaultPivot, ss)
+			require.True(t, errors.Is(err, ErrSchemaViolation))
+			_, err = b.ExplainSelection(defaultPivot, ss...)
+			require.True(t, errors.Is(err, ErrSchemaViolation))
+		})
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		c := b.Copy()
+		err := c.AddBucket("/Contry:RU", Nodes{{N: 3}})
+		require.True(t, errors.Is(err, ErrSchemaViolation))
+
+		data, err := b.MarshalBinary()
+		require.NoError(t, err)
+		require.NoError(t, c.UnmarshalBinary(data))
+		err = c.AddBucket("/Contry:RU", Nodes{{N: 3}})
+		require.True(t, errors.Is(err, ErrSchemaViolation))
+	})
+
+	t.Run("netmap", func(t *testing.T) {
+		m := NewNetMap()
+		m.SetSchema(s)
+		require.NoError(t, m.Apply(Event{Type: NodeAdded, Node: Node{N: 1}, Options: []string{"/Country:Germany"}}))
+		err := m.Apply(Event{Type: NodeAdded, Node: Node{N: 2}, Options: []string{"/Contry:RU"}})
+		require.True(t, errors.Is(err, ErrSchemaViolation))
+
+		root := m.Root()
+		_, err = root.FindGraphStrict(defaultPivot, bad["selector key"]...)
+		require.True(t, errors.Is(err, ErrSchemaViolation))
+	})
+
+	// other buckets are not affected
+	var other Bucket
+	require.NoError(t, other.AddBucket("/Contry:RU", Nodes{{N: 3}}))
+	_, err = other.FindGraphStrict(defaultPivot, bad["selector key"]...)
+	require.False(t, errors.Is(err, ErrSchemaViolation))
+}
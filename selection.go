@@ -0,0 +1,413 @@
+package netmap
+
+import (
+	"context"
+	"sort"
+)
+
+type (
+	// SelectOption is an optional parameter of selection.
+	SelectOption func(*selectParams)
+
+	// selectParams contains parameters of a single selection.
+	selectParams struct {
+		// shuffler defines order of candidates, if nil,
+		// candidates are tried in the order they are stored.
+		shuffler Shuffler
+		// strategy defines shuffler for the pivot if shuffler
+		// isn't set explicitly, if nil, StrategyWeighted is used.
+		strategy Strategy
+
+		// capacity is a total capacity of nodes to select.
+		// If zero, nodes are selected by count.
+		capacity uint64
+
+		// values contains values of attributes used in DISTINCT and SAME
+		// clauses for every node.
+		values map[string]map[uint32]string
+
+		// trace, if not nil, records all decisions made during selection.
+		trace *Trace
+		group int
+
+		// locality contains paths of buckets preferred by the client,
+		// local contains their nodes.
+		locality []string
+		local    map[uint32]struct{}
+
+		// quota, if not nil, limits number of nodes with the same
+		// attribute value. It is shared by all levels of selection.
+		quota *quota
+
+		// bucketWeight, if not nil, makes buckets tried with probability
+		// proportional to the total weight of their nodes.
+		bucketWeight WeightFunc
+
+		// cancel, if not nil, stops selection when its context is done.
+		cancel *cancelState
+
+		// solver, if not nil, enables exhaustive search
+		// when greedy selection fails.
+		solver *solver
+
+		// maintenance contains nodes which are in maintenance
+		// at the moment of selection.
+		maintenance map[uint32]struct{}
+	}
+
+	// cancelState contains context of selection and its error
+	// remembered after the context is done.
+	cancelState struct {
+		ctx context.Context
+		err error
+	}
+
+	quota struct {
+		limits map[string]int
+		counts map[string]map[string]int
+	}
+)
+
+// WithQuota returns option which limits number of selected nodes
+// having the same value of attribute key (e.g. no more than 2 nodes
+// from any single Rack), independent of the level Select clauses target.
+// Quotas are enforced when nodes are chosen.
+func WithQuota(key string, max int) SelectOption {
+	return func(p *selectParams) {
+		if p.quota == nil {
+			p.quota = &quota{
+				limits: make(map[string]int),
+				counts: make(map[string]map[string]int),
+			}
+		}
+		p.quota.limits[key] = max
+		p.quota.counts[key] = make(map[string]int)
+	}
+}
+
+// WithLocality returns option which makes buckets located at any of the
+// paths (e.g. "/Location:Europe/Country:Germany", wildcards are allowed)
+// preferred during selection. Other buckets are used only if counts
+// can't be satisfied locally.
+func WithLocality(paths ...string) SelectOption {
+	return func(p *selectParams) {
+		p.locality = append(p.locality, paths...)
+	}
+}
+
+// WithProportionalBuckets returns option which makes every bucket chosen
+// with probability proportional to the total weight of its nodes computed
+// by wf (e.g. CapWeightFunc), so that bigger datacenters receive
+// proportionally more placements. By default buckets are chosen uniformly.
+func WithProportionalBuckets(wf WeightFunc) SelectOption {
+	return func(p *selectParams) {
+		p.bucketWeight = wf
+	}
+}
+
+// withCancel returns option which stops selection when context of c is done.
+func withCancel(c *cancelState) SelectOption {
+	return func(p *selectParams) {
+		p.cancel = c
+	}
+}
+
+// canceled checks whether selection must be stopped.
+func (p selectParams) canceled() bool {
+	if p.cancel == nil {
+		return false
+	}
+	if p.cancel.err == nil {
+		p.cancel.err = p.cancel.ctx.Err()
+	}
+	return p.cancel.err != nil
+}
+
+// newSelectParams returns parameters of selection ss from b.
+// Attribute values are collected from b, so it must contain
+// all buckets used in DISTINCT and SAME clauses.
+func newSelectParams(b Bucket, ss []Select, pivot []byte, capacity uint64, opts ...SelectOption) selectParams {
+	p := selectParams{capacity: capacity}
+	for _, o := range opts {
+		o(&p)
+	}
+	if p.shuffler == nil && len(pivot) != 0 {
+		p.shuffler = p.pivotShuffler(pivot)
+	}
+
+	if len(p.locality) != 0 {
+		p.local = make(map[uint32]struct{})
+		for _, path := range p.locality {
+			for _, c := range b.Query(path) {
+				for _, n := range c.Nodelist() {
+					p.local[n.N] = struct{}{}
+				}
+			}
+		}
+	}
+
+	var keys []string
+	for i := range ss {
+		keys = append(keys, ss[i].Distinct, ss[i].Same)
+	}
+	if p.quota != nil {
+		for key := range p.quota.limits {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if p.values == nil {
+			p.values = make(map[string]map[uint32]string)
+		}
+		if _, ok := p.values[key]; !ok {
+			p.values[key] = b.nodeValues(key)
+		}
+	}
+	return p
+}
+
+// nodeValues returns values of attribute key for every node in b.
+func (b *Bucket) nodeValues(key string) map[uint32]string {
+	values := make(map[uint32]string)
+	for _, c := range b.findKey(key) {
+		for _, n := range c.nodes {
+			if _, ok := values[n.N]; !ok {
+				values[n.N] = c.Value
+			}
+		}
+	}
+	return values
+}
+
+// take returns first count nodes from ns. If capacity is set,
+// nodes are taken until their total capacity reaches it.
+func (p selectParams) take(ns Nodes, count int) (Nodes, bool) {
+	if len(ns) < count {
+		return nil, false
+	}
+	if p.capacity == 0 {
+		return ns[:count], true
+	}
+
+	var sum uint64
+	for i := range ns {
+		if i >= count && sum >= p.capacity {
+			return ns[:i], true
+		}
+		sum += ns[i].C
+	}
+	return ns, sum >= p.capacity
+}
+
+// distinct returns nodes from ns with pairwise different values
+// of attribute key preserving their order.
+// Nodes without such attribute are not restricted.
+func (p selectParams) distinct(key string, ns Nodes) Nodes {
+	var (
+		values = p.values[key]
+		used   = make(map[string]struct{}, len(ns))
+		result = make(Nodes, 0, len(ns))
+	)
+
+	for i := range ns {
+		if v, ok := values[ns[i].N]; ok {
+			if _, ok := used[v]; ok {
+				continue
+			}
+			used[v] = struct{}{}
+		}
+		result = append(result, ns[i])
+	}
+	return result
+}
+
+// sameValues returns all values of attribute key of nodes ns
+// in the order they must be tried in SAME clause.
+func (p selectParams) sameValues(key string, ns Nodes) []string {
+	var (
+		values = p.values[key]
+		used   = make(map[string]struct{})
+		result []string
+	)
+
+	for i := range ns {
+		if v, ok := values[ns[i].N]; ok {
+			if _, ok := used[v]; !ok {
+				used[v] = struct{}{}
+				result = append(result, v)
+			}
+		}
+	}
+
+	sort.Strings(result)
+	p.shuffleStrings(result)
+	return result
+}
+
+// useValues marks values of attribute key of nodes ns as used.
+// If some of them were already used, false is returned and used is left unchanged.
+func (p selectParams) useValues(key string, ns Nodes, used map[string]struct{}) bool {
+	values := p.values[key]
+	for i := range ns {
+		if v, ok := values[ns[i].N]; ok {
+			if _, ok := used[v]; ok {
+				return false
+			}
+		}
+	}
+	for i := range ns {
+		if v, ok := values[ns[i].N]; ok {
+			used[v] = struct{}{}
+		}
+	}
+	return true
+}
+
+// withinQuota returns nodes from ns which can be chosen without
+// exceeding quotas, assuming that all previous nodes are chosen too.
+func (p selectParams) withinQuota(ns Nodes) Nodes {
+	if p.quota == nil {
+		return ns
+	}
+
+	var (
+		result = make(Nodes, 0, len(ns))
+		taken  = p.newTaken()
+	)
+	for i := range ns {
+		if p.allowedByQuota(ns[i], taken) {
+			p.takeQuota(ns[i], taken, 1)
+			result = append(result, ns[i])
+		}
+	}
+	return result
+}
+
+// newTaken returns counters of nodes taken in addition to already chosen ones.
+func (p selectParams) newTaken() map[string]map[string]int {
+	if p.quota == nil {
+		return nil
+	}
+	taken := make(map[string]map[string]int, len(p.quota.limits))
+	for key := range p.quota.limits {
+		taken[key] = make(map[string]int)
+	}
+	return taken
+}
+
+// allowedByQuota checks whether node n can be chosen without exceeding
+// quotas, given nodes already chosen and taken.
+func (p selectParams) allowedByQuota(n Node, taken map[string]map[string]int) bool {
+	if p.quota == nil {
+		return true
+	}
+	for key, max := range p.quota.limits {
+		if v, ok := p.values[key][n.N]; ok && p.quota.counts[key][v]+taken[key][v] >= max {
+			return false
+		}
+	}
+	return true
+}
+
+// takeQuota adds d to taken counters of all attribute values of node n.
+func (p selectParams) takeQuota(n Node, taken map[string]map[string]int, d int) {
+	if p.quota == nil {
+		return
+	}
+	for key := range p.quota.limits {
+		if v, ok := p.values[key][n.N]; ok {
+			taken[key][v] += d
+		}
+	}
+}
+
+// consume accounts chosen nodes ns in quotas.
+func (p selectParams) consume(ns Nodes) {
+	p.updateQuota(ns, 1)
+}
+
+// release returns nodes ns which were not chosen eventually to quotas.
+func (p selectParams) release(ns Nodes) {
+	p.updateQuota(ns, -1)
+}
+
+func (p selectParams) updateQuota(ns Nodes, d int) {
+	if p.quota == nil {
+		return
+	}
+	for key := range p.quota.limits {
+		for i := range ns {
+			if v, ok := p.values[key][ns[i].N]; ok {
+				p.quota.counts[key][v] += d
+			}
+		}
+	}
+}
+
+// preferLocalBuckets moves buckets containing local nodes
+// to the beginning of cs preserving their order.
+func (p selectParams) preferLocalBuckets(cs []Bucket) {
+	if p.local == nil {
+		return
+	}
+	// buckets with the same name can have different parents,
+	// so flags are kept by position rather than by name
+	local := make([]bool, len(cs))
+	for i := range cs {
+		local[i] = p.hasLocal(cs[i].Nodelist())
+	}
+
+	r := make([]Bucket, 0, len(cs))
+	for _, first := range []bool{true, false} {
+		for i := range cs {
+			if local[i] == first {
+				r = append(r, cs[i])
+			}
+		}
+	}
+	copy(cs, r)
+}
+
+// preferLocalNodes moves local nodes to the beginning of ns preserving their order.
+func (p selectParams) preferLocalNodes(ns Nodes) {
+	if p.local == nil {
+		return
+	}
+	sort.SliceStable(ns, func(i, j int) bool {
+		return p.isLocal(ns[i]) && !p.isLocal(ns[j])
+	})
+}
+
+func (p selectParams) hasLocal(ns Nodes) bool {
+	for i := range ns {
+		if p.isLocal(ns[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p selectParams) allLocal(ns Nodes) bool {
+	for i := range ns {
+		if !p.isLocal(ns[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p selectParams) isLocal(n Node) bool {
+	_, ok := p.local[n.N]
+	return ok
+}
+
+// record adds step to the selection trace, if any.
+func (p selectParams) record(a TraceAction, b Bucket, nodes Nodes, reason string) {
+	if p.trace != nil {
+		p.trace.add(p.group, a, traceName(b), nodes.Nodes(), reason)
+	}
+}
@@ -1,18 +1,38 @@
 package netmap
 
 import (
+	"math"
 	"strconv"
 
 	// used by protoc
 	_ "github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
 )
 
 // Check checks is Bucket satisfies filter f.
 func (f Filter) Check(b Bucket) bool {
-	if sf := f.GetF(); sf != nil {
-		return f.Key == b.Key && sf.Check(b.Value)
+	if f.GetF() == nil && f.GetSub() == nil {
+		return false
 	}
-	return false
+	return f.Key == b.Key && f.admits(b)
+}
+
+// admits checks whether value of bucket b is accepted by f.F and,
+// if f.Sub is set, subtree of b can satisfy sub-policy f.Sub.
+func (f Filter) admits(b Bucket) bool {
+	if sf := f.GetF(); sf != nil && !sf.Check(b.Value) {
+		return false
+	}
+	if sub := f.GetSub(); sub != nil && b.GetMaxSelection(*sub) == nil {
+		return false
+	}
+	return true
+}
+
+// SubFilter returns filter which keeps only buckets with specified key
+// able to satisfy sub-policy s, e.g. Cities having 3 nodes with SSD.
+func SubFilter(key string, s SFGroup) Filter {
+	return Filter{Key: key, Sub: &s}
 }
 
 // Check returns result of applying sf to value.
@@ -47,6 +67,8 @@ func (sf SimpleFilter) Check(value string) bool {
 		return value == sf.GetValue()
 	case Operation_NE:
 		return value != sf.GetValue()
+	case Operation_RANGE:
+		return checkRange(sf.GetRange(), value)
 	}
 
 	var (
@@ -74,6 +96,212 @@ func (sf SimpleFilter) Check(value string) bool {
 	}
 }
 
+// CheckNumber returns result of applying sf to numeric value v.
+// Compared values are parsed to float64, values which are not
+// numbers are considered to match, see NewNodeFilter rejecting them.
+func (sf SimpleFilter) CheckNumber(v float64) bool {
+	switch sf.Op {
+	case Operation_OR:
+		if args := sf.GetFArgs(); args != nil {
+			for _, f := range args.Filters {
+				if f.CheckNumber(v) {
+					return true
+				}
+			}
+			return false
+		}
+		return true
+	case Operation_AND:
+		if args := sf.GetFArgs(); args != nil {
+			for _, f := range args.Filters {
+				if !f.CheckNumber(v) {
+					return false
+				}
+			}
+		}
+		return true
+	case Operation_NP:
+		return true
+	case Operation_RANGE:
+		r := sf.GetRange()
+		return r == nil || r.From <= v && v <= r.To
+	}
+
+	exp, err := strconv.ParseFloat(sf.GetValue(), 64)
+	if err != nil || math.IsNaN(exp) {
+		return true
+	}
+
+	switch sf.Op {
+	case Operation_EQ:
+		return v == exp
+	case Operation_NE:
+		return v != exp
+	case Operation_GT:
+		return v > exp
+	case Operation_GE:
+		return v >= exp
+	case Operation_LT:
+		return v < exp
+	case Operation_LE:
+		return v <= exp
+	default:
+		return true
+	}
+}
+
+// Check checks whether aggregate of field values of nodes ns satisfies af.
+func (af AggregateFilter) Check(ns Nodes) bool {
+	sf := af.GetF()
+	if sf == nil {
+		return true
+	}
+
+	agg := af.Agg.Aggregator()
+	for i := range ns {
+		agg.Add(af.Field.Value(ns[i]))
+	}
+	return sf.CheckNumber(agg.Compute())
+}
+
+// Aggregator returns new aggregator computing a.
+func (a Aggregation) Aggregator() Aggregator {
+	switch a {
+	case Aggregation_MEAN:
+		return NewMeanSumAgg()
+	case Aggregation_MIN:
+		return NewMinAgg()
+	case Aggregation_MAX:
+		return NewMaxAgg()
+	default:
+		return NewSumAgg()
+	}
+}
+
+// Value returns value of field f of node n.
+func (f NodeField) Value(n Node) float64 {
+	if f == NodeField_PRICE {
+		return float64(n.P)
+	}
+	return float64(n.C)
+}
+
+// Check checks whether node n satisfies f.
+func (f NodeFilter) Check(n Node) bool {
+	sf := f.GetF()
+	return sf == nil || sf.CheckNumber(f.Field.Value(n))
+}
+
+// NewNodeFilter returns node filter applying sf to field f. Node fields are
+// numeric, so error is returned if values compared by sf are not numbers.
+func NewNodeFilter(f NodeField, sf *SimpleFilter) (NodeFilter, error) {
+	if sf != nil {
+		if err := sf.checkNumeric(); err != nil {
+			return NodeFilter{}, errors.Wrapf(err, "filter on %s", f)
+		}
+	}
+	return NodeFilter{Field: f, F: sf}, nil
+}
+
+// checkNumeric checks that all values compared by sf are numbers.
+func (sf SimpleFilter) checkNumeric() error {
+	switch sf.Op {
+	case Operation_OR, Operation_AND:
+		if args := sf.GetFArgs(); args != nil {
+			for i := range args.Filters {
+				if err := args.Filters[i].checkNumeric(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case Operation_NP, Operation_RANGE:
+		return nil
+	}
+	if v, err := strconv.ParseFloat(sf.GetValue(), 64); err != nil || math.IsNaN(v) {
+		return errors.Errorf("value %q is not a number", sf.GetValue())
+	}
+	return nil
+}
+
+// MinCapacity returns node filter which allows nodes with capacity at least c.
+func MinCapacity(c uint64) NodeFilter {
+	return NodeFilter{Field: NodeField_CAPACITY, F: NewFilter(Operation_GE, strconv.FormatUint(c, 10))}
+}
+
+// MaxPrice returns node filter which allows nodes with price at most p.
+func MaxPrice(p uint64) NodeFilter {
+	return NodeFilter{Field: NodeField_PRICE, F: NewFilter(Operation_LE, strconv.FormatUint(p, 10))}
+}
+
+// checkNodeFilters checks whether node n satisfies all filters fs.
+func checkNodeFilters(fs []NodeFilter, n Node) bool {
+	for i := range fs {
+		if !fs[i].Check(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// PercentSelect returns select clause choosing percent of matching
+// buckets with specified key, but not less than min and at least one,
+// so that policy scales automatically as the netmap grows.
+func PercentSelect(percent uint32, key string, min uint32) Select {
+	return Select{Count: min, Key: key, Percent: percent}
+}
+
+// CountOf returns number of buckets or nodes s chooses out of n matching
+// ones. If s.Count is CountAll, it is n, but at least one. If s.Percent is
+// set, it is the percentage of n rounded down, but not less than s.Count
+// and 1, otherwise it is s.Count.
+func (s Select) CountOf(n int) int {
+	var c int
+	switch {
+	case s.Count == CountAll:
+		c = n
+	case s.Percent == 0:
+		return int(s.Count)
+	default:
+		if c = n * int(s.Percent) / 100; c < int(s.Count) {
+			c = int(s.Count)
+		}
+	}
+	if c < 1 {
+		c = 1
+	}
+	return c
+}
+
+// AllSelect returns select clause choosing all buckets with specified key.
+func AllSelect(key string) Select {
+	return Select{Count: CountAll, Key: key}
+}
+
+// AggregateSelect returns select clause choosing count buckets
+// with specified key whose aggregate of node field satisfies sf,
+// e.g. 2 Cities with total capacity of at least 1000.
+func AggregateSelect(count uint32, key string, a Aggregation, f NodeField, sf *SimpleFilter) Select {
+	return Select{
+		Count:     count,
+		Key:       key,
+		Aggregate: &AggregateFilter{Agg: a, Field: f, F: sf},
+	}
+}
+
+// checkRange checks if value is a number in range r (bounds included).
+// Values which are not numbers are considered to be in any range.
+func checkRange(r *Range, value string) bool {
+	if r == nil {
+		return true
+	}
+	val, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(val) {
+		return true
+	}
+	return r.From <= val && val <= r.To
+}
+
 // Filter returns sublist of bs, satisfying f.
 func (f Filter) Filter(bs ...Bucket) []Bucket {
 	result := make([]Bucket, 0, len(bs))
@@ -184,3 +412,11 @@ func FilterLE(v int64) *SimpleFilter {
 		Args: &SimpleFilter_Value{Value: strconv.FormatInt(v, 10)},
 	}
 }
+
+// FilterRange returns filter, which checks if value is in range from..to (bounds included).
+func FilterRange(from, to float64) *SimpleFilter {
+	return &SimpleFilter{
+		Op:   Operation_RANGE,
+		Args: &SimpleFilter_Range{Range: &Range{From: from, To: to}},
+	}
+}
@@ -4,6 +4,7 @@
 package netmap
 
 import (
+	encoding_binary "encoding/binary"
 	fmt "fmt"
 	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/golang/protobuf/proto"
@@ -26,15 +27,16 @@ const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 type Operation int32
 
 const (
-	Operation_NP  Operation = 0
-	Operation_EQ  Operation = 1
-	Operation_NE  Operation = 2
-	Operation_GT  Operation = 3
-	Operation_GE  Operation = 4
-	Operation_LT  Operation = 5
-	Operation_LE  Operation = 6
-	Operation_OR  Operation = 7
-	Operation_AND Operation = 8
+	Operation_NP    Operation = 0
+	Operation_EQ    Operation = 1
+	Operation_NE    Operation = 2
+	Operation_GT    Operation = 3
+	Operation_GE    Operation = 4
+	Operation_LT    Operation = 5
+	Operation_LE    Operation = 6
+	Operation_OR    Operation = 7
+	Operation_AND   Operation = 8
+	Operation_RANGE Operation = 9
 )
 
 var Operation_name = map[int32]string{
@@ -47,18 +49,20 @@ var Operation_name = map[int32]string{
 	6: "LE",
 	7: "OR",
 	8: "AND",
+	9: "RANGE",
 }
 
 var Operation_value = map[string]int32{
-	"NP":  0,
-	"EQ":  1,
-	"NE":  2,
-	"GT":  3,
-	"GE":  4,
-	"LT":  5,
-	"LE":  6,
-	"OR":  7,
-	"AND": 8,
+	"NP":    0,
+	"EQ":    1,
+	"NE":    2,
+	"GT":    3,
+	"GE":    4,
+	"LT":    5,
+	"LE":    6,
+	"OR":    7,
+	"AND":   8,
+	"RANGE": 9,
 }
 
 func (x Operation) String() string {
@@ -69,6 +73,62 @@ func (Operation) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_e4729c7385e2dd96, []int{0}
 }
 
+type Aggregation int32
+
+const (
+	Aggregation_SUM  Aggregation = 0
+	Aggregation_MEAN Aggregation = 1
+	Aggregation_MIN  Aggregation = 2
+	Aggregation_MAX  Aggregation = 3
+)
+
+var Aggregation_name = map[int32]string{
+	0: "SUM",
+	1: "MEAN",
+	2: "MIN",
+	3: "MAX",
+}
+
+var Aggregation_value = map[string]int32{
+	"SUM":  0,
+	"MEAN": 1,
+	"MIN":  2,
+	"MAX":  3,
+}
+
+func (x Aggregation) String() string {
+	return proto.EnumName(Aggregation_name, int32(x))
+}
+
+func (Aggregation) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e4729c7385e2dd96, []int{1}
+}
+
+type NodeField int32
+
+const (
+	NodeField_CAPACITY NodeField = 0
+	NodeField_PRICE    NodeField = 1
+)
+
+var NodeField_name = map[int32]string{
+	0: "CAPACITY",
+	1: "PRICE",
+}
+
+var NodeField_value = map[string]int32{
+	"CAPACITY": 0,
+	"PRICE":    1,
+}
+
+func (x NodeField) String() string {
+	return proto.EnumName(NodeField_name, int32(x))
+}
+
+func (NodeField) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_e4729c7385e2dd96, []int{2}
+}
+
 type Type int32
 
 const (
@@ -91,7 +151,7 @@ func (x Type) String() string {
 }
 
 func (Type) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_e4729c7385e2dd96, []int{1}
+	return fileDescriptor_e4729c7385e2dd96, []int{3}
 }
 
 type PlacementRule struct {
@@ -150,12 +210,14 @@ func (m *PlacementRule) GetSFGroups() []SFGroup {
 }
 
 type SFGroup struct {
-	Filters              []Filter `protobuf:"bytes,1,rep,name=Filters,proto3" json:"Filters"`
-	Selectors            []Select `protobuf:"bytes,2,rep,name=Selectors,proto3" json:"Selectors"`
-	Exclude              []uint32 `protobuf:"varint,3,rep,packed,name=Exclude,proto3" json:"Exclude,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Filters              []Filter     `protobuf:"bytes,1,rep,name=Filters,proto3" json:"Filters"`
+	Selectors            []Select     `protobuf:"bytes,2,rep,name=Selectors,proto3" json:"Selectors"`
+	Exclude              []uint32     `protobuf:"varint,3,rep,packed,name=Exclude,proto3" json:"Exclude,omitempty"`
+	Subnet               uint32       `protobuf:"varint,4,opt,name=Subnet,proto3" json:"Subnet,omitempty"`
+	NodeFilters          []NodeFilter `protobuf:"bytes,5,rep,name=NodeFilters,proto3" json:"NodeFilters"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
 }
 
 func (m *SFGroup) Reset()         { *m = SFGroup{} }
@@ -212,12 +274,30 @@ func (m *SFGroup) GetExclude() []uint32 {
 	return nil
 }
 
+func (m *SFGroup) GetSubnet() uint32 {
+	if m != nil {
+		return m.Subnet
+	}
+	return 0
+}
+
+func (m *SFGroup) GetNodeFilters() []NodeFilter {
+	if m != nil {
+		return m.NodeFilters
+	}
+	return nil
+}
+
 type Select struct {
-	Count                uint32   `protobuf:"varint,1,opt,name=Count,proto3" json:"Count,omitempty"`
-	Key                  string   `protobuf:"bytes,2,opt,name=Key,proto3" json:"Key,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Count                uint32           `protobuf:"varint,1,opt,name=Count,proto3" json:"Count,omitempty"`
+	Key                  string           `protobuf:"bytes,2,opt,name=Key,proto3" json:"Key,omitempty"`
+	Distinct             string           `protobuf:"bytes,3,opt,name=Distinct,proto3" json:"Distinct,omitempty"`
+	Same                 string           `protobuf:"bytes,4,opt,name=Same,proto3" json:"Same,omitempty"`
+	Aggregate            *AggregateFilter `protobuf:"bytes,5,opt,name=Aggregate,proto3" json:"Aggregate,omitempty"`
+	Percent              uint32           `protobuf:"varint,6,opt,name=Percent,proto3" json:"Percent,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
 }
 
 func (m *Select) Reset()         { *m = Select{} }
@@ -267,6 +347,152 @@ func (m *Select) GetKey() string {
 	return ""
 }
 
+func (m *Select) GetDistinct() string {
+	if m != nil {
+		return m.Distinct
+	}
+	return ""
+}
+
+func (m *Select) GetSame() string {
+	if m != nil {
+		return m.Same
+	}
+	return ""
+}
+
+func (m *Select) GetAggregate() *AggregateFilter {
+	if m != nil {
+		return m.Aggregate
+	}
+	return nil
+}
+
+func (m *Select) GetPercent() uint32 {
+	if m != nil {
+		return m.Percent
+	}
+	return 0
+}
+
+type NodeFilter struct {
+	Field                NodeField     `protobuf:"varint,1,opt,name=Field,proto3,enum=netmap.NodeField" json:"Field,omitempty"`
+	F                    *SimpleFilter `protobuf:"bytes,2,opt,name=F,proto3" json:"F,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *NodeFilter) Reset()         { *m = NodeFilter{} }
+func (m *NodeFilter) String() string { return proto.CompactTextString(m) }
+func (*NodeFilter) ProtoMessage()    {}
+func (*NodeFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e4729c7385e2dd96, []int{3}
+}
+func (m *NodeFilter) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *NodeFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_NodeFilter.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *NodeFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_NodeFilter.Merge(m, src)
+}
+func (m *NodeFilter) XXX_Size() int {
+	return m.Size()
+}
+func (m *NodeFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_NodeFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_NodeFilter proto.InternalMessageInfo
+
+func (m *NodeFilter) GetField() NodeField {
+	if m != nil {
+		return m.Field
+	}
+	return NodeField_CAPACITY
+}
+
+func (m *NodeFilter) GetF() *SimpleFilter {
+	if m != nil {
+		return m.F
+	}
+	return nil
+}
+
+type AggregateFilter struct {
+	Agg                  Aggregation   `protobuf:"varint,1,opt,name=Agg,proto3,enum=netmap.Aggregation" json:"Agg,omitempty"`
+	Field                NodeField     `protobuf:"varint,2,opt,name=Field,proto3,enum=netmap.NodeField" json:"Field,omitempty"`
+	F                    *SimpleFilter `protobuf:"bytes,3,opt,name=F,proto3" json:"F,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *AggregateFilter) Reset()         { *m = AggregateFilter{} }
+func (m *AggregateFilter) String() string { return proto.CompactTextString(m) }
+func (*AggregateFilter) ProtoMessage()    {}
+func (*AggregateFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e4729c7385e2dd96, []int{4}
+}
+func (m *AggregateFilter) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *AggregateFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_AggregateFilter.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *AggregateFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AggregateFilter.Merge(m, src)
+}
+func (m *AggregateFilter) XXX_Size() int {
+	return m.Size()
+}
+func (m *AggregateFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_AggregateFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AggregateFilter proto.InternalMessageInfo
+
+func (m *AggregateFilter) GetAgg() Aggregation {
+	if m != nil {
+		return m.Agg
+	}
+	return Aggregation_SUM
+}
+
+func (m *AggregateFilter) GetField() NodeField {
+	if m != nil {
+		return m.Field
+	}
+	return NodeField_CAPACITY
+}
+
+func (m *AggregateFilter) GetF() *SimpleFilter {
+	if m != nil {
+		return m.F
+	}
+	return nil
+}
+
 type SimpleFilters struct {
 	Filters              []SimpleFilter `protobuf:"bytes,1,rep,name=Filters,proto3" json:"Filters"`
 	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
@@ -278,7 +504,7 @@ func (m *SimpleFilters) Reset()         { *m = SimpleFilters{} }
 func (m *SimpleFilters) String() string { return proto.CompactTextString(m) }
 func (*SimpleFilters) ProtoMessage()    {}
 func (*SimpleFilters) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e4729c7385e2dd96, []int{3}
+	return fileDescriptor_e4729c7385e2dd96, []int{5}
 }
 func (m *SimpleFilters) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -314,11 +540,67 @@ func (m *SimpleFilters) GetFilters() []SimpleFilter {
 	return nil
 }
 
+type Range struct {
+	From                 float64  `protobuf:"fixed64,1,opt,name=From,proto3" json:"From,omitempty"`
+	To                   float64  `protobuf:"fixed64,2,opt,name=To,proto3" json:"To,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Range) Reset()         { *m = Range{} }
+func (m *Range) String() string { return proto.CompactTextString(m) }
+func (*Range) ProtoMessage()    {}
+func (*Range) Descriptor() ([]byte, []int) {
+	return fileDescriptor_e4729c7385e2dd96, []int{6}
+}
+func (m *Range) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *Range) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Range.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *Range) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Range.Merge(m, src)
+}
+func (m *Range) XXX_Size() int {
+	return m.Size()
+}
+func (m *Range) XXX_DiscardUnknown() {
+	xxx_messageInfo_Range.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Range proto.InternalMessageInfo
+
+func (m *Range) GetFrom() float64 {
+	if m != nil {
+		return m.From
+	}
+	return 0
+}
+
+func (m *Range) GetTo() float64 {
+	if m != nil {
+		return m.To
+	}
+	return 0
+}
+
 type SimpleFilter struct {
 	Op Operation `protobuf:"varint,1,opt,name=Op,proto3,enum=netmap.Operation" json:"Op,omitempty"`
 	// Types that are valid to be assigned to Args:
 	//	*SimpleFilter_Value
 	//	*SimpleFilter_FArgs
+	//	*SimpleFilter_Range
 	Args                 isSimpleFilter_Args `protobuf_oneof:"Args"`
 	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
 	XXX_unrecognized     []byte              `json:"-"`
@@ -329,7 +611,7 @@ func (m *SimpleFilter) Reset()         { *m = SimpleFilter{} }
 func (m *SimpleFilter) String() string { return proto.CompactTextString(m) }
 func (*SimpleFilter) ProtoMessage()    {}
 func (*SimpleFilter) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e4729c7385e2dd96, []int{4}
+	return fileDescriptor_e4729c7385e2dd96, []int{7}
 }
 func (m *SimpleFilter) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -370,9 +652,13 @@ type SimpleFilter_Value struct {
 type SimpleFilter_FArgs struct {
 	FArgs *SimpleFilters `protobuf:"bytes,3,opt,name=FArgs,proto3,oneof" json:"FArgs,omitempty"`
 }
+type SimpleFilter_Range struct {
+	Range *Range `protobuf:"bytes,4,opt,name=Range,proto3,oneof" json:"Range,omitempty"`
+}
 
 func (*SimpleFilter_Value) isSimpleFilter_Args() {}
 func (*SimpleFilter_FArgs) isSimpleFilter_Args() {}
+func (*SimpleFilter_Range) isSimpleFilter_Args() {}
 
 func (m *SimpleFilter) GetArgs() isSimpleFilter_Args {
 	if m != nil {
@@ -402,17 +688,26 @@ func (m *SimpleFilter) GetFArgs() *SimpleFilters {
 	return nil
 }
 
+func (m *SimpleFilter) GetRange() *Range {
+	if x, ok := m.GetArgs().(*SimpleFilter_Range); ok {
+		return x.Range
+	}
+	return nil
+}
+
 // XXX_OneofWrappers is for the internal use of the proto package.
 func (*SimpleFilter) XXX_OneofWrappers() []interface{} {
 	return []interface{}{
 		(*SimpleFilter_Value)(nil),
 		(*SimpleFilter_FArgs)(nil),
+		(*SimpleFilter_Range)(nil),
 	}
 }
 
 type Filter struct {
 	Key                  string        `protobuf:"bytes,1,opt,name=Key,proto3" json:"Key,omitempty"`
 	F                    *SimpleFilter `protobuf:"bytes,2,opt,name=F,proto3" json:"F,omitempty"`
+	Sub                  *SFGroup      `protobuf:"bytes,3,opt,name=Sub,proto3" json:"Sub,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
 	XXX_unrecognized     []byte        `json:"-"`
 	XXX_sizecache        int32         `json:"-"`
@@ -422,7 +717,7 @@ func (m *Filter) Reset()         { *m = Filter{} }
 func (m *Filter) String() string { return proto.CompactTextString(m) }
 func (*Filter) ProtoMessage()    {}
 func (*Filter) Descriptor() ([]byte, []int) {
-	return fileDescriptor_e4729c7385e2dd96, []int{5}
+	return fileDescriptor_e4729c7385e2dd96, []int{8}
 }
 func (m *Filter) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -465,13 +760,25 @@ func (m *Filter) GetF() *SimpleFilter {
 	return nil
 }
 
+func (m *Filter) GetSub() *SFGroup {
+	if m != nil {
+		return m.Sub
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("netmap.Operation", Operation_name, Operation_value)
+	proto.RegisterEnum("netmap.Aggregation", Aggregation_name, Aggregation_value)
+	proto.RegisterEnum("netmap.NodeField", NodeField_name, NodeField_value)
 	proto.RegisterEnum("netmap.Type", Type_name, Type_value)
 	proto.RegisterType((*PlacementRule)(nil), "netmap.PlacementRule")
 	proto.RegisterType((*SFGroup)(nil), "netmap.SFGroup")
 	proto.RegisterType((*Select)(nil), "netmap.Select")
+	proto.RegisterType((*NodeFilter)(nil), "netmap.NodeFilter")
+	proto.RegisterType((*AggregateFilter)(nil), "netmap.AggregateFilter")
 	proto.RegisterType((*SimpleFilters)(nil), "netmap.SimpleFilters")
+	proto.RegisterType((*Range)(nil), "netmap.Range")
 	proto.RegisterType((*SimpleFilter)(nil), "netmap.SimpleFilter")
 	proto.RegisterType((*Filter)(nil), "netmap.Filter")
 }
@@ -479,36 +786,53 @@ func init() {
 func init() { proto.RegisterFile("selector.proto", fileDescriptor_e4729c7385e2dd96) }
 
 var fileDescriptor_e4729c7385e2dd96 = []byte{
-	// 464 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x52, 0xcd, 0x6e, 0xd3, 0x40,
-	0x18, 0xf4, 0xda, 0x89, 0xdd, 0x7c, 0x21, 0x61, 0x59, 0x15, 0x64, 0x71, 0x70, 0x8d, 0x4f, 0x56,
-	0xa5, 0xba, 0x10, 0x38, 0x23, 0x35, 0x60, 0x17, 0x04, 0x6a, 0xca, 0x26, 0xe2, 0xee, 0x84, 0xc5,
-	0x58, 0x72, 0xbc, 0x2b, 0x7b, 0x2d, 0xd1, 0x1b, 0x37, 0x5e, 0x81, 0x47, 0xea, 0x91, 0x27, 0x40,
-	0x28, 0xbc, 0x08, 0xf2, 0xfa, 0x87, 0x28, 0xa2, 0xa7, 0xf1, 0x7c, 0xdf, 0xcc, 0xce, 0x37, 0x92,
-	0x61, 0x5a, 0xb2, 0x8c, 0x6d, 0x24, 0x2f, 0x02, 0x51, 0x70, 0xc9, 0x89, 0x99, 0x33, 0xb9, 0x8d,
-	0xc5, 0xe3, 0xb3, 0x24, 0x95, 0x5f, 0xaa, 0x75, 0xb0, 0xe1, 0xdb, 0xf3, 0x84, 0x27, 0xfc, 0x5c,
-	0xad, 0xd7, 0xd5, 0x67, 0xc5, 0x14, 0x51, 0x5f, 0x8d, 0xcd, 0x5b, 0xc3, 0xe4, 0x3a, 0x8b, 0x37,
-	0x6c, 0xcb, 0x72, 0x49, 0xab, 0x8c, 0x11, 0x07, 0x80, 0x32, 0x91, 0x45, 0x71, 0xfd, 0xb6, 0x8d,
-	0x5c, 0xe4, 0x4f, 0xe8, 0xde, 0x84, 0x3c, 0x83, 0xa3, 0x65, 0x74, 0x59, 0xf0, 0x4a, 0x94, 0xb6,
-	0xee, 0x1a, 0xfe, 0x78, 0x76, 0x3f, 0x68, 0xa2, 0x83, 0x76, 0x3e, 0x1f, 0xdc, 0xfe, 0x3a, 0xd1,
-	0x68, 0x2f, 0xf3, 0xbe, 0x23, 0xb0, 0x5a, 0x42, 0x02, 0xb0, 0xa2, 0x34, 0x93, 0xac, 0x28, 0x6d,
-	0xa4, 0xdc, 0xd3, 0xce, 0xdd, 0x8c, 0x5b, 0x73, 0x27, 0x22, 0x33, 0x18, 0x2d, 0xdb, 0xa2, 0x5d,
-	0x5e, 0xef, 0x68, 0x16, 0xad, 0xe3, 0x9f, 0x8c, 0xd8, 0x60, 0x85, 0x5f, 0x37, 0x59, 0xf5, 0x89,
-	0xd9, 0x86, 0x6b, 0xf8, 0x13, 0xda, 0x51, 0xef, 0x29, 0x98, 0x8d, 0x8c, 0x1c, 0xc3, 0xf0, 0x15,
-	0xaf, 0x72, 0xd9, 0x36, 0x6c, 0x08, 0xc1, 0x60, 0xbc, 0x63, 0x37, 0xb6, 0xee, 0x22, 0x7f, 0x44,
-	0xeb, 0x4f, 0x2f, 0x84, 0xc9, 0x32, 0xdd, 0x8a, 0x8c, 0x75, 0x07, 0xbd, 0x38, 0x2c, 0x70, 0xdc,
-	0x9f, 0xb3, 0xa7, 0x3b, 0xa8, 0xe1, 0x7d, 0x43, 0x70, 0x6f, 0x7f, 0x4f, 0x9e, 0x80, 0xbe, 0x10,
-	0x2a, 0x7c, 0x3a, 0x7b, 0xd0, 0xbd, 0xb0, 0x10, 0xac, 0x88, 0x65, 0xca, 0x73, 0xaa, 0x2f, 0x04,
-	0x79, 0x04, 0xc3, 0x8f, 0x71, 0x56, 0xb1, 0xe6, 0x9c, 0x37, 0x1a, 0x6d, 0x28, 0x39, 0x83, 0x61,
-	0x74, 0x51, 0x24, 0xa5, 0x6d, 0xb8, 0xc8, 0x1f, 0xcf, 0x1e, 0xfe, 0x2f, 0xbf, 0xac, 0xe5, 0x4a,
-	0x35, 0x37, 0x61, 0x50, 0xa3, 0xf7, 0x12, 0xcc, 0x36, 0xbb, 0x6d, 0x89, 0xfa, 0x96, 0xc4, 0x03,
-	0x14, 0xa9, 0x98, 0x3b, 0xea, 0x50, 0x14, 0x9d, 0xae, 0x60, 0xd4, 0xdf, 0x47, 0x4c, 0xd0, 0xaf,
-	0xae, 0xb1, 0x56, 0x63, 0xf8, 0x01, 0x23, 0xc5, 0x43, 0xac, 0xd7, 0x78, 0xb9, 0xc2, 0x86, 0xc2,
-	0x10, 0x0f, 0x6a, 0x7c, 0xbf, 0xc2, 0x43, 0x85, 0x21, 0x36, 0x6b, 0x5c, 0x50, 0x6c, 0x11, 0x0b,
-	0x8c, 0x8b, 0xab, 0xd7, 0xf8, 0xe8, 0xf4, 0x04, 0x06, 0xab, 0x1b, 0xc1, 0x08, 0x80, 0xb9, 0x94,
-	0x45, 0x9a, 0x27, 0x58, 0x23, 0x63, 0xb0, 0xde, 0xe6, 0x92, 0x25, 0xac, 0xc0, 0x68, 0x8e, 0x6f,
-	0x77, 0x0e, 0xfa, 0xb9, 0x73, 0xd0, 0xef, 0x9d, 0x83, 0x7e, 0xfc, 0x71, 0xb4, 0xb5, 0xa9, 0xfe,
-	0xdc, 0xe7, 0x7f, 0x03, 0x00, 0x00, 0xff, 0xff, 0xe7, 0x59, 0x33, 0x15, 0x02, 0x03, 0x00, 0x00,
+	// 734 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xc1, 0x8e, 0xe3, 0x44,
+	0x10, 0x4d, 0xdb, 0xb1, 0x13, 0x57, 0x36, 0xd9, 0xa6, 0x58, 0x16, 0x6b, 0x0f, 0xd9, 0x8c, 0xc5,
+	0x88, 0x28, 0x68, 0x32, 0x22, 0xc0, 0x85, 0x9b, 0x27, 0x63, 0x0f, 0x11, 0x4c, 0x12, 0x3a, 0x01,
+	0x31, 0x27, 0xe4, 0x24, 0x8d, 0xb1, 0xe4, 0xd8, 0x96, 0x63, 0x4b, 0xcc, 0x07, 0xf0, 0x0f, 0xfc,
+	0x01, 0x67, 0xfe, 0x62, 0x8e, 0xdc, 0x91, 0x10, 0x1a, 0x7e, 0x04, 0x75, 0xdb, 0x4e, 0x42, 0x34,
+	0x5a, 0xcd, 0xa9, 0xea, 0x55, 0xbd, 0xea, 0x7a, 0xd5, 0x5d, 0x6a, 0xe8, 0xec, 0x78, 0xc8, 0xd7,
+	0x59, 0x9c, 0x0e, 0x93, 0x34, 0xce, 0x62, 0xd4, 0x23, 0x9e, 0x6d, 0xbd, 0xe4, 0xcd, 0x85, 0x1f,
+	0x64, 0x3f, 0xe7, 0xab, 0xe1, 0x3a, 0xde, 0x5e, 0xfa, 0xb1, 0x1f, 0x5f, 0xca, 0xf4, 0x2a, 0xff,
+	0x49, 0x22, 0x09, 0xa4, 0x57, 0x94, 0x59, 0x2b, 0x68, 0xcf, 0x43, 0x6f, 0xcd, 0xb7, 0x3c, 0xca,
+	0x58, 0x1e, 0x72, 0xec, 0x02, 0x30, 0x9e, 0x84, 0xae, 0x27, 0xce, 0x36, 0x49, 0x8f, 0xf4, 0xdb,
+	0xec, 0x28, 0x82, 0x9f, 0x42, 0x73, 0xe1, 0xde, 0xa4, 0x71, 0x9e, 0xec, 0x4c, 0xa5, 0xa7, 0xf6,
+	0x5b, 0xa3, 0x97, 0xc3, 0xa2, 0xf5, 0xb0, 0x8c, 0x5f, 0xd5, 0x1f, 0xfe, 0x7e, 0x5b, 0x63, 0x7b,
+	0x9a, 0xf5, 0x17, 0x81, 0x46, 0x09, 0x70, 0x08, 0x0d, 0x37, 0x08, 0x33, 0x9e, 0xee, 0x4c, 0x22,
+	0xab, 0x3b, 0x55, 0x75, 0x11, 0x2e, 0x8b, 0x2b, 0x12, 0x8e, 0xc0, 0x58, 0x94, 0x83, 0x56, 0xfd,
+	0xf6, 0x15, 0x45, 0xa2, 0xac, 0x38, 0xd0, 0xd0, 0x84, 0x86, 0xf3, 0xcb, 0x3a, 0xcc, 0x37, 0xdc,
+	0x54, 0x7b, 0x6a, 0xbf, 0xcd, 0x2a, 0x88, 0xaf, 0x41, 0x5f, 0xe4, 0xab, 0x88, 0x67, 0x66, 0x5d,
+	0x0e, 0x56, 0x22, 0xfc, 0x12, 0x5a, 0xd3, 0x78, 0xc3, 0x2b, 0x65, 0x9a, 0xec, 0x83, 0x55, 0x9f,
+	0x43, 0xaa, 0xec, 0x75, 0x4c, 0xb6, 0xfe, 0x20, 0xa0, 0x17, 0xbd, 0xf1, 0x15, 0x68, 0xe3, 0x38,
+	0x8f, 0xb2, 0xf2, 0xda, 0x0a, 0x80, 0x14, 0xd4, 0xaf, 0xf9, 0xbd, 0xa9, 0xf4, 0x48, 0xdf, 0x60,
+	0xc2, 0xc5, 0x37, 0xd0, 0xbc, 0x0e, 0x76, 0x59, 0x10, 0xad, 0x33, 0x53, 0x95, 0xe1, 0x3d, 0x46,
+	0x84, 0xfa, 0xc2, 0xdb, 0x72, 0x29, 0xd0, 0x60, 0xd2, 0xc7, 0x2f, 0xc0, 0xb0, 0x7d, 0x3f, 0xe5,
+	0xbe, 0x97, 0x71, 0x53, 0xeb, 0x91, 0x7e, 0x6b, 0xf4, 0x61, 0x25, 0x6e, 0x9f, 0x28, 0xf4, 0xb0,
+	0x03, 0x53, 0xdc, 0xc3, 0x9c, 0xa7, 0x6b, 0x1e, 0x65, 0xa6, 0x2e, 0x05, 0x55, 0xd0, 0xba, 0x03,
+	0x38, 0x8c, 0x80, 0x1f, 0x83, 0xe6, 0x06, 0x3c, 0xdc, 0x48, 0xd9, 0x9d, 0xd1, 0x7b, 0xff, 0x9f,
+	0x9b, 0x87, 0x1b, 0x56, 0xe4, 0xd1, 0x02, 0xe2, 0xca, 0x39, 0x5a, 0xa3, 0x57, 0xfb, 0x47, 0x08,
+	0xb6, 0x49, 0x58, 0x35, 0x27, 0xae, 0xf5, 0x2b, 0x81, 0x97, 0x27, 0x9a, 0xf0, 0x1c, 0x54, 0xdb,
+	0xf7, 0xcb, 0xe3, 0xdf, 0x3f, 0x55, 0x1e, 0xc4, 0x11, 0x13, 0xf9, 0x83, 0x0e, 0xe5, 0x39, 0x3a,
+	0xd4, 0x77, 0xeb, 0x70, 0xa0, 0x7d, 0x1c, 0xda, 0xe1, 0xe7, 0xa7, 0x9b, 0xf7, 0x64, 0xe9, 0xc9,
+	0xfe, 0x59, 0x9f, 0x80, 0xc6, 0xbc, 0xc8, 0xe7, 0xe2, 0x5d, 0xdc, 0x34, 0xde, 0xca, 0x21, 0x08,
+	0x93, 0x3e, 0x76, 0x40, 0x59, 0xc6, 0x52, 0x2d, 0x61, 0xca, 0x32, 0xb6, 0x7e, 0x27, 0xf0, 0xe2,
+	0xf8, 0x30, 0x3c, 0x03, 0x65, 0x96, 0x9c, 0x5e, 0xeb, 0x2c, 0xe1, 0x69, 0x31, 0xb5, 0x32, 0x4b,
+	0xf0, 0x35, 0x68, 0xdf, 0x7b, 0x61, 0xce, 0x8b, 0xfd, 0xf8, 0xaa, 0xc6, 0x0a, 0x88, 0x17, 0xa0,
+	0xb9, 0x76, 0xea, 0xef, 0xca, 0x39, 0x3f, 0x78, 0x4a, 0xec, 0x4e, 0xd0, 0x25, 0x0b, 0xcf, 0x4b,
+	0x9d, 0x72, 0x6f, 0x5a, 0xa3, 0x76, 0x45, 0x97, 0x41, 0x41, 0x93, 0xce, 0x95, 0x0e, 0x75, 0x41,
+	0xb7, 0x3c, 0xd0, 0x4b, 0x89, 0xe5, 0x76, 0x92, 0xc3, 0x76, 0x3e, 0xe3, 0x95, 0xf1, 0x0c, 0xd4,
+	0x45, 0xbe, 0x2a, 0xb5, 0x9d, 0x7e, 0x00, 0x4c, 0xe4, 0x06, 0x3f, 0x82, 0xb1, 0x9f, 0x14, 0x75,
+	0x50, 0xa6, 0x73, 0x5a, 0x13, 0xd6, 0xf9, 0x96, 0x12, 0x89, 0x1d, 0xaa, 0x08, 0x7b, 0xb3, 0xa4,
+	0xaa, 0xb4, 0x0e, 0xad, 0x0b, 0xfb, 0xcd, 0x92, 0x6a, 0xd2, 0x3a, 0x54, 0x17, 0x76, 0xc6, 0x68,
+	0x03, 0x1b, 0xa0, 0xda, 0xd3, 0x6b, 0xda, 0x44, 0x03, 0x34, 0x66, 0x4f, 0x6f, 0x1c, 0x6a, 0x0c,
+	0x46, 0xd0, 0x3a, 0x5a, 0x21, 0x41, 0x59, 0x7c, 0x77, 0x4b, 0x6b, 0xd8, 0x84, 0xfa, 0xad, 0x63,
+	0x4f, 0x29, 0x11, 0xa1, 0xdb, 0xc9, 0x94, 0x2a, 0xd2, 0xb1, 0x7f, 0xa0, 0xea, 0xe0, 0x23, 0x30,
+	0xf6, 0xdb, 0x84, 0x2f, 0xa0, 0x39, 0xb6, 0xe7, 0xf6, 0x78, 0xb2, 0xbc, 0xa3, 0x35, 0x71, 0xf2,
+	0x9c, 0x4d, 0xc6, 0x0e, 0x25, 0x83, 0xb7, 0x50, 0x5f, 0xde, 0x27, 0x1c, 0x01, 0xf4, 0x45, 0x96,
+	0x06, 0x91, 0x4f, 0x6b, 0xd8, 0x82, 0xc6, 0x24, 0xca, 0xb8, 0xcf, 0x53, 0x4a, 0xae, 0xe8, 0xc3,
+	0x63, 0x97, 0xfc, 0xf9, 0xd8, 0x25, 0xff, 0x3c, 0x76, 0xc9, 0x6f, 0xff, 0x76, 0x6b, 0x2b, 0x5d,
+	0x7e, 0xa7, 0x9f, 0xfd, 0x37, 0x00, 0x9d, 0x48, 0x5e, 0xd1, 0x97, 0x05, 0x00, 0x00,
 }
 
 func (m *PlacementRule) Marshal() (dAtA []byte, err error) {
@@ -581,6 +905,25 @@ func (m *SFGroup) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if len(m.NodeFilters) > 0 {
+		for iNdEx := len(m.NodeFilters) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.NodeFilters[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintSelector(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x2a
+		}
+	}
+	if m.Subnet != 0 {
+		i = encodeVarintSelector(dAtA, i, uint64(m.Subnet))
+		i--
+		dAtA[i] = 0x20
+	}
 	if len(m.Exclude) > 0 {
 		dAtA2 := make([]byte, len(m.Exclude)*10)
 		var j1 int
@@ -654,6 +997,37 @@ func (m *Select) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.Percent != 0 {
+		i = encodeVarintSelector(dAtA, i, uint64(m.Percent))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.Aggregate != nil {
+		{
+			size, err := m.Aggregate.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintSelector(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Same) > 0 {
+		i -= len(m.Same)
+		copy(dAtA[i:], m.Same)
+		i = encodeVarintSelector(dAtA, i, uint64(len(m.Same)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.Distinct) > 0 {
+		i -= len(m.Distinct)
+		copy(dAtA[i:], m.Distinct)
+		i = encodeVarintSelector(dAtA, i, uint64(len(m.Distinct)))
+		i--
+		dAtA[i] = 0x1a
+	}
 	if len(m.Key) > 0 {
 		i -= len(m.Key)
 		copy(dAtA[i:], m.Key)
@@ -669,7 +1043,7 @@ func (m *Select) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *SimpleFilters) Marshal() (dAtA []byte, err error) {
+func (m *NodeFilter) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -679,12 +1053,12 @@ func (m *SimpleFilters) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *SimpleFilters) MarshalTo(dAtA []byte) (int, error) {
+func (m *NodeFilter) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *SimpleFilters) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *NodeFilter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -693,24 +1067,27 @@ func (m *SimpleFilters) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if len(m.Filters) > 0 {
-		for iNdEx := len(m.Filters) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Filters[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintSelector(dAtA, i, uint64(size))
+	if m.F != nil {
+		{
+			size, err := m.F.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
 			}
-			i--
-			dAtA[i] = 0xa
+			i -= size
+			i = encodeVarintSelector(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Field != 0 {
+		i = encodeVarintSelector(dAtA, i, uint64(m.Field))
+		i--
+		dAtA[i] = 0x8
 	}
 	return len(dAtA) - i, nil
 }
 
-func (m *SimpleFilter) Marshal() (dAtA []byte, err error) {
+func (m *AggregateFilter) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -720,12 +1097,12 @@ func (m *SimpleFilter) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *SimpleFilter) MarshalTo(dAtA []byte) (int, error) {
+func (m *AggregateFilter) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *SimpleFilter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *AggregateFilter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -734,17 +1111,146 @@ func (m *SimpleFilter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.Args != nil {
+	if m.F != nil {
 		{
-			size := m.Args.Size()
-			i -= size
-			if _, err := m.Args.MarshalTo(dAtA[i:]); err != nil {
+			size, err := m.F.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
 				return 0, err
 			}
+			i -= size
+			i = encodeVarintSelector(dAtA, i, uint64(size))
 		}
-	}
-	if m.Op != 0 {
-		i = encodeVarintSelector(dAtA, i, uint64(m.Op))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if m.Field != 0 {
+		i = encodeVarintSelector(dAtA, i, uint64(m.Field))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.Agg != 0 {
+		i = encodeVarintSelector(dAtA, i, uint64(m.Agg))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SimpleFilters) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SimpleFilters) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SimpleFilters) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if len(m.Filters) > 0 {
+		for iNdEx := len(m.Filters) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Filters[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintSelector(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Range) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Range) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Range) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.To != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.To))))
+		i--
+		dAtA[i] = 0x11
+	}
+	if m.From != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(math.Float64bits(float64(m.From))))
+		i--
+		dAtA[i] = 0x9
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SimpleFilter) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SimpleFilter) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SimpleFilter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.Args != nil {
+		{
+			size := m.Args.Size()
+			i -= size
+			if _, err := m.Args.MarshalTo(dAtA[i:]); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if m.Op != 0 {
+		i = encodeVarintSelector(dAtA, i, uint64(m.Op))
 		i--
 		dAtA[i] = 0x8
 	}
@@ -786,6 +1292,27 @@ func (m *SimpleFilter_FArgs) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	}
 	return len(dAtA) - i, nil
 }
+func (m *SimpleFilter_Range) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SimpleFilter_Range) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	if m.Range != nil {
+		{
+			size, err := m.Range.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintSelector(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x22
+	}
+	return len(dAtA) - i, nil
+}
 func (m *Filter) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -810,6 +1337,18 @@ func (m *Filter) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.Sub != nil {
+		{
+			size, err := m.Sub.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintSelector(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
+	}
 	if m.F != nil {
 		{
 			size, err := m.F.MarshalToSizedBuffer(dAtA[:i])
@@ -889,6 +1428,15 @@ func (m *SFGroup) Size() (n int) {
 		}
 		n += 1 + sovSelector(uint64(l)) + l
 	}
+	if m.Subnet != 0 {
+		n += 1 + sovSelector(uint64(m.Subnet))
+	}
+	if len(m.NodeFilters) > 0 {
+		for _, e := range m.NodeFilters {
+			l = e.Size()
+			n += 1 + l + sovSelector(uint64(l))
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -908,6 +1456,62 @@ func (m *Select) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovSelector(uint64(l))
 	}
+	l = len(m.Distinct)
+	if l > 0 {
+		n += 1 + l + sovSelector(uint64(l))
+	}
+	l = len(m.Same)
+	if l > 0 {
+		n += 1 + l + sovSelector(uint64(l))
+	}
+	if m.Aggregate != nil {
+		l = m.Aggregate.Size()
+		n += 1 + l + sovSelector(uint64(l))
+	}
+	if m.Percent != 0 {
+		n += 1 + sovSelector(uint64(m.Percent))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *NodeFilter) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Field != 0 {
+		n += 1 + sovSelector(uint64(m.Field))
+	}
+	if m.F != nil {
+		l = m.F.Size()
+		n += 1 + l + sovSelector(uint64(l))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
+func (m *AggregateFilter) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Agg != 0 {
+		n += 1 + sovSelector(uint64(m.Agg))
+	}
+	if m.Field != 0 {
+		n += 1 + sovSelector(uint64(m.Field))
+	}
+	if m.F != nil {
+		l = m.F.Size()
+		n += 1 + l + sovSelector(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -932,6 +1536,24 @@ func (m *SimpleFilters) Size() (n int) {
 	return n
 }
 
+func (m *Range) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.From != 0 {
+		n += 9
+	}
+	if m.To != 0 {
+		n += 9
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
 func (m *SimpleFilter) Size() (n int) {
 	if m == nil {
 		return 0
@@ -972,6 +1594,18 @@ func (m *SimpleFilter_FArgs) Size() (n int) {
 	}
 	return n
 }
+func (m *SimpleFilter_Range) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Range != nil {
+		l = m.Range.Size()
+		n += 1 + l + sovSelector(uint64(l))
+	}
+	return n
+}
 func (m *Filter) Size() (n int) {
 	if m == nil {
 		return 0
@@ -986,6 +1620,10 @@ func (m *Filter) Size() (n int) {
 		l = m.F.Size()
 		n += 1 + l + sovSelector(uint64(l))
 	}
+	if m.Sub != nil {
+		l = m.Sub.Size()
+		n += 1 + l + sovSelector(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1278,19 +1916,72 @@ func (m *SFGroup) Unmarshal(dAtA []byte) error {
 			} else {
 				return fmt.Errorf("proto: wrong wireType = %d for field Exclude", wireType)
 			}
-		default:
-			iNdEx = preIndex
-			skippy, err := skipSelector(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Subnet", wireType)
 			}
-			if skippy < 0 {
+			m.Subnet = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Subnet |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NodeFilters", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
 				return ErrInvalidLengthSelector
 			}
-			if (iNdEx + skippy) < 0 {
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
 				return ErrInvalidLengthSelector
 			}
-			if (iNdEx + skippy) > l {
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NodeFilters = append(m.NodeFilters, NodeFilter{})
+			if err := m.NodeFilters[len(m.NodeFilters)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipSelector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
 			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
@@ -1383,6 +2074,362 @@ func (m *Select) Unmarshal(dAtA []byte) error {
 			}
 			m.Key = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Distinct", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Distinct = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Same", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Same = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Aggregate", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Aggregate == nil {
+				m.Aggregate = &AggregateFilter{}
+			}
+			if err := m.Aggregate.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Percent", wireType)
+			}
+			m.Percent = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Percent |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipSelector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *NodeFilter) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowSelector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: NodeFilter: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: NodeFilter: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Field", wireType)
+			}
+			m.Field = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Field |= NodeField(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field F", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.F == nil {
+				m.F = &SimpleFilter{}
+			}
+			if err := m.F.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipSelector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *AggregateFilter) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowSelector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: AggregateFilter: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: AggregateFilter: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Agg", wireType)
+			}
+			m.Agg = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Agg |= Aggregation(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Field", wireType)
+			}
+			m.Field = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Field |= NodeField(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field F", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.F == nil {
+				m.F = &SimpleFilter{}
+			}
+			if err := m.F.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSelector(dAtA[iNdEx:])
@@ -1496,6 +2543,82 @@ func (m *SimpleFilters) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *Range) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowSelector
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Range: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Range: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field From", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.From = float64(math.Float64frombits(v))
+		case 2:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field To", wireType)
+			}
+			var v uint64
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			v = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
+			m.To = float64(math.Float64frombits(v))
+		default:
+			iNdEx = preIndex
+			skippy, err := skipSelector(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func (m *SimpleFilter) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
@@ -1611,6 +2734,41 @@ func (m *SimpleFilter) Unmarshal(dAtA []byte) error {
 			}
 			m.Args = &SimpleFilter_FArgs{v}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Range", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &Range{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Args = &SimpleFilter_Range{v}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSelector(dAtA[iNdEx:])
@@ -1733,6 +2891,42 @@ func (m *Filter) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Sub", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowSelector
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthSelector
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthSelector
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Sub == nil {
+				m.Sub = &SFGroup{}
+			}
+			if err := m.Sub.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipSelector(dAtA[iNdEx:])
@@ -105,3 +105,86 @@ func TestFilterLE(t *testing.T) {
 	require.False(t, f.Check("0"))
 	require.True(t, f.Check("nan"))
 }
+
+func TestFilterRange(t *testing.T) {
+	var f *SimpleFilter
+
+	f = FilterRange(1, 10)
+	require.False(t, f.Check("0"))
+	require.True(t, f.Check("1"))
+	require.True(t, f.Check("5"))
+	require.True(t, f.Check("10"))
+	require.False(t, f.Check("11"))
+	require.True(t, f.Check("nan"))
+
+	f = FilterRange(0.5, 0.8)
+	require.False(t, f.Check("0.4"))
+	require.True(t, f.Check("0.5"))
+	require.True(t, f.Check("0.7"))
+	require.False(t, f.Check("0.9"))
+	require.False(t, f.Check("1"))
+
+	f = FilterRange(10, 1)
+	require.False(t, f.Check("5"))
+}
+
+func TestSimpleFilter_CheckNumber(t *testing.T) {
+	require.True(t, FilterGE(10).CheckNumber(10))
+	require.False(t, FilterGT(10).CheckNumber(10))
+	require.True(t, FilterLT(1).CheckNumber(0.5))
+	require.True(t, FilterEQ("2.5").CheckNumber(2.5))
+	require.True(t, FilterRange(0.5, 0.8).CheckNumber(0.6))
+	require.False(t, FilterRange(0.5, 0.8).CheckNumber(1))
+	require.True(t, FilterOR(FilterLT(1), FilterGT(5)).CheckNumber(6))
+	require.False(t, FilterAND(FilterGT(1), FilterLT(5)).CheckNumber(6))
+	require.True(t, FilterEQ("nan").CheckNumber(1))
+}
+
+func TestAggregateFilter_Check(t *testing.T) {
+	ns := Nodes{{N: 1, C: 10, P: 3}, {N: 2, C: 20, P: 1}}
+
+	af := AggregateFilter{Agg: Aggregation_SUM, Field: NodeField_CAPACITY, F: FilterEQ("30")}
+	require.True(t, af.Check(ns))
+
+	af = AggregateFilter{Agg: Aggregation_MEAN, Field: NodeField_PRICE, F: FilterEQ("2")}
+	require.True(t, af.Check(ns))
+
+	af = AggregateFilter{Agg: Aggregation_MIN, Field: NodeField_PRICE, F: FilterGT(1)}
+	require.False(t, af.Check(ns))
+
+	require.True(t, AggregateFilter{}.Check(ns))
+}
+
+func TestNodeFilter_Check(t *testing.T) {
+	n := Node{N: 1, C: 10, P: 3}
+
+	require.True(t, MinCapacity(10).Check(n))
+	require.False(t, MinCapacity(11).Check(n))
+	require.True(t, MaxPrice(3).Check(n))
+	require.False(t, MaxPrice(2).Check(n))
+	require.True(t, NodeFilter{}.Check(n))
+
+	f, err := NewNodeFilter(NodeField_PRICE, FilterLE(3))
+	require.NoError(t, err)
+	require.True(t, f.Check(n))
+
+	_, err = NewNodeFilter(NodeField_CAPACITY, NewFilter(Operation_GE, "many"))
+	require.Error(t, err)
+	_, err = NewNodeFilter(NodeField_CAPACITY, FilterOR(FilterGE(1), NewFilter(Operation_EQ, "NaN")))
+	require.Error(t, err)
+}
+
+func TestSelect_CountOf(t *testing.T) {
+	require.Equal(t, 3, Select{Count: 3}.CountOf(10))
+	require.Equal(t, 3, PercentSelect(30, "City", 0).CountOf(10))
+	require.Equal(t, 3, PercentSelect(30, "City", 0).CountOf(11))
+	require.Equal(t, 1, PercentSelect(30, "City", 0).CountOf(2))
+	require.Equal(t, 2, PercentSelect(30, "City", 2).CountOf(2))
+	require.Equal(t, 10, PercentSelect(100, "City", 0).CountOf(10))
+}
+
+func TestAllSelect(t *testing.T) {
+	s := AllSelect("City")
+	require.Equal(t, 10, s.CountOf(10))
+	require.Equal(t, 1, s.CountOf(0))
+}
@@ -0,0 +1,317 @@
+package netmap
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/nspcc-dev/hrw"
+)
+
+type (
+	// Shuffler defines the order in which buckets and nodes are tried
+	// during selection.
+	Shuffler interface {
+		// Order returns permutation of candidates with specified hashes.
+		// Weights are nil if candidates are not weighted.
+		Order(hashes []uint64, weights []float64) []int
+	}
+
+	hrwShuffler struct {
+		hash uint64
+	}
+
+	randShuffler struct {
+		r *rand.Rand
+	}
+
+	jumpShuffler struct {
+		hash uint64
+	}
+)
+
+// uniformSource is implemented by shufflers which provide pseudo-random
+// number in (0, 1) for every candidate, it is used for proportional ordering.
+type uniformSource interface {
+	uniform(hashes []uint64) []float64
+}
+
+// sortedSource is implemented by shufflers which order candidates by their
+// names (bucket names, node indices or attribute values) instead of hashes,
+// less reports whether candidate i goes before candidate j.
+type sortedSource interface {
+	orderSorted(n int, less func(i, j int) bool) []int
+}
+
+var (
+	_ Shuffler = (*hrwShuffler)(nil)
+	_ Shuffler = (*randShuffler)(nil)
+	_ Shuffler = (*jumpShuffler)(nil)
+
+	_ uniformSource = (*hrwShuffler)(nil)
+	_ uniformSource = (*randShuffler)(nil)
+)
+
+// NewHRWShuffler returns Shuffler which orders candidates
+// using rendezvous hashing with pivot. It is used by default.
+func NewHRWShuffler(pivot []byte) Shuffler {
+	return &hrwShuffler{hash: hrw.Hash(pivot)}
+}
+
+// NewRandShuffler returns Shuffler which orders candidates randomly using r.
+// Resulting Shuffler is not safe for concurrent use.
+func NewRandShuffler(r *rand.Rand) Shuffler {
+	return &randShuffler{r: r}
+}
+
+// NewJumpShuffler returns Shuffler which orders candidates using jump
+// consistent hash of pivot. Candidates are numbered in the order of their
+// hashes, so that when a candidate is added after all existing ones only
+// the share of pivots it receives is remapped. It needs no hashing per
+// candidate and thus is cheaper than rendezvous hashing for large node sets,
+// but weights are ignored and removal of a candidate remaps more pivots.
+func NewJumpShuffler(pivot []byte) Shuffler {
+	return &jumpShuffler{hash: hrw.Hash(pivot)}
+}
+
+// WithJumpHash returns option which makes selection order candidates
+// by NewJumpShuffler of the pivot instead of rendezvous hashing.
+// It is the same as WithStrategy(StrategyJumpHash).
+func WithJumpHash() SelectOption {
+	return WithStrategy(StrategyJumpHash)
+}
+
+// Seed derives seed for pseudo-random generator from id, so that all clients
+// get the same order of candidates for the same object. First 8 bytes of
+// SHA-256 hash of id are used.
+func Seed(id []byte) int64 {
+	h := sha256.Sum256(id)
+	return int64(binary.BigEndian.Uint64(h[:8]))
+}
+
+// SeedFromBytes returns pseudo-random generator seeded with Seed(id).
+func SeedFromBytes(id []byte) *rand.Rand {
+	return rand.New(rand.NewSource(Seed(id)))
+}
+
+// SeedFromIDs returns pseudo-random generator seeded with hash of all ids,
+// e.g. container and object identifiers. Every id is prefixed with its
+// length, so different splits of the same bytes produce different seeds.
+func SeedFromIDs(ids ...[]byte) *rand.Rand {
+	var (
+		buf []byte
+		ln  [4]byte
+	)
+	for _, id := range ids {
+		binary.BigEndian.PutUint32(ln[:], uint32(len(id)))
+		buf = append(buf, ln[:]...)
+		buf = append(buf, id...)
+	}
+	return SeedFromBytes(buf)
+}
+
+// WithShuffler returns option which makes selection use s
+// instead of the pivot for ordering candidates. It takes
+// precedence over the Strategy.
+func WithShuffler(s Shuffler) SelectOption {
+	return func(p *selectParams) {
+		p.shuffler = s
+	}
+}
+
+func (s *hrwShuffler) Order(hashes []uint64, weights []float64) []int {
+	var order []uint64
+	if weights == nil {
+		order = hrw.Sort(hashes, s.hash)
+	} else {
+		order = hrw.SortByWeight(hashes, weights, s.hash)
+	}
+
+	r := make([]int, len(order))
+	for i := range order {
+		r[i] = int(order[i])
+	}
+	return r
+}
+
+func (s *randShuffler) Order(hashes []uint64, _ []float64) []int {
+	return s.r.Perm(len(hashes))
+}
+
+func (s *jumpShuffler) Order(hashes []uint64, _ []float64) []int {
+	rest := make([]int, len(hashes))
+	for i := range rest {
+		rest[i] = i
+	}
+	sort.SliceStable(rest, func(i, j int) bool { return hashes[rest[i]] < hashes[rest[j]] })
+
+	// every next candidate is chosen among the remaining ones with a
+	// different key, chosen candidate is replaced by the last one
+	r := make([]int, len(hashes))
+	for i := range r {
+		n := len(rest) - 1
+		j := jumpHash(mix64(s.hash+uint64(i)), len(rest))
+		r[i] = rest[j]
+		rest[j] = rest[n]
+		rest = rest[:n]
+	}
+	return r
+}
+
+// jumpHash maps key to one of n buckets, see "A Fast, Minimal Memory,
+// Consistent Hash Algorithm" by J. Lamping and E. Veach.
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+func (s *hrwShuffler) uniform(hashes []uint64) []float64 {
+	us := make([]float64, len(hashes))
+	for i := range hashes {
+		us[i] = toUnit(mix64(hashes[i] ^ s.hash))
+	}
+	return us
+}
+
+func (s *randShuffler) uniform(hashes []uint64) []float64 {
+	us := make([]float64, len(hashes))
+	for i := range us {
+		us[i] = toUnit(s.r.Uint64())
+	}
+	return us
+}
+
+// mix64 is a finalizer of SplitMix64 generator, it spreads bits of x uniformly.
+func mix64(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// toUnit maps x to (0, 1) interval.
+func toUnit(x uint64) float64 {
+	return (float64(x>>11) + 0.5) / (1 << 53)
+}
+
+// shuffleProportional reorders bs, so that every bucket is the first one
+// with probability proportional to its weight, which is a total weight of
+// its nodes computed by p.bucketWeight. Weighted rendezvous hashing is used:
+// buckets are sorted by ln(u)/w, where u is uniform in (0, 1). Shufflers
+// not providing u order buckets by themselves.
+func (p selectParams) shuffleProportional(bs []Bucket) {
+	if p.shuffler == nil {
+		return
+	}
+
+	var (
+		hashes  = make([]uint64, len(bs))
+		weights = make([]float64, len(bs))
+	)
+	for i := range bs {
+		hashes[i] = bs[i].Hash()
+		for _, n := range bs[i].nodes {
+			weights[i] += p.bucketWeight(n)
+		}
+	}
+
+	var order []int
+	if src, ok := p.shuffler.(uniformSource); ok {
+		keys := src.uniform(hashes)
+		order = make([]int, len(bs))
+		for i := range keys {
+			if order[i] = i; weights[i] > 0 {
+				keys[i] = math.Log(keys[i]) / weights[i]
+			} else {
+				keys[i] = math.Inf(-1)
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool { return keys[order[i]] > keys[order[j]] })
+	} else {
+		order = p.order(hashes, weights, func(i, j int) bool { return bs[i].Name() < bs[j].Name() })
+	}
+
+	src := make([]Bucket, len(bs))
+	copy(src, bs)
+	for i, j := range order {
+		bs[i] = src[j]
+	}
+}
+
+// order returns permutation of candidates with specified hashes and weights
+// using p's shuffler, less is used by shufflers ordering candidates by names.
+func (p selectParams) order(hashes []uint64, weights []float64, less func(i, j int) bool) []int {
+	if s, ok := p.shuffler.(sortedSource); ok {
+		return s.orderSorted(len(hashes), less)
+	}
+	return p.shuffler.Order(hashes, weights)
+}
+
+// shuffleBuckets reorders bs using p's shuffler.
+func (p selectParams) shuffleBuckets(bs []Bucket, weighted bool) {
+	if p.shuffler == nil {
+		return
+	}
+
+	var (
+		hashes  = make([]uint64, len(bs))
+		weights []float64
+	)
+	for i := range bs {
+		hashes[i] = bs[i].Hash()
+	}
+	if weighted {
+		weights = make([]float64, len(bs))
+		for i := range bs {
+			weights[i] = bs[i].weight
+		}
+	}
+
+	src := make([]Bucket, len(bs))
+	copy(src, bs)
+	for i, j := range p.order(hashes, weights, func(i, j int) bool { return src[i].Name() < src[j].Name() }) {
+		bs[i] = src[j]
+	}
+}
+
+// shuffleNodes reorders ns using p's shuffler.
+func (p selectParams) shuffleNodes(ns Nodes) {
+	if p.shuffler == nil {
+		return
+	}
+
+	hashes := make([]uint64, len(ns))
+	for i := range ns {
+		hashes[i] = ns[i].Hash()
+	}
+
+	src := make(Nodes, len(ns))
+	copy(src, ns)
+	for i, j := range p.order(hashes, ns.Weights(), func(i, j int) bool { return src[i].N < src[j].N }) {
+		ns[i] = src[j]
+	}
+}
+
+// shuffleStrings reorders ss using p's shuffler.
+func (p selectParams) shuffleStrings(ss []string) {
+	if p.shuffler == nil {
+		return
+	}
+
+	hashes := make([]uint64, len(ss))
+	for i := range ss {
+		hashes[i] = hrw.Hash([]byte(ss[i]))
+	}
+
+	src := make([]string, len(ss))
+	copy(src, ss)
+	for i, j := range p.order(hashes, nil, func(i, j int) bool { return src[i] < src[j] }) {
+		ss[i] = src[j]
+	}
+}
@@ -0,0 +1,158 @@
+package netmap
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// reverseShuffler tries candidates in reverse order.
+type reverseShuffler struct{}
+
+func (reverseShuffler) Order(hashes []uint64, _ []float64) []int {
+	r := make([]int, len(hashes))
+	for i := range r {
+		r[i] = len(r) - 1 - i
+	}
+	return r
+}
+
+func TestShuffler(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 1}}
+
+	t.Run("default", func(t *testing.T) {
+		require.Equal(t,
+			root.GetSelection(ss, defaultPivot),
+			root.GetSelection(ss, nil, WithShuffler(NewHRWShuffler(defaultPivot))))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		r := root.GetSelection(ss, defaultPivot, WithShuffler(reverseShuffler{}))
+		require.NotNil(t, r)
+		require.Equal(t, []uint32{6}, r.Nodelist().Nodes())
+
+		g := root.FindGraphWith(nil, []SFGroup{{Selectors: ss}}, WithShuffler(reverseShuffler{}))
+		require.NotNil(t, g)
+		require.Equal(t, []uint32{6}, g.Nodelist().Nodes())
+	})
+
+	t.Run("random", func(t *testing.T) {
+		g := []SFGroup{{Selectors: ss}}
+		seen := make(map[uint32]bool)
+		for i := int64(0); i < 20; i++ {
+			ns1 := root.FindNodesWith(nil, g, WithShuffler(NewRandShuffler(rand.New(rand.NewSource(i)))))
+			ns2 := root.FindNodesWith(nil, g, WithShuffler(NewRandShuffler(rand.New(rand.NewSource(i)))))
+			require.Len(t, ns1, 1)
+			require.Equal(t, ns1, ns2)
+			seen[ns1[0].N] = true
+		}
+		require.True(t, len(seen) > 1)
+	})
+}
+
+func TestWithProportionalBuckets(t *testing.T) {
+	root, err := newStrawRoot(
+		strawBucket{"/DC:Small", Nodes{{N: 1, C: 10}}},
+		strawBucket{"/DC:Big", Nodes{{N: 2, C: 20}, {N: 3, C: 10}}},
+		strawBucket{"/DC:Empty", Nodes{{N: 4}}},
+	)
+	require.NoError(t, err)
+
+	const iterations = 3000
+	var (
+		big   int
+		g     = []SFGroup{{Selectors: []Select{{Key: "DC", Count: 1}, {Key: NodesBucket, Count: 1}}}}
+		pivot = make([]byte, 8)
+	)
+	for i := 0; i < iterations; i++ {
+		binary.BigEndian.PutUint64(pivot, uint64(i))
+		ns := root.FindNodesWith(pivot, g, WithProportionalBuckets(CapWeightFunc))
+		require.Len(t, ns, 1)
+		require.NotEqual(t, uint32(4), ns[0].N)
+		if ns[0].N != 1 {
+			big++
+		}
+	}
+	require.InDelta(t, 0.75, float64(big)/iterations, 0.03)
+
+	t.Run("random", func(t *testing.T) {
+		big = 0
+		for i := int64(0); i < iterations; i++ {
+			ns := root.FindNodesWith(nil, g,
+				WithShuffler(NewRandShuffler(rand.New(rand.NewSource(i)))),
+				WithProportionalBuckets(CapWeightFunc))
+			require.Len(t, ns, 1)
+			if ns[0].N != 1 {
+				big++
+			}
+		}
+		require.InDelta(t, 0.75, float64(big)/iterations, 0.03)
+	})
+
+	t.Run("empty bucket is the last resort", func(t *testing.T) {
+		g := []SFGroup{{Selectors: []Select{{Key: "DC", Count: 3}, {Key: NodesBucket, Count: 1}}}}
+		ns := root.FindNodesWith([]byte("pivot"), g, WithProportionalBuckets(CapWeightFunc))
+		require.Len(t, ns, 3)
+	})
+}
+
+func TestSeedFromBytes(t *testing.T) {
+	id := []byte("object identifier")
+
+	require.Equal(t, Seed(id), Seed([]byte("object identifier")))
+	require.NotEqual(t, Seed(id), Seed([]byte("other identifier")))
+	require.Equal(t, SeedFromBytes(id).Int63(), SeedFromBytes(id).Int63())
+
+	a, b := SeedFromIDs([]byte("cid"), []byte("oid")), SeedFromIDs([]byte("cid"), []byte("oid"))
+	require.Equal(t, a.Perm(10), b.Perm(10))
+	require.NotEqual(t,
+		SeedFromIDs([]byte("ci"), []byte("doid")).Int63(),
+		SeedFromIDs([]byte("cid"), []byte("oid")).Int63())
+}
+
+func TestJumpShuffler(t *testing.T) {
+	var (
+		root Bucket
+		ss   = []Select{{Key: NodesBucket, Count: 2}}
+	)
+	for i := uint32(1); i <= 10; i++ {
+		require.NoError(t, root.AddNode(i, "/Location:Europe"))
+	}
+
+	r := root.GetSelection(ss, defaultPivot, WithJumpHash())
+	require.NotNil(t, r)
+	require.Len(t, r.Nodelist(), 2)
+	require.Equal(t, r, root.GetSelection(ss, nil, WithShuffler(NewJumpShuffler(defaultPivot))))
+
+	// added node takes over only pivots it is chosen for
+	ss = []Select{{Key: NodesBucket, Count: 1}}
+	grown := root.Copy()
+	require.NoError(t, grown.AddNode(11, "/Location:Europe"))
+
+	var moved int
+	for i := 0; i < 1000; i++ {
+		pivot := make([]byte, 8)
+		binary.BigEndian.PutUint64(pivot, uint64(i))
+
+		n := root.GetSelection(ss, pivot, WithJumpHash()).Nodelist()[0].N
+		if n1 := grown.GetSelection(ss, pivot, WithJumpHash()).Nodelist()[0].N; n1 != n {
+			require.Equal(t, uint32(11), n1)
+			moved++
+		}
+	}
+	require.True(t, moved > 50 && moved < 150, moved)
+
+	for n := 1; n < 100; n++ {
+		j := jumpHash(uint64(n)*0x9e3779b97f4a7c15, n)
+		require.True(t, j >= 0 && j < n)
+	}
+}
@@ -0,0 +1,105 @@
+package netmap
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// SignedNetmap is a netmap with signature over its canonical encoding.
+type SignedNetmap struct {
+	Bucket    Bucket
+	Signature []byte
+}
+
+// signatureSize is the size of r||s ECDSA signature on 256-bit curve.
+const signatureSize = 64
+
+// Sign signs Digest of the netmap with priv. Only 256-bit curves are supported.
+func (s *SignedNetmap) Sign(priv *ecdsa.PrivateKey) error {
+	if priv.Params().BitSize != 256 {
+		return errors.New("unsupported curve")
+	}
+
+	d := s.Bucket.Digest()
+	r, ss, err := ecdsa.Sign(rand.Reader, priv, d[:])
+	if err != nil {
+		return errors.Wrap(err, "can't sign netmap")
+	}
+
+	sig := make([]byte, signatureSize)
+	putBigInt(sig[:signatureSize/2], r)
+	putBigInt(sig[signatureSize/2:], ss)
+	s.Signature = sig
+	return nil
+}
+
+// putBigInt writes x to buf as big-endian number padded with zeroes.
+func putBigInt(buf []byte, x *big.Int) {
+	bs := x.Bytes()
+	copy(buf[len(buf)-len(bs):], bs)
+}
+
+// Verify checks that netmap was signed by the owner of pub.
+func (s SignedNetmap) Verify(pub *ecdsa.PublicKey) error {
+	if len(s.Signature) != signatureSize {
+		return errors.New("invalid signature length")
+	}
+
+	var (
+		d  = s.Bucket.Digest()
+		r  = new(big.Int).SetBytes(s.Signature[:signatureSize/2])
+		ss = new(big.Int).SetBytes(s.Signature[signatureSize/2:])
+	)
+	if !ecdsa.Verify(pub, d[:], r, ss) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// Write writes signature and netmap to w.
+func (s SignedNetmap) Write(w io.Writer) error {
+	if err := binary.Write(w, binary.BigEndian, int32(len(s.Signature))); err != nil {
+		return err
+	}
+	if _, err := w.Write(s.Signature); err != nil {
+		return err
+	}
+	return s.Bucket.Write(w)
+}
+
+// Read reads signature and netmap from r.
+func (s *SignedNetmap) Read(r io.Reader) error {
+	var ln int32
+	if err := binary.Read(r, binary.BigEndian, &ln); err != nil {
+		return err
+	}
+	if ln < 0 || ln > signatureSize {
+		return errors.Wrap(ErrMalformedEncoding, "invalid signature length")
+	}
+
+	s.Signature = make([]byte, ln)
+	if _, err := io.ReadFull(r, s.Signature); err != nil {
+		return err
+	}
+	return s.Bucket.Read(r)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (s SignedNetmap) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := s.Write(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *SignedNetmap) UnmarshalBinary(data []byte) error {
+	return s.Read(bytes.NewReader(data))
+}
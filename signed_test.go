@@ -0,0 +1,51 @@
+package netmap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedNetmap(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Asia/Country:China", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	s := SignedNetmap{Bucket: root}
+	require.NoError(t, s.Sign(priv))
+	require.Len(t, s.Signature, signatureSize)
+	require.NoError(t, s.Verify(&priv.PublicKey))
+	require.Error(t, s.Verify(&other.PublicKey))
+
+	t.Run("marshal", func(t *testing.T) {
+		data, err := s.MarshalBinary()
+		require.NoError(t, err)
+
+		var s1 SignedNetmap
+		require.NoError(t, s1.UnmarshalBinary(data))
+		require.Equal(t, s.Signature, s1.Signature)
+		require.NoError(t, s1.Verify(&priv.PublicKey))
+	})
+
+	t.Run("modified netmap", func(t *testing.T) {
+		s1 := SignedNetmap{Bucket: root.Copy(), Signature: s.Signature}
+		require.NoError(t, s1.Bucket.AddBucket("/Location:Asia/Country:China", Nodes{{N: 4}}))
+		require.Error(t, s1.Verify(&priv.PublicKey))
+	})
+
+	t.Run("unsupported curve", func(t *testing.T) {
+		k, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		require.NoError(t, err)
+		require.Error(t, s.Sign(k))
+	})
+}
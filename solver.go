@@ -0,0 +1,232 @@
+package netmap
+
+// solver contains state of the exhaustive search of selection.
+type solver struct {
+	// limit is the maximum number of candidates tried, zero means no limit.
+	limit int
+	steps int
+}
+
+// WithBacktracking returns option which makes selection search for a
+// satisfying subgraph exhaustively if the default greedy selection fails,
+// e.g. because of DISTINCT clauses or quotas. Buckets and nodes are tried
+// in the same order as by greedy selection, but choices which make further
+// selection impossible are revoked. Search can take exponential time, so
+// it is stopped after trying limit candidates, zero means no limit.
+func WithBacktracking(limit int) SelectOption {
+	return func(p *selectParams) {
+		p.solver = &solver{limit: limit}
+	}
+}
+
+// step accounts another tried candidate and checks whether search can continue.
+func (p selectParams) step() bool {
+	if p.canceled() {
+		return false
+	}
+	p.solver.steps++
+	return p.solver.limit == 0 || p.solver.steps <= p.solver.limit
+}
+
+// selectWithFallback returns result of greedy selection ss from b and,
+// if it fails and backtracking is enabled, result of exhaustive search.
+func (b Bucket) selectWithFallback(ss []Select, newParams func() selectParams) *Bucket {
+	p := newParams()
+	if r := b.getSelection(ss, p); r != nil || p.solver == nil || p.canceled() {
+		return r
+	}
+
+	var r *Bucket
+
+	p = newParams()
+	getLogger().Log("greedy selection failed, searching exhaustively", "limit", p.solver.limit)
+	b.search(ss, p, func(c *Bucket) bool {
+		r = c
+		return true
+	})
+	return r
+}
+
+// search enumerates subgraphs of b satisfying ss until k accepts one of them.
+// Nodes of every subgraph are accounted in quotas while k is called and
+// returned to them if k rejects the subgraph. It returns true if some
+// subgraph was accepted.
+func (b Bucket) search(ss []Select, p selectParams, k func(*Bucket) bool) bool {
+	if p.canceled() {
+		return false
+	}
+	if len(ss) == 0 || (ss[0].Key == NodesBucket && ss[0].Same == "" && p.capacity != 0) {
+		// capacity selection of nodes takes as many nodes as needed,
+		// so there is nothing to choose from
+		r := b.getSelection(ss, p)
+		if r == nil {
+			return false
+		}
+		if k(r) {
+			return true
+		}
+		if p.capacity != 0 {
+			p.release(r.nodes)
+		}
+		return false
+	}
+
+	if ss[0].Same != "" {
+		return b.searchSame(ss, p, k)
+	}
+	if ss[0].Key == NodesBucket {
+		return b.searchNodes(ss[0], p, k)
+	}
+
+	var (
+		cs       = b.orderedChildren(ss[0], p)
+		count    = ss[0].CountOf(len(cs))
+		distinct = ss[0].Distinct
+		used     map[string]struct{}
+		chosen   = make([]*Bucket, 0, count)
+		choose   func(start int) bool
+	)
+	if p.capacity != 0 && count != 0 {
+		p.capacity = (p.capacity + uint64(count) - 1) / uint64(count)
+	}
+	if distinct != "" {
+		used = make(map[string]struct{})
+	}
+
+	choose = func(start int) bool {
+		if len(chosen) == count {
+			root := Bucket{Key: b.Key, Value: b.Value}
+			for _, r := range chosen {
+				root.merge(*b.combine(r))
+			}
+			return k(&root)
+		}
+		for i := start; len(cs)-i >= count-len(chosen); i++ {
+			if !p.step() {
+				return false
+			}
+			p.record(TraceConsidered, cs[i], nil, "")
+			ok := cs[i].search(ss[1:], p, func(r *Bucket) bool {
+				ns := r.Nodelist()
+				if used != nil && !p.useValues(distinct, ns, used) {
+					p.record(TraceRejected, cs[i], nil, "distinct "+distinct+" conflict")
+					return false
+				}
+				p.record(TraceChosen, cs[i], nil, "")
+				chosen = append(chosen, r)
+				if choose(i + 1) {
+					return true
+				}
+				chosen = chosen[:len(chosen)-1]
+				if used != nil {
+					for _, n := range ns {
+						delete(used, p.values[distinct][n.N])
+					}
+				}
+				p.record(TraceRejected, cs[i], nil, "revoked")
+				return false
+			})
+			if ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !choose(0) {
+		p.record(TraceRejected, b, nil, "not enough "+ss[0].Key+" buckets")
+		return false
+	}
+	return true
+}
+
+// searchSame enumerates subgraphs satisfying ss in which all nodes chosen by
+// ss[0] have the same value of ss[0].Same attribute.
+func (b Bucket) searchSame(ss []Select, p selectParams, k func(*Bucket) bool) bool {
+	var (
+		key    = ss[0].Same
+		values = p.values[key]
+		sel    = make([]Select, len(ss))
+	)
+
+	copy(sel, ss)
+	sel[0].Same = ""
+
+	for _, v := range p.sameValues(key, b.Nodelist()) {
+		sub := b.withValue(values, v)
+		if sub == nil {
+			continue
+		}
+		p.record(TraceConsidered, *sub, nil, "same "+key+" "+v)
+		if sub.search(sel, p, k) {
+			return true
+		}
+	}
+	p.record(TraceRejected, b, nil, "no common value of "+key)
+	return false
+}
+
+// searchNodes enumerates sets of nodes of b chosen by s having different
+// values of s.Distinct attribute, if it is set, without exceeding quotas.
+func (b Bucket) searchNodes(s Select, p selectParams, k func(*Bucket) bool) bool {
+	var (
+		count  = s.CountOf(len(b.nodes))
+		nodes  = b.orderedNodes(p)
+		values = p.values[s.Distinct]
+		used   = make(map[string]struct{})
+		taken  = p.newTaken()
+		chosen = make(Nodes, 0, count)
+		choose func(start int) bool
+	)
+
+	choose = func(start int) bool {
+		if len(chosen) == count {
+			ns := make(Nodes, count)
+			copy(ns, chosen)
+			p.consume(ns)
+			p.record(TraceChosen, b, ns, "")
+			if k(&Bucket{Key: b.Key, Value: b.Value, nodes: ns}) {
+				return true
+			}
+			p.release(ns)
+			p.record(TraceRejected, b, ns, "revoked")
+			return false
+		}
+		for i := start; len(nodes)-i >= count-len(chosen); i++ {
+			if !p.step() {
+				return false
+			}
+
+			v, ok := values[nodes[i].N]
+			if ok {
+				if _, ok := used[v]; ok {
+					continue
+				}
+			}
+			if !p.allowedByQuota(nodes[i], taken) {
+				continue
+			}
+
+			if ok {
+				used[v] = struct{}{}
+			}
+			p.takeQuota(nodes[i], taken, 1)
+			chosen = append(chosen, nodes[i])
+			if choose(i + 1) {
+				return true
+			}
+			chosen = chosen[:len(chosen)-1]
+			p.takeQuota(nodes[i], taken, -1)
+			if ok {
+				delete(used, v)
+			}
+		}
+		return false
+	}
+
+	if !choose(0) {
+		p.record(TraceRejected, b, nil, "not enough nodes")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBacktracking(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Rack:A/Country:X", []uint32{1}},
+		bucket{"/Rack:B/Country:X", []uint32{2}},
+		bucket{"/Rack:A/Country:Y", []uint32{3}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: NodesBucket, Count: 2, Distinct: "Rack"}}
+
+	// greedy selection takes node 1 and then can't choose the second one
+	require.Nil(t, root.GetSelection(ss, nil, WithQuota("Country", 1)))
+
+	r := root.GetSelection(ss, nil, WithQuota("Country", 1), WithBacktracking(0))
+	require.NotNil(t, r)
+	require.Equal(t, []uint32{2, 3}, r.Nodelist().Nodes())
+
+	t.Run("limit", func(t *testing.T) {
+		require.Nil(t, root.GetSelection(ss, nil, WithQuota("Country", 1), WithBacktracking(2)))
+	})
+
+	t.Run("unsatisfiable", func(t *testing.T) {
+		ss := []Select{{Key: NodesBucket, Count: 3, Distinct: "Rack"}}
+		require.Nil(t, root.GetSelection(ss, nil, WithBacktracking(0)))
+	})
+
+	t.Run("buckets", func(t *testing.T) {
+		ss := []Select{{Key: "Rack", Count: 2}, {Key: NodesBucket, Count: 1}}
+		// Rack A is tried first and node 1 exhausts quota of Country X
+		require.Nil(t, root.GetSelection(ss, nil, WithQuota("Country", 1)))
+
+		r := root.GetSelection(ss, nil, WithQuota("Country", 1), WithBacktracking(0))
+		require.NotNil(t, r)
+		require.Equal(t, []uint32{2, 3}, r.Nodelist().Nodes())
+	})
+
+	t.Run("find nodes", func(t *testing.T) {
+		g := SFGroup{Selectors: []Select{{Key: "Rack", Count: 2}, {Key: NodesBucket, Count: 1}}}
+		ns := root.FindNodesWith([]byte("pivot"), []SFGroup{g}, WithQuota("Country", 1), WithBacktracking(0))
+		require.Equal(t, []uint32{2, 3}, ns.Nodes())
+	})
+}
@@ -0,0 +1,440 @@
+package netmap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// Stack item types used in NEO VM binary serialization.
+const (
+	stackItemInteger    = 0x21
+	stackItemByteString = 0x28
+	stackItemArray      = 0x40
+	stackItemStruct     = 0x41
+)
+
+// Node and bucket are represented as NEO VM arrays:
+//
+//	Node:   [N, C, P, R, X, Y, PubKey, [Address...], [Subnet...], [[Key, Value]...]]
+//	Bucket: [Key, Value, [Node...], [Bucket...]]
+//
+// Node attributes are sorted by key and are omitted if there are none.
+// Numbers are integers, floating point values are stored as integers
+// containing their IEEE 754 bits, strings and keys are byte strings.
+const (
+	nodeStackItemLen   = 9
+	nodeAttrsItemLen   = nodeStackItemLen + 1
+	bucketStackItemLen = 4
+)
+
+// ToStackItem returns n as a tree of values accepted by neo-go stackitem.Make:
+// []interface{} for arrays, []byte for byte strings and *big.Int for integers.
+func (n Node) ToStackItem() []interface{} {
+	addrs := make([]interface{}, len(n.Addresses))
+	for i := range n.Addresses {
+		addrs[i] = []byte(n.Addresses[i])
+	}
+	subnets := make([]interface{}, len(n.Subnets))
+	for i := range n.Subnets {
+		subnets[i] = new(big.Int).SetUint64(uint64(n.Subnets[i]))
+	}
+
+	item := []interface{}{
+		new(big.Int).SetUint64(uint64(n.N)),
+		new(big.Int).SetUint64(n.C),
+		new(big.Int).SetUint64(n.P),
+		new(big.Int).SetUint64(math.Float64bits(n.R)),
+		new(big.Int).SetUint64(math.Float64bits(n.Coord.X)),
+		new(big.Int).SetUint64(math.Float64bits(n.Coord.Y)),
+		append([]byte{}, n.PubKey...),
+		addrs,
+		subnets,
+	}
+	if len(n.Attrs) == 0 {
+		return item
+	}
+
+	keys := n.attrKeys()
+	attrs := make([]interface{}, len(keys))
+	for i, k := range keys {
+		attrs[i] = []interface{}{[]byte(k), []byte(n.Attrs[k])}
+	}
+	return append(item, attrs)
+}
+
+// FromStackItem restores n from the stack item produced by ToStackItem.
+// Integers can be represented by *big.Int or int64, byte strings by
+// []byte or string.
+func (n *Node) FromStackItem(item []interface{}) error {
+	if len(item) != nodeStackItemLen && len(item) != nodeAttrsItemLen {
+		return errors.Wrapf(ErrMalformedEncoding, "node must contain %d or %d items, got %d",
+			nodeStackItemLen, nodeAttrsItemLen, len(item))
+	}
+
+	var (
+		nums [6]uint64
+		err  error
+	)
+	for i := range nums {
+		if nums[i], err = stackItemUint64(item[i]); err != nil {
+			return errors.Wrapf(err, "invalid node field #%d", i)
+		}
+	}
+	if nums[0] > math.MaxUint32 {
+		return errors.Wrap(ErrMalformedEncoding, "node index overflow")
+	}
+
+	pub, err := stackItemBytes(item[6])
+	if err != nil {
+		return errors.Wrap(err, "invalid public key")
+	}
+	addrs, ok := item[7].([]interface{})
+	if !ok {
+		return errors.Wrap(ErrMalformedEncoding, "addresses must be an array")
+	}
+	subnets, ok := item[8].([]interface{})
+	if !ok {
+		return errors.Wrap(ErrMalformedEncoding, "subnets must be an array")
+	}
+
+	res := Node{
+		N:     uint32(nums[0]),
+		C:     nums[1],
+		P:     nums[2],
+		R:     math.Float64frombits(nums[3]),
+		Coord: Coord{X: math.Float64frombits(nums[4]), Y: math.Float64frombits(nums[5])},
+	}
+	if len(pub) != 0 {
+		res.PubKey = pub
+	}
+	for i := range addrs {
+		a, err := stackItemBytes(addrs[i])
+		if err != nil {
+			return errors.Wrap(err, "invalid address")
+		}
+		res.Addresses = append(res.Addresses, string(a))
+	}
+	for i := range subnets {
+		s, err := stackItemUint64(subnets[i])
+		if err != nil {
+			return errors.Wrap(err, "invalid subnet")
+		} else if s > math.MaxUint32 {
+			return errors.Wrap(ErrMalformedEncoding, "subnet overflow")
+		}
+		res.Subnets = append(res.Subnets, uint32(s))
+	}
+	if len(item) == nodeAttrsItemLen {
+		if res.Attrs, err = attrsFromStackItem(item[nodeStackItemLen]); err != nil {
+			return err
+		}
+	}
+
+	*n = res
+	return nil
+}
+
+func attrsFromStackItem(item interface{}) (map[string]string, error) {
+	arr, ok := item.([]interface{})
+	if !ok || len(arr) == 0 {
+		return nil, errors.Wrap(ErrMalformedEncoding, "attributes must be a non-empty array")
+	}
+
+	attrs := make(map[string]string, len(arr))
+	for i := range arr {
+		kv, ok := arr[i].([]interface{})
+		if !ok || len(kv) != 2 {
+			return nil, errors.Wrap(ErrMalformedEncoding, "attribute must be a key-value pair")
+		}
+		k, err := stackItemBytes(kv[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid attribute key")
+		}
+		v, err := stackItemBytes(kv[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid attribute value")
+		}
+		attrs[string(k)] = string(v)
+	}
+	return attrs, nil
+}
+
+// ToStackItem returns b as a tree of values accepted by neo-go stackitem.Make,
+// see Node.ToStackItem.
+func (b Bucket) ToStackItem() []interface{} {
+	nodes := make([]interface{}, len(b.nodes))
+	for i := range b.nodes {
+		nodes[i] = b.nodes[i].ToStackItem()
+	}
+	children := make([]interface{}, len(b.children))
+	for i := range b.children {
+		children[i] = b.children[i].ToStackItem()
+	}
+	return []interface{}{[]byte(b.Key), []byte(b.Value), nodes, children}
+}
+
+// FromStackItem restores b from the stack item produced by ToStackItem.
+func (b *Bucket) FromStackItem(item []interface{}) error {
+	return b.fromStackItem(item, 0)
+}
+
+func (b *Bucket) fromStackItem(item []interface{}, depth int) error {
+	if depth >= MaxBucketDepth {
+		return errors.Wrapf(ErrMalformedEncoding, "bucket tree is deeper than %d", MaxBucketDepth)
+	} else if len(item) != bucketStackItemLen {
+		return errors.Wrapf(ErrMalformedEncoding, "bucket must contain %d items, got %d", bucketStackItemLen, len(item))
+	}
+
+	key, err := stackItemBytes(item[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid key")
+	}
+	value, err := stackItemBytes(item[1])
+	if err != nil {
+		return errors.Wrap(err, "invalid value")
+	}
+	nodes, ok := item[2].([]interface{})
+	if !ok {
+		return errors.Wrap(ErrMalformedEncoding, "nodes must be an array")
+	}
+	children, ok := item[3].([]interface{})
+	if !ok {
+		return errors.Wrap(ErrMalformedEncoding, "children must be an array")
+	}
+
+	res := Bucket{Key: string(key), Value: string(value)}
+	if len(nodes) != 0 {
+		res.nodes = make(Nodes, len(nodes))
+		for i := range nodes {
+			n, ok := nodes[i].([]interface{})
+			if !ok {
+				return errors.Wrap(ErrMalformedEncoding, "node must be an array")
+			} else if err := res.nodes[i].FromStackItem(n); err != nil {
+				return err
+			}
+		}
+	}
+	if len(children) != 0 {
+		res.children = make([]Bucket, len(children))
+		for i := range children {
+			c, ok := children[i].([]interface{})
+			if !ok {
+				return errors.Wrap(ErrMalformedEncoding, "bucket must be an array")
+			} else if err := res.children[i].fromStackItem(c, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	res.schema = b.schema
+	*b = res
+	return nil
+}
+
+// MarshalStackItem returns b serialized in NEO VM binary stack item format,
+// i.e. in the same way as StdLib.serialize does in the netmap contract.
+func (b Bucket) MarshalStackItem() []byte {
+	buf := new(bytes.Buffer)
+	writeStackItem(buf, b.ToStackItem())
+	return buf.Bytes()
+}
+
+// UnmarshalStackItem restores b from data serialized in NEO VM binary
+// stack item format, see MarshalStackItem.
+func (b *Bucket) UnmarshalStackItem(data []byte) error {
+	r := bytes.NewReader(data)
+	item, err := readStackItem(r, 0)
+	if err != nil {
+		return err
+	} else if r.Len() != 0 {
+		return errors.Wrap(ErrMalformedEncoding, "trailing data")
+	}
+
+	arr, ok := item.([]interface{})
+	if !ok {
+		return errors.Wrap(ErrMalformedEncoding, "bucket must be an array")
+	}
+	return b.FromStackItem(arr)
+}
+
+func writeStackItem(buf *bytes.Buffer, item interface{}) {
+	switch v := item.(type) {
+	case []interface{}:
+		buf.WriteByte(stackItemArray)
+		putVarUint(buf, uint64(len(v)))
+		for i := range v {
+			writeStackItem(buf, v[i])
+		}
+	case []byte:
+		buf.WriteByte(stackItemByteString)
+		putVarUint(buf, uint64(len(v)))
+		buf.Write(v)
+	case *big.Int:
+		data := intToBytes(v)
+		buf.WriteByte(stackItemInteger)
+		putVarUint(buf, uint64(len(data)))
+		buf.Write(data)
+	default:
+		panic(errors.Errorf("unexpected stack item %T", item))
+	}
+}
+
+// readStackItem reads stack item of the types used by ToStackItem.
+// Nesting depth is limited: every bucket level adds two arrays
+// and nodes of the deepest bucket add four more.
+func readStackItem(r *bytes.Reader, depth int) (interface{}, error) {
+	if depth > 2*MaxBucketDepth+4 {
+		return nil, errors.Wrap(ErrMalformedEncoding, "stack item is too deep")
+	}
+
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case stackItemArray, stackItemStruct:
+		ln, err := readVarUint(r)
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, ln)
+		for i := range arr {
+			if arr[i], err = readStackItem(r, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case stackItemByteString, stackItemInteger:
+		ln, err := readVarUint(r)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, ln)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		if typ == stackItemByteString {
+			return data, nil
+		}
+		return bytesToInt(data), nil
+	default:
+		return nil, errors.Wrapf(ErrMalformedEncoding, "unsupported stack item type 0x%02x", typ)
+	}
+}
+
+// putVarUint writes x in NEO variable-length encoding.
+func putVarUint(buf *bytes.Buffer, x uint64) {
+	var b [9]byte
+	switch {
+	case x < 0xFD:
+		buf.WriteByte(byte(x))
+	case x <= math.MaxUint16:
+		b[0] = 0xFD
+		binary.LittleEndian.PutUint16(b[1:], uint16(x))
+		buf.Write(b[:3])
+	case x <= math.MaxUint32:
+		b[0] = 0xFE
+		binary.LittleEndian.PutUint32(b[1:], uint32(x))
+		buf.Write(b[:5])
+	default:
+		b[0] = 0xFF
+		binary.LittleEndian.PutUint64(b[1:], x)
+		buf.Write(b[:9])
+	}
+}
+
+// readVarUint reads number in NEO variable-length encoding,
+// which can't exceed the remaining data size.
+func readVarUint(r *bytes.Reader) (int, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		x uint64
+		b [8]byte
+	)
+	switch prefix {
+	case 0xFD:
+		_, err = io.ReadFull(r, b[:2])
+		x = uint64(binary.LittleEndian.Uint16(b[:]))
+	case 0xFE:
+		_, err = io.ReadFull(r, b[:4])
+		x = uint64(binary.LittleEndian.Uint32(b[:]))
+	case 0xFF:
+		_, err = io.ReadFull(r, b[:8])
+		x = binary.LittleEndian.Uint64(b[:])
+	default:
+		x = uint64(prefix)
+	}
+	if err != nil {
+		return 0, err
+	} else if x > uint64(r.Len()) {
+		return 0, errors.Wrap(ErrMalformedEncoding, "invalid length")
+	}
+	return int(x), nil
+}
+
+// intToBytes returns non-negative x as little-endian two's complement
+// number of minimal length, zero is represented by empty slice.
+func intToBytes(x *big.Int) []byte {
+	data := x.Bytes()
+	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+		data[i], data[j] = data[j], data[i]
+	}
+	if len(data) != 0 && data[len(data)-1]&0x80 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+// bytesToInt parses little-endian two's complement number.
+func bytesToInt(data []byte) *big.Int {
+	if len(data) == 0 {
+		return new(big.Int)
+	}
+
+	be := make([]byte, len(data))
+	for i := range data {
+		be[len(data)-1-i] = data[i]
+	}
+	x := new(big.Int).SetBytes(be)
+	if data[len(data)-1]&0x80 != 0 {
+		x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(8*len(data))))
+	}
+	return x
+}
+
+func stackItemUint64(item interface{}) (uint64, error) {
+	switch v := item.(type) {
+	case *big.Int:
+		if v.Sign() < 0 || !v.IsUint64() {
+			return 0, errors.Wrap(ErrMalformedEncoding, "integer is out of range")
+		}
+		return v.Uint64(), nil
+	case int64:
+		if v < 0 {
+			return 0, errors.Wrap(ErrMalformedEncoding, "integer is out of range")
+		}
+		return uint64(v), nil
+	default:
+		return 0, errors.Wrapf(ErrMalformedEncoding, "expected integer, got %T", item)
+	}
+}
+
+func stackItemBytes(item interface{}) ([]byte, error) {
+	switch v := item.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, errors.Wrapf(ErrMalformedEncoding, "expected byte string, got %T", item)
+	}
+}
@@ -0,0 +1,77 @@
+package netmap
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_StackItem(t *testing.T) {
+	before, err := newStrawRoot(
+		strawBucket{"/Location:Europe/Country:Germany", Nodes{{
+			N:         1,
+			C:         200,
+			P:         3,
+			R:         0.75,
+			Coord:     Coord{X: 1.5, Y: -2},
+			PubKey:    []byte{2, 0xAB, 0xCD},
+			Addresses: []string{"/ip4/10.0.0.1/tcp/8080"},
+		}}},
+		strawBucket{"/Location:Asia", Nodes{{N: 2, Subnets: []uint32{1, 7}}}},
+	)
+	require.NoError(t, err)
+
+	var after Bucket
+	require.NoError(t, after.FromStackItem(before.ToStackItem()))
+	require.Equal(t, before, after)
+
+	after = Bucket{}
+	require.NoError(t, after.UnmarshalStackItem(before.MarshalStackItem()))
+	require.Equal(t, before, after)
+
+	t.Run("invalid", func(t *testing.T) {
+		item := before.ToStackItem()
+		item[0] = big.NewInt(1)
+		require.Error(t, after.FromStackItem(item))
+		require.Error(t, after.FromStackItem(item[:3]))
+
+		data := before.MarshalStackItem()
+		require.Error(t, after.UnmarshalStackItem(data[:len(data)-1]))
+		require.Error(t, after.UnmarshalStackItem(append(data, 0)))
+	})
+}
+
+func TestNode_StackItem(t *testing.T) {
+	var n Node
+	require.NoError(t, n.FromStackItem([]interface{}{
+		int64(1), big.NewInt(2), int64(3), int64(0), int64(0), int64(0),
+		"key", []interface{}{"/ip4/1.2.3.4"}, []interface{}{},
+	}))
+	require.True(t, n.Equals(Node{N: 1, C: 2, P: 3, PubKey: []byte("key"), Addresses: []string{"/ip4/1.2.3.4"}}))
+
+	item := n.ToStackItem()
+	item[0] = big.NewInt(-1)
+	require.Error(t, n.FromStackItem(item))
+	item[0] = new(big.Int).Lsh(big.NewInt(1), 32)
+	require.Error(t, n.FromStackItem(item))
+}
+
+func TestStackItemInteger(t *testing.T) {
+	for _, tc := range []struct {
+		x    int64
+		data []byte
+	}{
+		{0, []byte{}},
+		{1, []byte{1}},
+		{127, []byte{0x7F}},
+		{128, []byte{0x80, 0}},
+		{255, []byte{0xFF, 0}},
+		{256, []byte{0, 1}},
+	} {
+		require.Equal(t, tc.data, intToBytes(big.NewInt(tc.x)))
+		require.Equal(t, tc.x, bytesToInt(tc.data).Int64())
+	}
+	require.Equal(t, int64(-1), bytesToInt([]byte{0xFF}).Int64())
+	require.Equal(t, int64(-128), bytesToInt([]byte{0x80}).Int64())
+}
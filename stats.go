@@ -0,0 +1,146 @@
+package netmap
+
+// TreeStats describes shape of the bucket tree.
+type TreeStats struct {
+	// Depth is the number of levels below the root bucket.
+	Depth int
+	// Buckets is the total number of buckets including the root.
+	Buckets int
+	// Nodes is the number of distinct nodes in the tree.
+	Nodes int
+	// Duplicates is the number of nodes attached to several buckets.
+	Duplicates int
+	// Branching maps number of children to the number of buckets having
+	// that many children, leaf buckets are not accounted.
+	Branching map[int]int
+	// BucketsPerLevel and NodesPerLevel contain number of buckets and
+	// total number of nodes in them for every level, starting with root.
+	BucketsPerLevel []int
+	NodesPerLevel   []int
+}
+
+// Stats walks b once and returns statistics of its shape.
+func (b Bucket) Stats() TreeStats {
+	var (
+		s = TreeStats{Branching: make(map[int]int)}
+		// attached contains number of buckets every node is attached to
+		attached = make(map[uint32]int)
+	)
+
+	b.collectStats(0, &s, attached)
+	s.Depth = len(s.BucketsPerLevel) - 1
+	s.Nodes = len(attached)
+	for _, c := range attached {
+		if c > 1 {
+			s.Duplicates++
+		}
+	}
+	return s
+}
+
+func (b Bucket) collectStats(level int, s *TreeStats, attached map[uint32]int) {
+	if level == len(s.BucketsPerLevel) {
+		s.BucketsPerLevel = append(s.BucketsPerLevel, 0)
+		s.NodesPerLevel = append(s.NodesPerLevel, 0)
+	}
+	s.Buckets++
+	s.BucketsPerLevel[level]++
+	s.NodesPerLevel[level] += len(b.Nodelist())
+
+	for _, n := range b.ownNodes() {
+		attached[n.N]++
+	}
+	if len(b.children) == 0 {
+		return
+	}
+
+	s.Branching[len(b.children)]++
+	for i := range b.children {
+		b.children[i].collectStats(level+1, s, attached)
+	}
+}
+
+// ValueCounts returns number of nodes for every value of attribute key,
+// e.g. number of nodes in every Country. Nodes present in several buckets
+// with the same value are counted once.
+func (b Bucket) ValueCounts(key string) map[string]int {
+	var (
+		bs     = b.findKey(key)
+		values = make(map[string]Nodes, len(bs))
+	)
+	for _, c := range bs {
+		values[c.Value] = merge(values[c.Value], c.Nodelist())
+	}
+
+	result := make(map[string]int, len(values))
+	for v, ns := range values {
+		result[v] = len(ns)
+	}
+	return result
+}
+
+// CapacityInfo describes capacity of nodes in a bucket.
+type CapacityInfo struct {
+	// Nodes is the number of nodes in the bucket, Reported is the number
+	// of them having usage data.
+	Nodes    int
+	Reported int
+	// Total is the sum of node capacities, Used is the sum of used capacity
+	// of reported nodes.
+	Total uint64
+	Used  uint64
+}
+
+// Free returns capacity which isn't used yet.
+func (c CapacityInfo) Free() uint64 {
+	if c.Used >= c.Total {
+		return 0
+	}
+	return c.Total - c.Used
+}
+
+// Usage returns share of used capacity in range of 0.0 to 1.0.
+func (c CapacityInfo) Usage() float64 {
+	if c.Used == 0 {
+		return 0
+	} else if c.Used >= c.Total {
+		return 1
+	}
+	return float64(c.Used) / float64(c.Total)
+}
+
+// CapacitySummary returns capacity of every bucket of b indexed by its
+// path, root bucket has path Separator. Total capacity is taken from the
+// nodes of b, while C of ns is the used capacity of the node with the same
+// index, e.g. as reported by the node itself. Nodes present in several
+// child buckets are counted once in their parent.
+func (b Bucket) CapacitySummary(ns Nodes) map[string]CapacityInfo {
+	used := make(map[uint32]uint64, len(ns))
+	for _, n := range ns {
+		used[n.N] += n.C
+	}
+
+	m := make(map[string]CapacityInfo)
+	b.collectCapacity(Separator, used, m)
+	return m
+}
+
+func (b Bucket) collectCapacity(path string, used map[uint32]uint64, m map[string]CapacityInfo) {
+	var c CapacityInfo
+	for _, n := range b.Nodelist() {
+		c.Nodes++
+		c.Total += n.C
+		if u, ok := used[n.N]; ok {
+			c.Reported++
+			c.Used += u
+		}
+	}
+	m[path] = c
+
+	if path == Separator {
+		path = ""
+	}
+	for i := range b.children {
+		b.children[i].collectCapacity(path+Separator+b.children[i].Name(), used, m)
+	}
+}
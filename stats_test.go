@@ -0,0 +1,75 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Stats(t *testing.T) {
+	var root Bucket
+	require.NoError(t, root.AddNode(1, "/Location:Europe/Country:Germany"))
+	require.NoError(t, root.AddNode(2, "/Location:Europe/Country:Germany"))
+	require.NoError(t, root.AddNode(3, "/Location:Europe/Country:France"))
+	require.NoError(t, root.AddNode(4, "/Location:Asia/Country:Korea"))
+	require.NoError(t, root.AddNode(3, "/Location:Asia/Country:Japan"))
+
+	s := root.Stats()
+	require.Equal(t, TreeStats{
+		Depth:           2,
+		Buckets:         7,
+		Nodes:           4,
+		Duplicates:      1,
+		Branching:       map[int]int{2: 3},
+		BucketsPerLevel: []int{1, 2, 4},
+		NodesPerLevel:   []int{4, 5, 5},
+	}, s)
+
+	require.Equal(t, TreeStats{Buckets: 1, Branching: map[int]int{}, BucketsPerLevel: []int{1}, NodesPerLevel: []int{0}}, Bucket{}.Stats())
+
+	// nodes attached to inner buckets are counted as well
+	var b Bucket
+	require.NoError(t, b.AddBucket("/Location:Europe", Nodes{{N: 5}}))
+	require.NoError(t, b.AddBucket("/Location:Europe/Country:DE", Nodes{{N: 6}}))
+	s = b.Stats()
+	require.Equal(t, len(b.Nodelist()), s.Nodes)
+	require.Equal(t, 2, s.Nodes)
+	require.Zero(t, s.Duplicates)
+}
+
+func TestBucket_ValueCounts(t *testing.T) {
+	var root Bucket
+	require.NoError(t, root.AddNode(1, "/Location:Europe/Country:Germany", "/StorageType:SSD"))
+	require.NoError(t, root.AddNode(2, "/Location:Europe/Country:Germany", "/StorageType:HDD"))
+	require.NoError(t, root.AddNode(3, "/Location:Europe/Country:France", "/StorageType:SSD"))
+	require.NoError(t, root.AddNode(4, "/Location:Asia/Country:Korea", "/StorageType:SSD"))
+	require.NoError(t, root.AddNode(5, "/Location:America/Country:Germany"))
+
+	require.Equal(t, map[string]int{"Germany": 3, "France": 1, "Korea": 1}, root.ValueCounts("Country"))
+	require.Equal(t, map[string]int{"SSD": 3, "HDD": 1}, root.ValueCounts("StorageType"))
+	require.Equal(t, map[string]int{"Europe": 3, "Asia": 1, "America": 1}, root.ValueCounts("Location"))
+	require.Empty(t, root.ValueCounts("City"))
+}
+
+func TestBucket_CapacitySummary(t *testing.T) {
+	var root Bucket
+	require.NoError(t, root.AddStrawNode(Node{N: 1, C: 100}, "/DC:A/Rack:1"))
+	require.NoError(t, root.AddStrawNode(Node{N: 2, C: 200}, "/DC:A/Rack:2"))
+	require.NoError(t, root.AddStrawNode(Node{N: 3, C: 300}, "/DC:B/Rack:1"))
+
+	m := root.CapacitySummary(Nodes{{N: 1, C: 50}, {N: 3, C: 300}, {N: 4, C: 10}})
+	require.Equal(t, map[string]CapacityInfo{
+		"/":            {Nodes: 3, Reported: 2, Total: 600, Used: 350},
+		"/DC:A":        {Nodes: 2, Reported: 1, Total: 300, Used: 50},
+		"/DC:A/Rack:1": {Nodes: 1, Reported: 1, Total: 100, Used: 50},
+		"/DC:A/Rack:2": {Nodes: 1, Total: 200},
+		"/DC:B":        {Nodes: 1, Reported: 1, Total: 300, Used: 300},
+		"/DC:B/Rack:1": {Nodes: 1, Reported: 1, Total: 300, Used: 300},
+	}, m)
+
+	require.Equal(t, uint64(250), m["/DC:A"].Free())
+	require.InEpsilon(t, 1.0/6, m["/DC:A"].Usage(), eps)
+	require.Equal(t, uint64(0), m["/DC:B"].Free())
+	require.Equal(t, 1.0, m["/DC:B"].Usage())
+	require.Equal(t, 0.0, CapacityInfo{}.Usage())
+}
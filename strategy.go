@@ -0,0 +1,150 @@
+package netmap
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+type (
+	// Strategy defines how candidates are chosen during selection for
+	// the specific pivot. Tree-walking logic only asks Shuffler returned
+	// by the Strategy for the order of candidates, so new strategies can be
+	// added without changes in selection itself.
+	Strategy interface {
+		// Shuffler returns Shuffler ordering candidates for pivot.
+		Shuffler(pivot []byte) Shuffler
+	}
+
+	// StrategyFunc is an adapter allowing to use ordinary function as Strategy.
+	StrategyFunc func(pivot []byte) Shuffler
+)
+
+var (
+	// StrategyWeighted orders candidates using rendezvous hashing with
+	// pivot taking their weights into account. It is used by default.
+	StrategyWeighted Strategy = StrategyFunc(NewHRWShuffler)
+
+	// StrategyHRW orders candidates using rendezvous hashing
+	// with pivot ignoring their weights.
+	StrategyHRW Strategy = StrategyFunc(func(pivot []byte) Shuffler {
+		return unweighted{NewHRWShuffler(pivot)}
+	})
+
+	// StrategyUniform orders candidates randomly with pseudo-random
+	// generator seeded by pivot, weights are ignored.
+	StrategyUniform Strategy = StrategyFunc(func(pivot []byte) Shuffler {
+		return NewRandShuffler(SeedFromBytes(pivot))
+	})
+
+	// StrategyJumpHash orders candidates using jump consistent hash of pivot.
+	StrategyJumpHash Strategy = StrategyFunc(NewJumpShuffler)
+)
+
+type (
+	// unweighted is a Shuffler which hides weights of candidates from s.
+	unweighted struct {
+		s Shuffler
+	}
+
+	roundRobin struct {
+		next uint64
+	}
+
+	rotateShuffler struct {
+		offset uint64
+	}
+)
+
+// placementStrategy is implemented by stateful strategies which must be
+// advanced once per placement. placement returns Strategy used for all
+// groups and selection attempts of a single placement.
+type placementStrategy interface {
+	placement() Strategy
+}
+
+var (
+	_ placementStrategy = (*roundRobin)(nil)
+	_ sortedSource      = rotateShuffler{}
+)
+
+// NewRoundRobinStrategy returns Strategy which tries candidates in the
+// ascending order of their names, i.e. buckets by Name and nodes by index,
+// starting from the offset-th one. Pivots are ignored. Offset is incremented
+// once per placement, i.e. per call of FindNodesWith, FindGraphWith,
+// GetSelection, CompiledPolicy.Place and other selection methods, no matter
+// how many groups the policy has and whether the selection is retried.
+// Every direct call of Shuffler increments it too. It gives fully
+// predictable placement and is intended for tests and demonstrations.
+// Resulting Strategy is safe for concurrent use, but then order of
+// placements is not defined.
+func NewRoundRobinStrategy(offset uint64) Strategy {
+	return &roundRobin{next: offset}
+}
+
+// Shuffler implements Strategy.
+func (f StrategyFunc) Shuffler(pivot []byte) Shuffler {
+	return f(pivot)
+}
+
+// WithStrategy returns option which makes selection order
+// candidates by the Shuffler s returns for the pivot.
+func WithStrategy(s Strategy) SelectOption {
+	return func(p *selectParams) {
+		p.strategy = s
+	}
+}
+
+// forPlacement returns opts with stateful strategy replaced by the one
+// advanced for the single placement, see placementStrategy. It must be
+// called once by every selection method before options are applied.
+func forPlacement(opts []SelectOption) []SelectOption {
+	var p selectParams
+	for _, o := range opts {
+		o(&p)
+	}
+	if s, ok := p.strategy.(placementStrategy); ok {
+		return append(opts[:len(opts):len(opts)], WithStrategy(s.placement()))
+	}
+	return opts
+}
+
+// pivotShuffler returns Shuffler used for pivot if it isn't set explicitly.
+func (p selectParams) pivotShuffler(pivot []byte) Shuffler {
+	if p.strategy == nil {
+		return StrategyWeighted.Shuffler(pivot)
+	}
+	return p.strategy.Shuffler(pivot)
+}
+
+func (r *roundRobin) Shuffler([]byte) Shuffler {
+	return rotateShuffler{offset: atomic.AddUint64(&r.next, 1) - 1}
+}
+
+func (r *roundRobin) placement() Strategy {
+	s := r.Shuffler(nil)
+	return StrategyFunc(func([]byte) Shuffler { return s })
+}
+
+// Order implements Shuffler ordering candidates by hashes,
+// selection orders them by names instead.
+func (s rotateShuffler) Order(hashes []uint64, _ []float64) []int {
+	return s.orderSorted(len(hashes), func(i, j int) bool { return hashes[i] < hashes[j] })
+}
+
+func (s rotateShuffler) orderSorted(n int, less func(i, j int) bool) []int {
+	r := make([]int, n)
+	if len(r) == 0 {
+		return r
+	}
+	for i := range r {
+		r[i] = i
+	}
+	sort.SliceStable(r, func(i, j int) bool { return less(r[i], r[j]) })
+
+	k := int(s.offset % uint64(len(r)))
+	return append(r[k:], r[:k]...)
+}
+
+func (u unweighted) Order(hashes []uint64, _ []float64) []int {
+	return u.s.Order(hashes, nil)
+}
@@ -0,0 +1,101 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategy(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 2}},
+		bucket{"/Location:Europe/Country:Spain", []uint32{3, 4}},
+		bucket{"/Location:Asia/Country:China", []uint32{5, 6}},
+	)
+	require.NoError(t, err)
+
+	ss := []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}
+
+	require.Equal(t,
+		root.GetSelection(ss, defaultPivot),
+		root.GetSelection(ss, defaultPivot, WithStrategy(StrategyWeighted)))
+	require.Equal(t,
+		root.GetSelection(ss, defaultPivot, WithJumpHash()),
+		root.GetSelection(ss, defaultPivot, WithStrategy(StrategyJumpHash)))
+
+	for _, s := range []Strategy{StrategyWeighted, StrategyHRW, StrategyUniform, StrategyJumpHash} {
+		r := root.GetSelection(ss, defaultPivot, WithStrategy(s))
+		require.NotNil(t, r)
+		require.Len(t, r.Nodelist(), 2)
+		require.Equal(t, r, root.GetSelection(ss, defaultPivot, WithStrategy(s)))
+	}
+
+	var pivot []byte
+	reverse := StrategyFunc(func(p []byte) Shuffler {
+		pivot = p
+		return reverseShuffler{}
+	})
+	r := root.GetSelection(ss, defaultPivot, WithStrategy(reverse))
+	require.Equal(t, defaultPivot, pivot)
+	require.Equal(t, []uint32{4, 6}, r.Nodelist().Nodes())
+
+	// explicit shuffler takes precedence
+	pivot = nil
+	require.Equal(t,
+		root.GetSelection(ss, nil, WithShuffler(NewHRWShuffler(defaultPivot))),
+		root.GetSelection(ss, defaultPivot, WithStrategy(reverse), WithShuffler(NewHRWShuffler(defaultPivot))))
+	require.Nil(t, pivot)
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	var root Bucket
+	for i := uint32(1); i <= 5; i++ {
+		require.NoError(t, root.AddNode(i, "/Location:Europe"))
+	}
+
+	var (
+		ss = []Select{{Key: NodesBucket, Count: 2}}
+		s  = NewRoundRobinStrategy(3)
+	)
+	for _, expected := range [][]uint32{{4, 5}, {5, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}} {
+		r := root.GetSelection(ss, defaultPivot, WithStrategy(s))
+		require.Equal(t, expected, r.Nodelist().Nodes())
+	}
+
+	require.Empty(t, rotateShuffler{offset: 1}.Order(nil, nil))
+
+	t.Run("once per placement", func(t *testing.T) {
+		root, err := newRoot(
+			bucket{"/Country:Germany", []uint32{1, 2}},
+			bucket{"/Country:Spain", []uint32{3, 4}},
+			bucket{"/Country:China", []uint32{5, 6}},
+		)
+		require.NoError(t, err)
+
+		ss := []SFGroup{
+			{Selectors: []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 1}}},
+			{Selectors: []Select{{Key: "Country", Count: 2}, {Key: NodesBucket, Count: 1}}},
+			// can't be satisfied because of quota, so selection is retried exhaustively
+			{
+				Selectors: []Select{{Key: NodesBucket, Count: 2}},
+				Filters:   []Filter{{Key: "Country", F: FilterEQ("China")}},
+			},
+		}
+
+		// countries are ordered by name: China, Germany, Spain
+		expected := [][]uint32{{1, 5}, {2, 4}, {3, 5}, {2, 6}, {1, 3}, {4, 6}}
+
+		s := NewRoundRobinStrategy(0)
+		for i := range expected {
+			ns := root.FindNodesWith(defaultPivot, ss, WithStrategy(s), WithQuota("Country", 1), WithBacktracking(10))
+			require.Equal(t, expected[i], ns.Nodes(), "placement %d", i)
+		}
+
+		s = NewRoundRobinStrategy(0)
+		c, err := root.CompilePolicy(ss, WithStrategy(s), WithQuota("Country", 1), WithBacktracking(10))
+		require.NoError(t, err)
+		for i := range expected {
+			require.Equal(t, expected[i], c.Place(defaultPivot).Nodes(), "placement %d", i)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package netmap
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Tier contains nodes chosen by a single selection group: primary nodes
+// store data and backup nodes are designated standbys for them.
+type Tier struct {
+	Primary Nodes
+	Backup  Nodes
+}
+
+// FindNodesTiered returns primary and backup nodes for every group of ss.
+// Primary nodes are the same as chosen by FindNodesWith. Backup nodes are
+// chosen by the same group from the rest of the netmap: they never coincide
+// with primary nodes of any group and are located in buckets of the first
+// Select clause (failure domains, e.g. Country) other than the ones primary
+// nodes of the group are located in. Backup is empty if there are not enough
+// such nodes. If primary nodes can't be chosen, ErrNotEnoughNodes is returned.
+func (b *Bucket) FindNodesTiered(pivot []byte, ss []SFGroup, opts ...SelectOption) ([]Tier, error) {
+	if err := b.schema.CheckGroups(ss...); err != nil {
+		return nil, err
+	}
+
+	var (
+		ts      = make([]Tier, len(ss))
+		primary Nodes
+	)
+	opts = forPlacement(opts)
+	for i := range ss {
+		if ts[i].Primary = b.findNodes(pivot, ss[i], opts...); ts[i].Primary == nil {
+			return nil, errors.Wrapf(ErrNotEnoughNodes, "selection group %d can't be satisfied", i)
+		}
+		primary = merge(primary, ts[i].Primary)
+	}
+
+	for i := range ss {
+		s := ss[i]
+		s.Exclude = b.backupExcludes(s, ts[i].Primary, primary)
+		ts[i].Backup = b.findNodes(pivot, s, opts...)
+	}
+	return ts, nil
+}
+
+// backupExcludes returns nodes which can't be backups for nodes ps chosen
+// by s: excluded by s, primary nodes of all groups and nodes located in the
+// same failure domains as ps.
+func (b *Bucket) backupExcludes(s SFGroup, ps, primary Nodes) []uint32 {
+	excl := make([]uint32, 0, len(s.Exclude)+len(primary))
+	excl = append(excl, s.Exclude...)
+	excl = append(excl, primary.Nodes()...)
+	if len(s.Selectors) == 0 || s.Selectors[0].Key == NodesBucket {
+		return excl
+	}
+
+	var (
+		values  = b.nodeValues(s.Selectors[0].Key)
+		domains = make(map[string]struct{}, len(ps))
+	)
+	for _, n := range ps {
+		if v, ok := values[n.N]; ok {
+			domains[v] = struct{}{}
+		}
+	}
+	for n, v := range values {
+		if _, ok := domains[v]; ok {
+			excl = append(excl, n)
+		}
+	}
+	return excl
+}
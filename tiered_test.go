@@ -0,0 +1,57 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_FindNodesTiered(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Country:Germany/City:Berlin", []uint32{1, 2}},
+		bucket{"/Country:Germany/City:Hamburg", []uint32{3}},
+		bucket{"/Country:Spain/City:Madrid", []uint32{4, 5}},
+		bucket{"/Country:France/City:Paris", []uint32{6, 7}},
+	)
+	require.NoError(t, err)
+
+	countries := root.nodeValues("Country")
+	ss := []SFGroup{
+		{Selectors: []Select{{Key: "Country", Count: 1}, {Key: NodesBucket, Count: 2}}},
+		{Selectors: []Select{{Key: NodesBucket, Count: 1}}},
+	}
+
+	ts, err := root.FindNodesTiered([]byte("pivot"), ss)
+	require.NoError(t, err)
+	require.Len(t, ts, 2)
+	require.Equal(t, root.FindNodesWith([]byte("pivot"), ss[:1]), ts[0].Primary)
+
+	require.Len(t, ts[0].Backup, 2)
+	pc := countries[ts[0].Primary[0].N]
+	for _, n := range ts[0].Backup {
+		require.NotEqual(t, pc, countries[n.N])
+	}
+
+	require.Len(t, ts[1].Backup, 1)
+	all := merge(ts[0].Primary, ts[1].Primary)
+	for _, tr := range ts {
+		for _, n := range tr.Backup {
+			require.False(t, containsSorted(all, n.N))
+		}
+	}
+
+	t.Run("no backup", func(t *testing.T) {
+		ss := []SFGroup{{Selectors: []Select{{Key: "Country", Count: 3}, {Key: NodesBucket, Count: 1}}}}
+		ts, err := root.FindNodesTiered([]byte("pivot"), ss)
+		require.NoError(t, err)
+		require.Len(t, ts[0].Primary, 3)
+		require.Empty(t, ts[0].Backup)
+	})
+
+	t.Run("not enough nodes", func(t *testing.T) {
+		ss := []SFGroup{{Selectors: []Select{{Key: "Country", Count: 4}}}}
+		_, err := root.FindNodesTiered([]byte("pivot"), ss)
+		require.True(t, errors.Is(err, ErrNotEnoughNodes))
+	})
+}
@@ -1,15 +1,32 @@
 package netmap
 
-func getNodes(b Bucket, path []Bucket) (nodes Nodes) {
-	if len(path) == 0 {
-		return b.Nodelist()
+import (
+	"sort"
+	"sync"
+)
+
+// nodesPool contains buffers for temporary node lists used during selection.
+var nodesPool sync.Pool
+
+// getNodesBuffer returns empty buffer from the pool.
+func getNodesBuffer() *Nodes {
+	buf, ok := nodesPool.Get().(*Nodes)
+	getMetrics().ObserveCacheLookup(ok)
+	if !ok {
+		return new(Nodes)
 	}
-	for _, p := range b.Children() {
-		if p.Equals(path[0]) {
-			return getNodes(p, path[1:])
-		}
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// putNodesBuffer returns buf to the pool. Nodes are cleared,
+// so that the buffer doesn't retain their attributes.
+func putNodesBuffer(buf *Nodes) {
+	ns := *buf
+	for i := range ns {
+		ns[i] = Node{}
 	}
-	return nil
+	nodesPool.Put(buf)
 }
 
 func contains(nodes Nodes, n Node) bool {
@@ -48,14 +65,10 @@ func intersect(a, b Nodes) Nodes {
 	return c
 }
 
-func diff(a Nodes, exclude map[uint32]bool) (c Nodes) {
-	c = make(Nodes, 0, len(a))
-	for _, e := range a {
-		if excl, ok := exclude[e.N]; ok && !excl {
-			c = append(c, e)
-		}
-	}
-	return
+// containsSorted checks if sorted nodes contain node with index n.
+func containsSorted(nodes Nodes, n uint32) bool {
+	i := sort.Search(len(nodes), func(i int) bool { return nodes[i].N >= n })
+	return i < len(nodes) && nodes[i].N == n
 }
 
 func union(a, b Nodes) Nodes {
@@ -102,3 +115,17 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// subtract returns nodes from a which are absent in b, both must be sorted.
+func subtract(a, b Nodes) Nodes {
+	c := make(Nodes, 0, len(a))
+	for i, j := 0, 0; i < len(a); i++ {
+		for j < len(b) && b[j].N < a[i].N {
+			j++
+		}
+		if j == len(b) || b[j].N != a[i].N {
+			c = append(c, a[i])
+		}
+	}
+	return c
+}
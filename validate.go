@@ -0,0 +1,220 @@
+package netmap
+
+import (
+	"fmt"
+	"sort"
+)
+
+type (
+	// Problem describes inconsistency of the bucket found by Validate.
+	Problem struct {
+		Kind ProblemKind
+		// Path is a path of the bucket from the root, e.g. /Location:Europe.
+		Path string
+		// Nodes are indices of the nodes the problem is about.
+		Nodes []uint32
+	}
+
+	// ProblemKind is a kind of bucket inconsistency.
+	ProblemKind int
+)
+
+const (
+	// ProblemUnsortedNodes means that nodes of the bucket are not sorted by index.
+	ProblemUnsortedNodes ProblemKind = iota
+	// ProblemDuplicateNodes means that the same node is listed several times.
+	ProblemDuplicateNodes
+	// ProblemMissingNodes means that nodes of sub-buckets are absent in the bucket.
+	ProblemMissingNodes
+	// ProblemConflictingNodes means that nodes with the same index
+	// have different attributes.
+	ProblemConflictingNodes
+	// ProblemSharedNodes means that nodes belong to several sub-buckets.
+	ProblemSharedNodes
+)
+
+// String implements fmt.Stringer interface.
+func (k ProblemKind) String() string {
+	switch k {
+	case ProblemUnsortedNodes:
+		return "unsorted nodes"
+	case ProblemDuplicateNodes:
+		return "duplicate nodes"
+	case ProblemMissingNodes:
+		return "missing nodes of sub-buckets"
+	case ProblemConflictingNodes:
+		return "conflicting nodes"
+	case ProblemSharedNodes:
+		return "nodes shared by sub-buckets"
+	default:
+		return "unknown"
+	}
+}
+
+// Fixable checks whether the problem can be fixed by Repair.
+func (p Problem) Fixable() bool {
+	switch p.Kind {
+	case ProblemUnsortedNodes, ProblemDuplicateNodes, ProblemMissingNodes:
+		return true
+	default:
+		return false
+	}
+}
+
+// String implements fmt.Stringer interface.
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s %v", p.Path, p.Kind, p.Nodes)
+}
+
+// Validate returns all inconsistencies of b in depth-first order.
+// Unlike IsValid, it reports what exactly is wrong and where.
+// Empty result means that b is well-formed.
+func (b Bucket) Validate() []Problem {
+	var ps []Problem
+	b.validate(Separator, &ps)
+	return ps
+}
+
+func (b Bucket) validate(path string, ps *[]Problem) {
+	*ps = append(*ps, b.problems(path)...)
+	for _, c := range b.children {
+		c.validate(childPath(path, c), ps)
+	}
+}
+
+// Repair fixes problems of b which can be fixed: sorts nodes, removes
+// duplicates and adds nodes of sub-buckets missing in their parents.
+// It returns problems left unfixed.
+func (b *Bucket) Repair() []Problem {
+	var ps []Problem
+	b.repair(Separator, &ps)
+	return ps
+}
+
+func (b *Bucket) repair(path string, ps *[]Problem) {
+	b.ownChildren()
+
+	var (
+		sub      []Problem
+		children = make([]Nodes, 0, len(b.children))
+	)
+	for i := range b.children {
+		b.children[i].repair(childPath(path, b.children[i]), &sub)
+		children = append(children, b.children[i].nodes)
+	}
+
+	nodes := append(Nodes(nil), b.nodes...)
+	sort.Stable(nodes)
+	nodes = dedupEqual(nodes)
+
+	var missing Nodes
+	for _, n := range dedupNodes(mergeAll(children)) {
+		if !containsSorted(nodes, n.N) {
+			missing = append(missing, n)
+		}
+	}
+	b.nodes = merge(nodes, missing)
+
+	for _, p := range b.problems(path) {
+		if !p.Fixable() {
+			*ps = append(*ps, p)
+		}
+	}
+	*ps = append(*ps, sub...)
+}
+
+// problems returns problems of b itself, not of its children.
+func (b Bucket) problems(path string) []Problem {
+	var ps []Problem
+
+	add := func(kind ProblemKind, ns []uint32) {
+		if len(ns) != 0 {
+			ps = append(ps, Problem{Kind: kind, Path: path, Nodes: ns})
+		}
+	}
+
+	if !sort.IsSorted(b.nodes) {
+		ps = append(ps, Problem{Kind: ProblemUnsortedNodes, Path: path})
+	}
+
+	var (
+		sorted        = append(Nodes(nil), b.nodes...)
+		dup, conflict []uint32
+	)
+	sort.Stable(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].N != sorted[i-1].N {
+			continue
+		} else if sorted[i].Equals(sorted[i-1]) {
+			dup = append(dup, sorted[i].N)
+		} else {
+			conflict = append(conflict, sorted[i].N)
+		}
+	}
+	add(ProblemDuplicateNodes, dedupIndices(dup))
+	add(ProblemConflictingNodes, dedupIndices(conflict))
+
+	var (
+		owners  = make(map[uint32]int)
+		missing []uint32
+		shared  []uint32
+	)
+	for _, c := range b.children {
+		seen := make(map[uint32]struct{}, len(c.nodes))
+		for _, n := range c.nodes {
+			if _, ok := seen[n.N]; ok {
+				continue
+			}
+			seen[n.N] = struct{}{}
+			if owners[n.N]++; owners[n.N] == 1 && !containsSorted(sorted, n.N) {
+				missing = append(missing, n.N)
+			} else if owners[n.N] == 2 {
+				shared = append(shared, n.N)
+			}
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	sort.Slice(shared, func(i, j int) bool { return shared[i] < shared[j] })
+	add(ProblemMissingNodes, missing)
+	add(ProblemSharedNodes, shared)
+	return ps
+}
+
+// childPath returns path of child c of the bucket located at path.
+func childPath(path string, c Bucket) string {
+	if path == Separator {
+		return Separator + c.Name()
+	}
+	return path + Separator + c.Name()
+}
+
+// dedupEqual removes adjacent nodes equal to the previous one from sorted ns.
+// Nodes with the same index but different attributes are kept.
+func dedupEqual(ns Nodes) Nodes {
+	if len(ns) < 2 {
+		return ns
+	}
+
+	r := ns[:1]
+	for i := 1; i < len(ns); i++ {
+		if !ns[i].Equals(r[len(r)-1]) {
+			r = append(r, ns[i])
+		}
+	}
+	return r
+}
+
+// dedupIndices removes adjacent duplicates from sorted indices.
+func dedupIndices(ns []uint32) []uint32 {
+	if len(ns) < 2 {
+		return ns
+	}
+
+	r := ns[:1]
+	for i := 1; i < len(ns); i++ {
+		if ns[i] != r[len(r)-1] {
+			r = append(r, ns[i])
+		}
+	}
+	return r
+}
@@ -0,0 +1,67 @@
+package netmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_Validate(t *testing.T) {
+	root, err := newRoot(
+		bucket{"/Location:Europe/Country:Germany", []uint32{1, 3}},
+		bucket{"/Location:Asia/Country:China", []uint32{2}},
+	)
+	require.NoError(t, err)
+	require.Empty(t, root.Validate())
+	require.Empty(t, root.Repair())
+
+	b := Bucket{
+		nodes: Nodes{{N: 3}, {N: 1}, {N: 1}},
+		children: []Bucket{
+			{
+				Key:   "Location",
+				Value: "Europe",
+				nodes: Nodes{{N: 1}, {N: 2}},
+				children: []Bucket{
+					{Key: "Country", Value: "Germany", nodes: Nodes{{N: 2}, {N: 4}}},
+				},
+			},
+			{
+				Key:   "Location",
+				Value: "Asia",
+				nodes: Nodes{{N: 3}, {N: 3, C: 10}, {N: 2}},
+			},
+		},
+	}
+	require.False(t, b.IsValid())
+
+	orig := b.Copy()
+	expected := []Problem{
+		{Kind: ProblemUnsortedNodes, Path: "/"},
+		{Kind: ProblemDuplicateNodes, Path: "/", Nodes: []uint32{1}},
+		{Kind: ProblemMissingNodes, Path: "/", Nodes: []uint32{2}},
+		{Kind: ProblemSharedNodes, Path: "/", Nodes: []uint32{2}},
+		{Kind: ProblemMissingNodes, Path: "/Location:Europe", Nodes: []uint32{4}},
+		{Kind: ProblemUnsortedNodes, Path: "/Location:Asia"},
+		{Kind: ProblemConflictingNodes, Path: "/Location:Asia", Nodes: []uint32{3}},
+	}
+	require.Equal(t, expected, b.Validate())
+
+	s := b.Snapshot()
+	unfixed := b.Repair()
+	require.Equal(t, []Problem{
+		{Kind: ProblemSharedNodes, Path: "/", Nodes: []uint32{2}},
+		{Kind: ProblemConflictingNodes, Path: "/Location:Asia", Nodes: []uint32{3}},
+	}, unfixed)
+	require.Equal(t, unfixed, b.Validate())
+	for _, p := range unfixed {
+		require.False(t, p.Fixable())
+	}
+
+	require.Equal(t, []uint32{1, 2, 3, 4}, b.Nodelist().Nodes())
+	require.Equal(t, []uint32{1, 2, 4}, b.children[0].Nodelist().Nodes())
+
+	// snapshot taken before repair is not changed
+	require.Equal(t, orig, s)
+	require.Equal(t, expected, s.Validate())
+}
@@ -0,0 +1,157 @@
+package netmap
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// Watcher accepts netmaps of new epochs and notifies subscribers
+	// about changes between them.
+	Watcher struct {
+		mu      sync.Mutex
+		started bool
+		epoch   uint64
+		nodes   map[uint32]nodeLocation
+		subs    map[*subscriber]struct{}
+	}
+
+	// Notification describes changes of the netmap in the new epoch.
+	// Nodes are sorted by index.
+	Notification struct {
+		Epoch   uint64
+		Netmap  Bucket
+		Added   Nodes
+		Removed Nodes
+		Changed []NodeChange
+	}
+
+	// NodeChange describes node which changed its attributes or location.
+	// Paths are paths of the leaf buckets containing the node.
+	NodeChange struct {
+		Old, New           Node
+		OldPaths, NewPaths []string
+	}
+
+	// nodeLocation is a node along with paths of leaves containing it.
+	nodeLocation struct {
+		node  Node
+		paths []string
+	}
+
+	subscriber struct {
+		ch   chan Notification
+		done chan struct{}
+		once sync.Once
+	}
+)
+
+// NewWatcher returns watcher without netmap.
+func NewWatcher() *Watcher {
+	return &Watcher{subs: make(map[*subscriber]struct{})}
+}
+
+// Subscribe returns channel delivering notifications with buffer of the
+// specified size and function canceling the subscription and closing the
+// channel. Update blocks until all subscribers receive the notification,
+// so they must read it promptly or cancel the subscription.
+func (w *Watcher) Subscribe(buffer int) (<-chan Notification, func()) {
+	s := &subscriber{
+		ch:   make(chan Notification, buffer),
+		done: make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	w.subs[s] = struct{}{}
+	w.mu.Unlock()
+
+	return s.ch, func() {
+		s.once.Do(func() {
+			close(s.done)
+
+			w.mu.Lock()
+			delete(w.subs, s)
+			close(s.ch)
+			w.mu.Unlock()
+		})
+	}
+}
+
+// Update sets b as a netmap of the epoch, which must be greater than
+// the previous one, and notifies subscribers about changes. All nodes
+// of the first netmap are reported as added.
+func (w *Watcher) Update(epoch uint64, b Bucket) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.started && epoch <= w.epoch {
+		return errors.Errorf("epoch %d is not newer than %d", epoch, w.epoch)
+	}
+
+	nodes := b.nodeLocations()
+	getMetrics().ObserveEpochNodes(epoch, len(nodes))
+
+	n := Notification{Epoch: epoch, Netmap: b.Snapshot()}
+	for i, np := range nodes {
+		old, ok := w.nodes[i]
+		if !ok {
+			n.Added = append(n.Added, np.node)
+		} else if !old.node.Equals(np.node) || !equalStrings(old.paths, np.paths) {
+			n.Changed = append(n.Changed, NodeChange{
+				Old:      old.node,
+				New:      np.node,
+				OldPaths: old.paths,
+				NewPaths: np.paths,
+			})
+		}
+	}
+	for i, old := range w.nodes {
+		if _, ok := nodes[i]; !ok {
+			n.Removed = append(n.Removed, old.node)
+		}
+	}
+	sort.Sort(n.Added)
+	sort.Sort(n.Removed)
+	sort.Slice(n.Changed, func(i, j int) bool { return n.Changed[i].New.N < n.Changed[j].New.N })
+
+	w.started, w.epoch, w.nodes = true, epoch, nodes
+	for s := range w.subs {
+		select {
+		case s.ch <- n:
+		case <-s.done:
+		}
+	}
+	return nil
+}
+
+// nodeLocations returns all nodes of b along with sorted paths of buckets
+// they are attached to.
+func (b Bucket) nodeLocations() map[uint32]nodeLocation {
+	m := make(map[uint32]nodeLocation)
+	for p, ns := range b.pathNodes() {
+		for _, n := range ns {
+			np := m[n.N]
+			np.node = n
+			np.paths = append(np.paths, p)
+			m[n.N] = np
+		}
+	}
+	for i := range m {
+		sort.Strings(m[i].paths)
+	}
+	return m
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package netmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	var (
+		w          = NewWatcher()
+		ch, stop   = w.Subscribe(1)
+		ch1, stop1 = w.Subscribe(0)
+	)
+
+	b1, err := newStrawRoot(
+		strawBucket{"/Location:Europe", Nodes{{N: 1, C: 10}, {N: 2, C: 10}}},
+		strawBucket{"/Location:Asia", Nodes{{N: 3, C: 10}}},
+	)
+	require.NoError(t, err)
+
+	// unbuffered subscriber doesn't block updates after cancellation
+	stop1()
+	_, ok := <-ch1
+	require.False(t, ok)
+
+	require.NoError(t, w.Update(1, b1))
+	n := <-ch
+	require.Equal(t, uint64(1), n.Epoch)
+	require.Equal(t, []uint32{1, 2, 3}, n.Added.Nodes())
+	require.Empty(t, n.Removed)
+	require.Empty(t, n.Changed)
+
+	b2, err := newStrawRoot(
+		strawBucket{"/Location:Europe", Nodes{{N: 1, C: 10}}},
+		strawBucket{"/Location:Asia", Nodes{{N: 2, C: 10}, {N: 3, C: 20}, {N: 4, C: 10}}},
+	)
+	require.NoError(t, err)
+
+	require.Error(t, w.Update(1, b2))
+	require.NoError(t, w.Update(2, b2))
+	n = <-ch
+	require.Equal(t, uint64(2), n.Epoch)
+	require.Equal(t, b2, n.Netmap)
+	require.Equal(t, []uint32{4}, n.Added.Nodes())
+	require.Empty(t, n.Removed)
+	require.Len(t, n.Changed, 2)
+	require.Equal(t, []string{"/Location:Europe"}, n.Changed[0].OldPaths)
+	require.Equal(t, []string{"/Location:Asia"}, n.Changed[0].NewPaths)
+	require.Equal(t, uint64(10), n.Changed[1].Old.C)
+	require.Equal(t, uint64(20), n.Changed[1].New.C)
+
+	require.NoError(t, w.Update(3, Bucket{}))
+	stop()
+	n = <-ch
+	require.Equal(t, []uint32{1, 2, 3, 4}, n.Removed.Nodes())
+
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel must be closed")
+	}
+}
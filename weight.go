@@ -1,5 +1,10 @@
 package netmap
 
+import (
+	"sort"
+	"sync"
+)
+
 type (
 	// AggregatorFactory is a Factory for a specific Aggregator
 	AggregatorFactory struct {
@@ -22,12 +27,34 @@ func NewWeightFunc(capNorm, priceNorm Normalizer) WeightFunc {
 	}
 }
 
-func getDefaultWeightFunc(ns Nodes) WeightFunc {
+// ReputationWeightFunc calculates weight which is equal to reputation.
+func ReputationWeightFunc(n Node) float64 { return n.R }
+
+// NewReputationWeightFunc returns WeightFunc which multiplies normalized
+// capacity and price by reputation, so that nodes with poor
+// reputation are penalized.
+func NewReputationWeightFunc(capNorm, priceNorm Normalizer) WeightFunc {
+	wf := NewWeightFunc(capNorm, priceNorm)
+	return func(n Node) float64 {
+		r := n.R
+		if r < 0 {
+			r = 0
+		} else if r > 1 {
+			r = 1
+		}
+		return wf(n) * r
+	}
+}
+
+// DefaultWeightFunc returns WeightFunc used for selection from ns.
+// Capacity is normalized by sigmoid with mean capacity as a scale,
+// price is normalized with respect to minimal price.
+func DefaultWeightFunc(ns Nodes) WeightFunc {
 	mean := new(meanAgg)
-	min := new(minAgg)
+	min := new(minPriceAgg)
 	for i := range ns {
-		mean.Add(float64(ns[i].C))
-		min.Add(float64(ns[i].P))
+		mean.Add(CapWeightFunc(ns[i]))
+		min.Add(PriceWeightFunc(ns[i]))
 	}
 	return NewWeightFunc(&sigmoidNorm{mean.Compute()}, &reverseMinNorm{min.Compute()})
 }
@@ -40,6 +67,68 @@ func (b *Bucket) Traverse(a Aggregator, wf WeightFunc) Aggregator {
 	return a
 }
 
+// AggregatePerChild computes aggregate of node weights for every subbucket
+// with the specified key. Result is indexed by bucket value.
+// Buckets with the same value are aggregated together.
+func (b *Bucket) AggregatePerChild(key string, a func() Aggregator, wf WeightFunc) map[string]float64 {
+	aggs := make(map[string]Aggregator)
+	for _, c := range b.findKey(key) {
+		agg, ok := aggs[c.Value]
+		if !ok {
+			agg = a()
+			aggs[c.Value] = agg
+		}
+		c.Traverse(agg, wf)
+	}
+
+	r := make(map[string]float64, len(aggs))
+	for v, agg := range aggs {
+		r[v] = agg.Compute()
+	}
+	return r
+}
+
+// TraverseParallel computes aggregate of all Bucket nodes splitting them
+// between workers goroutines. Aggregators created by af must implement
+// MergeableAggregator, otherwise nodes are traversed sequentially.
+func (b *Bucket) TraverseParallel(af AggregatorFactory, wf WeightFunc, workers int) Aggregator {
+	a := af.New()
+	if _, ok := a.(MergeableAggregator); !ok || workers <= 1 || len(b.nodes) < workers {
+		return b.Traverse(a, wf)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		size  = (len(b.nodes) + workers - 1) / workers
+		parts = make([]Aggregator, 0, workers)
+	)
+
+	for i := 0; i < len(b.nodes); i += size {
+		end := i + size
+		if end > len(b.nodes) {
+			end = len(b.nodes)
+		}
+
+		p := af.New()
+		parts = append(parts, p)
+
+		wg.Add(1)
+		go func(ns Nodes) {
+			defer wg.Done()
+			for i := range ns {
+				p.Add(wf(ns[i]))
+			}
+		}(b.nodes[i:end])
+	}
+	wg.Wait()
+
+	m := a.(MergeableAggregator)
+	for _, p := range parts {
+		m.Merge(p)
+	}
+	return m
+}
+
 // TraverseTree computes weight for every Bucket and all of its children.
 func (b *Bucket) TraverseTree(af AggregatorFactory, wf WeightFunc) {
 	a := af.New()
@@ -48,7 +137,33 @@ func (b *Bucket) TraverseTree(af AggregatorFactory, wf WeightFunc) {
 	}
 	b.weight = a.Compute()
 
+	b.ownChildren()
 	for i := range b.children {
 		b.children[i].TraverseTree(af, wf)
 	}
 }
+
+// TopNodes returns indices of k nodes from b with the highest weight.
+// Nodes with equal weight are ordered by their index.
+func (b *Bucket) TopNodes(k int, wf WeightFunc) []uint32 {
+	if k <= 0 {
+		return nil
+	}
+
+	var (
+		nodes   = b.Nodelist()
+		weights = make(map[uint32]float64, len(nodes))
+		ns      = make(Nodes, len(nodes))
+	)
+
+	copy(ns, nodes)
+	for i := range ns {
+		weights[ns[i].N] = wf(ns[i])
+	}
+	sort.SliceStable(ns, func(i, j int) bool { return weights[ns[i].N] > weights[ns[j].N] })
+
+	if k > len(ns) {
+		k = len(ns)
+	}
+	return ns[:k].Nodes()
+}